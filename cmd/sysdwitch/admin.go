@@ -0,0 +1,471 @@
+// cmd/sysdwitch/admin.go
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/store"
+)
+
+// adminUsage lists the admin commands
+const adminUsage = `usage: sysdwitch admin user add|del|passwd|role|list [flags] [name]
+       sysdwitch admin token create|list|revoke [flags] [id]`
+
+// runAdmin manages users and API tokens in the persistent store. It works
+// on the store directly, so a running panel sees changes on its next
+// request without a restart.
+func runAdmin(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return 2
+	}
+	var err error
+	switch args[0] + " " + args[1] {
+	case "user add":
+		err = adminUserAdd(args[2:])
+	case "user del":
+		err = adminUserDel(args[2:])
+	case "user passwd":
+		err = adminUserPasswd(args[2:])
+	case "user role":
+		err = adminUserRole(args[2:])
+	case "user list":
+		err = adminUserList(args[2:])
+	case "token create":
+		err = adminTokenCreate(args[2:])
+	case "token list":
+		err = adminTokenList(args[2:])
+	case "token revoke":
+		err = adminTokenRevoke(args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "admin: unknown command %q\n%s\n", args[0]+" "+args[1], adminUsage)
+		return 2
+	}
+	var usage usageError
+	if errors.As(err, &usage) || errors.Is(err, flag.ErrHelp) {
+		if usage != "" {
+			fmt.Fprintf(os.Stderr, "usage: sysdwitch admin %s %s\n", args[0]+" "+args[1], usage)
+		}
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin %s %s: %v\n", args[0], args[1], err)
+		return 1
+	}
+	return 0
+}
+
+// usageError carries the argument synopsis of a misused admin command
+type usageError string
+
+func (u usageError) Error() string { return string(u) }
+
+// adminFlags are the flags locating the store, shared by every admin command
+type adminFlags struct {
+	fset       *flag.FlagSet
+	configPath *string
+	storePath  *string
+}
+
+func newAdminFlags(name string) *adminFlags {
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
+	return &adminFlags{
+		fset:       fset,
+		configPath: fset.String("config", os.Getenv("CONFIG_FILE"), "config file naming the store"),
+		storePath:  fset.String("store", "", "JSON store file, instead of the configured store"),
+	}
+}
+
+// parse parses flags before and after positional arguments, returning
+// the positional arguments, of which there must be want
+func (af *adminFlags) parse(args []string, want int, synopsis string) ([]string, error) {
+	var positional []string
+	for {
+		if err := af.fset.Parse(args); err != nil {
+			return nil, flag.ErrHelp
+		}
+		if af.fset.NArg() == 0 {
+			break
+		}
+		positional = append(positional, af.fset.Arg(0))
+		args = af.fset.Args()[1:]
+	}
+	if len(positional) != want {
+		return nil, usageError(synopsis)
+	}
+	return positional, nil
+}
+
+// open opens the store named by -store, or the one the configuration uses
+func (af *adminFlags) open(ctx context.Context) (store.Store, error) {
+	if *af.storePath != "" {
+		return store.OpenFile(*af.storePath)
+	}
+	cfg, err := config.Load(*af.configPath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.StoreDriver == "postgres":
+		return store.Open(ctx, cfg.StoreDriver, cfg.StoreDSN)
+	case cfg.StorePath != "":
+		return store.Open(ctx, cfg.StoreDriver, cfg.StorePath)
+	default:
+		return nil, errors.New("no store is configured: set STORE_PATH or STORE_DRIVER, or pass -store")
+	}
+}
+
+func adminUserAdd(args []string) error {
+	af := newAdminFlags("user add")
+	password := af.fset.Bool("password-stdin", false, "read the password from stdin without prompting")
+	admin := af.fset.Bool("admin", false, "give the user the admin role")
+	positional, err := af.parse(args, 1, "[-config FILE] [-store FILE] [-password-stdin] [-admin] NAME")
+	if err != nil {
+		return err
+	}
+	name := positional[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	if _, err := users.GetUser(ctx, name); err == nil {
+		return fmt.Errorf("user %s already exists (use passwd to change the password)", name)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	hash, err := readPasswordHash(*password)
+	if err != nil {
+		return err
+	}
+	user := store.User{Name: name, PasswordHash: hash, CreatedAt: time.Now().UTC()}
+	if *admin {
+		user.Role = store.RoleAdmin
+	}
+	if err := users.PutUser(ctx, user); err != nil {
+		return err
+	}
+	fmt.Printf("Added user %s\n", name)
+	return nil
+}
+
+// adminUserRole gives a user the admin role, or takes it away with "user"
+func adminUserRole(args []string) error {
+	af := newAdminFlags("user role")
+	synopsis := "[-config FILE] [-store FILE] NAME admin|user"
+	positional, err := af.parse(args, 2, synopsis)
+	if err != nil {
+		return err
+	}
+	name, role := positional[0], positional[1]
+	switch role {
+	case store.RoleAdmin:
+	case "user":
+		role = ""
+	default:
+		return usageError(synopsis)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	user, err := users.GetUser(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no user %s", name)
+	} else if err != nil {
+		return err
+	}
+	user.Role = role
+	if err := users.PutUser(ctx, user); err != nil {
+		return err
+	}
+	fmt.Printf("Set the role of %s to %s\n", name, positional[1])
+	return nil
+}
+
+func adminUserPasswd(args []string) error {
+	af := newAdminFlags("user passwd")
+	password := af.fset.Bool("password-stdin", false, "read the password from stdin without prompting")
+	positional, err := af.parse(args, 1, "[-config FILE] [-store FILE] [-password-stdin] NAME")
+	if err != nil {
+		return err
+	}
+	name := positional[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	user, err := users.GetUser(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no user %s", name)
+	} else if err != nil {
+		return err
+	}
+	if user.PasswordHash, err = readPasswordHash(*password); err != nil {
+		return err
+	}
+	if err := users.PutUser(ctx, user); err != nil {
+		return err
+	}
+	fmt.Printf("Changed the password of %s\n", name)
+	return nil
+}
+
+// adminUserDel removes a user along with their API tokens
+func adminUserDel(args []string) error {
+	af := newAdminFlags("user del")
+	positional, err := af.parse(args, 1, "[-config FILE] [-store FILE] NAME")
+	if err != nil {
+		return err
+	}
+	name := positional[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	if err := users.DeleteUser(ctx, name); errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no user %s", name)
+	} else if err != nil {
+		return err
+	}
+	tokens, err := users.ListTokens(ctx)
+	if err != nil {
+		return err
+	}
+	revoked := 0
+	for _, token := range tokens {
+		if token.User != name {
+			continue
+		}
+		if err := users.DeleteToken(ctx, token.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		revoked++
+	}
+	fmt.Printf("Deleted user %s (%d tokens revoked)\n", name, revoked)
+	return nil
+}
+
+func adminUserList(args []string) error {
+	af := newAdminFlags("user list")
+	if _, err := af.parse(args, 0, "[-config FILE] [-store FILE]"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	list, err := users.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tROLE\tCREATED")
+	for _, user := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", user.Name, cmp.Or(user.Role, "user"), user.CreatedAt.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+// adminTokenCreate creates an API token and prints it; it cannot be
+// shown again
+func adminTokenCreate(args []string) error {
+	af := newAdminFlags("token create")
+	user := af.fset.String("user", "", "user the token acts as (required)")
+	name := af.fset.String("name", "", "label to recognize the token by")
+	expires := af.fset.Duration("expires", 0, "lifetime, such as 720h (default: never expires)")
+	synopsis := "[-config FILE] [-store FILE] -user NAME [-name LABEL] [-expires 720h]"
+	if _, err := af.parse(args, 0, synopsis); err != nil {
+		return err
+	}
+	if *user == "" || *expires < 0 {
+		return usageError(synopsis)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	// The environment admin account is not in the store
+	admin := ""
+	if *af.storePath == "" {
+		cfg, err := config.Load(*af.configPath)
+		if err != nil {
+			return err
+		}
+		admin = cfg.Auth.Username
+	}
+	if *user != admin {
+		if _, err := users.GetUser(ctx, *user); errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("no user %s", *user)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	token, secret, err := auth.NewToken(*name, *user, *expires)
+	if err != nil {
+		return err
+	}
+	if err := users.PutToken(ctx, token); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Created token %s for %s; it is shown only once:\n", token.ID, token.User)
+	fmt.Println(secret)
+	return nil
+}
+
+func adminTokenList(args []string) error {
+	af := newAdminFlags("token list")
+	if _, err := af.parse(args, 0, "[-config FILE] [-store FILE]"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	tokens, err := users.ListTokens(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tUSER\tCREATED\tEXPIRES")
+	for _, token := range tokens {
+		expires := "never"
+		if token.ExpiresAt != nil {
+			expires = token.ExpiresAt.Local().Format(time.DateTime)
+			if token.Expired(now) {
+				expires += " (expired)"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", token.ID, cmp.Or(token.Name, "-"), token.User,
+			token.CreatedAt.Local().Format(time.DateTime), expires)
+	}
+	return tw.Flush()
+}
+
+func adminTokenRevoke(args []string) error {
+	af := newAdminFlags("token revoke")
+	positional, err := af.parse(args, 1, "[-config FILE] [-store FILE] ID")
+	if err != nil {
+		return err
+	}
+	// Accept the full token as well as its ID
+	id := strings.TrimPrefix(positional[0], "sdw_")
+	id, _, _ = strings.Cut(id, "_")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	users, err := af.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	if err := users.DeleteToken(ctx, id); errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no token %s", id)
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("Revoked token %s\n", id)
+	return nil
+}
+
+// readPasswordHash reads a new password and hashes it. On a terminal it
+// prompts twice with echo off; otherwise, or with fromStdin, it reads one
+// line from stdin.
+func readPasswordHash(fromStdin bool) (string, error) {
+	in := bufio.NewReader(os.Stdin)
+	var password string
+	if info, err := os.Stdin.Stat(); fromStdin || err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	} else {
+		first, err := promptHidden(in, "Password: ")
+		if err != nil {
+			return "", err
+		}
+		second, err := promptHidden(in, "Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if first != second {
+			return "", errors.New("passwords do not match")
+		}
+		password = first
+	}
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return auth.HashPassword(password)
+}
+
+// promptHidden reads a line from the terminal with echo turned off by stty
+func promptHidden(in *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if err := stty("-echo"); err != nil {
+		return "", fmt.Errorf("cannot turn off echo: %w", err)
+	}
+	line, err := in.ReadString('\n')
+	_ = stty("echo")
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,238 @@
+// cmd/sysdwitch/bench.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/service/servicetest"
+)
+
+// Credentials of the in-process panel
+const (
+	panelUser     = "admin"
+	panelPassword = "secret"
+)
+
+// benchConfig is the configuration of the benchmarked panel: count allowed
+// services and rate limits too high to interfere
+func benchConfig(count int) *AppConfig {
+	cfg := config.Default()
+	cfg.AllowedServices = benchServices(count)
+	cfg.Auth.Username, cfg.Auth.Password = panelUser, panelPassword
+	cfg.Metrics.Interval = 0
+	unlimited := config.RateLimit{PerMinute: math.MaxInt32, Burst: math.MaxInt32}
+	cfg.RateLimits.Status, cfg.RateLimits.Control = unlimited, unlimited
+	return &AppConfig{Config: *cfg}
+}
+
+// benchServices names count services
+func benchServices(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = benchService(i) + ".service"
+	}
+	return names
+}
+
+// benchService names the i-th service as used in URLs
+func benchService(i int) string {
+	return fmt.Sprintf("svc%04d", i)
+}
+
+// benchScenario is a request a dashboard repeats; request builds the i-th
+// request of a dashboard
+type benchScenario struct {
+	name    string
+	summary string
+	request func(dashboard, i, services int) *http.Request
+}
+
+// benchScenarios are the status and control paths of a dashboard
+var benchScenarios = []benchScenario{
+	{"status", "GET /api/services/status, the dashboard's poll", func(dashboard, i, services int) *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/api/services/status", nil)
+	}},
+	{"page", "GET /, the server-rendered dashboard", func(dashboard, i, services int) *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/", nil)
+	}},
+	{"card", "GET /ui/services/{name}/card, an in-place card update", func(dashboard, i, services int) *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/ui/services/"+benchService((dashboard+i)%services)+"/card", nil)
+	}},
+	{"control", "POST /api/services/{name}/start|stop, alternating", func(dashboard, i, services int) *http.Request {
+		// Each dashboard controls its own service, so actions do not queue
+		// behind each other's unit locks
+		action := "start"
+		if i%2 == 1 {
+			action = "stop"
+		}
+		return httptest.NewRequest(http.MethodPost, "/api/services/"+benchService(dashboard%services)+"/"+action, nil)
+	}},
+}
+
+// benchRequest prepares a scenario request as sent by a dashboard, which
+// has its own client address
+func benchRequest(req *http.Request, dashboard int) *http.Request {
+	req.SetBasicAuth(panelUser, panelPassword)
+	req.RemoteAddr = fmt.Sprintf("10.0.%d.%d:40000", dashboard/250, dashboard%250+1)
+	return req
+}
+
+// benchResult sums up one scenario's run
+type benchResult struct {
+	requests      int
+	errors        int
+	elapsed       time.Duration
+	p50, p99, max time.Duration
+	allocs, bytes uint64
+}
+
+// runScenario has dashboards repeat a scenario against panel for duration
+func runScenario(panel http.Handler, scenario benchScenario, dashboards, services int, duration time.Duration) benchResult {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	latencies := make([][]time.Duration, dashboards)
+	failures := make([]int, dashboards)
+	start := time.Now()
+	deadline := start.Add(duration)
+	var wg sync.WaitGroup
+	for d := range dashboards {
+		wg.Go(func() {
+			for i := 0; time.Now().Before(deadline); i++ {
+				req := benchRequest(scenario.request(d, i, services), d)
+				rec := httptest.NewRecorder()
+				sent := time.Now()
+				panel.ServeHTTP(rec, req)
+				latencies[d] = append(latencies[d], time.Since(sent))
+				if rec.Code >= 300 {
+					failures[d]++
+				}
+			}
+		})
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	all := slices.Concat(latencies...)
+	slices.Sort(all)
+	result := benchResult{requests: len(all), elapsed: elapsed}
+	for _, n := range failures {
+		result.errors += n
+	}
+	if len(all) > 0 {
+		result.p50 = all[len(all)*50/100]
+		result.p99 = all[len(all)*99/100]
+		result.max = all[len(all)-1]
+		result.allocs = (after.Mallocs - before.Mallocs) / uint64(len(all))
+		result.bytes = (after.TotalAlloc - before.TotalAlloc) / uint64(len(all))
+	}
+	return result
+}
+
+// runBench simulates concurrent dashboards against an in-process panel on
+// the fake backend and reports latency and allocations per scenario, to
+// find and measure hot paths without a real init system
+func runBench(args []string) int {
+	fset := flag.NewFlagSet("bench", flag.ContinueOnError)
+	dashboards := fset.Int("dashboards", 50, "concurrent dashboards to simulate")
+	services := fset.Int("services", 100, "services on the fake backend")
+	duration := fset.Duration("duration", 5*time.Second, "how long to run each scenario")
+	only := fset.String("scenarios", "status,page,card,control", "comma-separated scenarios to run")
+	cpuProfile := fset.String("cpuprofile", "", "write a CPU profile of the run to this file")
+	memProfile := fset.String("memprofile", "", "write an allocation profile of the run to this file")
+	fset.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sysdwitch bench [flags]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "scenarios:")
+		for _, s := range benchScenarios {
+			fmt.Fprintf(os.Stderr, "  %-8s %s\n", s.name, s.summary)
+		}
+		fmt.Fprintln(os.Stderr)
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if *dashboards < 1 || *services < 1 || *duration <= 0 {
+		fmt.Fprintln(os.Stderr, "bench: -dashboards, -services and -duration must be positive")
+		return 2
+	}
+	var scenarios []benchScenario
+	for name := range strings.SplitSeq(*only, ",") {
+		i := slices.IndexFunc(benchScenarios, func(s benchScenario) bool { return s.name == strings.TrimSpace(name) })
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, "bench: unknown scenario %q\n", name)
+			return 2
+		}
+		scenarios = append(scenarios, benchScenarios[i])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := benchConfig(*services)
+	backend := servicetest.NewBackend(cfg.ServiceNames()...)
+	p, err := newPanel(ctx, cfg, slog.New(slog.DiscardHandler), panelOptions{backend: backend})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		return 1
+	}
+	defer p.Close()
+	panel := p.server.Handler
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+			return 1
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	fmt.Printf("%d dashboards, %d services, %s per scenario\n\n", *dashboards, *services, *duration)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "scenario\trequests\treq/s\tp50\tp99\tmax\tallocs/req\tbytes/req\terrors\t")
+	for _, scenario := range scenarios {
+		r := runScenario(panel, scenario, *dashboards, *services, *duration)
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%s\t%s\t%s\t%d\t%d\t%d\t\n",
+			scenario.name, r.requests, float64(r.requests)/r.elapsed.Seconds(),
+			r.p50.Round(time.Microsecond), r.p99.Round(time.Microsecond), r.max.Round(time.Microsecond),
+			r.allocs, r.bytes, r.errors)
+	}
+	tw.Flush()
+
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
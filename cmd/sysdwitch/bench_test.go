@@ -0,0 +1,47 @@
+// cmd/sysdwitch/bench_test.go
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"sysdwitch/internal/service/servicetest"
+)
+
+// BenchmarkScenarios runs the scenarios of the bench command in parallel,
+// for a small and a large fleet:
+//
+//	go test -run '^$' -bench Scenarios -cpuprofile cpu.out ./cmd/sysdwitch
+func BenchmarkScenarios(b *testing.B) {
+	for _, services := range []int{10, 500} {
+		cfg := benchConfig(services)
+		backend := servicetest.NewBackend(cfg.ServiceNames()...)
+		p, err := newPanel(b.Context(), cfg, slog.New(slog.DiscardHandler), panelOptions{backend: backend})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { p.Close() })
+		panel := p.server.Handler
+
+		for _, scenario := range benchScenarios {
+			b.Run(fmt.Sprintf("%s/services=%d", scenario.name, services), func(b *testing.B) {
+				var dashboards atomic.Int64
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					dashboard := int(dashboards.Add(1))
+					for i := 0; pb.Next(); i++ {
+						rec := httptest.NewRecorder()
+						panel.ServeHTTP(rec, benchRequest(scenario.request(dashboard, i, services), dashboard))
+						if rec.Code >= 300 {
+							b.Errorf("%s: status %d", scenario.name, rec.Code)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
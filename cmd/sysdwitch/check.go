@@ -0,0 +1,66 @@
+// cmd/sysdwitch/check.go
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/handlers"
+)
+
+// checkCommand is the name under which the binary acts as a Nagios
+// plugin, e.g. through a symlink in the plugin directory
+const checkCommand = "check_sysdwitch"
+
+// runCheck is a Nagios and Icinga plugin: it prints the check line of
+// /api/check/{service} of a running panel and exits with its state
+func runCheck(args []string) int {
+	base, user, password := ctlDefaults()
+	fset := flag.NewFlagSet("check", flag.ContinueOnError)
+	fset.StringVar(&base, "url", base, "panel URL (SYSDWITCH_URL)")
+	fset.StringVar(&user, "user", user, "user name (SYSDWITCH_USER)")
+	timeout := fset.Duration("timeout", 10*time.Second, "give up after this long")
+	expect := fset.String("expect", "running", "state the service should be in: running or stopped")
+	fset.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: check_sysdwitch [--url URL] [--user NAME] [--timeout 10s] [--expect running|stopped] SERVICE")
+		fset.PrintDefaults()
+	}
+	// Plugins exit UNKNOWN on usage errors
+	if err := fset.Parse(args); err != nil {
+		return handlers.CheckUnknown
+	}
+	if fset.NArg() != 1 {
+		fset.Usage()
+		return handlers.CheckUnknown
+	}
+
+	c := newCtlClient(base, user, password, *timeout)
+	path := "/api/check/" + url.PathEscape(fset.Arg(0)) + "?expect=" + url.QueryEscape(*expect)
+	resp, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		fmt.Printf("SYSDWITCH UNKNOWN - %v\n", err)
+		return handlers.CheckUnknown
+	}
+	defer resp.Body.Close()
+
+	line, _ := bufio.NewReader(io.LimitReader(resp.Body, 64<<10)).ReadString('\n')
+	line = strings.TrimSpace(line)
+	state := slices.Index(handlers.CheckHTTPStatus[:], resp.StatusCode)
+	if !strings.HasPrefix(line, "SYSDWITCH ") {
+		fmt.Printf("SYSDWITCH UNKNOWN - unexpected response: %s\n", resp.Status)
+		return handlers.CheckUnknown
+	}
+	fmt.Println(line)
+	if state < 0 {
+		return handlers.CheckUnknown
+	}
+	return state
+}
@@ -0,0 +1,80 @@
+// cmd/sysdwitch/checkconfig.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/desired"
+)
+
+// checkConfig validates the configuration for --check-config and checks
+// that every referenced unit exists, printing each problem. It returns the
+// exit status: 0 when the configuration is usable, 1 otherwise.
+func checkConfig(cfg *config.Config, loadErr error) int {
+	if loadErr != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", loadErr)
+		return 1
+	}
+
+	var problems []string
+	if file := cfg.DesiredState.File; file != "" {
+		problems = append(problems, checkDesiredState(cfg, file)...)
+	}
+	units := 0
+	if cfg.Backend.Type == "systemd" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		// Profiles, rules and maintenance windows may only name these
+		for _, name := range cfg.ServiceNames() {
+			if strings.Contains(name, ":") {
+				// Served by a named backend
+				continue
+			}
+			unit := config.NormalizeServiceName(name)
+			state, err := unitLoadState(ctx, unit)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("cannot check unit %s: systemctl --user failed: %v", unit, err))
+				continue
+			}
+			if state != "loaded" {
+				problems = append(problems, fmt.Sprintf("unit %s is %s", unit, state))
+				continue
+			}
+			units++
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "✗ %s\n", problem)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	fmt.Printf("✓ configuration is valid: %d services, %d units checked\n", len(cfg.ServiceNames()), units)
+	return 0
+}
+
+// checkDesiredState checks that the desired-state file parses and names
+// only allowed services
+func checkDesiredState(cfg *config.Config, file string) []string {
+	entries, err := desired.Load(file)
+	if err != nil {
+		return []string{fmt.Sprintf("desired state: %v", err)}
+	}
+	allowed := make(map[string]bool)
+	for _, name := range cfg.ServiceNames() {
+		allowed[config.NormalizeServiceName(name)] = true
+	}
+	var problems []string
+	for _, entry := range entries {
+		if !allowed[entry.Service] {
+			problems = append(problems, fmt.Sprintf("desired state: service %s is not allowed", entry.Service))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,73 @@
+// cmd/sysdwitch/commands.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// subcommand is a command run instead of the server
+type subcommand struct {
+	run     func(args []string) int
+	summary string
+}
+
+// subcommands are dispatched on the first argument; "serve", or no
+// subcommand at all, starts the server
+var subcommands = map[string]subcommand{
+	"ctl":            {runCtl, "control the services of a running panel"},
+	"check":          {runCheck, "print a service's state as a Nagios plugin"},
+	"admin":          {runAdmin, "manage users and API tokens in the store"},
+	"config":         {runConfig, "export or import the configuration"},
+	"migrate-config": {runMigrateConfig, "convert an environment-only setup to a config file"},
+	"install":        {runInstall, "install the panel as a systemd user unit"},
+	"uninstall":      {runUninstall, "remove the unit written by install"},
+	"doctor":         {runDoctor, "check the environment and suggest fixes"},
+	"completion":     {runCompletion, "print a shell completion script"},
+	"bench":          {runBench, "load-test an in-process panel on a fake backend"},
+}
+
+// commandOrder lists the subcommands for help
+var commandOrder = []string{"ctl", "check", "admin", "config", "migrate-config", "install", "uninstall", "doctor", "completion", "bench"}
+
+// dispatch runs the subcommand named by args[0], reporting whether there
+// was one; "serve" is removed from os.Args so the server's flags parse
+func dispatch(args []string) (int, bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return 0, false
+	}
+	if args[0] == "serve" {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		return 0, false
+	}
+	switch args[0] {
+	case "help":
+		return runHelp(), true
+	case "__complete":
+		return runComplete(args[1:]), true
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		runHelp()
+		return 2, true
+	}
+	return cmd.run(args[1:]), true
+}
+
+// runHelp lists the commands
+func runHelp() int {
+	fmt.Fprintln(os.Stderr, "usage: sysdwitch [serve] [flags]")
+	fmt.Fprintln(os.Stderr, "       sysdwitch <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintf(os.Stderr, "  %-16s %s\n", "serve", "run the panel (the default)")
+	for _, name := range commandOrder {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, subcommands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "  %-16s %s\n", "help", "show this help")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run sysdwitch <command> -h for the flags of a command.")
+	return 0
+}
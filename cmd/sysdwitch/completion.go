@@ -0,0 +1,179 @@
+// cmd/sysdwitch/completion.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cliCommand describes a subcommand for shell completion
+type cliCommand struct {
+	name string
+	// flags are completed as --name; valueFlags take an argument
+	flags      []string
+	valueFlags []string
+	// args are fixed candidates for positional arguments, dynamic looks
+	// them up when completing
+	args    []string
+	dynamic func() []string
+	sub     []cliCommand
+}
+
+// cliRoot is the command tree offered by shell completion
+var cliRoot = cliCommand{
+	flags:      []string{"version", "check-config", "dev"},
+	valueFlags: []string{"config", "host", "port"},
+	sub: []cliCommand{
+		{name: "serve", flags: []string{"version", "check-config", "dev"}, valueFlags: []string{"config", "host", "port"}},
+		{name: "ctl", flags: []string{"json"}, valueFlags: []string{"url", "user"}, sub: []cliCommand{
+			{name: "list"},
+			{name: "status", dynamic: completeServices},
+			{name: "start", flags: []string{"dry-run"}, valueFlags: []string{"wait"}, dynamic: completeServices},
+			{name: "stop", flags: []string{"dry-run"}, valueFlags: []string{"wait"}, dynamic: completeServices},
+			{name: "restart", flags: []string{"dry-run"}, valueFlags: []string{"wait"}, dynamic: completeServices},
+		}},
+		{name: "check", valueFlags: []string{"url", "user", "timeout", "expect"}, dynamic: completeServices},
+		{name: "admin", sub: []cliCommand{
+			{name: "user", sub: []cliCommand{
+				{name: "add", flags: []string{"password-stdin", "admin"}, valueFlags: []string{"config", "store"}},
+				{name: "del", valueFlags: []string{"config", "store"}},
+				{name: "passwd", flags: []string{"password-stdin"}, valueFlags: []string{"config", "store"}},
+				{name: "role", valueFlags: []string{"config", "store"}},
+				{name: "list", valueFlags: []string{"config", "store"}},
+			}},
+			{name: "token", sub: []cliCommand{
+				{name: "create", valueFlags: []string{"config", "store", "user", "name", "expires"}},
+				{name: "list", valueFlags: []string{"config", "store"}},
+				{name: "revoke", valueFlags: []string{"config", "store"}},
+			}},
+		}},
+		{name: "config", sub: []cliCommand{
+			{name: "export", valueFlags: []string{"config", "o"}},
+			{name: "import", flags: []string{"force"}, valueFlags: []string{"config"}},
+		}},
+		{name: "install", flags: []string{"user", "force", "now"}, valueFlags: []string{"name", "workdir", "config", "env-file"}},
+		{name: "uninstall", flags: []string{"user"}, valueFlags: []string{"name"}},
+		{name: "doctor", valueFlags: []string{"config"}},
+		{name: "migrate-config", flags: []string{"force"}, valueFlags: []string{"config", "store"}},
+		{name: "completion", args: []string{"bash", "zsh", "fish"}},
+		{name: "bench", valueFlags: []string{"dashboards", "services", "duration", "scenarios", "cpuprofile", "memprofile"}},
+		{name: "help"},
+	},
+}
+
+// completeServices asks the panel configured in the environment for the
+// allowed services, giving up quickly so the shell stays responsive
+func completeServices() []string {
+	base, user, password := ctlDefaults()
+	names, err := newCtlClient(base, user, password, 2*time.Second).serviceNames()
+	if err != nil {
+		return nil
+	}
+	return names
+}
+
+// runCompletion prints the completion script for a shell
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sysdwitch completion bash|zsh|fish")
+		return 2
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "completion: unsupported shell %q: expected bash, zsh or fish\n", args[0])
+		return 2
+	}
+	fmt.Print(script)
+	return 0
+}
+
+// runComplete prints the candidates for the word following args, one per
+// line; the completion scripts call it as "sysdwitch __complete"
+func runComplete(args []string) int {
+	for _, candidate := range completeArgs(args) {
+		fmt.Println(candidate)
+	}
+	return 0
+}
+
+// completeArgs walks the command tree along the words typed so far. No
+// candidates after a flag that takes a value lets the shell offer files.
+func completeArgs(args []string) []string {
+	cmd := cliRoot
+	positional := 0
+	for i := 0; i < len(args); i++ {
+		word := args[i]
+		if strings.HasPrefix(word, "-") {
+			// -flag=value carries its value and does not match
+			if slices.Contains(cmd.valueFlags, strings.TrimLeft(word, "-")) {
+				if i == len(args)-1 {
+					return nil
+				}
+				i++
+			}
+			continue
+		}
+		sub := slices.IndexFunc(cmd.sub, func(c cliCommand) bool { return c.name == word })
+		if sub < 0 {
+			positional++
+			continue
+		}
+		cmd = cmd.sub[sub]
+	}
+
+	var candidates []string
+	for _, sub := range cmd.sub {
+		candidates = append(candidates, sub.name)
+	}
+	if positional == 0 {
+		candidates = append(candidates, cmd.args...)
+		if cmd.dynamic != nil {
+			candidates = append(candidates, cmd.dynamic()...)
+		}
+	}
+	for _, flag := range append(slices.Clone(cmd.flags), cmd.valueFlags...) {
+		candidates = append(candidates, "--"+flag)
+	}
+	return candidates
+}
+
+// completionScripts ask the binary for candidates, so they stay current
+// with the installed version
+var completionScripts = map[string]string{
+	"bash": `# bash completion for sysdwitch
+# Install: sysdwitch completion bash > /etc/bash_completion.d/sysdwitch
+_sysdwitch() {
+    local IFS=$'\n'
+    local candidates
+    candidates=$(sysdwitch __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null)
+    COMPREPLY=($(compgen -W "$candidates" -- "${COMP_WORDS[COMP_CWORD]}"))
+}
+complete -o default -F _sysdwitch sysdwitch
+`,
+	"zsh": `#compdef sysdwitch
+# zsh completion for sysdwitch
+# Install: sysdwitch completion zsh > "${fpath[1]}/_sysdwitch"
+_sysdwitch() {
+    local -a candidates
+    candidates=("${(@f)$(sysdwitch __complete "${(@)words[2,CURRENT-1]}" 2>/dev/null)}")
+    candidates=(${candidates:#})
+    if (( ${#candidates} )); then
+        compadd -- $candidates
+    else
+        _files
+    fi
+}
+if [ "$funcstack[1]" = "_sysdwitch" ]; then
+    _sysdwitch "$@"
+else
+    compdef _sysdwitch sysdwitch
+fi
+`,
+	"fish": `# fish completion for sysdwitch
+# Install: sysdwitch completion fish > ~/.config/fish/completions/sysdwitch.fish
+complete -c sysdwitch -a '(sysdwitch __complete (commandline -opc)[2..-1])'
+`,
+}
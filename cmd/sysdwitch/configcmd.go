@@ -0,0 +1,139 @@
+// cmd/sysdwitch/configcmd.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"sysdwitch/internal/config"
+)
+
+// runConfig handles "config export" and "config import", which copy a
+// setup between instances
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: sysdwitch config export|import [flags]")
+		return 2
+	}
+	switch args[0] {
+	case "export":
+		return runConfigExport(args[1:])
+	case "import":
+		return runConfigImport(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "config: unknown command %q\n", args[0])
+		return 2
+	}
+}
+
+// runConfigExport writes the effective configuration, the config file with
+// the environment applied, as YAML
+func runConfigExport(args []string) int {
+	fset := flag.NewFlagSet("config export", flag.ContinueOnError)
+	configPath := fset.String("config", os.Getenv("CONFIG_FILE"), "config file to read")
+	output := fset.String("o", "-", "file to write, - for stdout")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config export: %v\n", err)
+		return 1
+	}
+	if *output == "-" {
+		err = cfg.EncodeYAML(os.Stdout)
+	} else {
+		err = exportConfig(cfg, *output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config export: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// exportConfig writes cfg as YAML to the file at path, which may hold
+// secrets such as API tokens
+func exportConfig(cfg *config.Config, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := cfg.EncodeYAML(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runConfigImport validates an exported configuration and installs it as
+// the config file
+func runConfigImport(args []string) int {
+	fset := flag.NewFlagSet("config import", flag.ContinueOnError)
+	configPath := fset.String("config", os.Getenv("CONFIG_FILE"), "config file to write")
+	force := fset.Bool("force", false, "overwrite an existing config file")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if fset.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sysdwitch config import [-config file] [-force] export.yaml|-")
+		return 2
+	}
+
+	if err := importConfig(fset.Arg(0), *configPath, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "config import: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func importConfig(source, configPath string, force bool) error {
+	if configPath == "" {
+		return errors.New("no config file to write: pass -config or set CONFIG_FILE")
+	}
+	if !force {
+		if _, err := os.Stat(configPath); err == nil {
+			return fmt.Errorf("%s already exists (use -force to overwrite)", configPath)
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if source != "-" {
+		f, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	// Exports used to be JSON, the config file format
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var cfg *config.Config
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		cfg, err = config.Parse(bytes.NewReader(data))
+	} else {
+		cfg, err = config.ParseYAML(bytes.NewReader(data))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid export: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		return err
+	}
+	if err := cfg.Save(configPath); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	fmt.Printf("Wrote config file: %s (%d services)\n", configPath, len(cfg.ServiceNames()))
+	fmt.Println("Credentials are not part of the export: set ADMIN_USER and ADMIN_PASS, or copy the store.")
+	return nil
+}
@@ -0,0 +1,276 @@
+// cmd/sysdwitch/ctl.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sysdwitch/internal/handlers"
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/service"
+)
+
+// ctlClient talks to the API of a running panel
+type ctlClient struct {
+	base     string
+	user     string
+	password string
+	token    string
+	http     *http.Client
+}
+
+// newCtlClient connects to the panel at base, such as
+// http://127.0.0.1:8081 or https://home.example/sysdwitch. An API token
+// in SYSDWITCH_TOKEN is used instead of the user and password.
+func newCtlClient(base, user, password string, timeout time.Duration) *ctlClient {
+	return &ctlClient{
+		base:     strings.TrimSuffix(base, "/"),
+		user:     user,
+		password: password,
+		token:    os.Getenv("SYSDWITCH_TOKEN"),
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+// ctlDefaults are the connection settings from the environment
+func ctlDefaults() (base, user, password string) {
+	base = os.Getenv("SYSDWITCH_URL")
+	if base == "" {
+		base = "http://127.0.0.1:8081"
+	}
+	user, password = os.Getenv("SYSDWITCH_USER"), os.Getenv("SYSDWITCH_PASSWORD")
+	if user == "" {
+		user, password = os.Getenv("ADMIN_USER"), os.Getenv("ADMIN_PASS")
+	}
+	return base, user, password
+}
+
+// call sends a request and decodes the API response. Failed responses
+// become errors carrying the API's message.
+func (c *ctlClient) call(method, path string, body any) (handlers.APIResponse, *http.Response, error) {
+	var response handlers.APIResponse
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return response, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	resp, err := c.do(method, path, reader)
+	if err != nil {
+		return response, resp, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response, resp, fmt.Errorf("%s: unexpected response: %s", path, resp.Status)
+	}
+	if !response.Success {
+		if response.Error == "" {
+			response.Error = resp.Status
+		}
+		return response, resp, errors.New(response.Error)
+	}
+	return response, resp, nil
+}
+
+// do sends an authenticated request. A 401 response is an error.
+func (c *ctlClient) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return resp, errors.New("unauthorized: set SYSDWITCH_TOKEN, or SYSDWITCH_USER and SYSDWITCH_PASSWORD")
+	}
+	return resp, nil
+}
+
+// serviceNames lists the allowed services, for completion
+func (c *ctlClient) serviceNames() ([]string, error) {
+	var names []string
+	resp, _, err := c.call(http.MethodGet, "/api/services/status?fields=name", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range resp.Services {
+		names = append(names, strings.TrimSuffix(s.Name, ".service"))
+	}
+	return names, nil
+}
+
+// runCtl controls the services of a running panel through its API
+func runCtl(args []string) int {
+	base, user, password := ctlDefaults()
+	fset := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	fset.StringVar(&base, "url", base, "panel URL (SYSDWITCH_URL)")
+	fset.StringVar(&user, "user", user, "user name (SYSDWITCH_USER)")
+	asJSON := fset.Bool("json", false, "print API responses as JSON")
+	fset.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sysdwitch ctl [--url URL] [--user NAME] [--json] list|status|start|stop|restart [flags] [service]")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if fset.NArg() == 0 {
+		fset.Usage()
+		return 2
+	}
+	c := newCtlClient(base, user, password, 6*time.Minute)
+
+	command, rest := fset.Arg(0), fset.Args()[1:]
+	var err error
+	switch command {
+	case "list":
+		err = c.list(*asJSON)
+	case "status":
+		if len(rest) != 1 {
+			fmt.Fprintln(os.Stderr, "usage: sysdwitch ctl status SERVICE")
+			return 2
+		}
+		err = c.status(rest[0], *asJSON)
+	case "start", "stop", "restart":
+		return c.runAction(command, rest, *asJSON)
+	default:
+		fmt.Fprintf(os.Stderr, "ctl: unknown command %q\n", command)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ctl %s: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
+
+func (c *ctlClient) list(asJSON bool) error {
+	resp, _, err := c.call(http.MethodGet, "/api/services/status", nil)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(resp)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tSTATUS\tSINCE")
+	for _, s := range resp.Services {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Label(), s.Status, since(s))
+	}
+	return tw.Flush()
+}
+
+func (c *ctlClient) status(name string, asJSON bool) error {
+	resp, _, err := c.call(http.MethodGet, "/api/services/"+url.PathEscape(name)+"/status", nil)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(resp)
+	}
+	printStatus(*resp.Service)
+	return nil
+}
+
+// runAction starts, stops or restarts a service, following the job when the panel
+// answers before the action finished
+func (c *ctlClient) runAction(action string, args []string, asJSON bool) int {
+	fset := flag.NewFlagSet(action, flag.ContinueOnError)
+	wait := fset.Duration("wait", 0, "wait until the unit settles (up to 5m)")
+	dryRun := fset.Bool("dry-run", false, "validate without running the action")
+	// Accept flags before and after the service name
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if fset.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: sysdwitch ctl %s [--wait 30s] [--dry-run] SERVICE\n", action)
+		return 2
+	}
+	name := fset.Arg(0)
+	if err := fset.Parse(fset.Args()[1:]); err != nil || fset.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "usage: sysdwitch ctl %s [--wait 30s] [--dry-run] SERVICE\n", action)
+		return 2
+	}
+
+	body := map[string]any{"action": action, "dry_run": *dryRun}
+	if *wait > 0 {
+		body["wait"] = wait.String()
+	}
+	resp, httpResp, err := c.call(http.MethodPost, "/api/services/"+url.PathEscape(name), body)
+	if err == nil && httpResp.StatusCode == http.StatusAccepted && resp.Job != nil {
+		resp, err = c.waitJob(resp.Job.ID)
+	}
+	if asJSON && (err == nil || resp.Service != nil || resp.Job != nil) {
+		_ = printJSON(resp)
+	} else if resp.Service != nil {
+		printStatus(*resp.Service)
+	} else if resp.Job != nil && resp.Job.Result != nil {
+		printStatus(*resp.Job.Result)
+	}
+	if err != nil {
+		return ctlFail(action, err)
+	}
+	return 0
+}
+
+func ctlFail(action string, err error) int {
+	fmt.Fprintf(os.Stderr, "ctl %s: %v\n", action, err)
+	return 1
+}
+
+// waitJob polls a job until it finishes
+func (c *ctlClient) waitJob(id string) (handlers.APIResponse, error) {
+	for {
+		resp, _, err := c.call(http.MethodGet, "/api/jobs/"+url.PathEscape(id), nil)
+		if err != nil || resp.Job == nil || resp.Job.Status == jobs.StatusSucceeded {
+			return resp, err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func printStatus(s service.ServiceStatus) {
+	fmt.Printf("%s: %s", s.Label(), s.Status)
+	if s.DryRun {
+		fmt.Printf(" (dry run: %s)", s.Command)
+	} else if s.Since != nil {
+		fmt.Printf(" since %s", since(s))
+	}
+	fmt.Println()
+}
+
+func since(s service.ServiceStatus) string {
+	if s.Since == nil {
+		return "-"
+	}
+	return s.Since.Local().Format(time.DateTime)
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
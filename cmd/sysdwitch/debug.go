@@ -0,0 +1,50 @@
+// cmd/sysdwitch/debug.go
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"sysdwitch/internal/handlers"
+)
+
+// registerPprof mounts the net/http/pprof handlers on mux, each wrapped by
+// wrap (the admin role on the main listener). Profiles and traces move
+// their write deadline past ?seconds= themselves, through
+// http.NewResponseController, so they outlast the main listener's write
+// timeout as long as the middleware's ResponseWriters implement Unwrap.
+func registerPprof(mux *http.ServeMux, wrap func(http.HandlerFunc) http.HandlerFunc) {
+	mux.HandleFunc("/debug/pprof/", wrap(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", wrap(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", wrap(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", wrap(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", wrap(pprof.Trace))
+}
+
+// runDebugListener serves pprof and runtime stats without authentication
+// on a separate listener until ctx is done
+func runDebugListener(ctx context.Context, ln net.Listener, handler *handlers.Handler, logger *slog.Logger) {
+	mux := http.NewServeMux()
+	registerPprof(mux, func(h http.HandlerFunc) http.HandlerFunc { return h })
+	mux.HandleFunc("/api/admin/debug", handler.Debug)
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	addr := ln.Addr().String()
+	logger.Warn("debug listener enabled without authentication", "address", addr)
+	if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+		logger.Error("debug listener failed", "error", err, "address", addr)
+	}
+}
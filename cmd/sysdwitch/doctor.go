@@ -0,0 +1,286 @@
+// cmd/sysdwitch/doctor.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/store"
+)
+
+// doctor prints the outcome of environment checks with fixes for problems
+type doctor struct {
+	failures int
+	warnings int
+}
+
+func (d *doctor) ok(format string, args ...any) {
+	fmt.Printf("✓ %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) warn(fix, format string, args ...any) {
+	d.warnings++
+	fmt.Printf("! %s\n    fix: %s\n", fmt.Sprintf(format, args...), fix)
+}
+
+func (d *doctor) fail(fix, format string, args ...any) {
+	d.failures++
+	fmt.Printf("✗ %s\n    fix: %s\n", fmt.Sprintf(format, args...), fix)
+}
+
+// runDoctor checks the environment the panel runs in, since most problems
+// are a missing user bus or a misnamed unit rather than bugs
+func runDoctor(args []string) int {
+	fset := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	configPath := fset.String("config", os.Getenv("CONFIG_FILE"), "config file to check")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+
+	d := &doctor{}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		d.fail("correct the config file or environment variable named in the error", "configuration: %v", err)
+		return 1
+	}
+	d.ok("configuration is valid (%d services)", len(cfg.ServiceNames()))
+
+	if cfg.Backend.Type == "systemd" {
+		if d.checkUserBus() {
+			d.checkUnits(cfg)
+		}
+		d.checkLinger()
+	} else {
+		d.ok("%s backend: systemd checks skipped", cfg.Backend.Type)
+	}
+	d.checkAuth(cfg)
+	d.checkStore(cfg)
+	d.checkWritable(cfg)
+	d.checkPorts(cfg)
+
+	fmt.Printf("\n%d problems, %d warnings\n", d.failures, d.warnings)
+	if d.failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkUserBus reports whether systemctl --user can reach the user manager
+func (d *doctor) checkUserBus() bool {
+	path, err := exec.LookPath("systemctl")
+	if err != nil {
+		d.fail("install systemd or pick another backend with BACKEND", "systemctl not found in PATH")
+		return false
+	}
+	d.ok("systemctl found at %s", path)
+
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		d.fail("export XDG_RUNTIME_DIR=/run/user/$(id -u), or run from a login session", "XDG_RUNTIME_DIR is not set")
+	} else if _, err := os.Stat(filepath.Join(runtimeDir, "systemd")); err != nil {
+		d.fail("log in as this user once, or enable lingering (see below)", "no user manager in %s", runtimeDir)
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" && runtimeDir != "" {
+		d.warn("export DBUS_SESSION_BUS_ADDRESS=unix:path=$XDG_RUNTIME_DIR/bus", "DBUS_SESSION_BUS_ADDRESS is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "systemctl", "--user", "is-system-running").Output()
+	switch state := strings.TrimSpace(string(out)); state {
+	case "running", "initializing", "starting":
+		d.ok("user manager is %s", state)
+	case "degraded":
+		d.warn("see systemctl --user --failed", "user manager is degraded: some units failed")
+	default:
+		if state == "" && err != nil {
+			state = err.Error()
+		}
+		d.fail("run sysdwitch as the user owning the services, from a login session or with lingering enabled",
+			"cannot reach the user manager: %s", state)
+		return false
+	}
+	return true
+}
+
+// checkUnits verifies that every allowed unit exists
+func (d *doctor) checkUnits(cfg *config.Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	problems := 0
+	for _, name := range cfg.ServiceNames() {
+		if strings.Contains(name, ":") {
+			// Served by a named backend
+			continue
+		}
+		unit := config.NormalizeServiceName(name)
+		state, err := unitLoadState(ctx, unit)
+		if err != nil {
+			problems++
+			d.warn("check that systemctl --user works", "cannot query %s: %v", unit, err)
+			continue
+		}
+		if state != "loaded" {
+			problems++
+			d.fail("check the name with systemctl --user list-unit-files, or run systemctl --user daemon-reload after adding the unit",
+				"unit %s is %s", unit, state)
+		}
+	}
+	if problems == 0 {
+		d.ok("all allowed units exist")
+	}
+}
+
+// unitLoadState asks the user manager whether a unit is loaded; missing
+// units are "not-found"
+func unitLoadState(ctx context.Context, unit string) (string, error) {
+	out, err := exec.CommandContext(ctx, "systemctl", "--user", "show", "--property=LoadState", "--value", unit).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkLinger warns when the user manager stops at logout
+func (d *doctor) checkLinger() {
+	u, err := user.Current()
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(filepath.Join("/var/lib/systemd/linger", u.Username)); err != nil {
+		d.warn("sudo loginctl enable-linger "+u.Username,
+			"lingering is disabled for %s: the panel and its services stop at logout", u.Username)
+		return
+	}
+	d.ok("lingering is enabled for %s", u.Username)
+}
+
+// checkAuth verifies that someone can log in
+func (d *doctor) checkAuth(cfg *config.Config) {
+	a := cfg.Auth
+	switch {
+	case (a.Username == "") != (a.Password == ""):
+		d.fail("set both ADMIN_USER and ADMIN_PASS, or neither", "only one of ADMIN_USER and ADMIN_PASS is set")
+	case a.Username != "":
+		if len(a.Password) < 12 {
+			d.warn("use a password of at least 12 characters", "ADMIN_PASS is short")
+		} else {
+			d.ok("admin account %s is configured", a.Username)
+		}
+	case cfg.StorePath != "" || cfg.StoreDriver == "postgres":
+		d.ok("logins are checked against the user store")
+	default:
+		d.fail("set ADMIN_USER and ADMIN_PASS, or STORE_PATH with stored users", "no way to log in is configured")
+	}
+	if len(a.ProxyTrusted) > 0 {
+		d.ok("proxy authentication is trusted from %s", strings.Join(a.ProxyTrusted, ", "))
+	}
+}
+
+// checkStore opens the user store when one is configured
+func (d *doctor) checkStore(cfg *config.Config) {
+	if cfg.StoreDriver != "postgres" && cfg.StorePath == "" {
+		return
+	}
+	location := cfg.StorePath
+	if cfg.StoreDriver == "postgres" {
+		location = cfg.StoreDSN
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	users, err := store.Open(ctx, cfg.StoreDriver, location)
+	if err != nil {
+		d.fail("check STORE_PATH or STORE_DSN and that the database accepts the credentials", "cannot open the user store: %v", err)
+		return
+	}
+	defer users.Close()
+	list, err := users.ListUsers(ctx)
+	if err != nil {
+		d.fail("check that the store is readable", "cannot read the user store: %v", err)
+		return
+	}
+	d.ok("user store holds %d users", len(list))
+}
+
+// checkWritable verifies that the panel can write where it keeps state
+func (d *doctor) checkWritable(cfg *config.Config) {
+	var paths []string
+	if cfg.StoreDriver != "postgres" && cfg.StorePath != "" {
+		paths = append(paths, filepath.Dir(cfg.StorePath))
+	}
+	if cfg.Log.File != "" {
+		paths = append(paths, filepath.Dir(cfg.Log.File))
+	}
+	if cfg.ACME.Enabled() {
+		paths = append(paths, cfg.ACME.CacheDir)
+	}
+	for _, dir := range paths {
+		if err := writableDir(dir); err != nil {
+			d.fail("fix the directory's owner or mode, and add it to ReadWritePaths= of the unit",
+				"cannot write to %s: %v", dir, err)
+			continue
+		}
+		d.ok("%s is writable", dir)
+	}
+	if cfg.Plugins.Dir != "" {
+		if info, err := os.Stat(cfg.Plugins.Dir); err != nil || !info.IsDir() {
+			d.fail("create the directory or unset PLUGINS_DIR", "plugin directory %s is missing", cfg.Plugins.Dir)
+		}
+	}
+}
+
+// writableDir tries to create a file in dir, or in its closest existing
+// parent when dir is still to be created
+func writableDir(dir string) error {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".sysdwitch-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkPorts verifies that the listening ports are free
+func (d *doctor) checkPorts(cfg *config.Config) {
+	ports := []int{cfg.Port}
+	if cfg.ACME.Enabled() && cfg.ACME.Challenge == "http-01" {
+		ports = append(ports, cfg.ACME.HTTPPort)
+	}
+	for _, port := range ports {
+		addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			fix := "stop whatever holds the port (is sysdwitch already running?) or change PORT"
+			if port < 1024 {
+				fix = "grant CAP_NET_BIND_SERVICE (AmbientCapabilities= in the unit) or use a port above 1023"
+			}
+			d.fail(fix, "cannot listen on %s: %v", addr, err)
+			continue
+		}
+		ln.Close()
+		d.ok("%s is free", addr)
+	}
+}
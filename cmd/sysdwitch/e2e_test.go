@@ -0,0 +1,997 @@
+// cmd/sysdwitch/e2e_test.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/handlers"
+	"sysdwitch/internal/service"
+	"sysdwitch/internal/service/servicetest"
+	"sysdwitch/internal/store"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// harness runs the panel as main assembles it against a fake backend
+type harness struct {
+	t       *testing.T
+	panel   *panel
+	server  *httptest.Server
+	backend *servicetest.Backend
+	cfg     *config.Config
+}
+
+// newHarness starts a panel allowing foo and bar, with the configuration
+// adjusted by configure when not nil. Unit states are read on every
+// request unless configure sets a status interval.
+func newHarness(t *testing.T, configure func(*config.Config)) *harness {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.AllowedServices = []string{"foo.service", "bar.service"}
+	cfg.Auth.Username, cfg.Auth.Password = panelUser, panelPassword
+	cfg.Metrics.Interval = 0
+	cfg.StatusInterval = 0
+	if configure != nil {
+		configure(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid test configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	logger := slog.New(slog.NewTextHandler(t.Output(), &slog.HandlerOptions{Level: slog.LevelWarn}))
+	backend := servicetest.NewBackend(cfg.ServiceNames()...)
+	app := &AppConfig{Config: *cfg}
+	p, err := newPanel(ctx, app, logger, panelOptions{backend: backend})
+	if err != nil {
+		t.Fatalf("panel: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	// The panel's own server, with its timeouts, on a local listener
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = p.server
+	server.Start()
+	t.Cleanup(server.Close)
+	return &harness{t: t, panel: p, server: server, backend: backend, cfg: &app.Config}
+}
+
+// request sends a request with the admin's credentials unless anonymous
+func (h *harness) request(method, path string, body io.Reader, anonymous bool) *http.Response {
+	h.t.Helper()
+	authorization := ""
+	if !anonymous {
+		authorization = basicAuth(panelUser, panelPassword)
+	}
+	return h.requestAuth(method, path, body, authorization)
+}
+
+// basicAuth is the Authorization header for a password login
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// requestAuth sends a request with the given Authorization header, if any
+func (h *harness) requestAuth(method, path string, body io.Reader, authorization string) *http.Response {
+	h.t.Helper()
+	req, err := http.NewRequest(method, h.server.URL+h.cfg.BasePath+path, body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// withStore keeps users and tokens in a file store of the test
+func withStore(t *testing.T) func(*config.Config) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	return func(cfg *config.Config) { cfg.StorePath = path }
+}
+
+// addUser stores a user with password, as the admin command does, and
+// returns the Authorization header to log in as them
+func (h *harness) addUser(name, password, role string) string {
+	h.t.Helper()
+	users, err := store.OpenFile(h.cfg.StorePath)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer users.Close()
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if err := users.PutUser(context.Background(), store.User{Name: name, PasswordHash: hash, Role: role, CreatedAt: time.Now()}); err != nil {
+		h.t.Fatal(err)
+	}
+	return basicAuth(name, password)
+}
+
+// addToken stores an API token for user expiring after ttl, or never when
+// zero, and returns the Authorization header sending it
+func (h *harness) addToken(user string, ttl time.Duration) string {
+	h.t.Helper()
+	users, err := store.OpenFile(h.cfg.StorePath)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer users.Close()
+	token, secret, err := auth.NewToken("test", user, ttl)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if err := users.PutToken(context.Background(), token); err != nil {
+		h.t.Fatal(err)
+	}
+	return "Bearer " + secret
+}
+
+// decode reads a JSON response body into v
+func (h *harness) decode(resp *http.Response, v any) {
+	h.t.Helper()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		h.t.Fatalf("%s %s: Content-Type %q, want JSON", resp.Request.Method, resp.Request.URL.Path, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		h.t.Fatalf("%s %s: decoding body: %v", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+}
+
+// expectStatus fails the test unless resp has the status code want
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: status %d, want %d; body: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, body)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	h := newHarness(t, nil)
+
+	resp := h.request(http.MethodGet, "/api/services/status", nil, true)
+	expectStatus(t, resp, http.StatusUnauthorized)
+	if resp.Header.Get("WWW-Authenticate") == "" {
+		t.Error("401 without a WWW-Authenticate challenge")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, h.server.URL+"/api/services/status", nil)
+	req.SetBasicAuth(panelUser, "wrong")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	expectStatus(t, h.request(http.MethodGet, "/api/services/status", nil, false), http.StatusOK)
+
+	// Probes and public pages need no credentials
+	expectStatus(t, h.request(http.MethodGet, "/healthz", nil, true), http.StatusOK)
+}
+
+func TestServiceLifecycle(t *testing.T) {
+	h := newHarness(t, nil)
+
+	var started handlers.APIResponse
+	resp := h.request(http.MethodPost, "/api/services/foo/start", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &started)
+	if !started.Success || started.Service == nil || started.Service.Status != service.StateRunning {
+		t.Fatalf("start: got %+v, want success with foo running", started)
+	}
+	if !started.Service.Active {
+		t.Error("started service not reported active")
+	}
+
+	var status handlers.APIResponse
+	resp = h.request(http.MethodGet, "/api/services/foo/status", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &status)
+	if status.Service == nil || status.Service.Status != service.StateRunning {
+		t.Fatalf("status after start: got %+v", status)
+	}
+
+	resp = h.request(http.MethodPost, "/api/services/foo/stop", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+
+	want := []servicetest.Call{{Action: "start", Unit: "foo.service"}, {Action: "stop", Unit: "foo.service"}}
+	if calls := h.backend.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("backend calls = %v, want %v", calls, want)
+	}
+}
+
+func TestActionErrors(t *testing.T) {
+	h := newHarness(t, nil)
+	h.backend.Fail("bar.service", errors.New("Job for bar.service failed"))
+	h.backend.Remove("foo.service")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"not allowed", http.MethodPost, "/api/services/nope/start", http.StatusNotFound},
+		{"unknown action", http.MethodPost, "/api/services/bar/reload", http.StatusBadRequest},
+		{"bad path", http.MethodPost, "/api/services/bar/start/now", http.StatusBadRequest},
+		{"failed action", http.MethodPost, "/api/services/bar/start", http.StatusBadGateway},
+		{"missing unit", http.MethodPost, "/api/services/foo/start", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := h.request(tt.method, tt.path, nil, false)
+			expectStatus(t, resp, tt.want)
+		})
+	}
+}
+
+func TestReadOnlyRejectsActions(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.ReadOnly = true
+	})
+
+	var body handlers.APIResponse
+	resp := h.request(http.MethodPost, "/api/services/foo/start", nil, false)
+	expectStatus(t, resp, http.StatusServiceUnavailable)
+	h.decode(resp, &body)
+	if body.Success || body.Error == "" {
+		t.Errorf("read-only rejection: got %+v, want an error", body)
+	}
+	if calls := h.backend.Calls(); len(calls) != 0 {
+		t.Errorf("backend ran %v in read-only mode", calls)
+	}
+}
+
+func TestReadOnlyNeedsAdmin(t *testing.T) {
+	h := newHarness(t, withStore(t))
+	viewer := h.addUser("viewer", "viewer-pass", "")
+
+	resp := h.requestAuth(http.MethodPut, "/api/admin/read-only", strings.NewReader(`{"read_only":true}`), viewer)
+	expectStatus(t, resp, http.StatusForbidden)
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo/start", nil, false), http.StatusOK)
+
+	resp = h.request(http.MethodPut, "/api/admin/read-only", strings.NewReader(`{"read_only":true}`), false)
+	expectStatus(t, resp, http.StatusOK)
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo/stop", nil, false), http.StatusServiceUnavailable)
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.RateLimits.Control = config.RateLimit{PerMinute: 1, Burst: 2}
+	})
+
+	for range 2 {
+		expectStatus(t, h.request(http.MethodPost, "/api/services/foo/start", nil, false), http.StatusOK)
+	}
+	resp := h.request(http.MethodPost, "/api/services/foo/stop", nil, false)
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+
+	// Reads have their own budget
+	expectStatus(t, h.request(http.MethodGet, "/api/services/status", nil, false), http.StatusOK)
+}
+
+func TestStatusContract(t *testing.T) {
+	h := newHarness(t, nil)
+	h.backend.SetState("bar.service", service.StateRunning)
+	h.backend.Remove("foo.service")
+
+	var body struct {
+		Success  bool             `json:"success"`
+		Services []map[string]any `json:"services"`
+	}
+	resp := h.request(http.MethodGet, "/api/services/status", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("ETag") == "" {
+		t.Error("status without an ETag")
+	}
+	h.decode(resp, &body)
+	if !body.Success || len(body.Services) != 2 {
+		t.Fatalf("got %+v, want both services", body)
+	}
+
+	// Clients rely on these fields, in allowlist order
+	want := []map[string]any{
+		{"name": "foo.service", "status": "not-installed", "active": false},
+		{"name": "bar.service", "status": "running", "active": true},
+	}
+	for i, fields := range want {
+		for key, value := range fields {
+			if got := body.Services[i][key]; got != value {
+				t.Errorf("services[%d].%s = %v, want %v", i, key, got, value)
+			}
+		}
+	}
+
+	etag := resp.Header.Get("ETag")
+	req, _ := http.NewRequest(http.MethodGet, h.server.URL+"/api/services/status", nil)
+	req.SetBasicAuth(panelUser, panelPassword)
+	req.Header.Set("If-None-Match", etag)
+	cached, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached.Body.Close()
+	expectStatus(t, cached, http.StatusNotModified)
+}
+
+func TestPagesAndAssets(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.BasePath = "/panel"
+	})
+
+	resp := h.request(http.MethodGet, "/", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	page, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{`data-service="foo"`, `data-service="bar"`, `/panel/static/css/`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("dashboard lacks %s", want)
+		}
+	}
+	if resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("dashboard without security headers")
+	}
+
+	resp = h.request(http.MethodGet, "/ui/services/foo/card", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	card, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(card), `data-status="stopped"`) {
+		t.Errorf("card fragment: got %s", card)
+	}
+
+	resp = h.request(http.MethodGet, "/favicon.ico", nil, true)
+	expectStatus(t, resp, http.StatusFound)
+	asset := strings.TrimPrefix(resp.Header.Get("Location"), h.cfg.BasePath)
+	resp = h.request(http.MethodGet, asset, nil, true)
+	expectStatus(t, resp, http.StatusOK)
+	if cc := resp.Header.Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("hashed asset %s: Cache-Control %q", asset, cc)
+	}
+
+	expectStatus(t, h.request(http.MethodGet, "/static/missing.css", nil, true), http.StatusNotFound)
+}
+
+func TestBackgroundRefresh(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.StatusInterval = config.Duration(interval)
+	})
+
+	status := func() service.State {
+		var body handlers.APIResponse
+		resp := h.request(http.MethodGet, "/api/services/foo/status", nil, false)
+		expectStatus(t, resp, http.StatusOK)
+		h.decode(resp, &body)
+		return body.Service.Status
+	}
+	// Wait for the first pass
+	deadline := time.Now().Add(2 * time.Second)
+	for status() != service.StateStopped && time.Now().Before(deadline) {
+		time.Sleep(interval / 5)
+	}
+
+	// A change behind the panel's back shows after the next pass
+	h.backend.SetState("foo.service", service.StateFailed)
+	for status() != service.StateFailed {
+		if time.Now().After(deadline) {
+			t.Fatal("state change not picked up by the refresher")
+		}
+		time.Sleep(interval / 5)
+	}
+
+	// Actions answer with the state they left, not the last pass
+	var started handlers.APIResponse
+	resp := h.request(http.MethodPost, "/api/services/foo/start", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &started)
+	if started.Service.Status != service.StateRunning {
+		t.Errorf("start answered %s, want running", started.Service.Status)
+	}
+	if got := status(); got != service.StateRunning {
+		t.Errorf("status after start = %s, want running", got)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	const writeTimeout = 300 * time.Millisecond
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.WriteTimeout = config.Duration(writeTimeout)
+	})
+
+	next := h.subscribe(basicAuth(panelUser, panelPassword))
+
+	// The stream outlives the server's write timeout
+	time.Sleep(2 * writeTimeout)
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo/start", nil, false), http.StatusOK)
+
+	var event audit.Event
+	for event.Type != audit.EventServiceAction {
+		event = nextEvent(t, next)
+	}
+	if event.Service != "foo.service" || event.User != panelUser {
+		t.Errorf("action event = %+v, want foo.service by %s", event, panelUser)
+	}
+}
+
+// subscribe opens the event stream as authorization and returns a function
+// reading its next line, once the subscription exists
+func (h *harness) subscribe(authorization string) func() string {
+	t := h.t
+	t.Helper()
+	resp := h.requestAuth(http.MethodGet, "/api/events", nil, authorization)
+	expectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q, want text/event-stream", ct)
+	}
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-t.Context().Done():
+				return
+			}
+		}
+	}()
+	next := func() string {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("event stream ended")
+			}
+			return line
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event within 2s")
+		}
+		return ""
+	}
+	// The subscription exists once the stream opened
+	if line := next(); line != ": connected" {
+		t.Fatalf("first line %q, want the connected comment", line)
+	}
+	return next
+}
+
+// nextEvent skips to the next event of a stream read by next
+func nextEvent(t *testing.T, next func() string) audit.Event {
+	t.Helper()
+	for {
+		data, ok := strings.CutPrefix(next(), "data: ")
+		if !ok {
+			continue
+		}
+		var event audit.Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("event %q: %v", data, err)
+		}
+		return event
+	}
+}
+
+func TestEventStreamForViewers(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		withStore(t)(cfg)
+		cfg.Tasks = []config.TaskConfig{{Name: "backup", Command: []string{"/bin/true"}}}
+	})
+	viewer := h.subscribe(h.addUser("viewer", "viewer-pass", ""))
+	admin := h.subscribe(basicAuth(panelUser, panelPassword))
+
+	// A task run, then a service action: admins get both, viewers only
+	// the action
+	expectStatus(t, h.request(http.MethodPost, "/api/admin/tasks/backup/run", nil, false), http.StatusOK)
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo/start", nil, false), http.StatusOK)
+	seen := func(next func() string) map[string]bool {
+		types := make(map[string]bool)
+		for !types[audit.EventServiceAction] {
+			types[nextEvent(t, next).Type] = true
+		}
+		return types
+	}
+	if !seen(admin)[audit.EventTaskRun] {
+		t.Error("admin stream lacks the task run")
+	}
+	if types := seen(viewer); types[audit.EventTaskRun] {
+		t.Errorf("viewer stream got %v, including the task run", types)
+	}
+}
+
+func TestRecentEvents(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.EventHistory = 3
+	})
+
+	// Start and stop foo, then start bar: each action and state change is
+	// an event, more than the history keeps
+	for _, action := range []string{"/api/services/foo/start", "/api/services/foo/stop", "/api/services/bar/start"} {
+		expectStatus(t, h.request(http.MethodPost, action, nil, false), http.StatusOK)
+	}
+
+	var recent struct {
+		Success bool          `json:"success"`
+		Events  []audit.Event `json:"events"`
+	}
+	resp := h.request(http.MethodGet, "/api/events/recent", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &recent)
+	if len(recent.Events) != 3 {
+		t.Fatalf("got %d events, want the 3 kept", len(recent.Events))
+	}
+	last := recent.Events[0]
+	if last.Service != "bar.service" || !slices.IsSortedFunc(recent.Events, func(a, b audit.Event) int { return b.Time.Compare(a.Time) }) {
+		t.Errorf("events not newest first, ending with bar: %+v", recent.Events)
+	}
+
+	resp = h.request(http.MethodGet, "/api/events/recent?service=foo&limit=1", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &recent)
+	if len(recent.Events) != 1 || recent.Events[0].Service != "foo.service" {
+		t.Errorf("foo's latest event: got %+v", recent.Events)
+	}
+
+	expectStatus(t, h.request(http.MethodGet, "/api/events/recent?limit=0", nil, false), http.StatusBadRequest)
+	expectStatus(t, h.request(http.MethodGet, "/api/events/recent?service=baz", nil, false), http.StatusNotFound)
+
+	resp = h.request(http.MethodGet, "/", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	page, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(page), "start requested for bar.service") {
+		t.Error("dashboard activity feed lacks the latest action")
+	}
+}
+
+func TestTimeoutsAndBodyLimit(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.WriteTimeout = config.Duration(500 * time.Millisecond)
+		cfg.MaxBodyBytes = 64
+	})
+
+	// An action asked to wait is answered after the write timeout
+	h.backend.SetDelay(time.Second)
+	var started handlers.APIResponse
+	resp := h.request(http.MethodPost, "/api/services/foo/start?wait=5s", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &started)
+	if started.Service == nil || started.Service.Status != service.StateRunning {
+		t.Errorf("start with wait: got %+v", started)
+	}
+	h.backend.SetDelay(0)
+
+	body := `{"action":"stop","env":{"PADDING":"` + strings.Repeat("x", 64) + `"}}`
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo", strings.NewReader(body), false), http.StatusRequestEntityTooLarge)
+	// Without a length the body is sent chunked and the cap is only hit
+	// while decoding it
+	for path, method := range map[string]string{
+		"/api/services/foo":       http.MethodPost,
+		"/api/services/foo/start": http.MethodPost,
+		"/api/admin/log-level":    http.MethodPut,
+	} {
+		chunked := io.MultiReader(strings.NewReader(body))
+		expectStatus(t, h.request(method, path, chunked, false), http.StatusRequestEntityTooLarge)
+	}
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo", strings.NewReader(`{"action":"stop"}`), false), http.StatusOK)
+}
+
+func TestRestartAction(t *testing.T) {
+	h := newHarness(t, nil)
+
+	var restarted handlers.APIResponse
+	resp := h.request(http.MethodPost, "/api/services/foo", strings.NewReader(`{"action":"restart","wait":"30s"}`), false)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &restarted)
+	if restarted.Service == nil || restarted.Service.Status != service.StateRunning {
+		t.Errorf("restart with wait: got %+v", restarted)
+	}
+	expectStatus(t, h.request(http.MethodPost, "/api/services/bar/restart", nil, false), http.StatusOK)
+	want := []servicetest.Call{{Action: "restart", Unit: "foo.service"}, {Action: "restart", Unit: "bar.service"}}
+	if calls := h.backend.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("backend calls %v, want %v", calls, want)
+	}
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo", strings.NewReader(`{"action":"reload"}`), false), http.StatusBadRequest)
+}
+
+func TestProfileOutlastsWriteTimeout(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.WriteTimeout = config.Duration(500 * time.Millisecond)
+		cfg.Debug.Pprof = true
+	})
+
+	resp := h.request(http.MethodGet, "/debug/pprof/trace?seconds=1", nil, false)
+	expectStatus(t, resp, http.StatusOK)
+	trace, err := io.ReadAll(resp.Body)
+	if err != nil || len(trace) == 0 {
+		t.Fatalf("trace cut short: %d bytes, %v", len(trace), err)
+	}
+}
+
+func TestHTTP3(t *testing.T) {
+	h := newHarness(t, nil)
+
+	// The panel's handler over HTTPS with the test certificate in place of
+	// one from ACME
+	server := httptest.NewUnstartedServer(h.panel.server.Handler)
+	h3 := newHTTP3Server(server.Config)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	h3.TLSConfig = server.TLS
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go h3.Serve(conn)
+	t.Cleanup(func() { h3.Close() })
+
+	get := func(client *http.Client, url string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, url+h.cfg.BasePath+"/api/services/status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", basicAuth(panelUser, panelPassword))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	transport := &http3.Transport{TLSClientConfig: server.Client().Transport.(*http.Transport).TLSClientConfig}
+	t.Cleanup(func() { transport.Close() })
+	resp := get(&http.Client{Transport: transport}, "https://"+conn.LocalAddr().String())
+	expectStatus(t, resp, http.StatusOK)
+	if resp.ProtoMajor != 3 {
+		t.Errorf("answered over %s, want HTTP/3", resp.Proto)
+	}
+
+	// Clients on TCP learn about the QUIC port
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	resp = get(server.Client(), server.URL)
+	expectStatus(t, resp, http.StatusOK)
+	if altSvc := resp.Header.Get("Alt-Svc"); !strings.Contains(altSvc, fmt.Sprintf(`h3=":%d"`, port)) {
+		t.Errorf("Alt-Svc %q does not announce port %d", altSvc, port)
+	}
+}
+
+func TestAdminRole(t *testing.T) {
+	storeTo := withStore(t)
+	h := newHarness(t, func(cfg *config.Config) {
+		storeTo(cfg)
+		cfg.DryRun = true
+		cfg.PowerActions = []string{"reboot"}
+		cfg.Tasks = []config.TaskConfig{{Name: "backup", Command: []string{"/bin/true"}}}
+		cfg.Debug.Pprof = true
+		cfg.WakeHosts = []config.WakeHost{{Name: "backup", MAC: "aa:bb:cc:dd:ee:ff"}}
+	})
+	viewer := h.addUser("viewer", "viewer-pass", "")
+	operator := h.addUser("operator", "operator-pass", store.RoleAdmin)
+	viewerToken := h.addToken("viewer", 0)
+	operatorToken := h.addToken("operator", 0)
+
+	reboot := "/api/admin/host/reboot?dry_run=true"
+	wake := "/api/hosts/backup/wake?dry_run=true"
+	for _, authorization := range []string{viewer, viewerToken} {
+		expectStatus(t, h.requestAuth(http.MethodGet, "/api/services/status", nil, authorization), http.StatusOK)
+		expectStatus(t, h.requestAuth(http.MethodPost, reboot, nil, authorization), http.StatusForbidden)
+		expectStatus(t, h.requestAuth(http.MethodGet, "/api/admin/log-level", nil, authorization), http.StatusForbidden)
+		expectStatus(t, h.requestAuth(http.MethodGet, "/debug/pprof/", nil, authorization), http.StatusForbidden)
+		expectStatus(t, h.requestAuth(http.MethodGet, "/api/hosts", nil, authorization), http.StatusForbidden)
+		expectStatus(t, h.requestAuth(http.MethodPost, wake, nil, authorization), http.StatusForbidden)
+	}
+	for _, authorization := range []string{basicAuth(panelUser, panelPassword), operator, operatorToken} {
+		expectStatus(t, h.requestAuth(http.MethodPost, reboot, nil, authorization), http.StatusOK)
+		expectStatus(t, h.requestAuth(http.MethodGet, "/debug/pprof/", nil, authorization), http.StatusOK)
+		expectStatus(t, h.requestAuth(http.MethodPost, wake, nil, authorization), http.StatusOK)
+	}
+
+	// Power actions, tasks and Wake-on-LAN are offered to admins only
+	for authorization, want := range map[string]bool{viewer: false, operator: true} {
+		resp := h.requestAuth(http.MethodGet, "/", nil, authorization)
+		expectStatus(t, resp, http.StatusOK)
+		page, _ := io.ReadAll(resp.Body)
+		for _, section := range []string{"data-power-action", "data-task=", "data-wake"} {
+			if got := strings.Contains(string(page), section); got != want {
+				t.Errorf("dashboard shows %s: %v, want %v", section, got, want)
+			}
+		}
+	}
+}
+
+func TestOperationsNeedAdmin(t *testing.T) {
+	h := newHarness(t, withStore(t))
+	viewer := h.addUser("viewer", "viewer-pass", "")
+
+	expectStatus(t, h.requestAuth(http.MethodGet, "/api/admin/operations", nil, viewer), http.StatusForbidden)
+	expectStatus(t, h.requestAuth(http.MethodDelete, "/api/admin/operations/1", nil, viewer), http.StatusForbidden)
+	expectStatus(t, h.request(http.MethodGet, "/api/admin/operations", nil, false), http.StatusOK)
+}
+
+func TestTasksNeedAdmin(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		withStore(t)(cfg)
+		cfg.Tasks = []config.TaskConfig{{Name: "backup", Command: []string{"/bin/true"}}}
+	})
+	viewer := h.addUser("viewer", "viewer-pass", "")
+
+	expectStatus(t, h.requestAuth(http.MethodGet, "/api/admin/tasks", nil, viewer), http.StatusForbidden)
+	expectStatus(t, h.requestAuth(http.MethodPost, "/api/admin/tasks/backup/run", nil, viewer), http.StatusForbidden)
+	expectStatus(t, h.requestAuth(http.MethodGet, "/api/admin/tasks/backup/logs", nil, viewer), http.StatusForbidden)
+	expectStatus(t, h.request(http.MethodGet, "/api/admin/tasks", nil, false), http.StatusOK)
+	if calls := h.backend.Calls(); len(calls) != 0 {
+		t.Errorf("backend ran %v for a non-admin", calls)
+	}
+}
+
+func TestTokenResources(t *testing.T) {
+	h := newHarness(t, withStore(t))
+	viewer := h.addUser("viewer", "viewer-pass", "")
+	h.addUser("operator", "operator-pass", store.RoleAdmin)
+	operatorToken := h.addToken("operator", time.Hour)
+
+	create := func(authorization, body string) (*http.Response, tokenResponse) {
+		t.Helper()
+		var token tokenResponse
+		resp := h.requestAuth(http.MethodPost, "/api/admin/config/tokens", strings.NewReader(body), authorization)
+		if resp.StatusCode == http.StatusCreated {
+			h.decode(resp, &token)
+		}
+		return resp, token
+	}
+
+	// Tokens are managed by admins, for any user
+	resp, _ := create(viewer, `{"name":"ci"}`)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp, token := create(basicAuth(panelUser, panelPassword), `{"name":"ci","user":"viewer"}`)
+	expectStatus(t, resp, http.StatusCreated)
+	if token.User != "viewer" || token.ExpiresAt != nil {
+		t.Errorf("token for viewer: got %+v", token)
+	}
+
+	// A token outlives neither the token creating it nor the one changing it
+	limit := time.Now().Add(time.Hour)
+	later := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	for _, body := range []string{`{"name":"forever"}`, `{"name":"later","expires_at":"` + later + `"}`} {
+		resp, token := create(operatorToken, body)
+		expectStatus(t, resp, http.StatusCreated)
+		if token.ExpiresAt == nil || token.ExpiresAt.After(limit) {
+			t.Errorf("%s created with a token expiring within the hour expires %v", body, token.ExpiresAt)
+		}
+	}
+	resp = h.requestAuth(http.MethodPut, "/api/admin/config/tokens/"+token.ID,
+		strings.NewReader(`{"name":"ci","expires_at":"`+later+`"}`), operatorToken)
+	expectStatus(t, resp, http.StatusOK)
+	h.decode(resp, &token)
+	if token.ExpiresAt == nil || token.ExpiresAt.After(limit) {
+		t.Errorf("token changed with a token expiring within the hour expires %v", token.ExpiresAt)
+	}
+}
+
+// tokenResponse is a token resource as the API returns it
+type tokenResponse struct {
+	ID        string     `json:"id"`
+	User      string     `json:"user"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Secret    string     `json:"secret"`
+}
+
+func TestUpgradeHandoff(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.RulesInterval = config.Duration(interval)
+		cfg.Rules = []config.RuleConfig{{
+			Name:    "keep-bar",
+			When:    `state("bar") == "stopped"`,
+			Actions: []config.RuleAction{{Action: "start", Service: "bar"}},
+		}}
+		cfg.AsyncAfter = config.Duration(10 * time.Millisecond)
+	})
+	barStarts := func() int {
+		n := 0
+		for _, call := range h.backend.Calls() {
+			if call == (servicetest.Call{Action: "start", Unit: "bar.service"}) {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The rule acts while this process owns the integrations
+	deadline := time.Now().Add(2 * time.Second)
+	for barStarts() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("rule never started bar")
+		}
+		time.Sleep(interval)
+	}
+
+	// Once the new process took over it no longer does, while actions
+	// handed out as jobs still finish
+	h.panel.stopIntegrations()
+	time.Sleep(2 * interval)
+	started := barStarts()
+	h.backend.SetState("bar.service", service.StateStopped)
+	// Shorter than the wait, so a start by the rule would be recorded
+	h.backend.SetDelay(5 * interval)
+	expectStatus(t, h.request(http.MethodPost, "/api/services/foo/start", nil, false), http.StatusAccepted)
+	time.Sleep(10 * interval)
+	if n := barStarts(); n != started {
+		t.Errorf("rule started bar %d more times after the handoff", n-started)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.panel.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if !slices.Contains(h.backend.Calls(), servicetest.Call{Action: "start", Unit: "foo.service"}) {
+		t.Error("job started before the handoff did not finish")
+	}
+}
+
+func TestRuleRestart(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.RulesInterval = config.Duration(20 * time.Millisecond)
+		cfg.Rules = []config.RuleConfig{{
+			Name:    "revive-foo",
+			When:    `state("foo") == "stopped"`,
+			Actions: []config.RuleAction{{Action: "restart", Service: "foo"}},
+		}}
+	})
+
+	// A restart is one call of the backend, not a stop and a start
+	deadline := time.Now().Add(2 * time.Second)
+	for !slices.Contains(h.backend.Calls(), servicetest.Call{Action: "restart", Unit: "foo.service"}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("rule never restarted foo; calls %v", h.backend.Calls())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if calls := h.backend.Calls(); slices.Contains(calls, servicetest.Call{Action: "stop", Unit: "foo.service"}) {
+		t.Errorf("restart stopped foo on its own: %v", calls)
+	}
+}
+
+func TestCanceledReadKeepsStatus(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) {
+		cfg.StatusInterval = config.Duration(time.Hour)
+	})
+	status := func() service.State {
+		var body handlers.APIResponse
+		h.decode(h.request(http.MethodGet, "/api/services/foo/status", nil, false), &body)
+		return body.Service.Status
+	}
+
+	// Wait until status requests are answered from the refresher's table,
+	// which keeps a read over later changes behind the panel's back
+	ctx := context.Background()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.backend.SetState("foo.service", service.StateRunning)
+		h.panel.services.RefreshStatus(ctx, "foo.service")
+		h.backend.SetState("foo.service", service.StateFailed)
+		if status() == service.StateRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("status table never used")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A read given up on, such as by a client going away, leaves the table
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := h.panel.services.RefreshStatus(canceled, "foo.service"); !errors.Is(err, service.ErrCanceled) {
+		t.Fatalf("canceled read: got %v, want ErrCanceled", err)
+	}
+	if got := status(); got != service.StateRunning {
+		t.Errorf("status after a canceled read = %s, want running", got)
+	}
+}
+
+func TestConfigExportImport(t *testing.T) {
+	dir := t.TempDir()
+	tricky := []string{
+		"", "yes", "No", "null", "~", "0x1F", "1e3", "12", "-1.5", "- dash", "a: b # not a comment",
+		"#hash", " padded ", `quote " and 'single'`, "line\nbreak\ttab", "ünïcödé ✓", "@at", "[flow]", "{flow}",
+		"trailing:", "http://jellyfin.home:8096/web",
+	}
+	cfg := config.Default()
+	cfg.AllowedServices = []string{"foo.service"}
+	cfg.ReadOnlyMessage = "Backups: running # until 3am"
+	for i, s := range tricky {
+		cfg.Services = append(cfg.Services, config.ServiceConfig{
+			Name:         fmt.Sprintf("svc%d.service", i),
+			Description:  s,
+			EnvOverrides: map[string][]string{"MODE": {s, "fast"}, "EMPTY": {}},
+			MinFree:      []config.FreeSpace{{Path: "/srv", Min: 1 << 30}},
+		})
+	}
+	original := filepath.Join(dir, "sysdwitch.json")
+	if err := cfg.Save(original); err != nil {
+		t.Fatal(err)
+	}
+
+	// The export is YAML that survives hand edits such as comments
+	export := filepath.Join(dir, "export.yaml")
+	if code := runConfigExport([]string{"-config", original, "-o", export}); code != 0 {
+		t.Fatalf("config export exited with %d", code)
+	}
+	data, err := os.ReadFile(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "host: ") {
+		t.Fatalf("export is not block-style YAML:\n%s", data)
+	}
+	edited := "# copied from the NAS\n---\n" + strings.Replace(string(data), "\n", "  # port\n", 2)
+	if err := os.WriteFile(export, []byte(edited), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := filepath.Join(dir, "imported", "sysdwitch.json")
+	if err := importConfig(export, imported, false); err != nil {
+		t.Fatalf("config import: %v", err)
+	}
+	if err := importConfig(export, imported, false); err == nil {
+		t.Error("import overwrote an existing config file without -force")
+	}
+	// Exports from before YAML are still accepted
+	if err := importConfig(original, filepath.Join(dir, "from-json.json"), false); err != nil {
+		t.Errorf("importing a JSON export: %v", err)
+	}
+
+	parse := func(path string) string {
+		t.Helper()
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		parsed, err := config.Parse(f)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		encoded, _ := json.Marshal(parsed)
+		return string(encoded)
+	}
+	if got, want := parse(imported), parse(original); got != want {
+		t.Errorf("imported configuration differs:\n got %s\nwant %s", got, want)
+	}
+}
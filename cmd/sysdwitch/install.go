@@ -0,0 +1,224 @@
+// cmd/sysdwitch/install.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// unitOptions describe the unit file written by install
+type unitOptions struct {
+	Binary     string
+	WorkDir    string
+	ConfigFile string
+	EnvFile    string
+}
+
+// runInstall writes a user unit for the panel itself and enables it
+func runInstall(args []string) int {
+	fset := flag.NewFlagSet("install", flag.ContinueOnError)
+	user := fset.Bool("user", true, "install as a systemd user unit")
+	name := fset.String("name", "sysdwitch", "unit name")
+	workDir := fset.String("workdir", "", "working directory, writable by the panel (default: the binary's directory)")
+	configPath := fset.String("config", os.Getenv("CONFIG_FILE"), "config file passed to the panel")
+	envFile := fset.String("env-file", "", "EnvironmentFile for the unit")
+	force := fset.Bool("force", false, "overwrite an existing unit file")
+	now := fset.Bool("now", false, "also start the unit")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if !*user {
+		fmt.Fprintln(os.Stderr, "install: only --user installs are supported, as SysDwitch controls user units")
+		return 2
+	}
+
+	if err := install(*name, *workDir, *configPath, *envFile, *force, *now); err != nil {
+		fmt.Fprintf(os.Stderr, "install: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func install(name, workDir, configPath, envFile string, force, now bool) error {
+	binary, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if binary, err = filepath.EvalSymlinks(binary); err != nil {
+		return err
+	}
+	if workDir == "" {
+		workDir = filepath.Dir(binary)
+	}
+	opts := unitOptions{Binary: binary}
+	for _, p := range []struct {
+		src string
+		dst *string
+	}{{workDir, &opts.WorkDir}, {configPath, &opts.ConfigFile}, {envFile, &opts.EnvFile}} {
+		if p.src == "" {
+			continue
+		}
+		if *p.dst, err = filepath.Abs(p.src); err != nil {
+			return err
+		}
+	}
+
+	unitPath, err := userUnitPath(name)
+	if err != nil {
+		return err
+	}
+	if !force {
+		if _, err := os.Stat(unitPath); err == nil {
+			return fmt.Errorf("%s already exists (use -force to overwrite)", unitPath)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(unitPath, []byte(unitFile(opts)), 0o644); err != nil {
+		return fmt.Errorf("failed to write unit file: %w", err)
+	}
+	fmt.Printf("Wrote unit file: %s\n", unitPath)
+
+	if err := systemctlUser("daemon-reload"); err != nil {
+		return err
+	}
+	enable := []string{"enable", name}
+	if now {
+		enable = []string{"enable", "--now", name}
+	}
+	if err := systemctlUser(enable...); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Next steps:")
+	if opts.EnvFile == "" && opts.ConfigFile == "" {
+		fmt.Println("  configure  ALLOWED_SERVICES, ADMIN_USER and ADMIN_PASS, e.g. with -env-file or -config and -force")
+	}
+	if !now {
+		fmt.Printf("  start      systemctl --user start %s\n", name)
+	}
+	fmt.Println("  linger     loginctl enable-linger $USER  (keep running after logout)")
+	fmt.Printf("  logs       journalctl --user -u %s -f\n", name)
+	return nil
+}
+
+// runUninstall disables and removes the unit written by install
+func runUninstall(args []string) int {
+	fset := flag.NewFlagSet("uninstall", flag.ContinueOnError)
+	user := fset.Bool("user", true, "remove the systemd user unit")
+	name := fset.String("name", "sysdwitch", "unit name")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if !*user {
+		fmt.Fprintln(os.Stderr, "uninstall: only --user installs are supported")
+		return 2
+	}
+
+	unitPath, err := userUnitPath(*name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "uninstall: %v\n", err)
+		return 1
+	}
+	if _, err := os.Stat(unitPath); err != nil {
+		fmt.Fprintf(os.Stderr, "uninstall: %v\n", err)
+		return 1
+	}
+	if err := systemctlUser("disable", "--now", *name); err != nil {
+		// Still remove the file; the unit may never have been loaded
+		fmt.Fprintf(os.Stderr, "uninstall: %v\n", err)
+	}
+	if err := os.Remove(unitPath); err != nil {
+		fmt.Fprintf(os.Stderr, "uninstall: %v\n", err)
+		return 1
+	}
+	if err := systemctlUser("daemon-reload"); err != nil {
+		fmt.Fprintf(os.Stderr, "uninstall: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed %s\n", unitPath)
+	return 0
+}
+
+// userUnitPath is where systemd looks for the user's own units
+func userUnitPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/ ") {
+		return "", fmt.Errorf("invalid unit name %q", name)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", name+".service"), nil
+}
+
+// unitFile renders a hardened unit running the panel
+func unitFile(opts unitOptions) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=SystemD Switch (sysdwitch)\n")
+	b.WriteString("After=network.target\n\n")
+
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=notify\n")
+	b.WriteString("NotifyAccess=all\n")
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", opts.WorkDir)
+	execStart := systemdQuote(opts.Binary)
+	if opts.ConfigFile != "" {
+		execStart += " -config " + systemdQuote(opts.ConfigFile)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", execStart)
+	b.WriteString("ExecReload=/bin/kill -USR2 $MAINPID\n")
+	b.WriteString("Restart=always\n")
+	b.WriteString("RestartSec=5\n\n")
+
+	b.WriteString("# Environment\n")
+	if opts.EnvFile != "" {
+		fmt.Fprintf(&b, "EnvironmentFile=%s\n", opts.EnvFile)
+	}
+	b.WriteString("Environment=LOG_OUTPUT=journald\n\n")
+
+	b.WriteString("# Security\n")
+	b.WriteString("NoNewPrivileges=true\n")
+	b.WriteString("ProtectSystem=strict\n")
+	b.WriteString("ProtectHome=read-only\n")
+	fmt.Fprintf(&b, "ReadWritePaths=%s\n", systemdQuote(opts.WorkDir))
+	b.WriteString("PrivateTmp=true\n")
+	b.WriteString("RestrictSUIDSGID=true\n")
+	b.WriteString("LockPersonality=true\n\n")
+
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=default.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes a path for settings that split words, such as
+// ExecStart, when it contains spaces
+func systemdQuote(path string) string {
+	if !strings.ContainsAny(path, " \t\"\\") {
+		return path
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path) + `"`
+}
+
+// systemctlUser runs systemctl --user, passing its output through
+func systemctlUser(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("systemctl --user %s failed", strings.Join(args, " "))
+		}
+		return err
+	}
+	return nil
+}
@@ -3,25 +3,34 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
-	"html/template"
-	"io/fs"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
+	"sysdwitch/internal/acme"
+	"sysdwitch/internal/audit"
 	"sysdwitch/internal/auth"
-	"sysdwitch/internal/handlers"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/expr"
+	"sysdwitch/internal/logging"
+	"sysdwitch/internal/netutil"
+	"sysdwitch/internal/notify"
+	"sysdwitch/internal/plugin"
+	"sysdwitch/internal/ratelimit"
+	"sysdwitch/internal/requestid"
+	"sysdwitch/internal/rules"
+	"sysdwitch/internal/schedule"
 	"sysdwitch/internal/service"
-	"sysdwitch/web"
 )
 
 // Version information - set at build time
@@ -31,26 +40,34 @@ var (
 	buildTime = "unknown"
 )
 
+// devWebDir holds the web files served in dev mode, relative to the working
+// directory, which is the repository root under go run
+const devWebDir = "web"
+
 // AppConfig holds application configuration
 type AppConfig struct {
-	Host            string        `json:"host"`
-	Port            int           `json:"port"`
-	AllowedServices []string      `json:"allowed_services"`
-	ReadTimeout     time.Duration `json:"read_timeout"`
-	WriteTimeout    time.Duration `json:"write_timeout"`
-	ServiceManager  *service.ServiceManager
-	AuthConfig      *auth.AuthConfig
+	config.Config
+	// Path is the config file, empty when only the environment is used
+	Path string
+	// Dev serves the web files from the working tree instead of the binary
+	Dev            bool
+	ServiceManager *service.ServiceManager
+	AuthConfig     *auth.AuthConfig
 }
 
-// loadConfig loads configuration from environment variables and flags
+// loadConfig loads configuration from the config file, environment variables and flags
 func loadConfig() (*AppConfig, error) {
-	var config AppConfig
-	var showVersion bool
+	var configPath, host string
+	var port int
+	var showVersion, checkOnly, dev bool
 
 	// Command line flags
-	flag.StringVar(&config.Host, "host", getEnvOrDefault("HOST", "127.0.0.1"), "server host")
-	flag.IntVar(&config.Port, "port", getEnvIntOrDefault("PORT", 8081), "server port")
+	flag.StringVar(&configPath, "config", os.Getenv("CONFIG_FILE"), "path to JSON config file")
+	flag.StringVar(&host, "host", "", "server host (overrides config)")
+	flag.IntVar(&port, "port", 0, "server port (overrides config)")
 	flag.BoolVar(&showVersion, "version", false, "show version information")
+	flag.BoolVar(&checkOnly, "check-config", false, "validate the configuration and referenced units, then exit")
+	flag.BoolVar(&dev, "dev", false, "serve templates and static files from ./web, re-read on every request")
 
 	// Parse flags
 	flag.Parse()
@@ -63,147 +80,165 @@ func loadConfig() (*AppConfig, error) {
 		os.Exit(0)
 	}
 
-	// Get allowed services from environment
-	allowedServicesStr := getEnvOrDefault("ALLOWED_SERVICES", "calibre.service,jellyfin.service,navidrome.service")
-	config.AllowedServices = strings.Split(allowedServicesStr, ",")
-	for i, s := range config.AllowedServices {
-		config.AllowedServices[i] = strings.TrimSpace(s)
-		if config.AllowedServices[i] == "" {
-			return nil, errors.New("empty service name in ALLOWED_SERVICES")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		if checkOnly {
+			os.Exit(checkConfig(nil, err))
 		}
+		return nil, err
 	}
 
-	// HTTP timeouts
-	config.ReadTimeout = 15 * time.Second
-	config.WriteTimeout = 15 * time.Second
+	// Explicit flags take precedence over file and environment
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			cfg.Host = host
+		case "port":
+			cfg.Port = port
+		}
+	})
 
 	// Validate configuration
-	if config.Port < 1 || config.Port > 65535 {
-		return nil, errors.New("invalid port number")
+	err = cfg.Validate()
+	if checkOnly {
+		os.Exit(checkConfig(cfg, err))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &config, nil
+	return &AppConfig{Config: *cfg, Path: configPath, Dev: dev}, nil
 }
 
-// Helper functions for environment variable handling
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func main() {
+	// Called through a check_sysdwitch link, act as the Nagios plugin
+	if filepath.Base(os.Args[0]) == checkCommand {
+		os.Exit(runCheck(os.Args[1:]))
 	}
-	return defaultValue
-}
 
-func getEnvIntOrDefault(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
-		}
+	// Dispatch subcommands before server flag parsing
+	if code, ok := dispatch(os.Args[1:]); ok {
+		os.Exit(code)
 	}
-	return defaultValue
-}
 
-func main() {
-	// Setup structured logging
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
-	slog.SetDefault(logger)
+	// Bootstrap logging until the configuration is loaded
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 	// Load configuration
-	config, err := loadConfig()
+	cfg, err := loadConfig()
 	if err != nil {
 		logger.Error("failed to load configuration", "error", err)
 		os.Exit(1)
 	}
 
-	// Initialize components
-	authConfig, err := auth.NewAuthConfig(logger)
+	// Setup structured logging; the level can be changed at runtime
+	logLevel := new(slog.LevelVar)
+	logHandler, logCloser, err := logging.NewHandler(cfg.Log, logLevel)
 	if err != nil {
-		logger.Error("failed to initialize auth config", "error", err)
+		logger.Error("failed to set up logging", "error", err)
 		os.Exit(1)
 	}
+	defer logCloser.Close()
+	logger = slog.New(requestid.NewLogHandler(logHandler))
+	slog.SetDefault(logger)
 
-	serviceManager := service.NewServiceManager(config.AllowedServices, logger)
-
-	// Parse templates from embedded files
-	templates, err := template.New("").Funcs(template.FuncMap{
-		"trimSuffix": strings.TrimSuffix,
-	}).ParseFS(web.TemplatesFS, "templates/index.html")
+	accessLog, err := logging.OpenAccessLog(cfg.Log)
 	if err != nil {
-		logger.Error("failed to parse embedded templates", "error", fmt.Errorf("template parsing failed: %w", err))
+		logger.Error("failed to open access log", "error", err)
 		os.Exit(1)
 	}
+	if accessLog != nil {
+		defer accessLog.Close()
+	}
 
-	// Store references in config for use in handlers
-	config.AuthConfig = authConfig
-	config.ServiceManager = serviceManager
-
-	// Create handler instance
-	handler := handlers.NewHandler(logger, serviceManager, authConfig, templates)
-
-	// Create HTTP server
-	mux := http.NewServeMux()
-
-	// Dashboard route
-	mux.HandleFunc("/", authConfig.BasicAuthMiddleware(handler.Dashboard))
-
-	// API routes for service control
-	mux.HandleFunc("/api/services/", authConfig.BasicAuthMiddleware(handler.ServiceControl))
+	// Listening sockets, handed over by the process this one replaces when
+	// started by an upgrade
+	sockets := inheritSockets()
 
-	// API status route
-	mux.HandleFunc("/api/services/status", authConfig.BasicAuthMiddleware(handler.ServiceStatus))
+	// Workers serving requests run until the panel has drained
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
 
-	// Static files from embedded FS with caching headers
-	staticFS, err := fs.Sub(web.StaticFS, "static")
+	p, err := newPanel(ctx, cfg, logger, panelOptions{logLevel: logLevel, accessLog: accessLog, sockets: sockets})
 	if err != nil {
-		logger.Error("failed to create static file subsystem", "error", err)
+		logger.Error("failed to start", "error", err)
 		os.Exit(1)
 	}
-	mux.Handle("/static/", http.StripPrefix("/static/", cacheControlMiddleware(http.FileServer(http.FS(staticFS)))))
+	defer p.Close()
+	server := p.server
 
-	// Apply middleware chain
-	muxWithMiddleware := panicRecoveryMiddleware(logger)(
-		requestLoggingMiddleware(logger)(
-			rateLimitMiddleware(logger)(
-				securityHeadersMiddleware(mux))))
+	// Channel to listen for interrupt and upgrade signals
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
 
-	// Configure HTTP server with timeouts and limits
-	server := &http.Server{
-		Addr:         config.Host + ":" + strconv.Itoa(config.Port),
-		Handler:      muxWithMiddleware,
-		ReadTimeout:  config.ReadTimeout,
-		WriteTimeout: config.WriteTimeout,
-		IdleTimeout:  60 * time.Second,
-		// Limit request body size to prevent DoS
-		MaxHeaderBytes: 1 << 20, // 1MB
+	ln, err := sockets.Listen("tcp", server.Addr)
+	if err != nil {
+		logger.Error("server failed to start", "error", err)
+		os.Exit(1)
 	}
-
-	// Channel to listen for interrupt signals
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	var quicConn net.PacketConn
+	if p.http3 != nil {
+		// HTTP/3 shares the port, over UDP
+		if quicConn, err = sockets.ListenPacket("udp", server.Addr); err != nil {
+			logger.Error("HTTP/3 listener failed to start", "error", err)
+			os.Exit(1)
+		}
+	}
+	sockets.closeUnused(logger)
 
 	// Start server in a goroutine
 	go func() {
 		logger.Info("starting Service Control Panel",
 			"address", server.Addr,
-			"allowed_services", config.AllowedServices)
-
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			"tls", server.TLSConfig != nil,
+			"http3", p.http3 != nil,
+			"allowed_services", cfg.ServiceNames())
+
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(ln, "", "")
+		} else {
+			err = server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("server failed to start", "error", err)
 			os.Exit(1)
 		}
 	}()
+	if quicConn != nil {
+		go func() {
+			if err := p.http3.Serve(quicConn); err != nil && err != http.ErrServerClosed {
+				logger.Error("HTTP/3 listener failed", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	notifyReady(logger)
+
+	// Wait for an interrupt signal, or an upgrade that handed the sockets
+	// to a new process
+	for sig := range signals {
+		if sig != syscall.SIGUSR2 {
+			logger.Info("received shutdown signal, shutting down gracefully...")
+			break
+		}
+		logger.Info("received upgrade signal, starting the installed binary")
+		if err := sockets.upgrade(logger); err != nil {
+			logger.Error("upgrade failed, continuing to serve", "error", err)
+			continue
+		}
+		// The new process runs rules, notifications and exporters from now
+		p.stopIntegrations()
+		sockets.handOff()
+		logger.Info("new process serving, shutting down gracefully...")
+		break
+	}
 
-	// Wait for interrupt signal
-	<-done
-	logger.Info("received shutdown signal, shutting down gracefully...")
-
-	// Create context with timeout for graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Attempt graceful shutdown: event streams end so browsers reconnect,
+	// requests in flight are answered and actions handed out as jobs finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-
-	// Attempt graceful shutdown
-	if err := server.Shutdown(ctx); err != nil {
+	if err := p.shutdown(shutdownCtx); err != nil {
 		logger.Error("server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
@@ -211,107 +246,285 @@ func main() {
 	logger.Info("server shutdown complete")
 }
 
-// Rate limiter for IP-based rate limiting
-type rateLimiter struct {
-	mu      sync.RWMutex
-	clients map[string]*clientLimiter
+// configureServices applies the allowlist, per-service settings and
+// maintenance windows of cfg, at startup and after configuration changes
+// through the API
+func configureServices(sm *service.ServiceManager, cfg *config.Config) {
+	sm.SetServices(cfg.ServiceNames())
+	for _, svc := range cfg.Services {
+		minFree := make([]service.FreeSpace, len(svc.MinFree))
+		for i, check := range svc.MinFree {
+			minFree[i] = service.FreeSpace{Path: check.Path, Min: uint64(check.Min)}
+		}
+		sm.SetMetadata(svc.Name, service.Metadata{
+			Group:       svc.Group,
+			DisplayName: svc.DisplayName,
+			Description: svc.Description,
+			Icon:        svc.Icon,
+			URL:         svc.URL,
+			Protected:   svc.Protected,
+			Exclusive:   svc.Exclusive,
+			EnvOptions:  svc.EnvOverrides,
+			MinFree:     minFree,
+		})
+	}
+	sm.SetActionTimeout("", time.Duration(cfg.ActionTimeout))
+	for _, svc := range cfg.Services {
+		if svc.ActionTimeout > 0 {
+			sm.SetActionTimeout(svc.Name, time.Duration(svc.ActionTimeout))
+		}
+	}
+
+	windows := make([]service.MaintenanceWindow, 0, len(cfg.Maintenance))
+	for _, w := range cfg.Maintenance {
+		// Schedules were checked by cfg.Validate
+		sched, _ := schedule.Parse(w.Schedule)
+		services := make([]string, len(w.Services))
+		for i, name := range w.Services {
+			services[i] = config.NormalizeServiceName(name)
+		}
+		windows = append(windows, service.MaintenanceWindow{Name: w.Name, Schedule: sched, Duration: time.Duration(w.Duration), Services: services})
+	}
+	sm.SetMaintenanceWindows(windows)
 }
 
-type clientLimiter struct {
-	requests []time.Time
+// newCertManager creates the ACME certificate manager with the configured
+// DNS provider for dns-01 challenges
+func newCertManager(a config.ACMEConfig, plugins []*plugin.Plugin, logger *slog.Logger) (*acme.Manager, error) {
+	acmeCfg := acme.Config{
+		Domains:     a.Domains,
+		Email:       a.Email,
+		Directory:   a.Directory,
+		CacheDir:    a.CacheDir,
+		Challenge:   a.Challenge,
+		RenewBefore: time.Duration(a.RenewBefore),
+	}
+	if a.Challenge == acme.ChallengeDNS {
+		if a.DNSProvider == "cloudflare" {
+			acmeCfg.DNS = acme.NewCloudflare(a.CloudflareToken)
+		} else {
+			p, ok := plugin.Find(plugins, a.DNSProvider)
+			if !ok || !p.Provides(plugin.KindDNS) {
+				return nil, fmt.Errorf("no dns plugin named %q", a.DNSProvider)
+			}
+			acmeCfg.DNS = acme.NewPluginDNS(p)
+		}
+	}
+	return acme.NewManager(acmeCfg, logger), nil
 }
 
-func newRateLimiter() *rateLimiter {
-	return &rateLimiter{
-		clients: make(map[string]*clientLimiter),
+// httpsRedirect sends plain HTTP requests to the same URL over HTTPS
+func httpsRedirect(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// newDispatcher creates the notification dispatcher with every configured
+// channel and notifier plugin
+func newDispatcher(cfg config.Notifications, plugins []*plugin.Plugin, logger *slog.Logger) (*notify.Dispatcher, error) {
+	dispatcher := notify.NewDispatcher(logger)
+
+	if cfg.SMTP != nil {
+		smtpNotifier, err := notify.NewSMTPNotifier(*cfg.SMTP)
+		if err != nil {
+			return nil, err
+		}
+		dispatcher.Add(smtpNotifier, cfg.SMTP.Events)
+	}
+
+	if cfg.Discord != nil {
+		dispatcher.Add(notify.NewDiscordNotifier(*cfg.Discord), cfg.Discord.Events)
+	}
+
+	if cfg.Slack != nil {
+		dispatcher.Add(notify.NewSlackNotifier(*cfg.Slack), cfg.Slack.Events)
+	}
+
+	for _, p := range plugins {
+		if p.Provides(plugin.KindNotifier) {
+			dispatcher.Add(notify.NewPluginNotifier(p), p.Events)
+		}
 	}
+
+	return dispatcher, nil
 }
 
-// allow checks if a client is allowed to make a request
-func (rl *rateLimiter) allow(clientIP string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// newRuleEngine compiles the configured automation rules
+func newRuleEngine(configs []config.RuleConfig, services *service.ServiceManager, recorder *audit.Recorder, logger *slog.Logger) (*rules.Engine, error) {
+	compiled := make([]rules.Rule, 0, len(configs))
+	for _, rc := range configs {
+		// Expressions were checked by cfg.Validate
+		when, _ := expr.Compile(rc.When)
+		rule := rules.Rule{Name: rc.Name, When: when, Notify: rc.Notify, Cooldown: time.Duration(rc.Cooldown)}
+		for _, action := range rc.Actions {
+			rule.Actions = append(rule.Actions, rules.Action{Action: action.Action, Service: config.NormalizeServiceName(action.Service)})
+		}
+		compiled = append(compiled, rule)
+	}
+	return rules.NewEngine(compiled, services, recorder, logger)
+}
 
-	now := time.Now()
-	windowStart := now.Add(-time.Minute) // 1 minute window
+// rateLimiters groups the per-class request limiters
+type rateLimiters struct {
+	status      *ratelimit.Limiter
+	control     *ratelimit.Limiter
+	authFailure *ratelimit.Limiter
+	// userStatus and userControl are nil when per-user limits are off
+	userStatus  *ratelimit.Limiter
+	userControl *ratelimit.Limiter
+	endpoints   []endpointLimiter
+	// basePath is trimmed from request paths before matching endpoints
+	basePath string
+}
+
+// endpointLimiter is the budget of requests under a path
+type endpointLimiter struct {
+	path    string
+	perUser bool
+	limiter *ratelimit.Limiter
+}
 
-	// Get or create client limiter
-	client, exists := rl.clients[clientIP]
-	if !exists {
-		client = &clientLimiter{requests: []time.Time{}}
-		rl.clients[clientIP] = client
+func newRateLimiters(limits config.RateLimits, basePath string) *rateLimiters {
+	rl := &rateLimiters{
+		status:      ratelimit.New(limits.Status.PerMinute, limits.Status.Burst),
+		control:     ratelimit.New(limits.Control.PerMinute, limits.Control.Burst),
+		authFailure: ratelimit.New(limits.AuthFailure.PerMinute, limits.AuthFailure.Burst),
+		basePath:    basePath,
+	}
+	if limits.UserStatus.PerMinute > 0 {
+		rl.userStatus = ratelimit.New(limits.UserStatus.PerMinute, limits.UserStatus.Burst)
+	}
+	if limits.UserControl.PerMinute > 0 {
+		rl.userControl = ratelimit.New(limits.UserControl.PerMinute, limits.UserControl.Burst)
 	}
+	for _, e := range limits.Endpoints {
+		rl.endpoints = append(rl.endpoints, endpointLimiter{
+			path:    e.Path,
+			perUser: e.PerUser,
+			limiter: ratelimit.New(e.PerMinute, e.Burst),
+		})
+	}
+	return rl
+}
 
-	// Remove old requests outside the window
-	validRequests := make([]time.Time, 0, len(client.requests))
-	for _, req := range client.requests {
-		if req.After(windowStart) {
-			validRequests = append(validRequests, req)
+// runEviction drops idle buckets from all limiters until ctx is cancelled
+func (rl *rateLimiters) runEviction(ctx context.Context) {
+	all := []*ratelimit.Limiter{rl.status, rl.control, rl.authFailure}
+	for _, l := range []*ratelimit.Limiter{rl.userStatus, rl.userControl} {
+		if l != nil {
+			all = append(all, l)
 		}
 	}
-	client.requests = validRequests
+	for _, e := range rl.endpoints {
+		all = append(all, e.limiter)
+	}
+	for _, l := range all {
+		go l.RunEviction(ctx, time.Minute)
+	}
+}
 
-	// Check rate limit (100 requests per minute)
-	if len(client.requests) >= 100 {
-		return false
+// allowEndpoints consumes a token for key from every endpoint budget
+// matching r that is keyed per user (perUser) or per client IP
+func (rl *rateLimiters) allowEndpoints(r *http.Request, key string, perUser bool) (bool, time.Duration) {
+	path := strings.TrimPrefix(r.URL.Path, rl.basePath)
+	for _, e := range rl.endpoints {
+		if e.perUser != perUser || !strings.HasPrefix(path, e.path) {
+			continue
+		}
+		if ok, retryAfter := e.limiter.Allow(key); !ok {
+			return false, retryAfter
+		}
 	}
+	return true, 0
+}
 
-	// Add current request
-	client.requests = append(client.requests, now)
-	return true
+// allowUser applies the per-user budgets to an authenticated request
+func (rl *rateLimiters) allowUser(r *http.Request, username string) (bool, time.Duration) {
+	limiter := rl.userStatus
+	if isControlRequest(r) {
+		limiter = rl.userControl
+	}
+	if limiter != nil {
+		if ok, retryAfter := limiter.Allow(username); !ok {
+			return false, retryAfter
+		}
+	}
+	return rl.allowEndpoints(r, username, true)
 }
 
-// Global rate limiter instance
-var globalRateLimiter = newRateLimiter()
+// isControlRequest reports whether r changes service state; GraphQL
+// queries are reads even when POSTed. Paths still carry the base path here.
+func isControlRequest(r *http.Request) bool {
+	return r.Method != http.MethodGet && r.Method != http.MethodHead && !strings.HasSuffix(r.URL.Path, "/api/graphql")
+}
 
-// rateLimitMiddleware implements IP-based rate limiting
-func rateLimitMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+// rateLimitMiddleware implements IP-based token-bucket rate limiting with
+// separate budgets for reads, control actions, failed logins and configured
+// endpoints; per-user budgets are applied after authentication
+func rateLimitMiddleware(limiters *rateLimiters, logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientIP := getClientIP(r)
+			clientIP := netutil.ClientIP(r)
 
-			if !globalRateLimiter.allow(clientIP) {
-				logger.Warn("rate limit exceeded",
+			reject := func(class string, retryAfter time.Duration) {
+				logger.WarnContext(r.Context(), "rate limit exceeded",
 					"client_ip", clientIP,
+					"class", class,
 					"url", r.URL.Path,
 					"method", r.Method)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
 				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			}
+
+			// Clients that used up their failed-login budget are blocked entirely
+			if exhausted, retryAfter := limiters.authFailure.Exhausted(clientIP); exhausted {
+				reject("auth_failure", retryAfter)
 				return
 			}
 
-			next.ServeHTTP(w, r)
-		})
-	}
-}
+			limiter, class := limiters.status, "status"
+			if isControlRequest(r) {
+				limiter, class = limiters.control, "control"
+			}
+			if ok, retryAfter := limiter.Allow(clientIP); !ok {
+				reject(class, retryAfter)
+				return
+			}
+			if ok, retryAfter := limiters.allowEndpoints(r, clientIP, false); !ok {
+				reject("endpoint", retryAfter)
+				return
+			}
 
-// getClientIP extracts the real client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies/load balancers)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP if multiple are present
-		if idx := strings.Index(xff, ","); idx > 0 {
-			return strings.TrimSpace(xff[:idx])
-		}
-		return strings.TrimSpace(xff)
-	}
+			wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapper, r)
 
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
+			// Wrong webhook secrets count as failed logins too
+			isHook := strings.HasPrefix(strings.TrimPrefix(r.URL.Path, limiters.basePath), "/hooks/")
+			if wrapper.statusCode == http.StatusUnauthorized && (r.Header.Get("Authorization") != "" || isHook) {
+				limiters.authFailure.Allow(clientIP)
+			}
+		})
 	}
-
-	// Fall back to RemoteAddr
-	return strings.Split(r.RemoteAddr, ":")[0]
 }
 
-// cacheControlMiddleware adds appropriate caching headers for static assets
-func cacheControlMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Add caching for static assets (1 day)
-		// These are embedded in the binary, so they won't change without a redeploy
-		w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
-		next.ServeHTTP(w, r)
-	})
+// basePathHandler serves next under prefix, redirecting the bare prefix to
+// its trailing-slash form; requests outside the prefix get 404
+func basePathHandler(prefix string, next http.Handler) http.Handler {
+	if prefix == "" {
+		return next
+	}
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, next))
+	mux.Handle(prefix, http.RedirectHandler(prefix+"/", http.StatusMovedPermanently))
+	return mux
 }
 
 // panicRecoveryMiddleware recovers from panics and logs them
@@ -320,7 +533,7 @@ func panicRecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handle
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					logger.Error("panic recovered in HTTP handler",
+					logger.ErrorContext(r.Context(), "panic recovered in HTTP handler",
 						"panic", err,
 						"url", r.URL.Path,
 						"method", r.Method,
@@ -335,32 +548,47 @@ func panicRecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handle
 	}
 }
 
-// requestLoggingMiddleware logs all HTTP requests
-func requestLoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+// requestLoggingMiddleware logs all HTTP requests to the access log when one
+// is configured, otherwise to the application log
+func requestLoggingMiddleware(logger *slog.Logger, accessLog *logging.AccessLog) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			// Create a response writer wrapper to capture status code
+			// Create a response writer wrapper to capture status code and size
 			wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 			next.ServeHTTP(wrapper, r)
 
-			logger.Info("HTTP request",
+			if accessLog != nil {
+				entry := logging.NewAccessEntry(r, netutil.ClientIP(r))
+				entry.Time = start
+				entry.Status = wrapper.statusCode
+				entry.Bytes = wrapper.bytes
+				entry.Duration = time.Since(start)
+				entry.RequestID = requestid.FromContext(r.Context())
+				accessLog.Log(entry)
+				return
+			}
+
+			logger.InfoContext(r.Context(), "HTTP request",
 				"method", r.Method,
 				"url", r.URL.Path,
 				"status", wrapper.statusCode,
 				"duration", time.Since(start),
 				"remote_addr", r.RemoteAddr,
+				"client_ip", netutil.ClientIP(r),
 				"user_agent", r.Header.Get("User-Agent"))
 		})
 	}
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and
+// response size
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -368,6 +596,33 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// bodyLimitMiddleware caps request bodies at limit bytes. Larger declared
+// bodies are rejected up front; handlers reading past the cap of a chunked
+// one get an *http.MaxBytesError, which they answer with 413 too.
+func bodyLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // securityHeadersMiddleware adds security headers to all responses
 func securityHeadersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -383,9 +638,11 @@ func securityHeadersMiddleware(next http.Handler) http.Handler {
 		// Referrer policy for privacy
 		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
 
-		// Content Security Policy for additional protection
+		// Content Security Policy for additional protection. All assets are
+		// embedded, so nothing is loaded from third-party origins; inline
+		// styles are only used for the theme accent colour.
 		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; script-src 'self' 'unsafe-inline' https://cdn.tailwindcss.com; style-src 'self' 'unsafe-inline' https://cdn.tailwindcss.com; img-src 'self' data:;")
+			"default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; connect-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'")
 
 		// HSTS (HTTP Strict Transport Security) - only if using HTTPS
 		// w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
@@ -393,3 +650,41 @@ func securityHeadersMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// warnNotInstalled logs allowed services the init system has no unit for,
+// which usually means a typo in the allowlist
+func warnNotInstalled(ctx context.Context, sm *service.ServiceManager, logger *slog.Logger) {
+	for _, s := range sm.GetAllServicesStatus(ctx) {
+		if s.Status == service.StateNotInstalled {
+			logger.Warn("allowed service is not installed", "service", s.Name)
+		}
+	}
+}
+
+// newBackend creates the backend described by a validated configuration
+func newBackend(b config.BackendConfig, plugins []*plugin.Plugin, logger *slog.Logger) (service.Backend, error) {
+	switch b.Type {
+	case "openrc":
+		openrc := service.NewOpenRCBackend(b.OpenRC.LogDir, logger)
+		openrc.SetUser(b.OpenRC.User)
+		return openrc, nil
+	case "runit":
+		return service.NewRunitBackend(b.Supervisor.ServiceDir, b.Supervisor.LogDir, logger), nil
+	case "s6":
+		return service.NewS6Backend(b.Supervisor.ServiceDir, b.Supervisor.LogDir, logger), nil
+	case "kubernetes":
+		kube := service.NewKubernetesBackend(b.Kubernetes.Namespace, b.Kubernetes.Replicas, logger)
+		kube.SetContext(b.Kubernetes.Context)
+		return kube, nil
+	case "compose":
+		return service.NewComposeBackend(b.Compose.Command, b.Compose.StacksDir, logger), nil
+	case "plugin":
+		p, ok := plugin.Find(plugins, b.Plugin)
+		if !ok || !p.Provides(plugin.KindBackend) {
+			return nil, fmt.Errorf("no backend plugin named %q", b.Plugin)
+		}
+		return service.NewPluginBackend(p), nil
+	default:
+		return service.NewSystemdBackend(logger), nil
+	}
+}
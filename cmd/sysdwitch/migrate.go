@@ -0,0 +1,106 @@
+// cmd/sysdwitch/migrate.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/store"
+)
+
+// runMigrateConfig converts an environment-only deployment into a config
+// file plus a store holding hashed credentials
+func runMigrateConfig(args []string) int {
+	fset := flag.NewFlagSet("migrate-config", flag.ContinueOnError)
+	configPath := fset.String("config", "configs/sysdwitch.json", "config file to write")
+	storePath := fset.String("store", "data/store.json", "credential store to write")
+	force := fset.Bool("force", false, "overwrite an existing config file")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := migrateConfig(*configPath, *storePath, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate-config: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func migrateConfig(configPath, storePath string, force bool) error {
+	cfg, err := config.Load("")
+	if err != nil {
+		return fmt.Errorf("current environment is invalid: %w", err)
+	}
+	if cfg.Auth.Username == "" || cfg.Auth.Password == "" {
+		return errors.New("ADMIN_USER and ADMIN_PASS must be set to migrate credentials")
+	}
+
+	if !force {
+		if _, err := os.Stat(configPath); err == nil {
+			return fmt.Errorf("%s already exists (use -force to overwrite)", configPath)
+		}
+	}
+
+	configAbs, err := filepath.Abs(configPath)
+	if err != nil {
+		return err
+	}
+	storeAbs, err := filepath.Abs(storePath)
+	if err != nil {
+		return err
+	}
+
+	// Hash the admin password into the store
+	hash, err := auth.HashPassword(cfg.Auth.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	fileStore, err := store.OpenFile(storeAbs)
+	if err != nil {
+		return err
+	}
+	defer fileStore.Close()
+
+	err = fileStore.PutUser(context.Background(), store.User{
+		Name:         cfg.Auth.Username,
+		PasswordHash: hash,
+		Role:         store.RoleAdmin,
+		CreatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store user: %w", err)
+	}
+
+	// Write the config file pointing at the store
+	cfg.StorePath = storeAbs
+	if err := os.MkdirAll(filepath.Dir(configAbs), 0o755); err != nil {
+		return err
+	}
+	if err := cfg.Save(configAbs); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Printf("Wrote config file:  %s\n", configAbs)
+	fmt.Printf("Wrote credentials:  %s (user %q)\n", storeAbs, cfg.Auth.Username)
+	fmt.Println()
+	fmt.Println("Update your unit / EnvironmentFile:")
+	fmt.Printf("  add     CONFIG_FILE=%s\n", configAbs)
+	for _, key := range []string{"ADMIN_USER", "ADMIN_PASS", "ALLOWED_SERVICES", "HOST", "PORT", "AUTH_PROXY_TRUSTED", "AUTH_PROXY_HEADER"} {
+		if os.Getenv(key) != "" {
+			fmt.Printf("  remove  %s (now in config file or store)\n", key)
+		}
+	}
+	fmt.Printf("  ensure  ReadWritePaths= includes %s\n", filepath.Dir(storeAbs))
+	fmt.Println()
+	fmt.Println("Then run: systemctl --user daemon-reload && systemctl --user restart sysdwitch")
+
+	return nil
+}
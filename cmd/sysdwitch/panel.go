@@ -0,0 +1,411 @@
+// cmd/sysdwitch/panel.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/desired"
+	"sysdwitch/internal/events"
+	"sysdwitch/internal/handlers"
+	"sysdwitch/internal/hoststats"
+	"sysdwitch/internal/influx"
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/logging"
+	"sysdwitch/internal/netutil"
+	"sysdwitch/internal/plugin"
+	"sysdwitch/internal/service"
+	"sysdwitch/internal/snmp"
+	"sysdwitch/internal/statsd"
+	"sysdwitch/internal/store"
+	"sysdwitch/internal/update"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// panelOptions are the parts of a panel not taken from its configuration
+type panelOptions struct {
+	// backend replaces the one configured, as in tests and the bench command
+	backend service.Backend
+	// logLevel is changed at runtime through the API; nil keeps the level
+	logLevel *slog.LevelVar
+	// accessLog, when not nil, gets a line per request
+	accessLog *logging.AccessLog
+	// sockets opens the listeners other than the main one; nil opens fresh
+	// sockets
+	sockets *sockets
+}
+
+// panel is the application assembled from its configuration: the
+// components, their background workers and the HTTP server serving them,
+// which is not listening yet
+type panel struct {
+	logger   *slog.Logger
+	recorder *audit.Recorder
+	users    store.Store
+	services *service.ServiceManager
+	handler  *handlers.Handler
+	jobs     *jobs.Manager
+	events   *events.Hub
+
+	server *http.Server
+	// challengeServer answers ACME HTTP-01 challenges; nil unless enabled
+	challengeServer *http.Server
+	// http3 serves the same handler over QUIC; nil unless enabled
+	http3 *http3.Server
+
+	// stopIntegrations ends the loops that act or report on their own,
+	// such as rules, notifications and exporters, which must not run twice
+	// once another process took over
+	stopIntegrations context.CancelFunc
+	closers          []io.Closer
+}
+
+// newPanel assembles the panel for cfg. Workers needed to answer requests,
+// such as the job workers and the state refresher, run until ctx is done;
+// integrations stop earlier, with stopIntegrations.
+func newPanel(ctx context.Context, cfg *AppConfig, logger *slog.Logger, opts panelOptions) (_ *panel, err error) {
+	if opts.sockets == nil {
+		opts.sockets = newSockets()
+	}
+	if opts.logLevel == nil {
+		opts.logLevel = new(slog.LevelVar)
+	}
+	integrations, stopIntegrations := context.WithCancel(ctx)
+	p := &panel{logger: logger, stopIntegrations: stopIntegrations}
+	defer func() {
+		if err != nil {
+			stopIntegrations()
+			p.Close()
+		}
+	}()
+
+	// Open the persistent store if configured
+	if cfg.StoreDriver == "postgres" || cfg.StorePath != "" {
+		location := cfg.StorePath
+		if cfg.StoreDriver == "postgres" {
+			location = cfg.StoreDSN
+		}
+		openCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		p.users, err = store.Open(openCtx, cfg.StoreDriver, location)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open store: %w", err)
+		}
+		p.closers = append(p.closers, p.users)
+	}
+
+	// Initialize components
+	p.recorder = audit.NewRecorder(logger)
+
+	var plugins []*plugin.Plugin
+	if cfg.Plugins.Dir != "" {
+		plugins, err = plugin.Discover(ctx, cfg.Plugins.Dir, time.Duration(cfg.Plugins.Timeout), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load plugins from %s: %w", cfg.Plugins.Dir, err)
+		}
+	}
+
+	notifier, err := newDispatcher(cfg.Notifications, plugins, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize notifications: %w", err)
+	}
+	if notifier.Len() > 0 {
+		p.recorder.Subscribe(notifier.Enqueue)
+		go notifier.Run(integrations)
+	}
+
+	authConfig, err := auth.NewAuthConfig(cfg.Auth, p.users, p.recorder, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize auth config: %w", err)
+	}
+	for _, plug := range plugins {
+		if plug.Provides(plugin.KindAuth) {
+			authConfig.Providers = append(authConfig.Providers, plug)
+		}
+	}
+
+	p.services = service.NewServiceManager(cfg.ServiceNames(), logger)
+	configureServices(p.services, &cfg.Config)
+	backend := opts.backend
+	if backend == nil {
+		if backend, err = newBackend(cfg.Backend, plugins, logger); err != nil {
+			return nil, fmt.Errorf("failed to initialize backend: %w", err)
+		}
+		if len(cfg.Backends) > 0 {
+			multi := service.NewMultiBackend(backend)
+			for _, b := range cfg.Backends {
+				extra, err := newBackend(b, plugins, logger)
+				if err != nil {
+					return nil, fmt.Errorf("failed to initialize backend %s: %w", b.Name, err)
+				}
+				multi.Add(b.Name, extra)
+			}
+			backend = multi
+		}
+	}
+	p.services.SetBackend(backend)
+	p.services.SetDryRun(cfg.DryRun)
+	p.services.SetFlapDetection(cfg.Flapping.Threshold, time.Duration(cfg.Flapping.Window))
+	p.services.SetRecorder(p.recorder)
+	go warnNotInstalled(ctx, p.services, logger)
+	if len(cfg.Rules) > 0 {
+		engine, err := newRuleEngine(cfg.Rules, p.services, p.recorder, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize rules: %w", err)
+		}
+		p.recorder.Subscribe(engine.Enqueue)
+		go engine.Run(integrations, time.Duration(cfg.RulesInterval))
+	}
+	var reconciler *desired.Reconciler
+	if ds := cfg.DesiredState; ds.File != "" {
+		if _, err := desired.Load(ds.File); err != nil {
+			return nil, fmt.Errorf("invalid desired state file: %w", err)
+		}
+		reconciler = desired.NewReconciler(ds.File, ds.GitPull, ds.ReportOnly, p.services, p.recorder, logger)
+		go reconciler.Run(integrations, time.Duration(ds.Interval))
+	}
+	if cfg.Influx.URL != "" {
+		host := cfg.Influx.Host
+		if host == "" {
+			host, _ = os.Hostname()
+		}
+		exporter := influx.NewExporter(cfg.Influx.URL, cfg.Influx.Token, host, p.services, logger)
+		p.recorder.Subscribe(exporter.Record)
+		go exporter.Run(integrations, time.Duration(cfg.Influx.Interval))
+	}
+	if cfg.StatsD.Addr != "" {
+		emitter, err := statsd.Dial(cfg.StatsD.Addr, cfg.StatsD.Prefix, cfg.StatsD.Tags, cfg.StatsD.Format == "dogstatsd", p.services, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize statsd at %s: %w", cfg.StatsD.Addr, err)
+		}
+		p.closers = append(p.closers, emitter)
+		p.recorder.Subscribe(emitter.Record)
+		go emitter.Run(integrations, time.Duration(cfg.StatsD.Interval))
+	}
+	if cfg.SNMP.Listen != "" {
+		// The OID was checked by cfg.Validate
+		base, _ := snmp.ParseOID(cfg.SNMP.OID)
+		agent := snmp.NewAgent(cfg.SNMP.Community, base, "sysdwitch "+version, p.services.GetAllServicesStatus, logger)
+		conn, err := opts.sockets.ListenPacket("udp", cfg.SNMP.Listen)
+		if err != nil {
+			logger.Error("SNMP agent failed", "error", err, "address", cfg.SNMP.Listen)
+		} else {
+			go func() {
+				if err := agent.ServeConn(integrations, conn); err != nil {
+					logger.Error("SNMP agent failed", "error", err, "address", cfg.SNMP.Listen)
+				}
+			}()
+		}
+	}
+	if cfg.StatusInterval > 0 {
+		go p.services.RunRefresher(ctx, time.Duration(cfg.StatusInterval))
+	}
+	if cfg.Metrics.Interval > 0 {
+		go p.services.RunSampler(ctx, time.Duration(cfg.Metrics.Interval), cfg.Metrics.Samples)
+	}
+	if cfg.DryRun {
+		logger.Warn("dry-run mode enabled: control actions will not be executed")
+	}
+
+	// Static files and templates, embedded or in dev mode from disk
+	if cfg.Dev {
+		logger.Warn("dev mode enabled: serving templates and static files from disk", "dir", devWebDir)
+	}
+	assets, templates, err := loadWeb(cfg.BasePath, cfg.Dev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load web files: %w", err)
+	}
+
+	// Store references in config for use in handlers
+	cfg.AuthConfig = authConfig
+	cfg.ServiceManager = p.services
+
+	// Create handler instance
+	handler := handlers.NewHandler(logger, p.services, authConfig, templates, p.recorder)
+	p.handler = handler
+	handler.SetReadOnly(cfg.ReadOnly, cfg.ReadOnlyMessage)
+	handler.SetPublicBadges(cfg.PublicBadges)
+	handler.SetPublicStatusServices(cfg.PublicStatus)
+	handler.SetRefreshInterval(time.Duration(cfg.RefreshInterval))
+	handler.SetBasePath(cfg.BasePath)
+	handler.SetGroups(cfg.Groups)
+	handler.SetProfiles(cfg.Profiles)
+	handler.SetTasks(cfg.Tasks)
+	handler.SetHooks(cfg.Hooks)
+	handler.SetDesiredState(reconciler)
+	handler.SetPowerActions(cfg.PowerActions)
+	handler.SetWakeHosts(cfg.WakeHosts)
+	handler.SetHostStats(hoststats.NewCollector(cfg.HostStats.Mounts))
+	handler.SetGraphQL(cfg.GraphQL)
+	handler.SetInventoryHost(cfg.InventoryHost)
+	handler.SetTheme(cfg.Theme)
+	handler.SetConfig(&cfg.Config, cfg.Path, func(changed *config.Config) {
+		configureServices(p.services, changed)
+		handler.SetGroups(changed.Groups)
+	})
+	handler.SetLogLevel(opts.logLevel)
+	p.jobs = jobs.NewManager()
+	go p.jobs.Run(ctx, cfg.JobWorkers)
+	handler.SetJobs(p.jobs, time.Duration(cfg.AsyncAfter))
+	p.events = events.NewHub(cfg.EventBuffer, logger)
+	p.recorder.Subscribe(p.events.Publish)
+	handler.SetEvents(p.events)
+	if cfg.EventHistory > 0 {
+		history := events.NewHistory(cfg.EventHistory)
+		p.recorder.Subscribe(history.Record)
+		handler.SetHistory(history)
+	}
+	handler.SetBuildInfo(handlers.BuildInfo{Version: version, Commit: commit, BuildTime: buildTime})
+	if cfg.UpdateCheck {
+		checker := update.NewChecker(version, update.DefaultReleasesURL, logger)
+		handler.SetUpdateChecker(checker)
+		go checker.Run(integrations, 24*time.Hour)
+	}
+
+	// Create HTTP server
+	mux := http.NewServeMux()
+	registerRoutes(mux, handler, authConfig, assets)
+
+	// Profiling, on the main listener for admins and/or a separate one
+	if cfg.Debug.Pprof {
+		registerPprof(mux, authConfig.AdminOnly)
+	}
+	if cfg.Debug.Listen != "" {
+		if ln, err := opts.sockets.Listen("tcp", cfg.Debug.Listen); err != nil {
+			logger.Error("debug listener failed", "error", err, "address", cfg.Debug.Listen)
+		} else {
+			go runDebugListener(ctx, ln, handler, logger)
+		}
+	}
+
+	// Rate limiters with background eviction of idle clients
+	limiters := newRateLimiters(cfg.RateLimits, cfg.BasePath)
+	limiters.runEviction(ctx)
+	authConfig.UserLimit = limiters.allowUser
+
+	// Only honor forwarding headers from configured proxies
+	trustedProxies, err := netutil.ParsePrefixes(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	clientIPResolver := netutil.NewClientIPResolver(trustedProxies)
+
+	// Configure HTTP server with the middleware chain, timeouts and limits
+	p.server = &http.Server{
+		Addr:         cfg.Host + ":" + strconv.Itoa(cfg.Port),
+		Handler:      withMiddleware(mux, cfg.BasePath, cfg.MaxBodyBytes, limiters, clientIPResolver, logger, opts.accessLog),
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.IdleTimeout),
+		// Limit request header size to prevent DoS; bodies are capped by
+		// bodyLimitMiddleware
+		MaxHeaderBytes: 1 << 20, // 1MB
+	}
+	// Event streams end on shutdown, so browsers reconnect
+	p.server.RegisterOnShutdown(p.events.Close)
+
+	// Serve HTTPS with automatic certificates when ACME domains are set
+	if cfg.ACME.Enabled() {
+		certs, err := newCertManager(cfg.ACME, plugins, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up ACME: %w", err)
+		}
+		p.server.TLSConfig = certs.TLSConfig()
+		go certs.Run(integrations)
+
+		if cfg.ACME.HTTPPort != 0 {
+			challengeServer := &http.Server{
+				Addr:              cfg.Host + ":" + strconv.Itoa(cfg.ACME.HTTPPort),
+				Handler:           certs.HTTPHandler(httpsRedirect(cfg.Port)),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			ln, err := opts.sockets.Listen("tcp", challengeServer.Addr)
+			if err != nil {
+				return nil, fmt.Errorf("ACME HTTP listener failed on %s: %w", challengeServer.Addr, err)
+			}
+			p.challengeServer = challengeServer
+			go func() {
+				if err := challengeServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+					logger.Error("ACME HTTP listener failed", "address", challengeServer.Addr, "error", err)
+					os.Exit(1)
+				}
+			}()
+		}
+	}
+
+	// HTTP/2 is negotiated over TLS on its own; h2c adds it to plain HTTP
+	if cfg.H2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		p.server.Protocols = protocols
+	}
+	if cfg.HTTP3 {
+		// Validated to come with ACME, so the server has TLS
+		p.http3 = newHTTP3Server(p.server)
+	}
+	return p, nil
+}
+
+// newHTTP3Server serves the handler of server over QUIC with its TLS
+// configuration, and has server announce it in Alt-Svc headers once
+// listening, so browsers switch on their next connection
+func newHTTP3Server(server *http.Server) *http3.Server {
+	h3 := &http3.Server{
+		Addr:           server.Addr,
+		Handler:        server.Handler,
+		TLSConfig:      server.TLSConfig,
+		IdleTimeout:    server.IdleTimeout,
+		MaxHeaderBytes: server.MaxHeaderBytes,
+	}
+	handler := server.Handler
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fails only until the QUIC listener is up
+		_ = h3.SetQUICHeaders(w.Header())
+		handler.ServeHTTP(w, r)
+	})
+	return h3
+}
+
+// shutdown stops the integrations, then answers the requests in flight
+// and waits for the actions handed out as jobs, until ctx is done
+func (p *panel) shutdown(ctx context.Context) error {
+	p.stopIntegrations()
+	if p.challengeServer != nil {
+		_ = p.challengeServer.Shutdown(ctx)
+	}
+	if err := p.server.Shutdown(ctx); err != nil {
+		return err
+	}
+	// After the main server, which ends the event streams on both
+	if p.http3 != nil {
+		if err := p.http3.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	if err := p.jobs.Drain(ctx); err != nil {
+		return fmt.Errorf("jobs still running: %w", err)
+	}
+	return nil
+}
+
+// Close releases the store and exporters once the panel stopped serving
+func (p *panel) Close() error {
+	var errs []error
+	for _, c := range p.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,163 @@
+// cmd/sysdwitch/routes.go
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/handlers"
+	"sysdwitch/internal/logging"
+	"sysdwitch/internal/netutil"
+	"sysdwitch/internal/requestid"
+	"sysdwitch/web"
+)
+
+// loadWeb sets up the static files and parses the templates, from the
+// embedded FS with static files hashed so their URLs change with them, or in
+// dev mode from disk
+func loadWeb(basePath string, dev bool) (*web.Assets, *handlers.Templates, error) {
+	templatesFS, staticRoot := fs.FS(web.TemplatesFS), fs.FS(web.StaticFS)
+	if dev {
+		templatesFS = os.DirFS(devWebDir)
+		staticRoot = templatesFS
+	}
+	staticFS, err := fs.Sub(staticRoot, "static")
+	if err != nil {
+		return nil, nil, fmt.Errorf("static file subsystem: %w", err)
+	}
+	assets := web.NewDevAssets(staticFS, basePath+"/static/")
+	if !dev {
+		if assets, err = web.NewAssets(staticFS, basePath+"/static/"); err != nil {
+			return nil, nil, fmt.Errorf("hashing static assets: %w", err)
+		}
+	}
+
+	templates, err := handlers.NewTemplates(templatesFS, template.FuncMap{
+		"asset":    assets.Path,
+		"url":      func(path string) string { return basePath + path },
+		"basePath": func() string { return basePath },
+	}, dev, "templates/*.html")
+	if err != nil {
+		return nil, nil, fmt.Errorf("template parsing failed: %w", err)
+	}
+	return assets, templates, nil
+}
+
+// registerRoutes mounts the panel's pages, API and static files on mux.
+// Optional listeners such as pprof are added by the caller.
+func registerRoutes(mux *http.ServeMux, handler *handlers.Handler, authConfig *auth.AuthConfig, assets *web.Assets) {
+	// Dashboard route
+	mux.HandleFunc("/", authConfig.BasicAuthMiddleware(handler.Dashboard))
+
+	// API routes for service control
+	mux.HandleFunc("/api/services/", authConfig.BasicAuthMiddleware(handler.ServiceControl))
+
+	// Server-rendered card fragments for in-place dashboard updates
+	mux.HandleFunc("/ui/services/", authConfig.BasicAuthMiddleware(handler.ServiceFragment))
+
+	// Per-service detail pages
+	mux.HandleFunc("/services/", authConfig.BasicAuthMiddleware(handler.ServiceDetail))
+
+	// API status route
+	mux.HandleFunc("/api/services/status", authConfig.BasicAuthMiddleware(handler.ServiceStatus))
+
+	// Failed-unit triage, covering units outside the allowlist read-only
+	mux.HandleFunc("/api/failed", authConfig.BasicAuthMiddleware(handler.Failed))
+	mux.HandleFunc("/api/host/stats", authConfig.BasicAuthMiddleware(handler.HostStats))
+	mux.HandleFunc("/ui/host/stats", authConfig.BasicAuthMiddleware(handler.HostStats))
+	mux.HandleFunc("/failed", authConfig.BasicAuthMiddleware(handler.Failed))
+
+	// Public status page (disabled unless PUBLIC_STATUS lists services)
+	mux.HandleFunc("/status", handler.PublicStatus)
+
+	// Unauthenticated liveness and readiness probes
+	mux.HandleFunc("/healthz", handler.Healthz)
+	mux.HandleFunc("/readyz", handler.Readyz)
+
+	// Inbound webhooks, authenticated by their own secrets
+	mux.HandleFunc("/hooks/", handler.Hook)
+
+	// Status badges (authenticated unless listed in PUBLIC_BADGES)
+	mux.HandleFunc("/badge/", handler.Badge)
+
+	// Profiles: ordered start/stop of service sets
+	mux.HandleFunc("/api/profiles", authConfig.BasicAuthMiddleware(handler.Profiles))
+	mux.HandleFunc("/api/profiles/", authConfig.BasicAuthMiddleware(handler.Profiles))
+
+	// Live audit events as server-sent events, and the latest state changes
+	// and actions
+	mux.HandleFunc("/api/events", authConfig.BasicAuthMiddleware(handler.Events))
+	mux.HandleFunc("/api/events/recent", authConfig.BasicAuthMiddleware(handler.RecentEvents))
+
+	// Status of actions that outlasted the async threshold
+	mux.HandleFunc("/api/jobs/", authConfig.BasicAuthMiddleware(handler.Job))
+
+	// Read-only GraphQL queries (disabled unless GRAPHQL is set)
+	mux.HandleFunc("/api/graphql", authConfig.BasicAuthMiddleware(handler.GraphQL))
+
+	// Nagios/Icinga plugin output per service
+	mux.HandleFunc("/api/check/", authConfig.BasicAuthMiddleware(handler.Check))
+
+	// Grafana JSON datasource
+	mux.HandleFunc("/api/grafana", authConfig.BasicAuthMiddleware(handler.Grafana))
+	mux.HandleFunc("/api/grafana/", authConfig.BasicAuthMiddleware(handler.Grafana))
+
+	// Zabbix low-level discovery and item data
+	mux.HandleFunc("/api/zabbix/", authConfig.BasicAuthMiddleware(handler.Zabbix))
+
+	// Compact status for homelab dashboard widgets
+	mux.HandleFunc("/api/widget", authConfig.BasicAuthMiddleware(handler.Widget))
+
+	// Wake-on-LAN for remote hosts, like power actions for admins only
+	mux.HandleFunc("/api/hosts", authConfig.AdminOnly(handler.Hosts))
+	mux.HandleFunc("/api/hosts/", authConfig.AdminOnly(handler.Hosts))
+
+	// Ansible dynamic inventory of this host's services
+	mux.HandleFunc("/api/inventory", authConfig.BasicAuthMiddleware(handler.Inventory))
+
+	// Drift from the desired-state file
+	mux.HandleFunc("/api/desired-state", authConfig.BasicAuthMiddleware(handler.DesiredState))
+
+	// Build and update information
+	mux.HandleFunc("/api/version", authConfig.BasicAuthMiddleware(handler.Version))
+
+	// Admin routes, for users with the admin role
+	mux.HandleFunc("/api/admin/read-only", authConfig.AdminOnly(handler.ReadOnly))
+	mux.HandleFunc("/api/admin/debug", authConfig.AdminOnly(handler.Debug))
+	mux.HandleFunc("/api/admin/log-level", authConfig.AdminOnly(handler.LogLevel))
+	mux.HandleFunc("/api/admin/tasks", authConfig.AdminOnly(handler.Tasks))
+	mux.HandleFunc("/api/admin/tasks/", authConfig.AdminOnly(handler.Tasks))
+	mux.HandleFunc("/api/admin/operations", authConfig.AdminOnly(handler.Operations))
+	mux.HandleFunc("/api/admin/operations/", authConfig.AdminOnly(handler.Operations))
+	mux.HandleFunc("/api/admin/host", authConfig.AdminOnly(handler.Host))
+	mux.HandleFunc("/api/admin/host/", authConfig.AdminOnly(handler.Host))
+
+	// Allowlist, groups, schedules and tokens as resources for declarative
+	// clients such as Terraform providers
+	mux.HandleFunc("/api/admin/config/", authConfig.AdminOnly(handler.ConfigResource))
+
+	// Static files: hashed names are cached for a year, plain ones revalidated
+	mux.Handle("/static/", http.StripPrefix("/static/", assets))
+
+	// Browsers request /favicon.ico regardless of the <link> tags
+	mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, assets.Path("icons/favicon.svg"), http.StatusFound)
+	})
+}
+
+// withMiddleware wraps the routes in the middleware every request passes
+// through, outermost first
+func withMiddleware(mux http.Handler, basePath string, maxBody int64, limiters *rateLimiters, clientIPResolver *netutil.ClientIPResolver, logger *slog.Logger, accessLog *logging.AccessLog) http.Handler {
+	return requestid.Middleware(
+		panicRecoveryMiddleware(logger)(
+			clientIPResolver.Middleware(
+				requestLoggingMiddleware(logger, accessLog)(
+					rateLimitMiddleware(limiters, logger)(
+						securityHeadersMiddleware(
+							bodyLimitMiddleware(maxBody)(basePathHandler(basePath, mux))))))))
+}
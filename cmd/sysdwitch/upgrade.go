@@ -0,0 +1,309 @@
+// cmd/sysdwitch/upgrade.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Environment of a process started by an upgrade
+const (
+	// listenFDsEnv lists the keys of the inherited sockets, which are
+	// passed from descriptor 3 on in the same order
+	listenFDsEnv = "SYSDWITCH_LISTEN_FDS"
+	// readyFDEnv is the descriptor on which the new process reports that
+	// it serves
+	readyFDEnv = "SYSDWITCH_READY_FD"
+)
+
+const (
+	// upgradeTimeout bounds how long the new process of an upgrade may take
+	// to start serving before it is given up on
+	upgradeTimeout = time.Minute
+	// handoffGrace is how long connections accepted just before the new
+	// process took over get to send their request, as the HTTP server drops
+	// those whose request it reads once shutting down
+	handoffGrace = 500 * time.Millisecond
+)
+
+// filer is a listening socket whose descriptor can be handed on
+type filer interface {
+	File() (*os.File, error)
+}
+
+// sockets opens the panel's listening sockets, reusing those inherited from
+// the process this one replaced, and hands them on to the process replacing
+// this one. Sockets are keyed by network and address, such as
+// "tcp:127.0.0.1:8081".
+type sockets struct {
+	// executable is the path this process was started from, resolved before
+	// the binary there may be replaced
+	executable string
+
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	active    map[string]filer
+	listeners []*handoffListener
+}
+
+// newSockets creates a set of sockets opening fresh ones
+func newSockets() *sockets {
+	s := &sockets{inherited: make(map[string]*os.File), active: make(map[string]filer)}
+	s.executable, _ = os.Executable()
+	return s
+}
+
+// inheritSockets picks up the sockets passed by an upgrade, if any
+func inheritSockets() *sockets {
+	s := newSockets()
+	keys := os.Getenv(listenFDsEnv)
+	// Not passed on to processes this one starts
+	os.Unsetenv(listenFDsEnv)
+	if keys == "" {
+		return s
+	}
+	for i, key := range strings.Split(keys, ",") {
+		s.inherited[key] = os.NewFile(uintptr(3+i), key)
+	}
+	return s
+}
+
+// Listen returns a stream listener on addr, the inherited one if any
+func (s *sockets) Listen(network, addr string) (net.Listener, error) {
+	key := network + ":" + addr
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ln net.Listener
+	var err error
+	if f, ok := s.inherited[key]; ok {
+		delete(s.inherited, key)
+		ln, err = net.FileListener(f)
+		f.Close()
+	} else {
+		ln, err = net.Listen(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := ln.(filer); ok {
+		s.active[key] = f
+	}
+	handoff := &handoffListener{Listener: ln, closed: make(chan struct{})}
+	s.listeners = append(s.listeners, handoff)
+	return handoff, nil
+}
+
+// ListenPacket returns a packet socket on addr, the inherited one if any
+func (s *sockets) ListenPacket(network, addr string) (net.PacketConn, error) {
+	key := network + ":" + addr
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var conn net.PacketConn
+	var err error
+	if f, ok := s.inherited[key]; ok {
+		delete(s.inherited, key)
+		conn, err = net.FilePacketConn(f)
+		f.Close()
+	} else {
+		conn, err = net.ListenPacket(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := conn.(filer); ok {
+		s.active[key] = f
+	}
+	return conn, nil
+}
+
+// closeUnused closes inherited sockets no longer configured, once every
+// listener was opened
+func (s *sockets) closeUnused(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, f := range s.inherited {
+		logger.Info("closing inherited socket no longer configured", "socket", key)
+		f.Close()
+		delete(s.inherited, key)
+	}
+}
+
+// upgrade starts the binary now installed at the path this process was
+// started from with the same arguments and environment, hands it the open
+// sockets and waits until it serves. Both processes then accept
+// connections on the same sockets, so none is refused, until handOff. On
+// error the new process is gone and this one keeps serving.
+func (s *sockets) upgrade(logger *slog.Logger) error {
+	exe := s.executable
+	if exe == "" {
+		return errors.New("path of the executable unknown")
+	}
+
+	s.mu.Lock()
+	keys := slices.Sorted(func(yield func(string) bool) {
+		for key := range s.active {
+			if !yield(key) {
+				return
+			}
+		}
+	})
+	files := make([]*os.File, 0, len(keys)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, key := range keys {
+		f, err := s.active[key].File()
+		if err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("socket %s: %w", key, err)
+		}
+		files = append(files, f)
+	}
+	s.mu.Unlock()
+
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+	files = append(files, readyWriter)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		listenFDsEnv+"="+strings.Join(keys, ","),
+		readyFDEnv+"="+strconv.Itoa(3+len(keys)))
+	cmd.ExtraFiles = files
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Only the new process holds the pipe's write end now, so reading sees
+	// EOF if it exits before reporting ready
+	readyWriter.Close()
+	logger.Info("started new process", "pid", cmd.Process.Pid, "executable", exe, "sockets", keys)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		if errors.Is(err, io.EOF) {
+			err = errors.New("new process exited before serving")
+		}
+		result <- err
+	}()
+	select {
+	case err = <-result:
+	case <-time.After(upgradeTimeout):
+		err = fmt.Errorf("new process not serving after %s", upgradeTimeout)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	// Reap the new process should it exit while this one still drains
+	go cmd.Wait()
+	return nil
+}
+
+// handOff stops accepting on the stream sockets after an upgrade, leaving
+// queued connections to the new process, and gives those accepted just
+// before time to send their request. This process should then drain and
+// exit.
+func (s *sockets) handOff() {
+	s.mu.Lock()
+	for _, ln := range s.listeners {
+		ln.handOff()
+	}
+	s.mu.Unlock()
+	time.Sleep(handoffGrace)
+}
+
+// handoffListener is a stream listener that stops accepting once handed
+// off, leaving the socket's connections to the new process while this one
+// still serves those it accepted
+type handoffListener struct {
+	net.Listener
+	handedOff atomic.Bool
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Accept waits for the next connection; once handed off, it waits for
+// the listener to be closed instead
+func (l *handoffListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil && l.handedOff.Load() {
+		<-l.closed
+		return nil, net.ErrClosed
+	}
+	return conn, err
+}
+
+// handOff stops accepting, waking a pending Accept through a deadline, which
+// only applies to this process's descriptor of the socket
+func (l *handoffListener) handOff() {
+	l.handedOff.Store(true)
+	if d, ok := l.Listener.(interface{ SetDeadline(time.Time) error }); ok {
+		_ = d.SetDeadline(time.Now())
+	}
+}
+
+// Close closes the listener, ending a pending Accept
+func (l *handoffListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+// notifyReady reports that this process serves: to the process it replaces
+// when started by an upgrade, and to systemd, which then takes this process
+// for the service's main process
+func notifyReady(logger *slog.Logger) {
+	if fd := os.Getenv(readyFDEnv); fd != "" {
+		os.Unsetenv(readyFDEnv)
+		if n, err := strconv.Atoi(fd); err == nil {
+			pipe := os.NewFile(uintptr(n), "ready")
+			if _, err := pipe.Write([]byte{1}); err != nil {
+				logger.Warn("failed to report readiness to the replaced process", "error", err)
+			}
+			pipe.Close()
+		}
+	}
+	if err := sdNotify(fmt.Sprintf("MAINPID=%d\nREADY=1", os.Getpid())); err != nil {
+		logger.Warn("failed to notify systemd", "error", err)
+	}
+}
+
+// sdNotify sends state to systemd's notification socket, when running
+// under a unit with one
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Abstract sockets are given with a leading @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
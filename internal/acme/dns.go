@@ -0,0 +1,177 @@
+// internal/acme/dns.go
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/plugin"
+)
+
+// maxResponse caps the size of a response from the DNS provider's API
+const maxResponse = 1 << 20
+
+// DNSProvider publishes and removes the TXT records that answer dns-01
+// challenges. fqdn is the record name with a trailing dot, such as
+// _acme-challenge.home.example.
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// Cloudflare manages challenge records through the Cloudflare API with an
+// API token allowed to edit DNS of the zone
+type Cloudflare struct {
+	token string
+	api   string
+	http  *http.Client
+
+	mu      sync.Mutex
+	records map[string]cloudflareRecord // by fqdn and value
+}
+
+type cloudflareRecord struct {
+	zone, id string
+}
+
+// NewCloudflare creates a Cloudflare DNS provider
+func NewCloudflare(token string) *Cloudflare {
+	return &Cloudflare{
+		token:   token,
+		api:     "https://api.cloudflare.com/client/v4",
+		http:    &http.Client{Timeout: 30 * time.Second},
+		records: make(map[string]cloudflareRecord),
+	}
+}
+
+// Present implements DNSProvider
+func (c *Cloudflare) Present(ctx context.Context, fqdn, value string) error {
+	name := strings.TrimSuffix(fqdn, ".")
+	zone, err := c.zoneID(ctx, name)
+	if err != nil {
+		return err
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	record := map[string]any{"type": "TXT", "name": name, "content": value, "ttl": 120}
+	if err := c.call(ctx, http.MethodPost, "/zones/"+zone+"/dns_records", record, &created); err != nil {
+		return fmt.Errorf("cloudflare: create TXT record %s: %w", name, err)
+	}
+	c.mu.Lock()
+	c.records[fqdn+" "+value] = cloudflareRecord{zone: zone, id: created.ID}
+	c.mu.Unlock()
+	return nil
+}
+
+// CleanUp implements DNSProvider
+func (c *Cloudflare) CleanUp(ctx context.Context, fqdn, value string) error {
+	c.mu.Lock()
+	record, ok := c.records[fqdn+" "+value]
+	delete(c.records, fqdn+" "+value)
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if err := c.call(ctx, http.MethodDelete, "/zones/"+record.zone+"/dns_records/"+record.id, nil, nil); err != nil {
+		return fmt.Errorf("cloudflare: delete TXT record %s: %w", strings.TrimSuffix(fqdn, "."), err)
+	}
+	return nil
+}
+
+// zoneID finds the zone holding name by trying each of its parent domains
+func (c *Cloudflare) zoneID(ctx context.Context, name string) (string, error) {
+	labels := strings.Split(name, ".")
+	for i := 1; i < len(labels)-1; i++ {
+		candidate := strings.Join(labels[i:], ".")
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		if err := c.call(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(candidate), nil, &zones); err != nil {
+			return "", fmt.Errorf("cloudflare: look up zone %s: %w", candidate, err)
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("cloudflare: no zone found for %s", name)
+}
+
+// call sends a request to the API and decodes its result into out
+func (c *Cloudflare) call(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.api+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponse)).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s: %w", resp.Status, err)
+	}
+	if !envelope.Success {
+		if len(envelope.Errors) > 0 {
+			return errors.New(envelope.Errors[0].Message)
+		}
+		return errors.New(resp.Status)
+	}
+	if out != nil {
+		return json.Unmarshal(envelope.Result, out)
+	}
+	return nil
+}
+
+// PluginDNS delegates challenge records to a plugin providing "dns"
+type PluginDNS struct {
+	plugin *plugin.Plugin
+}
+
+// NewPluginDNS creates a DNS provider backed by p
+func NewPluginDNS(p *plugin.Plugin) *PluginDNS {
+	return &PluginDNS{plugin: p}
+}
+
+// dnsParams are the params of the present and cleanup methods
+type dnsParams struct {
+	FQDN  string `json:"fqdn"`
+	Value string `json:"value"`
+}
+
+// Present implements DNSProvider
+func (p *PluginDNS) Present(ctx context.Context, fqdn, value string) error {
+	return p.plugin.Call(ctx, "present", dnsParams{FQDN: fqdn, Value: value}, nil)
+}
+
+// CleanUp implements DNSProvider
+func (p *PluginDNS) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.plugin.Call(ctx, "cleanup", dnsParams{FQDN: fqdn, Value: value}, nil)
+}
@@ -0,0 +1,392 @@
+// internal/acme/manager.go
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Challenge types
+const (
+	ChallengeHTTP = "http-01"
+	ChallengeDNS  = "dns-01"
+)
+
+// LetsEncrypt is the directory URL of Let's Encrypt's production CA
+const LetsEncrypt = acme.LetsEncryptURL
+
+// Config configures a Manager
+type Config struct {
+	Domains   []string
+	Email     string
+	Directory string
+	// CacheDir keeps the account key and the certificate across restarts
+	CacheDir  string
+	Challenge string
+	// DNS answers dns-01 challenges
+	DNS DNSProvider
+	// RenewBefore is how long before expiry the certificate is renewed
+	RenewBefore time.Duration
+}
+
+// Manager keeps certificates for the configured domains, obtaining them on
+// first start and renewing them ahead of expiry. With http-01, autocert
+// does the work, a certificate per domain; with dns-01, which autocert
+// lacks, the Manager orders one certificate for all domains itself.
+type Manager struct {
+	cfg    Config
+	logger *slog.Logger
+	cache  autocert.DirCache
+
+	// autocert is nil with dns-01
+	autocert *autocert.Manager
+	// cert is the dns-01 certificate
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// NewManager creates a certificate manager
+func NewManager(cfg Config, logger *slog.Logger) *Manager {
+	m := &Manager{
+		cfg:    cfg,
+		logger: logger,
+		cache:  autocert.DirCache(cfg.CacheDir),
+	}
+	if cfg.Challenge == ChallengeHTTP {
+		m.autocert = &autocert.Manager{
+			Prompt:      autocert.AcceptTOS,
+			Cache:       m.cache,
+			HostPolicy:  autocert.HostWhitelist(cfg.Domains...),
+			RenewBefore: cfg.RenewBefore,
+			Client:      &acme.Client{DirectoryURL: cfg.Directory},
+			Email:       cfg.Email,
+		}
+	}
+	return m
+}
+
+// TLSConfig is the server configuration serving the certificates, which
+// also answers tls-alpn-01 challenges when autocert tries them
+func (m *Manager) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+		MinVersion:     tls.VersionTLS12,
+	}
+	if m.autocert != nil {
+		config.NextProtos = append(config.NextProtos, acme.ALPNProto)
+	}
+	return config
+}
+
+// GetCertificate serves the current certificate in TLS handshakes
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if m.autocert != nil {
+		// Clients connecting by address get the first domain's certificate
+		if hello.ServerName == "" {
+			named := *hello
+			named.ServerName = m.cfg.Domains[0]
+			hello = &named
+		}
+		return m.autocert.GetCertificate(hello)
+	}
+	if cert := m.cert.Load(); cert != nil {
+		return cert, nil
+	}
+	return nil, errors.New("acme: no certificate obtained yet")
+}
+
+// HTTPHandler answers http-01 challenges and passes other requests to
+// fallback
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	if m.autocert == nil {
+		return fallback
+	}
+	return m.autocert.HTTPHandler(fallback)
+}
+
+// Run obtains the certificates at startup, retrying failed attempts with
+// backoff. autocert renews its certificates on its own; the dns-01 one is
+// kept valid until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	if m.autocert != nil {
+		m.warmUp(ctx)
+		return
+	}
+	if err := m.loadCached(ctx); err != nil && !errors.Is(err, autocert.ErrCacheMiss) {
+		m.logger.Warn("ignoring cached certificate", "error", err)
+	}
+
+	retry := time.Minute
+	for {
+		wait := m.untilRenewal()
+		if wait <= 0 {
+			if err := m.obtain(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				m.logger.Error("failed to obtain certificate", "domains", m.cfg.Domains, "error", err, "retry_in", retry.String())
+				wait = retry
+				retry = min(retry*2, 6*time.Hour)
+			} else {
+				retry = time.Minute
+				wait = m.untilRenewal()
+			}
+		}
+		// Recheck at least twice a day in case the clock jumped
+		if err := sleep(ctx, min(wait, 12*time.Hour)); err != nil {
+			return
+		}
+	}
+}
+
+// warmUp has autocert load or obtain each domain's certificate before the
+// first client asks for it
+func (m *Manager) warmUp(ctx context.Context) {
+	retry := time.Minute
+	for _, domain := range m.cfg.Domains {
+		for {
+			// As from a browser, so autocert picks the same ECDSA key
+			cert, err := m.autocert.GetCertificate(&tls.ClientHelloInfo{
+				ServerName:       domain,
+				SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+				SupportedCurves:  []tls.CurveID{tls.CurveP256},
+				CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			})
+			if err == nil {
+				m.logger.Info("certificate ready", "domain", domain, "expires", cert.Leaf.NotAfter)
+				break
+			}
+			m.logger.Error("failed to obtain certificate", "domain", domain, "error", err, "retry_in", retry.String())
+			if sleep(ctx, retry) != nil {
+				return
+			}
+			retry = min(retry*2, 6*time.Hour)
+		}
+	}
+}
+
+// untilRenewal is how long until the dns-01 certificate is due for renewal
+func (m *Manager) untilRenewal() time.Duration {
+	cert := m.cert.Load()
+	if cert == nil || cert.Leaf == nil {
+		return 0
+	}
+	return time.Until(cert.Leaf.NotAfter.Add(-m.cfg.RenewBefore))
+}
+
+// loadCached installs the dns-01 certificate from the cache when it covers
+// the configured domains
+func (m *Manager) loadCached(ctx context.Context) error {
+	certPEM, err := m.cache.Get(ctx, "certificate.pem")
+	if err != nil {
+		return err
+	}
+	keyPEM, err := m.cache.Get(ctx, "certificate.key")
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	for _, domain := range m.cfg.Domains {
+		if !slices.Contains(cert.Leaf.DNSNames, domain) {
+			return fmt.Errorf("cached certificate does not cover %s", domain)
+		}
+	}
+	m.cert.Store(&cert)
+	m.logger.Info("loaded cached certificate", "domains", cert.Leaf.DNSNames, "expires", cert.Leaf.NotAfter)
+	return nil
+}
+
+// obtain orders a certificate for all domains over dns-01 and installs it
+func (m *Manager) obtain(ctx context.Context) error {
+	client, err := m.client(ctx)
+	if err != nil {
+		return err
+	}
+	o, err := client.AuthorizeOrder(ctx, acme.DomainIDs(m.cfg.Domains...))
+	if err != nil {
+		return err
+	}
+	for _, authzURL := range o.AuthzURLs {
+		if err := m.authorize(ctx, client, authzURL); err != nil {
+			return err
+		}
+	}
+	if o, err = client.WaitOrder(ctx, o.URI); err != nil {
+		return err
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: m.cfg.Domains[0]},
+		DNSNames: m.cfg.Domains,
+	}, certKey)
+	if err != nil {
+		return fmt.Errorf("create CSR: %w", err)
+	}
+	chain, _, err := client.CreateOrderCert(ctx, o.FinalizeURL, csr, true)
+	if err != nil {
+		return err
+	}
+
+	var chainPEM []byte
+	for _, der := range chain {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(chainPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("parse issued certificate: %w", err)
+	}
+	if err := m.cache.Put(ctx, "certificate.pem", chainPEM); err != nil {
+		return err
+	}
+	if err := m.cache.Put(ctx, "certificate.key", keyPEM); err != nil {
+		return err
+	}
+	m.cert.Store(&cert)
+	m.logger.Info("obtained certificate", "domains", m.cfg.Domains, "expires", cert.Leaf.NotAfter)
+	return nil
+}
+
+// client is an ACME client for the cached account, registered with the CA
+func (m *Manager) client(ctx context.Context) (*acme.Client, error) {
+	key, err := m.accountKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{Key: key, DirectoryURL: m.cfg.Directory}
+	account := &acme.Account{}
+	if m.cfg.Email != "" {
+		account.Contact = []string{"mailto:" + m.cfg.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, err
+	}
+	return client, nil
+}
+
+// authorize answers the dns-01 challenge of one authorization through the
+// DNS provider
+func (m *Manager) authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	i := slices.IndexFunc(authz.Challenges, func(ch *acme.Challenge) bool { return ch.Type == ChallengeDNS })
+	if i < 0 {
+		return fmt.Errorf("the CA offers no %s challenge for %s", ChallengeDNS, authz.Identifier.Value)
+	}
+	ch := authz.Challenges[i]
+	value, err := client.DNS01ChallengeRecord(ch.Token)
+	if err != nil {
+		return err
+	}
+
+	fqdn := "_acme-challenge." + authz.Identifier.Value + "."
+	if err := m.cfg.DNS.Present(ctx, fqdn, value); err != nil {
+		return err
+	}
+	defer func() {
+		// Clean up even when ctx is done
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		if err := m.cfg.DNS.CleanUp(cleanupCtx, fqdn, value); err != nil {
+			m.logger.Warn("failed to remove challenge record", "record", fqdn, "error", err)
+		}
+	}()
+	m.waitForRecord(ctx, fqdn, value)
+
+	m.logger.Info("answering ACME challenge", "domain", authz.Identifier.Value, "type", ch.Type)
+	if _, err := client.Accept(ctx, ch); err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+// waitForRecord gives a new TXT record up to two minutes to show up in DNS
+// before the CA is asked to look for it
+func (m *Manager) waitForRecord(ctx context.Context, fqdn, value string) {
+	deadline := time.Now().Add(2 * time.Minute)
+	for time.Now().Before(deadline) {
+		records, _ := net.DefaultResolver.LookupTXT(ctx, fqdn)
+		if slices.Contains(records, value) {
+			return
+		}
+		if sleep(ctx, 5*time.Second) != nil {
+			return
+		}
+	}
+	m.logger.Warn("challenge record not visible yet, trying anyway", "record", fqdn)
+}
+
+// accountKey loads the dns-01 account key from the cache, creating it on
+// first use
+func (m *Manager) accountKey(ctx context.Context) (*ecdsa.PrivateKey, error) {
+	data, err := m.cache.Get(ctx, "account.key")
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("invalid account key in cache")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.cache.Put(ctx, "account.key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
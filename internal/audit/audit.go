@@ -0,0 +1,106 @@
+// internal/audit/audit.go
+package audit
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event types recorded in the audit log
+const (
+	EventAuthLockout         = "auth.lockout"
+	EventReadOnlyChanged     = "admin.read_only"
+	EventLogLevelChanged     = "admin.log_level"
+	EventConfigChanged       = "admin.config"
+	EventOperationCanceled   = "admin.operation_canceled"
+	EventServiceStateChanged = "service.state_changed"
+	EventServiceFailed       = "service.failed"
+	EventServiceRestarted    = "service.watchdog_restart"
+	EventServiceAction       = "service.action"
+	EventServiceFlapping     = "service.flapping"
+	EventServiceStable       = "service.stable"
+	EventProfileAction       = "profile.action"
+	EventTaskRun             = "task.run"
+	EventHostPower           = "host.power"
+	EventHostWake            = "host.wake"
+	EventRuleFired           = "rule.fired"
+	EventHookTriggered       = "hook.triggered"
+	EventDesiredStateDrift   = "desired_state.drift"
+)
+
+// Event is a single security- or operations-relevant occurrence
+type Event struct {
+	Time       time.Time      `json:"time"`
+	Type       string         `json:"type"`
+	User       string         `json:"user,omitempty"`
+	Service    string         `json:"service,omitempty"`
+	RemoteAddr string         `json:"remote_addr,omitempty"`
+	Message    string         `json:"message"`
+	Fields     map[string]any `json:"fields,omitempty"`
+	// Suppressed events are logged but not sent to notification channels
+	Suppressed bool `json:"suppressed,omitempty"`
+}
+
+// Recorder writes audit events to the log and fans them out to subscribers
+type Recorder struct {
+	logger      *slog.Logger
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewRecorder creates an audit recorder logging through logger
+func NewRecorder(logger *slog.Logger) *Recorder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Recorder{
+		logger: logger.With("log_type", "audit"),
+	}
+}
+
+// Subscribe registers fn to be called for every recorded event.
+// Subscribers run synchronously and must not block.
+func (rec *Recorder) Subscribe(fn func(Event)) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.subscribers = append(rec.subscribers, fn)
+}
+
+// Record logs an event and delivers it to subscribers. A nil Recorder
+// discards events.
+func (rec *Recorder) Record(event Event) {
+	if rec == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	attrs := []any{"event", event.Type}
+	if event.User != "" {
+		attrs = append(attrs, "user", event.User)
+	}
+	if event.Service != "" {
+		attrs = append(attrs, "service", event.Service)
+	}
+	if event.RemoteAddr != "" {
+		attrs = append(attrs, "remote_addr", event.RemoteAddr)
+	}
+	for k, v := range event.Fields {
+		attrs = append(attrs, k, v)
+	}
+	if event.Suppressed {
+		attrs = append(attrs, "suppressed", true)
+	}
+	rec.logger.Info(event.Message, attrs...)
+
+	rec.mu.RLock()
+	subscribers := rec.subscribers
+	rec.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
@@ -2,28 +2,145 @@
 package auth
 
 import (
+	"context"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
-	"os"
+	"net/netip"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/netutil"
+	"sysdwitch/internal/plugin"
+	"sysdwitch/internal/store"
 )
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	Username string
 	Password string
+
+	// ProxyHeaders lists the headers an upstream authenticating proxy
+	// (Authelia, oauth2-proxy, ...) uses to pass the logged-in user
+	ProxyHeaders []string
+	// ProxyTrusted lists the source networks allowed to set ProxyHeaders
+	ProxyTrusted []netip.Prefix
+
+	// Users holds additional accounts with hashed passwords (optional)
+	Users store.Store
+
+	// Providers are auth plugins asked, in order, about credentials that
+	// match neither the admin account nor a stored user
+	Providers []*plugin.Plugin
+
+	// UserLimit, when set, is asked whether an authenticated user may make
+	// the request, and otherwise how long they must wait
+	UserLimit func(r *http.Request, username string) (bool, time.Duration)
+
+	// verified caches the last successfully checked password per stored
+	// user, so PBKDF2 does not run on every request
+	verified sync.Map
+	// providerVerified caches logins accepted by a provider for
+	// providerCacheTTL, so plugins do not run on every request
+	providerVerified sync.Map
+
+	lockouts *lockoutTracker
+	audit    *audit.Recorder
 	logger   *slog.Logger
 }
 
-// NewAuthConfig creates auth config from environment variables
-func NewAuthConfig(logger *slog.Logger) (*AuthConfig, error) {
-	username := strings.TrimSpace(os.Getenv("ADMIN_USER"))
-	password := strings.TrimSpace(os.Getenv("ADMIN_PASS"))
+// providerCacheTTL is how long a login accepted by an auth plugin is
+// trusted without asking the plugin again
+const providerCacheTTL = time.Minute
+
+// providerEntry records a login accepted by an auth plugin
+type providerEntry struct {
+	digest  [32]byte
+	admin   bool
+	expires time.Time
+}
+
+// verifiedEntry records a password hash already checked for a stored user
+type verifiedEntry struct {
+	passwordHash string
+	digest       [32]byte
+}
+
+// contextKey is the type for values stored in request contexts by this package
+type contextKey struct{}
+
+// Session describes how a request was authenticated
+type Session struct {
+	User string
+	// Admin is set for the environment admin account and users with the
+	// admin role
+	Admin bool
+	// Expires is when the API token the request carries expires; nil for
+	// other logins and tokens that never expire
+	Expires *time.Time
+}
+
+// SessionFromContext returns the session stored by the middleware
+func SessionFromContext(ctx context.Context) Session {
+	session, _ := ctx.Value(contextKey{}).(Session)
+	return session
+}
+
+// UserFromContext returns the authenticated username stored by the middleware
+func UserFromContext(ctx context.Context) string {
+	return SessionFromContext(ctx).User
+}
+
+// IsAdmin reports whether the request was authenticated as an admin
+func IsAdmin(ctx context.Context) bool {
+	return SessionFromContext(ctx).Admin
+}
+
+// withSession stores the session in the request context
+func withSession(r *http.Request, session Session) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, session))
+}
+
+// ContextWithUser attributes work done with ctx to username, for callers
+// authenticated by other means than the middleware, such as webhooks.
+// The user is not an admin.
+func ContextWithUser(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, contextKey{}, Session{User: username})
+}
+
+// AdminOnly authenticates like BasicAuthMiddleware and answers 403 to
+// users without the admin role
+func (ac *AuthConfig) AdminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return ac.BasicAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if !IsAdmin(r.Context()) {
+			ac.logger.WarnContext(r.Context(), "admin endpoint denied",
+				"username", UserFromContext(r.Context()),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"remote_addr", r.RemoteAddr)
+			http.Error(w, "Forbidden: admin role required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
 
-	if username == "" || password == "" {
+// NewAuthConfig creates auth config from the application configuration.
+// users may be nil when no store is configured.
+func NewAuthConfig(cfg config.AuthConfig, users store.Store, recorder *audit.Recorder, logger *slog.Logger) (*AuthConfig, error) {
+	if (cfg.Username == "") != (cfg.Password == "") {
+		return nil, errors.New("ADMIN_USER and ADMIN_PASS must be set together")
+	}
+	if cfg.Username == "" && users == nil {
 		return nil, errors.New("ADMIN_USER and ADMIN_PASS environment variables must be set")
 	}
 
@@ -31,19 +148,94 @@ func NewAuthConfig(logger *slog.Logger) (*AuthConfig, error) {
 		logger = slog.Default()
 	}
 
+	proxyTrusted, err := netutil.ParsePrefixes(cfg.ProxyTrusted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_PROXY_TRUSTED: %w", err)
+	}
+
+	var proxyHeaders []string
+	if len(proxyTrusted) > 0 {
+		proxyHeaders = []string{"Remote-User", "X-Forwarded-User"}
+		if len(cfg.ProxyHeaders) > 0 {
+			proxyHeaders = make([]string, len(cfg.ProxyHeaders))
+			for i, h := range cfg.ProxyHeaders {
+				proxyHeaders[i] = http.CanonicalHeaderKey(strings.TrimSpace(h))
+			}
+		}
+	}
+
 	return &AuthConfig{
-		Username: username,
-		Password: password,
-		logger:   logger,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		ProxyHeaders: proxyHeaders,
+		ProxyTrusted: proxyTrusted,
+		Users:        users,
+		lockouts:     newLockoutTracker(cfg.MaxFailures, time.Duration(cfg.LockoutBase), time.Duration(cfg.LockoutMax)),
+		audit:        recorder,
+		logger:       logger,
 	}, nil
 }
 
-// BasicAuthMiddleware provides HTTP Basic Authentication
+// Configured reports whether at least one account can log in: the
+// environment admin account or a user in the store
+func (ac *AuthConfig) Configured(ctx context.Context) (bool, error) {
+	if ac.Username != "" && ac.Password != "" || len(ac.Providers) > 0 {
+		return true, nil
+	}
+	if ac.Users == nil {
+		return false, nil
+	}
+	users, err := ac.Users.ListUsers(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(users) > 0, nil
+}
+
+// proxyUser returns the username asserted by a trusted authenticating proxy
+func (ac *AuthConfig) proxyUser(r *http.Request) (string, bool) {
+	if len(ac.ProxyHeaders) == 0 {
+		return "", false
+	}
+
+	var username, header string
+	for _, h := range ac.ProxyHeaders {
+		if value := strings.TrimSpace(r.Header.Get(h)); value != "" {
+			username, header = value, h
+			break
+		}
+	}
+	if username == "" {
+		return "", false
+	}
+
+	if addr, ok := netutil.RemoteAddr(r); ok && netutil.PrefixesContain(ac.ProxyTrusted, addr) {
+		return username, true
+	}
+
+	ac.logger.WarnContext(r.Context(), "ignoring proxy user header from untrusted source",
+		"header", header,
+		"remote_addr", r.RemoteAddr)
+	return "", false
+}
+
+// BasicAuthMiddleware provides HTTP Basic Authentication, also accepting
+// API tokens as "Authorization: Bearer sdw_..."
 func (ac *AuthConfig) BasicAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if username, ok := ac.proxyUser(r); ok {
+			ac.logger.DebugContext(r.Context(), "authenticated by trusted proxy",
+				"username", username,
+				"remote_addr", r.RemoteAddr)
+			if ac.allowUser(w, r, username) {
+				next(w, withSession(r, Session{User: username, Admin: ac.isAdmin(r.Context(), username)}))
+			}
+			return
+		}
+
 		auth := r.Header.Get("Authorization")
 		if auth == "" {
-			ac.logger.Debug("missing authorization header",
+			ac.logger.DebugContext(r.Context(), "missing authorization header",
 				"remote_addr", r.RemoteAddr,
 				"method", r.Method,
 				"path", r.URL.Path)
@@ -51,8 +243,13 @@ func (ac *AuthConfig) BasicAuthMiddleware(next http.HandlerFunc) http.HandlerFun
 			return
 		}
 
+		if value, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			ac.tokenAuth(w, r, strings.TrimSpace(value), next)
+			return
+		}
+
 		if !strings.HasPrefix(auth, "Basic ") {
-			ac.logger.Warn("invalid authorization scheme",
+			ac.logger.WarnContext(r.Context(), "invalid authorization scheme",
 				"scheme", strings.Fields(auth)[0],
 				"remote_addr", r.RemoteAddr)
 			ac.requireAuth(w)
@@ -61,7 +258,7 @@ func (ac *AuthConfig) BasicAuthMiddleware(next http.HandlerFunc) http.HandlerFun
 
 		decoded, err := base64.StdEncoding.DecodeString(auth[6:])
 		if err != nil {
-			ac.logger.Warn("failed to decode authorization header",
+			ac.logger.WarnContext(r.Context(), "failed to decode authorization header",
 				"error", err,
 				"remote_addr", r.RemoteAddr)
 			ac.requireAuth(w)
@@ -70,31 +267,218 @@ func (ac *AuthConfig) BasicAuthMiddleware(next http.HandlerFunc) http.HandlerFun
 
 		creds := strings.SplitN(string(decoded), ":", 2)
 		if len(creds) != 2 {
-			ac.logger.Warn("malformed credentials in authorization header",
+			ac.logger.WarnContext(r.Context(), "malformed credentials in authorization header",
 				"remote_addr", r.RemoteAddr)
 			ac.requireAuth(w)
 			return
 		}
 
 		username, password := creds[0], creds[1]
+		ipKey, userKey := "ip:"+netutil.ClientIP(r), "user:"+username
+
+		// Reject locked-out clients before spending time on verification
+		now := time.Now()
+		if wait := max(ac.lockouts.remaining(ipKey, now), ac.lockouts.remaining(userKey, now)); wait > 0 {
+			ac.logger.WarnContext(r.Context(), "rejecting login during lockout",
+				"username", username,
+				"remote_addr", r.RemoteAddr,
+				"retry_after", wait)
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+0.5)))
+			http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+			return
+		}
 
-		// Use constant-time comparison to prevent timing attacks
-		if subtle.ConstantTimeCompare([]byte(username), []byte(ac.Username)) != 1 ||
-			subtle.ConstantTimeCompare([]byte(password), []byte(ac.Password)) != 1 {
-			ac.logger.Warn("authentication failed",
+		admin, ok := ac.checkCredentials(r.Context(), username, password)
+		if !ok {
+			ac.logger.WarnContext(r.Context(), "authentication failed",
 				"username", username,
 				"remote_addr", r.RemoteAddr)
+			ac.recordFailure(r, ipKey, username, now)
+			ac.recordFailure(r, userKey, username, now)
 			ac.requireAuth(w)
 			return
 		}
 
-		ac.logger.Debug("authentication successful",
+		ac.lockouts.reset(ipKey)
+		ac.lockouts.reset(userKey)
+
+		ac.logger.DebugContext(r.Context(), "authentication successful",
 			"username", username,
 			"remote_addr", r.RemoteAddr)
 
 		// Authentication successful, call next handler
-		next(w, r)
+		if ac.allowUser(w, r, username) {
+			next(w, withSession(r, Session{User: username, Admin: admin}))
+		}
+	}
+}
+
+// tokenAuth authenticates a request carrying an API token, with the same
+// lockout as password logins
+func (ac *AuthConfig) tokenAuth(w http.ResponseWriter, r *http.Request, value string, next http.HandlerFunc) {
+	ipKey := "ip:" + netutil.ClientIP(r)
+	now := time.Now()
+	if wait := ac.lockouts.remaining(ipKey, now); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+0.5)))
+		http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	session, err := ac.checkToken(r.Context(), value)
+	if err != nil {
+		ac.logger.WarnContext(r.Context(), "token authentication failed",
+			"error", err,
+			"remote_addr", r.RemoteAddr)
+		ac.recordFailure(r, ipKey, "", now)
+		ac.requireAuth(w)
+		return
+	}
+	ac.lockouts.reset(ipKey)
+
+	ac.logger.DebugContext(r.Context(), "authenticated by token",
+		"username", session.User,
+		"remote_addr", r.RemoteAddr)
+	if ac.allowUser(w, r, session.User) {
+		next(w, withSession(r, session))
+	}
+}
+
+// allowUser applies UserLimit, answering 429 when the user is over budget
+func (ac *AuthConfig) allowUser(w http.ResponseWriter, r *http.Request, username string) bool {
+	if ac.UserLimit == nil {
+		return true
+	}
+	ok, retryAfter := ac.UserLimit(r, username)
+	if !ok {
+		ac.logger.WarnContext(r.Context(), "user rate limit exceeded",
+			"username", username,
+			"url", r.URL.Path,
+			"method", r.Method)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+// recordFailure counts a failed login against key and audits any
+// resulting lockout
+func (ac *AuthConfig) recordFailure(r *http.Request, key, username string, now time.Time) {
+	lockout := ac.lockouts.fail(key, now)
+	if lockout == 0 {
+		return
+	}
+
+	ac.logger.WarnContext(r.Context(), "login locked out",
+		"key", key,
+		"username", username,
+		"remote_addr", r.RemoteAddr,
+		"duration", lockout)
+	ac.audit.Record(audit.Event{
+		Type:       audit.EventAuthLockout,
+		User:       username,
+		RemoteAddr: r.RemoteAddr,
+		Message:    "login locked out after repeated failures",
+		Fields: map[string]any{
+			"key":      key,
+			"duration": lockout.String(),
+		},
+	})
+}
+
+// checkCredentials verifies a username and password against the
+// environment admin account and, if configured, the user store, reporting
+// whether the account is an admin
+func (ac *AuthConfig) checkCredentials(ctx context.Context, username, password string) (admin, ok bool) {
+	// Use constant-time comparison to prevent timing attacks
+	if ac.Username != "" &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(ac.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(ac.Password)) == 1 {
+		return true, true
+	}
+
+	if ac.Users == nil {
+		return ac.checkProviders(ctx, username, password)
+	}
+
+	user, err := ac.Users.GetUser(ctx, username)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			ac.logger.ErrorContext(ctx, "failed to look up user", "username", username, "error", err)
+		}
+		return ac.checkProviders(ctx, username, password)
+	}
+
+	digest := sha256.Sum256([]byte(password))
+	if cached, ok := ac.verified.Load(username); ok {
+		entry := cached.(verifiedEntry)
+		if entry.passwordHash == user.PasswordHash &&
+			subtle.ConstantTimeCompare(entry.digest[:], digest[:]) == 1 {
+			return user.IsAdmin(), true
+		}
+	}
+
+	ok, err = VerifyPassword(user.PasswordHash, password)
+	if err != nil {
+		ac.logger.ErrorContext(ctx, "failed to verify stored password", "username", username, "error", err)
+		return false, false
+	}
+	if ok {
+		ac.verified.Store(username, verifiedEntry{passwordHash: user.PasswordHash, digest: digest})
+	}
+	return ok && user.IsAdmin(), ok
+}
+
+// checkProviders asks the auth plugins to verify a username and password,
+// reporting whether the plugin that accepted them made the user an admin
+func (ac *AuthConfig) checkProviders(ctx context.Context, username, password string) (admin, ok bool) {
+	if len(ac.Providers) == 0 {
+		return false, false
+	}
+
+	digest := sha256.Sum256([]byte(username + "\x00" + password))
+	if cached, ok := ac.providerVerified.Load(username); ok {
+		entry := cached.(providerEntry)
+		if time.Now().Before(entry.expires) && subtle.ConstantTimeCompare(entry.digest[:], digest[:]) == 1 {
+			return entry.admin, true
+		}
+	}
+
+	for _, p := range ac.Providers {
+		var result struct {
+			OK    bool `json:"ok"`
+			Admin bool `json:"admin"`
+		}
+		params := map[string]string{"username": username, "password": password}
+		if err := p.Call(ctx, "authenticate", params, &result); err != nil {
+			ac.logger.ErrorContext(ctx, "auth plugin failed", "plugin", p.Name, "username", username, "error", err)
+			continue
+		}
+		if result.OK {
+			ac.providerVerified.Store(username, providerEntry{digest: digest, admin: result.Admin, expires: time.Now().Add(providerCacheTTL)})
+			return result.Admin, true
+		}
+	}
+	return false, false
+}
+
+// isAdmin reports whether username, authenticated by other means than its
+// password, is the environment admin account or a stored user with the
+// admin role
+func (ac *AuthConfig) isAdmin(ctx context.Context, username string) bool {
+	if ac.Username != "" && username == ac.Username {
+		return true
+	}
+	if ac.Users == nil {
+		return false
+	}
+	user, err := ac.Users.GetUser(ctx, username)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			ac.logger.ErrorContext(ctx, "failed to look up user", "username", username, "error", err)
+		}
+		return false
 	}
+	return user.IsAdmin()
 }
 
 // requireAuth sends a 401 Unauthorized response
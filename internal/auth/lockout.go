@@ -0,0 +1,92 @@
+// internal/auth/lockout.go
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// lockoutTracker counts failed logins per key (client IP or username) and
+// applies exponentially growing lockouts once a threshold is reached
+type lockoutTracker struct {
+	mu          sync.Mutex
+	maxFailures int
+	base        time.Duration
+	max         time.Duration
+	entries     map[string]*failureEntry
+}
+
+type failureEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newLockoutTracker(maxFailures int, base, max time.Duration) *lockoutTracker {
+	return &lockoutTracker{
+		maxFailures: maxFailures,
+		base:        base,
+		max:         max,
+		entries:     make(map[string]*failureEntry),
+	}
+}
+
+// remaining returns how long key stays locked out, or zero
+func (lt *lockoutTracker) remaining(key string, now time.Time) time.Duration {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	entry, ok := lt.entries[key]
+	if !ok || !now.Before(entry.lockedUntil) {
+		return 0
+	}
+	return entry.lockedUntil.Sub(now)
+}
+
+// fail records a failed attempt for key and returns the lockout duration
+// it triggered, or zero if key is still below the threshold
+func (lt *lockoutTracker) fail(key string, now time.Time) time.Duration {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.prune(now)
+
+	entry, ok := lt.entries[key]
+	if !ok {
+		entry = &failureEntry{}
+		lt.entries[key] = entry
+	}
+	entry.failures++
+	entry.lastFailure = now
+
+	if entry.failures < lt.maxFailures {
+		return 0
+	}
+
+	// Double the lockout for every failure past the threshold
+	lockout := lt.base
+	for i := lt.maxFailures; i < entry.failures && lockout < lt.max; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, lt.max)
+
+	entry.lockedUntil = now.Add(lockout)
+	return lockout
+}
+
+// reset clears the failure history of key after a successful login
+func (lt *lockoutTracker) reset(key string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	delete(lt.entries, key)
+}
+
+// prune forgets keys whose last failure is older than the maximum lockout
+// and that are no longer locked. Callers must hold lt.mu.
+func (lt *lockoutTracker) prune(now time.Time) {
+	for key, entry := range lt.entries {
+		if now.Sub(entry.lastFailure) > lt.max && !now.Before(entry.lockedUntil) {
+			delete(lt.entries, key)
+		}
+	}
+}
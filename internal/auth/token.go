@@ -0,0 +1,97 @@
+// internal/auth/token.go
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/store"
+)
+
+// tokenPrefix marks API tokens, so they are recognizable in configs and
+// secret scanners
+const tokenPrefix = "sdw_"
+
+// NewToken creates an API token for user. The returned secret is the full
+// token to hand out; only its hash is kept in the store.Token. A zero ttl
+// never expires.
+func NewToken(name, user string, ttl time.Duration) (store.Token, string, error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return store.Token{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return store.Token{}, "", err
+	}
+
+	token := store.Token{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		User:      user,
+		CreatedAt: time.Now().UTC(),
+	}
+	if ttl > 0 {
+		expiresAt := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	token.SecretHash = hashSecret(encoded)
+	return token, tokenPrefix + token.ID + "_" + encoded, nil
+}
+
+// hashSecret hashes a token secret. Secrets are random, so a plain hash
+// is enough where passwords need PBKDF2.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseToken splits "sdw_<id>_<secret>"
+func parseToken(value string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(value, tokenPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	return id, secret, ok && id != "" && secret != ""
+}
+
+// checkToken returns the session of an API token: its user, with the
+// user's role, and its expiry
+func (ac *AuthConfig) checkToken(ctx context.Context, value string) (Session, error) {
+	if ac.Users == nil {
+		return Session{}, errors.New("no token store configured")
+	}
+	id, secret, ok := parseToken(value)
+	if !ok {
+		return Session{}, errors.New("malformed token")
+	}
+	token, err := ac.Users.GetToken(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(token.SecretHash)) != 1 {
+		return Session{}, errors.New("wrong token secret")
+	}
+	if token.Expired(time.Now()) {
+		return Session{}, errors.New("token expired")
+	}
+	session := Session{User: token.User, Admin: true, Expires: token.ExpiresAt}
+	// Tokens stop working with their user, even if not revoked
+	if token.User != ac.Username {
+		user, err := ac.Users.GetUser(ctx, token.User)
+		if err != nil {
+			return Session{}, err
+		}
+		session.Admin = user.IsAdmin()
+	}
+	return session, nil
+}
@@ -0,0 +1,1985 @@
+// internal/config/config.go
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/expr"
+	"sysdwitch/internal/schedule"
+	"sysdwitch/internal/snmp"
+	"sysdwitch/internal/wol"
+)
+
+// Config holds the effective application configuration. Values are
+// resolved from built-in defaults, then the optional JSON config file,
+// then environment variables.
+type Config struct {
+	Host            string   `json:"host"`
+	Port            int      `json:"port"`
+	AllowedServices []string `json:"allowed_services"`
+	// BasePath serves the panel under a URL prefix such as /sysdwitch for
+	// reverse proxies that mount it in a subdirectory
+	BasePath string `json:"base_path,omitempty"`
+	// Backend selects the init system services are controlled through
+	Backend BackendConfig `json:"backend"`
+	// Backends are additional backends whose services are named
+	// "<backend name>:<service>"
+	Backends []BackendConfig `json:"backends,omitempty"`
+	// Services carries per-service settings; listed services are allowed
+	// even when missing from AllowedServices
+	Services []ServiceConfig `json:"services,omitempty"`
+	// Groups orders the dashboard sections
+	Groups []GroupConfig `json:"groups,omitempty"`
+	// Profiles are named, ordered sets of services started and stopped
+	// together
+	Profiles []ProfileConfig `json:"profiles,omitempty"`
+	// Tasks are predefined one-shot commands admins can run as transient
+	// units
+	Tasks []TaskConfig `json:"tasks,omitempty"`
+	// PowerActions enables suspend, hibernate, reboot and poweroff of the
+	// host for admins
+	PowerActions []string `json:"power_actions,omitempty"`
+	// WakeHosts are remote machines that can be woken with Wake-on-LAN
+	WakeHosts []WakeHost `json:"wake_hosts,omitempty"`
+	// ReadTimeout and WriteTimeout bound reading a request and writing its
+	// response; zero disables them. Long-polls, actions with a wait and the
+	// event stream extend the write deadline of their own requests.
+	ReadTimeout  Duration `json:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout"`
+	// IdleTimeout is how long a keep-alive connection may wait for its next
+	// request
+	IdleTimeout Duration `json:"idle_timeout"`
+	// MaxBodyBytes caps the body of any request; endpoints may accept less
+	MaxBodyBytes int64  `json:"max_body_bytes"`
+	StorePath    string `json:"store_path,omitempty"`
+	// StoreDriver selects where users are kept: "file" or "bolt"
+	// (StorePath), or "postgres" (StoreDSN)
+	StoreDriver     string   `json:"store_driver,omitempty"`
+	StoreDSN        string   `json:"store_dsn,omitempty"`
+	TrustedProxies  []string `json:"trusted_proxies,omitempty"`
+	ReadOnly        bool     `json:"read_only,omitempty"`
+	ReadOnlyMessage string   `json:"read_only_message,omitempty"`
+	DryRun          bool     `json:"dry_run,omitempty"`
+	PublicBadges    []string `json:"public_badges,omitempty"`
+	PublicStatus    []string `json:"public_status,omitempty"`
+	RefreshInterval Duration `json:"refresh_interval"`
+	// StatusInterval is how often unit states are read in the background;
+	// requests are answered from the last read. Zero reads on every request.
+	StatusInterval Duration       `json:"status_interval"`
+	Theme          ThemeConfig    `json:"theme"`
+	Metrics        MetricsConfig  `json:"metrics"`
+	HostStats      HostStats      `json:"host_stats"`
+	Plugins        PluginsConfig  `json:"plugins"`
+	Flapping       FlappingConfig `json:"flapping"`
+	// Maintenance windows suppress alerts for selected services
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+	Debug       DebugConfig         `json:"debug"`
+	Log         LogConfig           `json:"log"`
+	Auth        AuthConfig          `json:"auth"`
+	RateLimits  RateLimits          `json:"rate_limits"`
+
+	// Rules automate actions when their condition holds
+	Rules []RuleConfig `json:"rules,omitempty"`
+	// RulesInterval is how often rules are evaluated besides on events
+	RulesInterval Duration `json:"rules_interval"`
+
+	// Hooks are inbound webhooks at /hooks/{name} that run actions for
+	// callers holding their secret
+	Hooks []HookConfig `json:"hooks,omitempty"`
+
+	// DesiredState enforces service states declared in a file
+	DesiredState DesiredStateConfig `json:"desired_state"`
+
+	// UpdateCheck enables a daily check for new releases on GitHub
+	UpdateCheck bool `json:"update_check,omitempty"`
+
+	// GraphQL enables the read-only query endpoint at /api/graphql
+	GraphQL bool `json:"graphql,omitempty"`
+
+	// InventoryHost names this host in the Ansible inventory at
+	// /api/inventory; the default is the hostname
+	InventoryHost string `json:"inventory_host,omitempty"`
+
+	// SNMP serves service states to SNMP pollers
+	SNMP SNMPConfig `json:"snmp"`
+	// Influx pushes metrics to InfluxDB or VictoriaMetrics
+	Influx InfluxConfig `json:"influx"`
+	// StatsD emits counters and gauges over StatsD UDP
+	StatsD StatsDConfig `json:"statsd"`
+
+	// ACME serves HTTPS with certificates obtained automatically
+	ACME ACMEConfig `json:"acme"`
+	// H2C accepts HTTP/2 without TLS from clients with prior knowledge,
+	// such as reverse proxies speaking HTTP/2 to the backend
+	H2C bool `json:"h2c,omitempty"`
+	// HTTP3 also serves HTTPS over QUIC on the same UDP port, announced to
+	// clients in Alt-Svc headers. Experimental; needs ACME.
+	HTTP3 bool `json:"http3,omitempty"`
+
+	// ActionTimeout bounds how long start/stop may take
+	ActionTimeout Duration `json:"action_timeout"`
+	// AsyncAfter is how long a request waits for an action before it
+	// returns 202 Accepted with a job to poll; zero always waits
+	AsyncAfter Duration `json:"async_after"`
+	// JobWorkers is how many actions on different services run in parallel
+	JobWorkers int `json:"job_workers"`
+	// EventBuffer is how many events a client of the live event stream may
+	// fall behind before it is disconnected
+	EventBuffer int `json:"event_buffer"`
+	// EventHistory is how many recent state changes and actions are kept
+	// in memory for GET /api/events/recent; zero disables the history
+	EventHistory int `json:"event_history"`
+
+	Notifications Notifications `json:"notifications"`
+}
+
+// MetricsConfig configures per-service CPU and memory sampling
+type MetricsConfig struct {
+	// Interval between samples; zero disables sampling
+	Interval Duration `json:"interval"`
+	// Samples is how many recent samples are kept per service
+	Samples int `json:"samples"`
+}
+
+// HostStats configures the host resource panel
+type HostStats struct {
+	// Mounts whose disk usage is shown
+	Mounts []string `json:"mounts"`
+}
+
+// PluginsConfig configures external plugin executables
+type PluginsConfig struct {
+	// Dir is scanned for plugins at startup; empty disables plugins
+	Dir string `json:"dir,omitempty"`
+	// Timeout bounds each plugin call
+	Timeout Duration `json:"timeout"`
+}
+
+// ACMEConfig configures automatic certificates from an ACME CA such as
+// Let's Encrypt. Listing domains switches the server to HTTPS.
+type ACMEConfig struct {
+	Domains []string `json:"domains,omitempty"`
+	// Email receives expiry notices from the CA
+	Email string `json:"email,omitempty"`
+	// Directory is the CA's directory URL
+	Directory string `json:"directory"`
+	// CacheDir keeps the account key and certificate across restarts
+	CacheDir string `json:"cache_dir"`
+	// Challenge is http-01 or dns-01
+	Challenge string `json:"challenge"`
+	// HTTPPort serves http-01 challenges and redirects other requests to
+	// HTTPS; zero disables the plain HTTP listener
+	HTTPPort int `json:"http_port"`
+	// DNSProvider answers dns-01 challenges: cloudflare or the name of a
+	// plugin providing dns
+	DNSProvider     string `json:"dns_provider,omitempty"`
+	CloudflareToken string `json:"cloudflare_token,omitempty"`
+	// RenewBefore is how long before expiry certificates are renewed
+	RenewBefore Duration `json:"renew_before"`
+}
+
+// Enabled reports whether certificates should be obtained
+func (a ACMEConfig) Enabled() bool {
+	return len(a.Domains) > 0
+}
+
+// FlappingConfig configures crash-loop detection
+type FlappingConfig struct {
+	// Threshold is how many automatic restarts within Window mark a unit
+	// as flapping; zero disables detection
+	Threshold int      `json:"threshold"`
+	Window    Duration `json:"window"`
+}
+
+// BackendConfig selects and configures the init-system backend
+type BackendConfig struct {
+	// Name namespaces the services of an additional backend; it is
+	// ignored for the primary backend
+	Name string `json:"name,omitempty"`
+	// Type is systemd (default), openrc, runit, s6, kubernetes, compose
+	// or plugin
+	Type string `json:"type"`
+	// Plugin names the plugin serving a backend of type plugin
+	Plugin string       `json:"plugin,omitempty"`
+	OpenRC OpenRCConfig `json:"openrc"`
+	// Supervisor configures the runit and s6 backends
+	Supervisor SupervisorConfig `json:"supervisor"`
+	Kubernetes KubernetesConfig `json:"kubernetes"`
+	Compose    ComposeConfig    `json:"compose"`
+}
+
+// ComposeConfig configures the Compose backend, which controls each
+// project under StacksDir as one service
+type ComposeConfig struct {
+	// Command runs compose, e.g. "docker compose" or "podman-compose"
+	Command string `json:"command"`
+	// StacksDir holds one project directory per stack
+	StacksDir string `json:"stacks_dir,omitempty"`
+}
+
+// KubernetesConfig configures the Kubernetes backend, which scales
+// Deployments and StatefulSets through kubectl
+type KubernetesConfig struct {
+	// Namespace of the workloads; empty uses kubectl's default
+	Namespace string `json:"namespace,omitempty"`
+	// Context selects a kubeconfig context; empty uses the current one
+	Context string `json:"context,omitempty"`
+	// Replicas is what start scales to when a workload has no remembered
+	// replica count; zero means 1
+	Replicas int `json:"replicas"`
+}
+
+// SupervisorConfig configures the runit and s6 backends
+type SupervisorConfig struct {
+	// ServiceDir holds the service directories; the default is
+	// /var/service for runit and /run/service for s6
+	ServiceDir string `json:"service_dir,omitempty"`
+	// LogDir holds svlogd/s6-log directories, read as <name>/current
+	LogDir string `json:"log_dir,omitempty"`
+}
+
+// OpenRCConfig configures the OpenRC backend
+type OpenRCConfig struct {
+	// LogDir holds <service>.log files shown as the service's logs
+	LogDir string `json:"log_dir,omitempty"`
+	// User controls user services (rc-service --user)
+	User bool `json:"user,omitempty"`
+}
+
+// WakeHost is a remote machine woken by a Wake-on-LAN magic packet
+type WakeHost struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+	MAC         string `json:"mac"`
+	// Broadcast is the UDP address packets are sent to, e.g.
+	// 192.168.1.255 or 192.168.1.255:7; the default is 255.255.255.255:9
+	Broadcast string `json:"broadcast,omitempty"`
+}
+
+// MaintenanceWindow silences failure and restart alerts for services
+// while a cron schedule's window is open
+type MaintenanceWindow struct {
+	Name string `json:"name"`
+	// Schedule is a cron expression ("0 3 * * *") or alias ("@daily")
+	// marking when the window opens
+	Schedule string   `json:"schedule"`
+	Duration Duration `json:"duration"`
+	// Services covered by the window; empty covers all services
+	Services []string `json:"services,omitempty"`
+}
+
+// RuleConfig declares an automation: when the expression When holds, the
+// actions run, at most once per Cooldown
+type RuleConfig struct {
+	Name string `json:"name"`
+	// When is an expression such as
+	// state("jellyfin") == "failed" && state_for("jellyfin") >= 5m
+	When    string       `json:"when"`
+	Actions []RuleAction `json:"actions,omitempty"`
+	// Notify sends the rule.fired event to notification channels
+	Notify   bool     `json:"notify,omitempty"`
+	Cooldown Duration `json:"cooldown,omitempty"`
+}
+
+// RuleAction is a start, stop or restart of a service
+type RuleAction struct {
+	Action  string `json:"action"`
+	Service string `json:"service"`
+}
+
+// DesiredStateConfig points at a file of "service: running|stopped"
+// lines that a reconciler enforces every Interval
+type DesiredStateConfig struct {
+	File     string   `json:"file,omitempty"`
+	Interval Duration `json:"interval"`
+	// GitPull pulls the git repository holding File before each pass
+	GitPull bool `json:"git_pull,omitempty"`
+	// ReportOnly shows drift without starting or stopping anything
+	ReportOnly bool `json:"report_only,omitempty"`
+}
+
+// HookConfig declares an inbound webhook: a POST to /hooks/{name}
+// carrying Secret runs the actions in order
+type HookConfig struct {
+	Name    string       `json:"name"`
+	Secret  string       `json:"secret"`
+	Actions []RuleAction `json:"actions"`
+}
+
+// LogConfig configures application logging
+type LogConfig struct {
+	// Level is debug, info, warn or error
+	Level string `json:"level"`
+	// Format is json or text; it applies to stdout and file output
+	Format string `json:"format"`
+	// Output is stdout (default, or File when set), journald or syslog
+	Output string `json:"output,omitempty"`
+	// File writes logs to a rotated file instead of stdout
+	File string `json:"file,omitempty"`
+	// MaxSizeMB rotates the file once it grows past this size
+	MaxSizeMB int `json:"max_size_mb,omitempty"`
+	// MaxAge rotates the file once it has been written to for this long
+	MaxAge Duration `json:"max_age,omitempty"`
+	// MaxBackups is how many rotated files are kept
+	MaxBackups int `json:"max_backups,omitempty"`
+	// AccessLog writes requests to stdout, stderr or a file instead of
+	// the application log
+	AccessLog string `json:"access_log,omitempty"`
+	// AccessFormat is common, combined or json
+	AccessFormat string `json:"access_format,omitempty"`
+}
+
+// DebugConfig configures profiling endpoints
+type DebugConfig struct {
+	// Pprof mounts /debug/pprof/ on the main listener behind auth
+	Pprof bool `json:"pprof,omitempty"`
+	// Listen starts a separate unauthenticated listener serving pprof and
+	// runtime stats; bind it to localhost
+	Listen string `json:"listen,omitempty"`
+}
+
+// SNMPConfig configures the read-only SNMPv1/v2c agent
+type SNMPConfig struct {
+	// Listen is the agent's UDP address, such as ":161"; empty disables it
+	Listen string `json:"listen,omitempty"`
+	// Community is the community string pollers must send
+	Community string `json:"community,omitempty"`
+	// OID roots the service table
+	OID string `json:"oid"`
+}
+
+// InfluxConfig configures pushing metrics in InfluxDB line protocol
+type InfluxConfig struct {
+	// URL is the write endpoint, including any org, bucket or db query
+	// parameters; empty disables pushing
+	URL string `json:"url,omitempty"`
+	// Token is sent as "Authorization: Token ..."
+	Token    string   `json:"token,omitempty"`
+	Interval Duration `json:"interval"`
+	// Host tags every point; it defaults to the hostname
+	Host string `json:"host,omitempty"`
+}
+
+// StatsDConfig configures emitting metrics over StatsD UDP
+type StatsDConfig struct {
+	// Addr is the agent's UDP address, such as "127.0.0.1:8125"; empty
+	// disables emitting
+	Addr   string `json:"addr,omitempty"`
+	Prefix string `json:"prefix"`
+	// Tags are "key:value" pairs added to every metric
+	Tags []string `json:"tags,omitempty"`
+	// Format is "dogstatsd", which sends tags, or "statsd", which folds
+	// the service and action into metric names
+	Format   string   `json:"format"`
+	Interval Duration `json:"interval"`
+}
+
+// Notifications configures outgoing notification channels
+type Notifications struct {
+	SMTP    *SMTPConfig    `json:"smtp,omitempty"`
+	Discord *DiscordConfig `json:"discord,omitempty"`
+	Slack   *SlackConfig   `json:"slack,omitempty"`
+}
+
+// SlackConfig configures Slack/Mattermost incoming-webhook notifications
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Channel    string `json:"channel,omitempty"`
+	Username   string `json:"username,omitempty"`
+	IconEmoji  string `json:"icon_emoji,omitempty"`
+	// Events selects the event types to post; empty means all
+	Events []string `json:"events,omitempty"`
+	// RateLimit caps messages per service (or event type)
+	RateLimit RateLimit `json:"rate_limit"`
+}
+
+// DiscordConfig configures Discord webhook notifications
+type DiscordConfig struct {
+	// WebhookURL receives events not matched by a route (optional when
+	// every event of interest is routed)
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// Username overrides the webhook's display name
+	Username string `json:"username,omitempty"`
+	// Events selects the event types to post; empty means all
+	Events []string `json:"events,omitempty"`
+	// Routes sends events for specific services to other channels
+	Routes []DiscordRoute `json:"routes,omitempty"`
+}
+
+// DiscordRoute maps services to a dedicated webhook
+type DiscordRoute struct {
+	Services   []string `json:"services"`
+	WebhookURL string   `json:"webhook_url"`
+}
+
+// SMTPConfig configures email notifications
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"-"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// TLS uses implicit TLS (port 465); otherwise STARTTLS is used when offered
+	TLS bool `json:"tls,omitempty"`
+	// Events selects the event types to email; empty means all
+	Events []string `json:"events,omitempty"`
+	// Templates overrides the subject/body per event type (Go text/template)
+	Templates map[string]EmailTemplate `json:"templates,omitempty"`
+}
+
+// EmailTemplate is a subject/body template pair rendered with an audit event
+type EmailTemplate struct {
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body,omitempty"`
+}
+
+// ThemeConfig holds UI theme defaults
+type ThemeConfig struct {
+	// Default is "light", "dark" or "system" (follow the browser)
+	Default string `json:"default,omitempty"`
+	// Accent is a hex colour used for primary buttons and highlights
+	Accent string `json:"accent,omitempty"`
+}
+
+// ServiceConfig holds per-service settings
+type ServiceConfig struct {
+	Name        string `json:"name"`
+	Group       string `json:"group,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Icon is an emoji or an image URL
+	Icon string `json:"icon,omitempty"`
+	// URL links to the service's own web UI
+	URL string `json:"url,omitempty"`
+	// Protected asks for confirmation before stop/restart in the UI
+	Protected bool `json:"protected,omitempty"`
+	// ActionTimeout overrides the global action timeout for this service
+	ActionTimeout Duration `json:"action_timeout,omitempty"`
+	// Exclusive names a group of services of which only one may run;
+	// starting one stops the others
+	Exclusive string `json:"exclusive,omitempty"`
+	// EnvOverrides whitelists environment variables that may be set when
+	// starting the service, each with its allowed values (none: any)
+	EnvOverrides map[string][]string `json:"env_overrides,omitempty"`
+	// MinFree refuses to start the service while any listed filesystem
+	// has less free space than required
+	MinFree []FreeSpace `json:"min_free,omitempty"`
+}
+
+// FreeSpace requires Min bytes free on the filesystem holding Path
+type FreeSpace struct {
+	Path string   `json:"path"`
+	Min  ByteSize `json:"min"`
+}
+
+// GroupConfig declares a dashboard section
+type GroupConfig struct {
+	Name      string `json:"name"`
+	Collapsed bool   `json:"collapsed,omitempty"`
+}
+
+// ProfileConfig declares services that start in order, each waiting for
+// the previous one to become active, and stop in reverse
+type ProfileConfig struct {
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Services    []string `json:"services"`
+	// WaitTimeout bounds how long each step waits for its unit
+	WaitTimeout Duration `json:"wait_timeout,omitempty"`
+}
+
+// TaskConfig declares a one-shot command run with systemd-run
+type TaskConfig struct {
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Command     []string `json:"command"`
+	// Timeout kills the task if it runs longer (default 1h)
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// RateLimits configures the request budgets, per client IP unless noted
+type RateLimits struct {
+	// Status covers dashboard loads, status reads and static assets
+	Status RateLimit `json:"status"`
+	// Control covers start/stop and other state-changing actions
+	Control RateLimit `json:"control"`
+	// AuthFailure is consumed only by failed logins
+	AuthFailure RateLimit `json:"auth_failure"`
+	// UserStatus and UserControl are the same budgets per authenticated
+	// user, across all of their addresses; zero per_minute disables them
+	UserStatus  RateLimit `json:"user_status"`
+	UserControl RateLimit `json:"user_control"`
+	// Endpoints adds budgets for paths, on top of the budgets above
+	Endpoints []EndpointLimit `json:"endpoints,omitempty"`
+}
+
+// EndpointLimit is a budget for requests whose path starts with Path
+type EndpointLimit struct {
+	Path string `json:"path"`
+	RateLimit
+	// PerUser keys the budget by authenticated user instead of client IP
+	PerUser bool `json:"per_user,omitempty"`
+}
+
+// RateLimit is a token bucket: PerMinute sustained requests with bursts
+// of up to Burst
+type RateLimit struct {
+	PerMinute int `json:"per_minute"`
+	Burst     int `json:"burst"`
+}
+
+// AuthConfig holds authentication settings. Credentials are only ever
+// taken from the environment or the store, never from the config file.
+type AuthConfig struct {
+	Username     string   `json:"-"`
+	Password     string   `json:"-"`
+	ProxyTrusted []string `json:"proxy_trusted,omitempty"`
+	ProxyHeaders []string `json:"proxy_headers,omitempty"`
+
+	// Failed logins allowed per client IP or username before lockout
+	MaxFailures int `json:"max_failures"`
+	// LockoutBase is the first lockout, doubled for each further failure
+	// up to LockoutMax
+	LockoutBase Duration `json:"lockout_base"`
+	LockoutMax  Duration `json:"lockout_max"`
+}
+
+// Duration is a time.Duration encoded as a string ("15s") in JSON
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"15s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ByteSize is a byte count encoded in JSON as a number or a string with a
+// unit ("10GB", "512MiB")
+type ByteSize uint64
+
+// byteUnits maps size suffixes to multipliers
+var byteUnits = map[string]uint64{
+	"": 1, "B": 1,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+	"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30, "TIB": 1 << 40,
+}
+
+// ParseByteSize parses a size like "10GB", "1.5GiB" or "4096"
+func ParseByteSize(value string) (ByteSize, error) {
+	value = strings.TrimSpace(value)
+	i := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(value)
+	}
+	n, err := strconv.ParseFloat(value[:i], 64)
+	unit, ok := byteUnits[strings.ToUpper(strings.TrimSpace(value[i:]))]
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("invalid size %q: expected a number with an optional unit like 10GB or 512MiB", value)
+	}
+	return ByteSize(n * float64(unit)), nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (b ByteSize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(uint64(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n uint64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("size must be a number of bytes or a string like \"10GB\": %w", err)
+	}
+	parsed, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+// hexColor matches #rgb and #rrggbb colours
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// taskName matches task names, which become part of a unit name
+var taskName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// envName matches environment variable names accepted as overrides
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// hostName restricts wake host names, which appear in URLs
+var hostName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// backendName restricts the namespaces of additional backends
+var backendName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// acmeDomain matches host names, optionally with a leading wildcard label
+var acmeDomain = regexp.MustCompile(`^(\*\.)?([A-Za-z0-9-]+\.)+[A-Za-z0-9-]+$`)
+
+// basePath matches URL prefixes made of plain path segments
+var basePath = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// Default returns the built-in configuration defaults
+func Default() *Config {
+	return &Config{
+		Host:            "127.0.0.1",
+		Port:            8081,
+		AllowedServices: []string{"calibre.service", "jellyfin.service", "navidrome.service"},
+		Backend: BackendConfig{
+			Type:       "systemd",
+			Kubernetes: KubernetesConfig{Replicas: 1},
+			Compose:    ComposeConfig{Command: "docker compose"},
+		},
+		ReadTimeout:     Duration(15 * time.Second),
+		WriteTimeout:    Duration(15 * time.Second),
+		IdleTimeout:     Duration(60 * time.Second),
+		MaxBodyBytes:    1 << 20,
+		RefreshInterval: Duration(30 * time.Second),
+		StatusInterval:  Duration(5 * time.Second),
+		ActionTimeout:   Duration(30 * time.Second),
+		AsyncAfter:      Duration(5 * time.Second),
+		JobWorkers:      4,
+		EventBuffer:     64,
+		EventHistory:    100,
+		RulesInterval:   Duration(30 * time.Second),
+		DesiredState:    DesiredStateConfig{Interval: Duration(time.Minute)},
+		SNMP:            SNMPConfig{OID: snmp.DefaultOID},
+		Influx:          InfluxConfig{Interval: Duration(30 * time.Second)},
+		StatsD:          StatsDConfig{Prefix: "sysdwitch.", Format: "dogstatsd", Interval: Duration(10 * time.Second)},
+		Log: LogConfig{
+			Level:      "info",
+			Format:     "json",
+			MaxSizeMB:  100,
+			MaxBackups: 5,
+		},
+		Metrics: MetricsConfig{
+			Interval: Duration(30 * time.Second),
+			Samples:  120,
+		},
+		HostStats: HostStats{Mounts: []string{"/"}},
+		Plugins:   PluginsConfig{Timeout: Duration(10 * time.Second)},
+		ACME: ACMEConfig{
+			Directory:   "https://acme-v02.api.letsencrypt.org/directory",
+			CacheDir:    "data/acme",
+			Challenge:   "http-01",
+			HTTPPort:    80,
+			RenewBefore: Duration(30 * 24 * time.Hour),
+		},
+		Flapping: FlappingConfig{
+			Threshold: 5,
+			Window:    Duration(10 * time.Minute),
+		},
+		Auth: AuthConfig{
+			MaxFailures: 5,
+			LockoutBase: Duration(time.Minute),
+			LockoutMax:  Duration(time.Hour),
+		},
+		RateLimits: RateLimits{
+			Status:      RateLimit{PerMinute: 120, Burst: 60},
+			Control:     RateLimit{PerMinute: 20, Burst: 10},
+			AuthFailure: RateLimit{PerMinute: 10, Burst: 5},
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the config file at path
+// (skipped when path is empty) and environment variable overrides
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Parse reads a config file's JSON from r over the defaults, without
+// consulting the environment
+func Parse(r io.Reader) (*Config, error) {
+	cfg := Default()
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile merges the JSON config file into cfg
+func (cfg *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides configuration values with environment variables
+func (cfg *Config) applyEnv() error {
+	if value := os.Getenv("HOST"); value != "" {
+		cfg.Host = value
+	}
+	if value := os.Getenv("PORT"); value != "" {
+		if port, err := strconv.Atoi(value); err == nil {
+			cfg.Port = port
+		}
+	}
+	if value := os.Getenv("BASE_PATH"); value != "" {
+		cfg.BasePath = strings.TrimRight(value, "/")
+	}
+	if value := os.Getenv("ALLOWED_SERVICES"); value != "" {
+		cfg.AllowedServices = SplitList(value)
+		if len(cfg.AllowedServices) != len(strings.Split(value, ",")) {
+			return errors.New("empty service name in ALLOWED_SERVICES")
+		}
+	}
+	if value := os.Getenv("SERVICE_GROUPS"); value != "" {
+		if err := cfg.applyServiceGroups(value); err != nil {
+			return err
+		}
+	}
+	if value := os.Getenv("PROTECTED_SERVICES"); value != "" {
+		for _, name := range SplitList(value) {
+			cfg.Service(name).Protected = true
+		}
+	}
+	if value := os.Getenv("EXCLUSIVE_GROUPS"); value != "" {
+		// games=factorio|minecraft;other=a|b
+		for _, entry := range strings.Split(value, ";") {
+			group, services, ok := strings.Cut(entry, "=")
+			if group = strings.TrimSpace(group); !ok || group == "" {
+				return fmt.Errorf("invalid EXCLUSIVE_GROUPS entry %q: expected group=svc1|svc2", entry)
+			}
+			for _, name := range strings.Split(services, "|") {
+				if name = strings.TrimSpace(name); name != "" {
+					cfg.Service(name).Exclusive = group
+				}
+			}
+		}
+	}
+	if value := os.Getenv("MIN_FREE_SPACE"); value != "" {
+		// qbittorrent=/data:10GB|/tmp:1GB;other=/srv:5GB
+		for _, entry := range strings.Split(value, ";") {
+			name, checks, ok := strings.Cut(entry, "=")
+			if name = strings.TrimSpace(name); !ok || name == "" {
+				return fmt.Errorf("invalid MIN_FREE_SPACE entry %q: expected service=/path:size|/path:size", entry)
+			}
+			svc := cfg.Service(name)
+			svc.MinFree = nil
+			for _, check := range strings.Split(checks, "|") {
+				path, size, ok := strings.Cut(strings.TrimSpace(check), ":")
+				if !ok {
+					return fmt.Errorf("invalid MIN_FREE_SPACE check %q: expected /path:size", check)
+				}
+				min, err := ParseByteSize(size)
+				if err != nil {
+					return fmt.Errorf("invalid MIN_FREE_SPACE: %w", err)
+				}
+				svc.MinFree = append(svc.MinFree, FreeSpace{Path: path, Min: min})
+			}
+		}
+	}
+	if value := os.Getenv("PROFILES"); value != "" {
+		// name:svc1,svc2;other:svc3
+		cfg.Profiles = nil
+		for _, entry := range strings.Split(value, ";") {
+			name, services, ok := strings.Cut(entry, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				return fmt.Errorf("invalid PROFILES entry %q: expected name:service1,service2", entry)
+			}
+			cfg.Profiles = append(cfg.Profiles, ProfileConfig{Name: strings.TrimSpace(name), Services: SplitList(services)})
+		}
+	}
+	if value := os.Getenv("STORE_PATH"); value != "" {
+		cfg.StorePath = value
+	}
+	if value := os.Getenv("STORE_DRIVER"); value != "" {
+		cfg.StoreDriver = value
+	}
+	if value := os.Getenv("STORE_DSN"); value != "" {
+		cfg.StoreDSN = value
+	}
+	if value := os.Getenv("READ_ONLY"); value != "" {
+		readOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid READ_ONLY: %w", err)
+		}
+		cfg.ReadOnly = readOnly
+	}
+	if value := os.Getenv("READ_ONLY_MESSAGE"); value != "" {
+		cfg.ReadOnlyMessage = value
+	}
+	if value := os.Getenv("DRY_RUN"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid DRY_RUN: %w", err)
+		}
+		cfg.DryRun = dryRun
+	}
+	if value := os.Getenv("POWER_ACTIONS"); value != "" {
+		cfg.PowerActions = SplitList(value)
+	}
+	if value := os.Getenv("WOL_HOSTS"); value != "" {
+		// nas=aa:bb:cc:dd:ee:ff@192.168.1.255;backup=11:22:33:44:55:66
+		cfg.WakeHosts = nil
+		for _, entry := range strings.Split(value, ";") {
+			name, target, ok := strings.Cut(entry, "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				return fmt.Errorf("invalid WOL_HOSTS entry %q: expected name=mac[@broadcast]", entry)
+			}
+			mac, broadcast, _ := strings.Cut(strings.TrimSpace(target), "@")
+			cfg.WakeHosts = append(cfg.WakeHosts, WakeHost{Name: strings.TrimSpace(name), MAC: mac, Broadcast: broadcast})
+		}
+	}
+	if value := os.Getenv("PUBLIC_BADGES"); value != "" {
+		cfg.PublicBadges = SplitList(value)
+	}
+	if value := os.Getenv("PUBLIC_STATUS"); value != "" {
+		cfg.PublicStatus = SplitList(value)
+	}
+	if value := os.Getenv("REFRESH_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid REFRESH_INTERVAL: %w", err)
+		}
+		cfg.RefreshInterval = Duration(d)
+	}
+	if value := os.Getenv("ACTION_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid ACTION_TIMEOUT: %w", err)
+		}
+		cfg.ActionTimeout = Duration(d)
+	}
+	if value := os.Getenv("ACTION_ASYNC_AFTER"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid ACTION_ASYNC_AFTER: %w", err)
+		}
+		cfg.AsyncAfter = Duration(d)
+	}
+	if value := os.Getenv("JOB_WORKERS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid JOB_WORKERS: %w", err)
+		}
+		cfg.JobWorkers = n
+	}
+	for name, target := range map[string]*Duration{
+		"READ_TIMEOUT":  &cfg.ReadTimeout,
+		"WRITE_TIMEOUT": &cfg.WriteTimeout,
+		"IDLE_TIMEOUT":  &cfg.IdleTimeout,
+	} {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = Duration(d)
+		}
+	}
+	if value := os.Getenv("MAX_BODY_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid MAX_BODY_BYTES: %w", err)
+		}
+		cfg.MaxBodyBytes = n
+	}
+	if value := os.Getenv("EVENT_BUFFER"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid EVENT_BUFFER: %w", err)
+		}
+		cfg.EventBuffer = n
+	}
+	if value := os.Getenv("EVENT_HISTORY"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid EVENT_HISTORY: %w", err)
+		}
+		cfg.EventHistory = n
+	}
+	if value := os.Getenv("STATUS_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid STATUS_INTERVAL: %w", err)
+		}
+		cfg.StatusInterval = Duration(d)
+	}
+	if value := os.Getenv("RULES_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid RULES_INTERVAL: %w", err)
+		}
+		cfg.RulesInterval = Duration(d)
+	}
+	if value := os.Getenv("DESIRED_STATE_FILE"); value != "" {
+		cfg.DesiredState.File = value
+	}
+	if value := os.Getenv("DESIRED_STATE_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid DESIRED_STATE_INTERVAL: %w", err)
+		}
+		cfg.DesiredState.Interval = Duration(d)
+	}
+	if value := os.Getenv("DESIRED_STATE_GIT_PULL"); value != "" {
+		gitPull, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid DESIRED_STATE_GIT_PULL: %w", err)
+		}
+		cfg.DesiredState.GitPull = gitPull
+	}
+	if value := os.Getenv("DESIRED_STATE_REPORT_ONLY"); value != "" {
+		reportOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid DESIRED_STATE_REPORT_ONLY: %w", err)
+		}
+		cfg.DesiredState.ReportOnly = reportOnly
+	}
+	if value := os.Getenv("METRICS_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid METRICS_INTERVAL: %w", err)
+		}
+		cfg.Metrics.Interval = Duration(d)
+	}
+	if value := os.Getenv("METRICS_SAMPLES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid METRICS_SAMPLES: %w", err)
+		}
+		cfg.Metrics.Samples = n
+	}
+	if value := os.Getenv("FLAP_THRESHOLD"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid FLAP_THRESHOLD: %w", err)
+		}
+		cfg.Flapping.Threshold = n
+	}
+	if value := os.Getenv("HOST_MOUNTS"); value != "" {
+		cfg.HostStats.Mounts = SplitList(value)
+	}
+	if value := os.Getenv("FLAP_WINDOW"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid FLAP_WINDOW: %w", err)
+		}
+		cfg.Flapping.Window = Duration(d)
+	}
+	if value := os.Getenv("BACKEND"); value != "" {
+		cfg.Backend.Type = value
+	}
+	if value := os.Getenv("OPENRC_LOG_DIR"); value != "" {
+		cfg.Backend.OpenRC.LogDir = value
+	}
+	if value := os.Getenv("SERVICE_DIR"); value != "" {
+		cfg.Backend.Supervisor.ServiceDir = value
+	}
+	if value := os.Getenv("SUPERVISOR_LOG_DIR"); value != "" {
+		cfg.Backend.Supervisor.LogDir = value
+	}
+	if value := os.Getenv("KUBE_NAMESPACE"); value != "" {
+		cfg.Backend.Kubernetes.Namespace = value
+	}
+	if value := os.Getenv("KUBE_CONTEXT"); value != "" {
+		cfg.Backend.Kubernetes.Context = value
+	}
+	if value := os.Getenv("KUBE_REPLICAS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid KUBE_REPLICAS: %w", err)
+		}
+		cfg.Backend.Kubernetes.Replicas = n
+	}
+	if value := os.Getenv("BACKEND_PLUGIN"); value != "" {
+		cfg.Backend.Plugin = value
+	}
+	if value := os.Getenv("PLUGINS_DIR"); value != "" {
+		cfg.Plugins.Dir = value
+	}
+	if value := os.Getenv("PLUGIN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid PLUGIN_TIMEOUT: %w", err)
+		}
+		cfg.Plugins.Timeout = Duration(d)
+	}
+	if value := os.Getenv("H2C"); value != "" {
+		h2c, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid H2C: %w", err)
+		}
+		cfg.H2C = h2c
+	}
+	if value := os.Getenv("HTTP3"); value != "" {
+		http3, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid HTTP3: %w", err)
+		}
+		cfg.HTTP3 = http3
+	}
+	if value := os.Getenv("ACME_DOMAINS"); value != "" {
+		cfg.ACME.Domains = SplitList(value)
+	}
+	if value := os.Getenv("ACME_EMAIL"); value != "" {
+		cfg.ACME.Email = value
+	}
+	if value := os.Getenv("ACME_DIRECTORY"); value != "" {
+		cfg.ACME.Directory = value
+	}
+	if value := os.Getenv("ACME_CACHE_DIR"); value != "" {
+		cfg.ACME.CacheDir = value
+	}
+	if value := os.Getenv("ACME_CHALLENGE"); value != "" {
+		cfg.ACME.Challenge = value
+	}
+	if value := os.Getenv("ACME_HTTP_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid ACME_HTTP_PORT: %w", err)
+		}
+		cfg.ACME.HTTPPort = port
+	}
+	if value := os.Getenv("ACME_DNS_PROVIDER"); value != "" {
+		cfg.ACME.DNSProvider = value
+	}
+	if value := os.Getenv("CLOUDFLARE_API_TOKEN"); value != "" {
+		cfg.ACME.CloudflareToken = value
+	}
+	if value := os.Getenv("ACME_RENEW_BEFORE"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid ACME_RENEW_BEFORE: %w", err)
+		}
+		cfg.ACME.RenewBefore = Duration(d)
+	}
+	if value := os.Getenv("COMPOSE_CLI"); value != "" {
+		cfg.Backend.Compose.Command = value
+	}
+	if value := os.Getenv("COMPOSE_STACKS_DIR"); value != "" {
+		cfg.Backend.Compose.StacksDir = value
+	}
+	if value := os.Getenv("OPENRC_USER"); value != "" {
+		user, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid OPENRC_USER: %w", err)
+		}
+		cfg.Backend.OpenRC.User = user
+	}
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		cfg.Log.Level = value
+	}
+	if value := os.Getenv("LOG_FORMAT"); value != "" {
+		cfg.Log.Format = value
+	}
+	if value := os.Getenv("LOG_OUTPUT"); value != "" {
+		cfg.Log.Output = value
+	}
+	if value := os.Getenv("LOG_FILE"); value != "" {
+		cfg.Log.File = value
+	}
+	if value := os.Getenv("ACCESS_LOG"); value != "" {
+		cfg.Log.AccessLog = value
+	}
+	if value := os.Getenv("ACCESS_LOG_FORMAT"); value != "" {
+		cfg.Log.AccessFormat = value
+	}
+	if value := os.Getenv("LOG_MAX_SIZE_MB"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid LOG_MAX_SIZE_MB: %w", err)
+		}
+		cfg.Log.MaxSizeMB = n
+	}
+	if value := os.Getenv("LOG_MAX_AGE"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid LOG_MAX_AGE: %w", err)
+		}
+		cfg.Log.MaxAge = Duration(d)
+	}
+	if value := os.Getenv("LOG_MAX_BACKUPS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid LOG_MAX_BACKUPS: %w", err)
+		}
+		cfg.Log.MaxBackups = n
+	}
+	if value := os.Getenv("UPDATE_CHECK"); value != "" {
+		check, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid UPDATE_CHECK: %w", err)
+		}
+		cfg.UpdateCheck = check
+	}
+	if value := os.Getenv("GRAPHQL"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid GRAPHQL: %w", err)
+		}
+		cfg.GraphQL = enabled
+	}
+	if value := os.Getenv("INVENTORY_HOST"); value != "" {
+		cfg.InventoryHost = value
+	}
+	if value := os.Getenv("SNMP_LISTEN"); value != "" {
+		cfg.SNMP.Listen = value
+	}
+	if value := os.Getenv("SNMP_COMMUNITY"); value != "" {
+		cfg.SNMP.Community = value
+	}
+	if value := os.Getenv("SNMP_OID"); value != "" {
+		cfg.SNMP.OID = value
+	}
+	if value := os.Getenv("INFLUX_URL"); value != "" {
+		cfg.Influx.URL = value
+	}
+	if value := os.Getenv("INFLUX_TOKEN"); value != "" {
+		cfg.Influx.Token = value
+	}
+	if value := os.Getenv("INFLUX_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid INFLUX_INTERVAL: %w", err)
+		}
+		cfg.Influx.Interval = Duration(d)
+	}
+	if value := os.Getenv("INFLUX_HOST"); value != "" {
+		cfg.Influx.Host = value
+	}
+	if value := os.Getenv("STATSD_ADDR"); value != "" {
+		cfg.StatsD.Addr = value
+	}
+	if value, ok := os.LookupEnv("STATSD_PREFIX"); ok {
+		cfg.StatsD.Prefix = value
+	}
+	if value := os.Getenv("STATSD_TAGS"); value != "" {
+		cfg.StatsD.Tags = SplitList(value)
+	}
+	if value := os.Getenv("STATSD_FORMAT"); value != "" {
+		cfg.StatsD.Format = value
+	}
+	if value := os.Getenv("STATSD_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid STATSD_INTERVAL: %w", err)
+		}
+		cfg.StatsD.Interval = Duration(d)
+	}
+	if value := os.Getenv("DEBUG_PPROF"); value != "" {
+		pprof, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid DEBUG_PPROF: %w", err)
+		}
+		cfg.Debug.Pprof = pprof
+	}
+	if value := os.Getenv("DEBUG_LISTEN"); value != "" {
+		cfg.Debug.Listen = value
+	}
+	if value := os.Getenv("THEME_DEFAULT"); value != "" {
+		cfg.Theme.Default = value
+	}
+	if value := os.Getenv("THEME_ACCENT"); value != "" {
+		cfg.Theme.Accent = value
+	}
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		cfg.TrustedProxies = SplitList(value)
+	}
+
+	cfg.Auth.Username = strings.TrimSpace(os.Getenv("ADMIN_USER"))
+	cfg.Auth.Password = strings.TrimSpace(os.Getenv("ADMIN_PASS"))
+	if value := os.Getenv("AUTH_PROXY_TRUSTED"); value != "" {
+		cfg.Auth.ProxyTrusted = SplitList(value)
+	}
+	if value := os.Getenv("AUTH_PROXY_HEADER"); value != "" {
+		cfg.Auth.ProxyHeaders = SplitList(value)
+	}
+	if value := os.Getenv("AUTH_MAX_FAILURES"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			cfg.Auth.MaxFailures = n
+		}
+	}
+	if value := os.Getenv("AUTH_LOCKOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid AUTH_LOCKOUT: %w", err)
+		}
+		cfg.Auth.LockoutBase = Duration(d)
+	}
+
+	for key, limit := range map[string]*RateLimit{
+		"RATE_LIMIT_STATUS":       &cfg.RateLimits.Status,
+		"RATE_LIMIT_CONTROL":      &cfg.RateLimits.Control,
+		"RATE_LIMIT_AUTH":         &cfg.RateLimits.AuthFailure,
+		"RATE_LIMIT_USER_STATUS":  &cfg.RateLimits.UserStatus,
+		"RATE_LIMIT_USER_CONTROL": &cfg.RateLimits.UserControl,
+	} {
+		if value := os.Getenv(key); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			limit.PerMinute = n
+			if limit.Burst == 0 {
+				limit.Burst = max(1, n/2)
+			}
+		}
+	}
+	if value := os.Getenv("RATE_LIMIT_ENDPOINTS"); value != "" {
+		// /api/graphql=30|10;/api/admin/=10|5|user
+		cfg.RateLimits.Endpoints = nil
+		for _, entry := range strings.Split(value, ";") {
+			path, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			fields := strings.Split(spec, "|")
+			if !ok || len(fields) < 2 || len(fields) > 3 || len(fields) == 3 && fields[2] != "user" {
+				return fmt.Errorf("invalid RATE_LIMIT_ENDPOINTS entry %q: expected /path=per_minute|burst or /path=per_minute|burst|user", entry)
+			}
+			perMinute, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return fmt.Errorf("invalid RATE_LIMIT_ENDPOINTS: %w", err)
+			}
+			burst, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return fmt.Errorf("invalid RATE_LIMIT_ENDPOINTS: %w", err)
+			}
+			cfg.RateLimits.Endpoints = append(cfg.RateLimits.Endpoints, EndpointLimit{
+				Path:      path,
+				RateLimit: RateLimit{PerMinute: perMinute, Burst: burst},
+				PerUser:   len(fields) == 3,
+			})
+		}
+	}
+
+	if value := os.Getenv("SMTP_HOST"); value != "" {
+		if cfg.Notifications.SMTP == nil {
+			cfg.Notifications.SMTP = &SMTPConfig{
+				Port:   587,
+				Events: []string{"service.failed", "service.watchdog_restart", "auth.lockout"},
+			}
+		}
+		cfg.Notifications.SMTP.Host = value
+	}
+	if smtp := cfg.Notifications.SMTP; smtp != nil {
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid SMTP_PORT: %w", err)
+			}
+			smtp.Port = port
+		}
+		if value := os.Getenv("SMTP_USERNAME"); value != "" {
+			smtp.Username = value
+		}
+		smtp.Password = os.Getenv("SMTP_PASSWORD")
+		if value := os.Getenv("SMTP_FROM"); value != "" {
+			smtp.From = value
+		}
+		if value := os.Getenv("SMTP_TO"); value != "" {
+			smtp.To = SplitList(value)
+		}
+		if value := os.Getenv("SMTP_EVENTS"); value != "" {
+			smtp.Events = SplitList(value)
+		}
+	}
+
+	if value := os.Getenv("DISCORD_WEBHOOK_URL"); value != "" {
+		if cfg.Notifications.Discord == nil {
+			cfg.Notifications.Discord = &DiscordConfig{Username: "SysDwitch"}
+		}
+		cfg.Notifications.Discord.WebhookURL = value
+	}
+	if value := os.Getenv("DISCORD_EVENTS"); value != "" && cfg.Notifications.Discord != nil {
+		cfg.Notifications.Discord.Events = SplitList(value)
+	}
+
+	if value := os.Getenv("SLACK_WEBHOOK_URL"); value != "" {
+		if cfg.Notifications.Slack == nil {
+			cfg.Notifications.Slack = &SlackConfig{Username: "SysDwitch"}
+		}
+		cfg.Notifications.Slack.WebhookURL = value
+	}
+	if slack := cfg.Notifications.Slack; slack != nil {
+		if value := os.Getenv("SLACK_EVENTS"); value != "" {
+			slack.Events = SplitList(value)
+		}
+		if slack.RateLimit.PerMinute == 0 {
+			slack.RateLimit = RateLimit{PerMinute: 6, Burst: 3}
+		}
+	}
+
+	return nil
+}
+
+// applyServiceGroups parses SERVICE_GROUPS ("Media=jellyfin|navidrome;Infra=calibre")
+// and assigns the listed services to groups in the given order
+func (cfg *Config) applyServiceGroups(value string) error {
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, services, ok := strings.Cut(entry, "=")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return fmt.Errorf("invalid SERVICE_GROUPS entry %q: expected Group=svc1|svc2", entry)
+		}
+
+		if !slices.ContainsFunc(cfg.Groups, func(g GroupConfig) bool { return g.Name == group }) {
+			cfg.Groups = append(cfg.Groups, GroupConfig{Name: group})
+		}
+		for _, name := range strings.Split(services, "|") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.Service(name).Group = group
+			}
+		}
+	}
+	return nil
+}
+
+// Service returns the settings entry for name, adding one if missing
+func (cfg *Config) Service(name string) *ServiceConfig {
+	key := NormalizeServiceName(name)
+	for i := range cfg.Services {
+		if NormalizeServiceName(cfg.Services[i].Name) == key {
+			return &cfg.Services[i]
+		}
+	}
+	cfg.Services = append(cfg.Services, ServiceConfig{Name: name})
+	return &cfg.Services[len(cfg.Services)-1]
+}
+
+// ServiceNames returns every allowed service: AllowedServices followed by
+// any additional entries from Services
+func (cfg *Config) ServiceNames() []string {
+	names := slices.Clone(cfg.AllowedServices)
+	for _, svc := range cfg.Services {
+		key := NormalizeServiceName(svc.Name)
+		if !slices.ContainsFunc(names, func(n string) bool { return NormalizeServiceName(n) == key }) {
+			names = append(names, svc.Name)
+		}
+	}
+	return names
+}
+
+// NormalizeServiceName appends the .service suffix when missing
+func NormalizeServiceName(name string) string {
+	if !strings.HasSuffix(name, ".service") {
+		name += ".service"
+	}
+	return name
+}
+
+// validateACME checks the certificate settings when ACME is enabled
+func (cfg *Config) validateACME() error {
+	a := cfg.ACME
+	if !a.Enabled() {
+		return nil
+	}
+	for _, domain := range a.Domains {
+		if !acmeDomain.MatchString(domain) {
+			return fmt.Errorf("invalid ACME domain %q", domain)
+		}
+		if strings.HasPrefix(domain, "*.") && a.Challenge != "dns-01" {
+			return fmt.Errorf("wildcard domain %s needs the dns-01 challenge", domain)
+		}
+	}
+	if !strings.HasPrefix(a.Directory, "https://") {
+		return fmt.Errorf("invalid ACME directory %q: expected an https:// URL", a.Directory)
+	}
+	if a.CacheDir == "" {
+		return errors.New("ACME needs a cache directory")
+	}
+	if a.RenewBefore <= 0 {
+		return errors.New("ACME renew_before must be positive")
+	}
+	if a.HTTPPort < 0 || a.HTTPPort > 65535 || a.HTTPPort == cfg.Port {
+		return fmt.Errorf("invalid ACME HTTP port %d: expected 0 or a port other than %d", a.HTTPPort, cfg.Port)
+	}
+	switch a.Challenge {
+	case "http-01":
+		if a.HTTPPort == 0 {
+			return errors.New("the http-01 challenge needs an ACME HTTP port")
+		}
+	case "dns-01":
+		switch a.DNSProvider {
+		case "":
+			return errors.New("the dns-01 challenge needs a DNS provider")
+		case "cloudflare":
+			if a.CloudflareToken == "" {
+				return errors.New("the cloudflare DNS provider needs an API token")
+			}
+		default:
+			if cfg.Plugins.Dir == "" {
+				return fmt.Errorf("DNS provider %q is not cloudflare and no plugins directory is set", a.DNSProvider)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid ACME challenge %q: expected http-01 or dns-01", a.Challenge)
+	}
+	return nil
+}
+
+// validate checks a backend's type and the settings it requires
+func (b BackendConfig) validate() error {
+	switch b.Type {
+	case "systemd", "openrc", "runit", "s6":
+	case "kubernetes":
+		if b.Kubernetes.Replicas < 0 {
+			return errors.New("kubernetes replicas must not be negative")
+		}
+	case "compose":
+		if b.Compose.StacksDir == "" {
+			return errors.New("the compose backend needs a stacks directory")
+		}
+	case "plugin":
+		if b.Plugin == "" {
+			return errors.New("the plugin backend needs a plugin name")
+		}
+	default:
+		return fmt.Errorf("invalid backend %q: expected systemd, openrc, runit, s6, kubernetes, compose or plugin", b.Type)
+	}
+	return nil
+}
+
+// Validate checks the configuration for invalid values
+func (cfg *Config) Validate() error {
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return errors.New("invalid port number")
+	}
+	if cfg.BasePath != "" && !basePath.MatchString(cfg.BasePath) {
+		return fmt.Errorf("invalid base path %q: expected a path like /sysdwitch, without a trailing slash", cfg.BasePath)
+	}
+	switch cfg.Theme.Default {
+	case "", "light", "dark", "system":
+	default:
+		return fmt.Errorf("invalid theme default %q: expected light, dark or system", cfg.Theme.Default)
+	}
+	if cfg.Theme.Accent != "" && !hexColor.MatchString(cfg.Theme.Accent) {
+		return fmt.Errorf("invalid theme accent %q: expected a hex colour like #3b82f6", cfg.Theme.Accent)
+	}
+	if cfg.RefreshInterval < 0 {
+		return errors.New("refresh_interval must not be negative")
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		return fmt.Errorf("invalid log level %q: expected debug, info, warn or error", cfg.Log.Level)
+	}
+	switch cfg.Log.Format {
+	case "json", "text":
+	default:
+		return fmt.Errorf("invalid log format %q: expected json or text", cfg.Log.Format)
+	}
+	switch cfg.Log.Output {
+	case "", "stdout", "journald", "syslog":
+	default:
+		return fmt.Errorf("invalid log output %q: expected stdout, journald or syslog", cfg.Log.Output)
+	}
+	if cfg.Log.File != "" && cfg.Log.Output != "" && cfg.Log.Output != "stdout" {
+		return errors.New("log file cannot be combined with journald or syslog output")
+	}
+	switch cfg.Log.AccessFormat {
+	case "", "common", "combined", "json":
+	default:
+		return fmt.Errorf("invalid access log format %q: expected common, combined or json", cfg.Log.AccessFormat)
+	}
+	if cfg.Log.MaxSizeMB < 0 || cfg.Log.MaxAge < 0 || cfg.Log.MaxBackups < 0 {
+		return errors.New("log rotation limits must not be negative")
+	}
+	if err := cfg.Backend.validate(); err != nil {
+		return err
+	}
+	if cfg.Plugins.Timeout <= 0 {
+		return errors.New("plugin timeout must be positive")
+	}
+	if cfg.Plugins.Dir == "" && (cfg.Backend.Type == "plugin" ||
+		slices.ContainsFunc(cfg.Backends, func(b BackendConfig) bool { return b.Type == "plugin" })) {
+		return errors.New("plugin backends need a plugins directory")
+	}
+	if err := cfg.validateACME(); err != nil {
+		return err
+	}
+	if cfg.HTTP3 && !cfg.ACME.Enabled() {
+		return errors.New("HTTP/3 needs TLS: set ACME domains")
+	}
+	switch cfg.StoreDriver {
+	case "", "file":
+	case "bolt":
+		if cfg.StorePath == "" {
+			return errors.New("the bolt store needs store_path")
+		}
+	case "postgres":
+		if !strings.HasPrefix(cfg.StoreDSN, "postgres://") && !strings.HasPrefix(cfg.StoreDSN, "postgresql://") {
+			return errors.New("the postgres store needs store_dsn as a postgres:// URL")
+		}
+	default:
+		return fmt.Errorf("invalid store driver %q: expected file, bolt or postgres", cfg.StoreDriver)
+	}
+	namespaces := make(map[string]bool)
+	for _, b := range cfg.Backends {
+		if !backendName.MatchString(b.Name) {
+			return fmt.Errorf("invalid backend name %q: use letters, digits, - and _", b.Name)
+		}
+		if namespaces[b.Name] {
+			return fmt.Errorf("duplicate backend %q", b.Name)
+		}
+		namespaces[b.Name] = true
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("backend %s: %w", b.Name, err)
+		}
+	}
+	for _, name := range cfg.ServiceNames() {
+		if namespace, _, ok := strings.Cut(name, ":"); ok && !namespaces[namespace] {
+			return fmt.Errorf("service %s: unknown backend %q", name, namespace)
+		}
+	}
+	// Environment drop-ins and transient units need the primary backend to
+	// be systemd
+	for _, svc := range cfg.Services {
+		if len(svc.EnvOverrides) > 0 && (cfg.Backend.Type != "systemd" || strings.Contains(svc.Name, ":")) {
+			return fmt.Errorf("service %s: environment overrides require the systemd backend", svc.Name)
+		}
+	}
+	if len(cfg.Tasks) > 0 && cfg.Backend.Type != "systemd" {
+		return errors.New("tasks require the systemd backend")
+	}
+	wakeHosts := make(map[string]bool)
+	for _, host := range cfg.WakeHosts {
+		if !hostName.MatchString(host.Name) {
+			return fmt.Errorf("invalid wake host name %q: use letters, digits, - and _", host.Name)
+		}
+		if wakeHosts[host.Name] {
+			return fmt.Errorf("duplicate wake host %q", host.Name)
+		}
+		wakeHosts[host.Name] = true
+		if _, err := wol.MagicPacket(host.MAC); err != nil {
+			return fmt.Errorf("wake host %s: %w", host.Name, err)
+		}
+	}
+	for _, action := range cfg.PowerActions {
+		switch action {
+		case "suspend", "hibernate", "reboot", "poweroff":
+		default:
+			return fmt.Errorf("invalid power action %q: expected suspend, hibernate, reboot or poweroff", action)
+		}
+	}
+	for _, svc := range cfg.Services {
+		for _, check := range svc.MinFree {
+			if !filepath.IsAbs(check.Path) {
+				return fmt.Errorf("service %s: free space path %q must be absolute", svc.Name, check.Path)
+			}
+			if check.Min == 0 {
+				return fmt.Errorf("service %s: free space minimum for %s must be positive", svc.Name, check.Path)
+			}
+		}
+	}
+	for _, svc := range cfg.Services {
+		for key := range svc.EnvOverrides {
+			if !envName.MatchString(key) {
+				return fmt.Errorf("service %s: invalid environment override name %q", svc.Name, key)
+			}
+		}
+	}
+	allowed := make(map[string]bool)
+	for _, name := range cfg.ServiceNames() {
+		allowed[NormalizeServiceName(name)] = true
+	}
+	profiles := make(map[string]bool)
+	for _, p := range cfg.Profiles {
+		if p.Name == "" || strings.ContainsAny(p.Name, "/ ") {
+			return fmt.Errorf("invalid profile name %q", p.Name)
+		}
+		if profiles[p.Name] {
+			return fmt.Errorf("duplicate profile %q", p.Name)
+		}
+		profiles[p.Name] = true
+		if len(p.Services) == 0 {
+			return fmt.Errorf("profile %s has no services", p.Name)
+		}
+		for _, svc := range p.Services {
+			if !allowed[NormalizeServiceName(svc)] {
+				return fmt.Errorf("profile %s: service %s is not allowed", p.Name, svc)
+			}
+		}
+		if p.WaitTimeout < 0 {
+			return fmt.Errorf("profile %s: wait_timeout must not be negative", p.Name)
+		}
+	}
+	tasks := make(map[string]bool)
+	for _, t := range cfg.Tasks {
+		if !taskName.MatchString(t.Name) {
+			return fmt.Errorf("invalid task name %q: use letters, digits, - and _", t.Name)
+		}
+		if tasks[t.Name] {
+			return fmt.Errorf("duplicate task %q", t.Name)
+		}
+		tasks[t.Name] = true
+		if len(t.Command) == 0 || t.Command[0] == "" {
+			return fmt.Errorf("task %s has no command", t.Name)
+		}
+		if t.Timeout < 0 {
+			return fmt.Errorf("task %s: timeout must not be negative", t.Name)
+		}
+	}
+	if cfg.ActionTimeout <= 0 {
+		return errors.New("action_timeout must be positive")
+	}
+	if cfg.AsyncAfter < 0 {
+		return errors.New("async_after must not be negative")
+	}
+	if cfg.JobWorkers < 1 {
+		return errors.New("job_workers must be at least 1")
+	}
+	if cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 || cfg.IdleTimeout < 0 {
+		return errors.New("read_timeout, write_timeout and idle_timeout must not be negative")
+	}
+	if cfg.MaxBodyBytes < 1 {
+		return errors.New("max_body_bytes must be positive")
+	}
+	if cfg.EventBuffer < 1 {
+		return errors.New("event_buffer must be at least 1")
+	}
+	if cfg.EventHistory < 0 {
+		return errors.New("event_history must not be negative")
+	}
+	for _, svc := range cfg.Services {
+		if svc.ActionTimeout < 0 {
+			return fmt.Errorf("service %s: action_timeout must not be negative", svc.Name)
+		}
+	}
+	if cfg.StatusInterval < 0 {
+		return errors.New("status_interval must not be negative")
+	}
+	if cfg.Metrics.Interval < 0 {
+		return errors.New("metrics interval must not be negative")
+	}
+	if cfg.Metrics.Interval > 0 && cfg.Metrics.Samples < 2 {
+		return errors.New("metrics samples must be at least 2")
+	}
+	if cfg.Flapping.Threshold < 0 {
+		return errors.New("flapping threshold must not be negative")
+	}
+	if cfg.Flapping.Threshold > 0 && cfg.Flapping.Window <= 0 {
+		return errors.New("flapping window must be positive")
+	}
+	windows := make(map[string]bool)
+	for _, w := range cfg.Maintenance {
+		if w.Name == "" {
+			return errors.New("maintenance window without a name")
+		}
+		if windows[w.Name] {
+			return fmt.Errorf("duplicate maintenance window %q", w.Name)
+		}
+		windows[w.Name] = true
+		if _, err := schedule.Parse(w.Schedule); err != nil {
+			return fmt.Errorf("maintenance window %s: %w", w.Name, err)
+		}
+		if w.Duration <= 0 || time.Duration(w.Duration) > 7*24*time.Hour {
+			return fmt.Errorf("maintenance window %s: duration must be between 1m and 168h", w.Name)
+		}
+		for _, svc := range w.Services {
+			if !allowed[NormalizeServiceName(svc)] {
+				return fmt.Errorf("maintenance window %s: service %s is not allowed", w.Name, svc)
+			}
+		}
+	}
+	rules := make(map[string]bool)
+	for _, rule := range cfg.Rules {
+		if !taskName.MatchString(rule.Name) {
+			return fmt.Errorf("invalid rule name %q: use letters, digits, - and _", rule.Name)
+		}
+		if rules[rule.Name] {
+			return fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		rules[rule.Name] = true
+		if _, err := expr.Compile(rule.When); err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		if rule.Cooldown < 0 {
+			return fmt.Errorf("rule %s: cooldown must not be negative", rule.Name)
+		}
+		for _, action := range rule.Actions {
+			switch action.Action {
+			case "start", "stop", "restart":
+			default:
+				return fmt.Errorf("rule %s: invalid action %q: expected start, stop or restart", rule.Name, action.Action)
+			}
+			if !allowed[NormalizeServiceName(action.Service)] {
+				return fmt.Errorf("rule %s: service %s is not allowed", rule.Name, action.Service)
+			}
+		}
+	}
+	hooks := make(map[string]bool)
+	for _, hook := range cfg.Hooks {
+		if !taskName.MatchString(hook.Name) {
+			return fmt.Errorf("invalid hook name %q: use letters, digits, - and _", hook.Name)
+		}
+		if hooks[hook.Name] {
+			return fmt.Errorf("duplicate hook %q", hook.Name)
+		}
+		hooks[hook.Name] = true
+		if len(hook.Secret) < 16 {
+			return fmt.Errorf("hook %s: secret must be at least 16 characters", hook.Name)
+		}
+		if len(hook.Actions) == 0 {
+			return fmt.Errorf("hook %s has no actions", hook.Name)
+		}
+		for _, action := range hook.Actions {
+			switch action.Action {
+			case "start", "stop", "restart":
+			default:
+				return fmt.Errorf("hook %s: invalid action %q: expected start, stop or restart", hook.Name, action.Action)
+			}
+			if !allowed[NormalizeServiceName(action.Service)] {
+				return fmt.Errorf("hook %s: service %s is not allowed", hook.Name, action.Service)
+			}
+		}
+	}
+	if cfg.DesiredState.File != "" && cfg.DesiredState.Interval < Duration(time.Second) {
+		return errors.New("desired_state interval must be at least 1s")
+	}
+	if cfg.SNMP.Listen != "" {
+		if cfg.SNMP.Community == "" {
+			return errors.New("snmp community is required when snmp listen is set")
+		}
+		if _, err := snmp.ParseOID(cfg.SNMP.OID); err != nil {
+			return fmt.Errorf("snmp oid: %w", err)
+		}
+	}
+	if cfg.Influx.URL != "" {
+		if !isWebhookURL(cfg.Influx.URL) {
+			return fmt.Errorf("influx url %q must be an http or https URL", cfg.Influx.URL)
+		}
+		if cfg.Influx.Interval < Duration(time.Second) {
+			return errors.New("influx interval must be at least 1s")
+		}
+	}
+	if cfg.StatsD.Addr != "" {
+		if _, _, err := net.SplitHostPort(cfg.StatsD.Addr); err != nil {
+			return fmt.Errorf("statsd addr: %w", err)
+		}
+		if cfg.StatsD.Format != "dogstatsd" && cfg.StatsD.Format != "statsd" {
+			return fmt.Errorf("statsd format %q must be dogstatsd or statsd", cfg.StatsD.Format)
+		}
+		for _, tag := range cfg.StatsD.Tags {
+			if strings.ContainsAny(tag, "|,#\n ") {
+				return fmt.Errorf("statsd tag %q contains a reserved character", tag)
+			}
+		}
+		if cfg.StatsD.Interval < Duration(time.Second) {
+			return errors.New("statsd interval must be at least 1s")
+		}
+	}
+	if len(cfg.Rules) > 0 && cfg.RulesInterval <= 0 {
+		return errors.New("rules_interval must be positive")
+	}
+	if cfg.Auth.MaxFailures < 1 {
+		return errors.New("auth max_failures must be at least 1")
+	}
+	if cfg.Auth.LockoutBase <= 0 || cfg.Auth.LockoutMax < cfg.Auth.LockoutBase {
+		return errors.New("auth lockout_base must be positive and not exceed lockout_max")
+	}
+	for name, limit := range map[string]RateLimit{
+		"status":       cfg.RateLimits.Status,
+		"control":      cfg.RateLimits.Control,
+		"auth_failure": cfg.RateLimits.AuthFailure,
+	} {
+		if limit.PerMinute < 1 || limit.Burst < 1 {
+			return fmt.Errorf("rate limit %s must allow at least 1 request per minute and a burst of 1", name)
+		}
+	}
+	for name, limit := range map[string]RateLimit{
+		"user_status":  cfg.RateLimits.UserStatus,
+		"user_control": cfg.RateLimits.UserControl,
+	} {
+		if limit.PerMinute < 0 || limit.PerMinute > 0 && limit.Burst < 1 {
+			return fmt.Errorf("rate limit %s must be 0 (off) or allow a burst of at least 1", name)
+		}
+	}
+	for _, limit := range cfg.RateLimits.Endpoints {
+		if !strings.HasPrefix(limit.Path, "/") {
+			return fmt.Errorf("invalid rate limit path %q: expected a path starting with /", limit.Path)
+		}
+		if limit.PerMinute < 1 || limit.Burst < 1 {
+			return fmt.Errorf("rate limit for %s must allow at least 1 request per minute and a burst of 1", limit.Path)
+		}
+	}
+	if smtp := cfg.Notifications.SMTP; smtp != nil {
+		if smtp.Host == "" || smtp.From == "" || len(smtp.To) == 0 {
+			return errors.New("smtp notifications require host, from and at least one recipient")
+		}
+		if smtp.Port < 1 || smtp.Port > 65535 {
+			return errors.New("invalid smtp port")
+		}
+	}
+	if discord := cfg.Notifications.Discord; discord != nil {
+		if discord.WebhookURL == "" && len(discord.Routes) == 0 {
+			return errors.New("discord notifications require webhook_url or routes")
+		}
+		if discord.WebhookURL != "" && !isWebhookURL(discord.WebhookURL) {
+			return fmt.Errorf("invalid discord webhook_url %q: expected an http(s) URL", discord.WebhookURL)
+		}
+		for _, rt := range discord.Routes {
+			if rt.WebhookURL == "" || len(rt.Services) == 0 {
+				return errors.New("each discord route requires services and webhook_url")
+			}
+			if !isWebhookURL(rt.WebhookURL) {
+				return fmt.Errorf("invalid discord route webhook_url %q: expected an http(s) URL", rt.WebhookURL)
+			}
+		}
+	}
+	if slack := cfg.Notifications.Slack; slack != nil {
+		if slack.WebhookURL == "" {
+			return errors.New("slack notifications require webhook_url")
+		}
+		if !isWebhookURL(slack.WebhookURL) {
+			return fmt.Errorf("invalid slack webhook_url %q: expected an http(s) URL", slack.WebhookURL)
+		}
+		if slack.RateLimit.PerMinute < 1 || slack.RateLimit.Burst < 1 {
+			return errors.New("slack rate_limit must allow at least 1 message per minute and a burst of 1")
+		}
+	}
+	for _, s := range cfg.AllowedServices {
+		if strings.TrimSpace(s) == "" {
+			return errors.New("empty service name in allowed services")
+		}
+	}
+	for _, svc := range cfg.Services {
+		if strings.TrimSpace(svc.Name) == "" {
+			return errors.New("empty service name in services")
+		}
+	}
+	return nil
+}
+
+// isWebhookURL reports whether s is an absolute http or https URL
+func isWebhookURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// Save writes the configuration as indented JSON to path
+func (cfg *Config) Save(path string) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o640)
+}
+
+// ErrInvalid marks configuration changes rejected by validation
+var ErrInvalid = errors.New("invalid configuration")
+
+// Update applies change to the config file at path and saves it, returning
+// the effective configuration that results. The file is read without the
+// environment so that only its own settings are written back. Nothing is
+// saved when change fails, the result would not be valid or verify, if not
+// nil, rejects the effective configuration.
+func Update(path string, change func(file *Config) error, verify func(effective *Config) error) (*Config, error) {
+	file := Default()
+	if err := file.readFile(path); err != nil {
+		return nil, err
+	}
+	if err := change(file); err != nil {
+		return nil, err
+	}
+
+	// The effective configuration is what a restart would load
+	data, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	effective := Default()
+	if err := json.Unmarshal(data, effective); err != nil {
+		return nil, err
+	}
+	if err := effective.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := effective.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if verify != nil {
+		if err := verify(effective); err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace the file in one step so a crash never leaves half of it
+	tmp := path + ".tmp"
+	if err := file.Save(tmp); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return effective, nil
+}
+
+// SplitList splits a comma-separated list, trimming whitespace and
+// dropping empty items
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
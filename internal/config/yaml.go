@@ -0,0 +1,422 @@
+// internal/config/yaml.go
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// The YAML form of a configuration is its JSON form written in block
+// style: keys, value types and their formats are the config file's. Only
+// the subset of YAML that EncodeYAML writes is read back, with comments and
+// blank lines, so exports can be edited by hand; anchors, tags, flow
+// collections other than [] and {}, and multi-line scalars are not
+// supported.
+
+// EncodeYAML writes cfg as a YAML document
+func (cfg *Config) EncodeYAML(w io.Writer) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := yamlValue(&buf, dec, 0, ""); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// ParseYAML reads a configuration written by EncodeYAML from r over the
+// defaults, without consulting the environment
+func ParseYAML(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := yamlToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(bytes.NewReader(converted))
+}
+
+// yamlValue writes the next JSON value of dec, with the entries of a
+// collection at indent. prefix is the "key:" that precedes it, or "" for
+// sequence items and the document.
+func yamlValue(buf *bytes.Buffer, dec *json.Decoder, indent int, prefix string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		writeYAMLLine(buf, prefix, yamlScalar(tok))
+		return nil
+	}
+
+	if !dec.More() {
+		// Empty collections are written in flow style
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		writeYAMLLine(buf, prefix, map[json.Delim]string{'{': "{}", '[': "[]"}[delim])
+		return nil
+	}
+	if prefix != "" {
+		buf.WriteString(prefix + "\n")
+	}
+	pad := strings.Repeat(" ", indent)
+	for dec.More() {
+		if delim == '[' {
+			// An item's first line follows its dash, which takes the place
+			// of the indentation
+			var item bytes.Buffer
+			if err := yamlValue(&item, dec, indent+2, ""); err != nil {
+				return err
+			}
+			buf.WriteString(pad + "- ")
+			buf.Write(bytes.TrimLeft(item.Bytes(), " "))
+			continue
+		}
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := yamlValue(buf, dec, indent+2, pad+yamlString(key.(string))+":"); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// writeYAMLLine writes a scalar or flow collection after prefix
+func writeYAMLLine(buf *bytes.Buffer, prefix, value string) {
+	if prefix != "" {
+		buf.WriteString(prefix + " ")
+	}
+	buf.WriteString(value + "\n")
+}
+
+// yamlScalar formats a JSON scalar token
+func yamlScalar(tok json.Token) string {
+	switch v := tok.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(v)
+	case json.Number:
+		return v.String()
+	default:
+		return yamlString(v.(string))
+	}
+}
+
+var (
+	// yamlPlain matches strings that read back as the same string unquoted
+	yamlPlain = regexp.MustCompile(`^[A-Za-z0-9_/][A-Za-z0-9_./@+-]*$`)
+	// yamlNumber matches what YAML 1.1 and 1.2 tools may read as a number
+	yamlNumber = regexp.MustCompile(`^[-+]?(\.[0-9_]+|[0-9][0-9_]*(\.[0-9_]*)?)([eE][-+]?[0-9]+)?$|^0[xXoObB]`)
+	// yamlReserved holds the words YAML tools read as booleans or null
+	yamlReserved = regexp.MustCompile(`^(?i:y|n|yes|no|on|off|true|false|null|~)$`)
+)
+
+// yamlString writes s plain when that is unambiguous and double-quoted
+// otherwise, with JSON's escapes, which YAML shares
+func yamlString(s string) string {
+	if yamlPlain.MatchString(s) && !yamlNumber.MatchString(s) && !yamlReserved.MatchString(s) {
+		return s
+	}
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// yamlLine is a line of a YAML document without its comment
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser turns the lines of a block-style YAML document into JSON
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlToJSON converts a YAML document to JSON
+func yamlToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs cannot indent", i+1)
+		}
+		text = strings.TrimSpace(stripYAMLComment(text))
+		if text == "" || text == "---" {
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+	if len(p.lines) == 0 {
+		return nil, errors.New("yaml: empty document")
+	}
+
+	var out bytes.Buffer
+	if err := p.node(&out, p.lines[0].indent); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return out.Bytes(), nil
+}
+
+// stripYAMLComment removes a comment, which starts the line or follows a
+// space, outside quotes
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	line := p.lines[min(p.pos, len(p.lines)-1)].number
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// node converts the block starting at the current line, which is at
+// indent, into JSON
+func (p *yamlParser) node(out *bytes.Buffer, indent int) error {
+	line := p.lines[p.pos]
+	if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+		return p.sequence(out, indent)
+	}
+	if _, _, ok := cutYAMLKey(line.text); ok {
+		return p.mapping(out, indent)
+	}
+	p.pos++
+	return yamlScalarJSON(out, line.text, p)
+}
+
+// sequence converts the "- item" lines at indent
+func (p *yamlParser) sequence(out *bytes.Buffer, indent int) error {
+	out.WriteByte('[')
+	for n := 0; p.pos < len(p.lines); n++ {
+		line := p.lines[p.pos]
+		if line.indent != indent || !(line.text == "-" || strings.HasPrefix(line.text, "- ")) {
+			break
+		}
+		if n > 0 {
+			out.WriteByte(',')
+		}
+		if err := p.item(out, indent, strings.TrimSpace(line.text[1:])); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+// item converts a sequence item whose line, at indent, holds rest after
+// the dash. Content on the dash's line continues at the column it starts.
+func (p *yamlParser) item(out *bytes.Buffer, indent int, rest string) error {
+	if rest == "" {
+		p.pos++
+		return p.nested(out, indent)
+	}
+	// Reread the line as if the dash were indentation
+	line := &p.lines[p.pos]
+	column := indent + strings.Index(line.text[1:], rest) + 1
+	line.indent, line.text = column, rest
+	return p.node(out, column)
+}
+
+// mapping converts the "key: value" lines at indent
+func (p *yamlParser) mapping(out *bytes.Buffer, indent int) error {
+	seen := make(map[string]bool)
+	out.WriteByte('{')
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent {
+			if line.indent > indent {
+				return p.errorf("unexpected indentation")
+			}
+			break
+		}
+		rawKey, value, ok := cutYAMLKey(line.text)
+		if !ok {
+			return p.errorf("expected key: value")
+		}
+		key, err := yamlKey(rawKey)
+		if err != nil {
+			return p.errorf("%v", err)
+		}
+		if seen[key] {
+			return p.errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+		if len(seen) > 1 {
+			out.WriteByte(',')
+		}
+		encoded, _ := json.Marshal(key)
+		out.Write(encoded)
+		out.WriteByte(':')
+
+		p.pos++
+		if value != "" {
+			p.pos--
+			if err := yamlScalarJSON(out, value, p); err != nil {
+				return err
+			}
+			p.pos++
+			continue
+		}
+		// A sequence may sit at its key's indent
+		if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && strings.HasPrefix(p.lines[p.pos].text, "-") {
+			if err := p.sequence(out, indent); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.nested(out, indent); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+// nested converts the block indented past indent that follows, or null
+// when there is none
+func (p *yamlParser) nested(out *bytes.Buffer, indent int) error {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		out.WriteString("null")
+		return nil
+	}
+	return p.node(out, p.lines[p.pos].indent)
+}
+
+// cutYAMLKey splits "key: value" and "key:" lines, where the key may be
+// quoted and value is "" for a nested block
+func cutYAMLKey(text string) (key, value string, ok bool) {
+	end := 0
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end = closingQuote(text)
+		if end < 0 {
+			return "", "", false
+		}
+	}
+	i := strings.Index(text[end:], ":")
+	for i >= 0 {
+		i += end
+		if i+1 == len(text) || text[i+1] == ' ' {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+		end = i + 1
+		i = strings.Index(text[end:], ":")
+	}
+	return "", "", false
+}
+
+// closingQuote returns the index just past the quoted string text starts
+// with, or -1
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote:
+			if quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// yamlKey unquotes a mapping key
+func yamlKey(raw string) (string, error) {
+	var out bytes.Buffer
+	if err := yamlScalarJSON(&out, raw, nil); err != nil {
+		return "", err
+	}
+	var key any
+	if err := json.Unmarshal(out.Bytes(), &key); err != nil {
+		return "", err
+	}
+	switch k := key.(type) {
+	case string:
+		return k, nil
+	case json.Number, float64, bool:
+		return raw, nil
+	}
+	return "", fmt.Errorf("invalid key %s", raw)
+}
+
+// yamlScalarJSON converts a scalar, or an empty flow collection, to JSON
+func yamlScalarJSON(out *bytes.Buffer, text string, p *yamlParser) error {
+	fail := func(format string, args ...any) error {
+		if p == nil {
+			return fmt.Errorf(format, args...)
+		}
+		return p.errorf(format, args...)
+	}
+	switch {
+	case text == "[]" || text == "{}":
+		out.WriteString(text)
+	case text[0] == '"':
+		if closingQuote(text) != len(text) {
+			return fail("unterminated string %s", text)
+		}
+		var s string
+		if err := json.Unmarshal([]byte(text), &s); err != nil {
+			return fail("unsupported escape in %s", text)
+		}
+		encoded, _ := json.Marshal(s)
+		out.Write(encoded)
+	case text[0] == '\'':
+		if closingQuote(text) != len(text) {
+			return fail("unterminated string %s", text)
+		}
+		encoded, _ := json.Marshal(strings.ReplaceAll(text[1:len(text)-1], "''", "'"))
+		out.Write(encoded)
+	case text[0] == '[' || text[0] == '{' || text[0] == '&' || text[0] == '*' || text[0] == '!' || text[0] == '|' || text[0] == '>':
+		return fail("unsupported YAML %s", text)
+	case text == "null" || text == "~":
+		out.WriteString("null")
+	case text == "true" || text == "false":
+		out.WriteString(text)
+	case yamlNumber.MatchString(text) && json.Valid([]byte(text)):
+		out.WriteString(text)
+	default:
+		encoded, _ := json.Marshal(text)
+		out.Write(encoded)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+// internal/desired/desired.go
+package desired
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/service"
+)
+
+// Desired states of a service
+const (
+	Running = "running"
+	Stopped = "stopped"
+)
+
+// Entry is the desired state of one service
+type Entry struct {
+	Service string `json:"service"`
+	State   string `json:"state"`
+}
+
+// State compares a service's desired and actual state after a pass
+type State struct {
+	Service string `json:"service"`
+	Desired string `json:"desired"`
+	Actual  string `json:"actual"`
+	Drift   bool   `json:"drift"`
+	// Action is what the pass did about drift, e.g. "start"
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the outcome of the last reconciliation pass
+type Report struct {
+	File string `json:"file"`
+	// Revision is the checked-out commit when the repository is pulled
+	Revision   string    `json:"revision,omitempty"`
+	Checked    time.Time `json:"checked"`
+	ReportOnly bool      `json:"report_only,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Services   []State   `json:"services"`
+}
+
+// Drifting lists the services whose actual state differs from the desired one
+func (r Report) Drifting() []State {
+	var drifting []State
+	for _, s := range r.Services {
+		if s.Drift {
+			drifting = append(drifting, s)
+		}
+	}
+	return drifting
+}
+
+// Reconciler periodically brings services to the states declared in a
+// file, or only reports the drift
+type Reconciler struct {
+	file       string
+	gitPull    bool
+	reportOnly bool
+	services   *service.ServiceManager
+	audit      *audit.Recorder
+	logger     *slog.Logger
+
+	// pass serializes reconciliation passes
+	pass     sync.Mutex
+	drifting map[string]bool
+
+	mu     sync.RWMutex
+	report *Report
+}
+
+// NewReconciler creates a reconciler for the desired-state file. With
+// gitPull the file's repository is pulled before each pass.
+func NewReconciler(file string, gitPull, reportOnly bool, services *service.ServiceManager, recorder *audit.Recorder, logger *slog.Logger) *Reconciler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Reconciler{
+		file:       file,
+		gitPull:    gitPull,
+		reportOnly: reportOnly,
+		services:   services,
+		audit:      recorder,
+		logger:     logger,
+		drifting:   make(map[string]bool),
+	}
+}
+
+// Run reconciles right away and then every interval until ctx is cancelled
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	r.Reconcile(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reconcile(ctx)
+		}
+	}
+}
+
+// Latest returns the report of the last pass, or nil before the first one
+// or when no reconciler is configured
+func (r *Reconciler) Latest() *Report {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.report
+}
+
+// Reconcile runs one pass: it reads the file, compares each service with
+// its desired state and starts or stops those that drifted
+func (r *Reconciler) Reconcile(ctx context.Context) Report {
+	r.pass.Lock()
+	defer r.pass.Unlock()
+
+	report := Report{File: r.file, Checked: time.Now(), ReportOnly: r.reportOnly}
+	dir := filepath.Dir(r.file)
+	if r.gitPull {
+		if err := git(ctx, dir, "pull", "--ff-only", "--quiet"); err != nil {
+			// Enforce the last checked-out revision rather than nothing
+			r.logger.WarnContext(ctx, "desired state: git pull failed", "dir", dir, "error", err)
+			report.Error = "git pull failed: " + err.Error()
+		}
+		if out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--short", "HEAD").Output(); err == nil {
+			report.Revision = strings.TrimSpace(string(out))
+		}
+	}
+
+	entries, err := Load(r.file)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "desired state: cannot read file", "file", r.file, "error", err)
+		report.Error = err.Error()
+		r.publish(&report)
+		return report
+	}
+	for _, entry := range entries {
+		report.Services = append(report.Services, r.reconcile(ctx, entry))
+	}
+	r.publish(&report)
+	return report
+}
+
+func (r *Reconciler) publish(report *Report) {
+	r.mu.Lock()
+	r.report = report
+	r.mu.Unlock()
+}
+
+// reconcile compares one service with its desired state, correcting drift
+// unless the service is in a maintenance window or only reporting is asked
+func (r *Reconciler) reconcile(ctx context.Context, entry Entry) State {
+	status, err := r.services.GetServiceStatus(ctx, entry.Service)
+	state := State{Service: entry.Service, Desired: entry.State, Actual: string(status.Status)}
+	switch {
+	case errors.Is(err, service.ErrNotAllowed):
+		state.Error = "service is not allowed"
+		return state
+	case err != nil:
+		state.Error = "cannot read the service's state: " + err.Error()
+		return state
+	}
+
+	// Units on their way to the desired state are left alone
+	running := status.Active || status.Status == service.StateStarting
+	state.Drift = running != (entry.State == Running)
+	if !state.Drift {
+		delete(r.drifting, entry.Service)
+		return state
+	}
+
+	newDrift := !r.drifting[entry.Service]
+	r.drifting[entry.Service] = true
+	if window, open := r.services.InMaintenance(entry.Service, time.Now()); open {
+		state.Error = "in maintenance window " + window
+	} else if !r.reportOnly {
+		state.Action = "start"
+		if entry.State == Stopped {
+			state.Action = "stop"
+		}
+		var result service.ServiceStatus
+		if state.Action == "start" {
+			result, err = r.services.StartService(ctx, entry.Service)
+		} else {
+			result, err = r.services.StopService(ctx, entry.Service)
+		}
+		if result.Refused != "" {
+			state.Error = result.Refused
+		} else if result.Failure != nil {
+			state.Error = result.Failure.Message
+		} else if err != nil {
+			state.Error = err.Error()
+		}
+	}
+
+	if newDrift || state.Action != "" {
+		r.record(ctx, state)
+	}
+	return state
+}
+
+// record audits drift and what was done about it
+func (r *Reconciler) record(ctx context.Context, state State) {
+	message := fmt.Sprintf("%s is %s, desired %s", state.Service, state.Actual, state.Desired)
+	switch {
+	case state.Action != "" && state.Error != "":
+		message += fmt.Sprintf(": %s failed: %s", state.Action, state.Error)
+	case state.Action != "":
+		message += ": " + state.Action + " requested"
+	case state.Error != "":
+		message += ": " + state.Error
+	}
+	r.logger.InfoContext(ctx, "desired state drift", "service", state.Service,
+		"actual", state.Actual, "desired", state.Desired, "action", state.Action, "error", state.Error)
+
+	fields := map[string]any{"desired": state.Desired, "actual": state.Actual}
+	if state.Action != "" {
+		fields["action"] = state.Action
+	}
+	if state.Error != "" {
+		fields["error"] = state.Error
+	}
+	r.audit.Record(audit.Event{
+		Type:    audit.EventDesiredStateDrift,
+		User:    "desired-state",
+		Service: state.Service,
+		Message: message,
+		Fields:  fields,
+	})
+}
+
+// Load reads a desired-state file
+func Load(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Parse reads desired states given as YAML-style "service: state" lines,
+// optionally nested under "services:", or as the same JSON object. States
+// are running (or started, active) and stopped (or inactive).
+func Parse(data []byte) ([]Entry, error) {
+	states := make(map[string]string)
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		var top map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &top); err != nil {
+			return nil, err
+		}
+		if raw, ok := top["services"]; ok && len(top) == 1 {
+			trimmed = raw
+		}
+		if err := json.Unmarshal(trimmed, &states); err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+			line = strings.TrimSpace(line)
+			if line == "" || line == "---" || line == "services:" {
+				continue
+			}
+			// Names of services on named backends contain colons too
+			i := strings.LastIndex(line, ":")
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: expected \"service: running\" or \"service: stopped\"", n)
+			}
+			name, state := unquote(line[:i]), unquote(line[i+1:])
+			if _, dup := states[name]; dup {
+				return nil, fmt.Errorf("line %d: %s is listed twice", n, name)
+			}
+			states[name] = state
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]Entry, 0, len(states))
+	for name, state := range states {
+		switch strings.ToLower(state) {
+		case "running", "started", "active":
+			state = Running
+		case "stopped", "inactive":
+			state = Stopped
+		default:
+			return nil, fmt.Errorf("%s: invalid state %q: expected running or stopped", name, state)
+		}
+		if name == "" {
+			return nil, errors.New("empty service name")
+		}
+		entries = append(entries, Entry{Service: config.NormalizeServiceName(name), State: state})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Service < entries[j].Service })
+	return entries, nil
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// git runs a git command in dir, returning its stderr on failure
+func git(ctx context.Context, dir string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// The first line says what went wrong; the rest is advice
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
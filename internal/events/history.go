@@ -0,0 +1,77 @@
+// internal/events/history.go
+package events
+
+import (
+	"sync"
+
+	"sysdwitch/internal/audit"
+)
+
+// historyTypes are the events a history keeps: what happened to services
+var historyTypes = map[string]bool{
+	audit.EventServiceStateChanged: true,
+	audit.EventServiceFailed:       true,
+	audit.EventServiceRestarted:    true,
+	audit.EventServiceAction:       true,
+	audit.EventProfileAction:       true,
+}
+
+// ServiceEvent reports whether events of eventType are about services,
+// the ones a history keeps and non-admins are streamed
+func ServiceEvent(eventType string) bool {
+	return historyTypes[eventType]
+}
+
+// History keeps the most recent state-change and action events in a ring
+// of fixed size, so its memory stays bounded however long the panel runs
+type History struct {
+	mu     sync.RWMutex
+	ring   []audit.Event
+	next   int
+	filled bool
+}
+
+// NewHistory creates a history of the last size events
+func NewHistory(size int) *History {
+	return &History{ring: make([]audit.Event, size)}
+}
+
+// Record keeps event if it is a state change or an action, overwriting
+// the oldest kept event once the history is full. Meant to be subscribed
+// to the audit recorder.
+func (h *History) Record(event audit.Event) {
+	if !historyTypes[event.Type] {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ring[h.next] = event
+	h.next = (h.next + 1) % len(h.ring)
+	if h.next == 0 {
+		h.filled = true
+	}
+}
+
+// Recent returns up to limit kept events, newest first, of serviceName
+// only unless it is empty. A limit of zero or less returns all of them.
+func (h *History) Recent(limit int, serviceName string) []audit.Event {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := h.next
+	if h.filled {
+		count = len(h.ring)
+	}
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+	recent := make([]audit.Event, 0, limit)
+	for i := 1; i <= count && len(recent) < limit; i++ {
+		event := h.ring[(h.next-i+len(h.ring))%len(h.ring)]
+		if serviceName == "" || event.Service == serviceName {
+			recent = append(recent, event)
+		}
+	}
+	return recent
+}
@@ -0,0 +1,149 @@
+// internal/events/hub.go
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"sysdwitch/internal/audit"
+)
+
+// Message is an audit event as streamed to clients, numbered in the order
+// it was published
+type Message struct {
+	ID    uint64
+	Event audit.Event
+}
+
+// Hub fans audit events out to live subscribers. Each client has its own
+// buffered channel; a client whose buffer is full when an event arrives is
+// evicted rather than waited for, so one stalled connection never delays
+// the others or the recorder publishing the event.
+type Hub struct {
+	buffer int
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	lastID  uint64
+	closed  bool
+
+	delivered atomic.Uint64
+	evicted   atomic.Uint64
+}
+
+// Client is one subscriber of a hub
+type Client struct {
+	hub      *Hub
+	messages chan Message
+	evicted  chan struct{}
+}
+
+// Stats are the counters of a hub since it was created
+type Stats struct {
+	Clients   int    `json:"clients"`
+	Buffer    int    `json:"buffer"`
+	Published uint64 `json:"published"`
+	Delivered uint64 `json:"delivered"`
+	Evicted   uint64 `json:"evicted"`
+}
+
+// NewHub creates a hub buffering up to buffer events per client
+func NewHub(buffer int, logger *slog.Logger) *Hub {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Hub{
+		buffer:  buffer,
+		logger:  logger,
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+// Publish delivers event to every client without blocking, evicting those
+// that fell behind. Meant to be subscribed to the audit recorder.
+func (h *Hub) Publish(event audit.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	msg := Message{ID: h.lastID, Event: event}
+	for client := range h.clients {
+		select {
+		case client.messages <- msg:
+			h.delivered.Add(1)
+		default:
+			delete(h.clients, client)
+			close(client.evicted)
+			h.evicted.Add(1)
+			h.logger.Warn("event stream client fell behind, disconnecting",
+				"buffer", h.buffer, "event", event.Type)
+		}
+	}
+}
+
+// Subscribe registers a client receiving the events published from now on.
+// The client must be closed when done.
+func (h *Hub) Subscribe() *Client {
+	client := &Client{
+		hub:      h,
+		messages: make(chan Message, h.buffer),
+		evicted:  make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(client.evicted)
+		return client
+	}
+	h.clients[client] = struct{}{}
+	return client
+}
+
+// Close drops every client, ending their streams, as when the panel shuts
+// down; browsers reconnect, to the process replacing this one after an
+// upgrade. Clients subscribing later are dropped right away.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for client := range h.clients {
+		delete(h.clients, client)
+		close(client.evicted)
+	}
+}
+
+// Stats returns the hub's client count and counters
+func (h *Hub) Stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return Stats{
+		Clients:   len(h.clients),
+		Buffer:    h.buffer,
+		Published: h.lastID,
+		Delivered: h.delivered.Load(),
+		Evicted:   h.evicted.Load(),
+	}
+}
+
+// Messages returns the client's events in publish order
+func (c *Client) Messages() <-chan Message {
+	return c.messages
+}
+
+// Evicted is closed when the hub dropped the client for falling behind
+// or because it closed; messages still buffered may be discarded
+func (c *Client) Evicted() <-chan struct{} {
+	return c.evicted
+}
+
+// Close unsubscribes the client
+func (c *Client) Close() {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	delete(c.hub.clients, c)
+}
@@ -0,0 +1,482 @@
+// internal/expr/expr.go
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Program is a compiled boolean expression such as
+//
+//	state("jellyfin") == "failed" && state_for("jellyfin") >= 5m && !between("02:00", "04:00")
+//
+// Expressions combine string, number, duration ("90s", "1h30m") and boolean
+// literals, variables and function calls with ==, !=, <, <=, >, >=, &&, ||,
+// ! and parentheses.
+type Program struct {
+	src   string
+	root  node
+	vars  []string
+	funcs []string
+}
+
+// Func is a function callable from expressions
+type Func func(args []any) (any, error)
+
+// Env supplies the variables and functions an expression may use. Values
+// are strings, booleans, time.Duration or numbers (any integer or float
+// type).
+type Env struct {
+	Vars  map[string]any
+	Funcs map[string]Func
+}
+
+// Compile parses src
+func Compile(src string) (*Program, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	p := &parser{tokens: tokens, prog: &Program{src: src}}
+	root, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	p.prog.root = root
+	return p.prog, nil
+}
+
+// String returns the source of the expression
+func (p *Program) String() string {
+	return p.src
+}
+
+// Check reports variables or functions used by the expression that env
+// does not provide
+func (p *Program) Check(env Env) error {
+	for _, name := range p.vars {
+		if _, ok := env.Vars[name]; !ok {
+			return fmt.Errorf("unknown variable %q", name)
+		}
+	}
+	for _, name := range p.funcs {
+		if _, ok := env.Funcs[name]; !ok {
+			return fmt.Errorf("unknown function %q", name)
+		}
+	}
+	return nil
+}
+
+// Eval evaluates the expression, which must yield a boolean
+func (p *Program) Eval(env Env) (bool, error) {
+	v, err := p.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression yields %s, not a boolean", typeName(v))
+	}
+	return b, nil
+}
+
+// token kinds
+const (
+	tokIdent = iota
+	tokString
+	tokNumber
+	tokDuration
+	tokOp
+)
+
+type token struct {
+	kind  int
+	text  string
+	value any
+}
+
+// operators lists the operator tokens, longest first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ","}
+
+// tokenize splits src into tokens
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, errors.New("unterminated string")
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", src[i:j+1])
+			}
+			tokens = append(tokens, token{kind: tokString, text: src[i : j+1], value: s})
+			i = j + 1
+
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(src) && (isIdentChar(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			text := src[i:j]
+			if n, err := strconv.ParseFloat(text, 64); err == nil {
+				tokens = append(tokens, token{kind: tokNumber, text: text, value: n})
+			} else if d, err := time.ParseDuration(text); err == nil {
+				tokens = append(tokens, token{kind: tokDuration, text: text, value: d})
+			} else {
+				return nil, fmt.Errorf("invalid number or duration %q", text)
+			}
+			i = j
+
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (isIdentChar(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[i:j]})
+			i = j
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// parser is a recursive-descent parser over tokens
+type parser struct {
+	tokens []token
+	pos    int
+	prog   *Program
+}
+
+// accept consumes the next token if it is the operator op
+func (p *parser) accept(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokOp && p.tokens[p.pos].text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// or := and ("||" and)*
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.accept("||") {
+		var right node
+		if right, err = p.and(); err == nil {
+			left = logical{or: true, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// and := comparison ("&&" comparison)*
+func (p *parser) and() (node, error) {
+	left, err := p.comparison()
+	for err == nil && p.accept("&&") {
+		var right node
+		if right, err = p.comparison(); err == nil {
+			left = logical{left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// comparison := unary (("==" | "!=" | "<" | "<=" | ">" | ">=") unary)?
+func (p *parser) comparison() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return compare{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// unary := "!" unary | primary
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand: operand}, nil
+	}
+	return p.primary()
+}
+
+// primary := literal | ident | ident "(" args ")" | "(" or ")"
+func (p *parser) primary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("unexpected end of expression")
+	}
+	if p.accept("(") {
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, errors.New("missing )")
+		}
+		return inner, nil
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch tok.kind {
+	case tokString, tokNumber, tokDuration:
+		return literal{value: tok.value}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return literal{value: true}, nil
+		case "false":
+			return literal{value: false}, nil
+		}
+		if !p.accept("(") {
+			if !slices.Contains(p.prog.vars, tok.text) {
+				p.prog.vars = append(p.prog.vars, tok.text)
+			}
+			return variable{name: tok.text}, nil
+		}
+		c := call{name: tok.text}
+		for !p.accept(")") {
+			if len(c.args) > 0 && !p.accept(",") {
+				return nil, fmt.Errorf("expected , or ) in call to %s", tok.text)
+			}
+			arg, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+		}
+		if !slices.Contains(p.prog.funcs, tok.text) {
+			p.prog.funcs = append(p.prog.funcs, tok.text)
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok.text)
+}
+
+// node is an expression tree node
+type node interface {
+	eval(env Env) (any, error)
+}
+
+type literal struct{ value any }
+
+func (n literal) eval(Env) (any, error) { return n.value, nil }
+
+type variable struct{ name string }
+
+func (n variable) eval(env Env) (any, error) {
+	v, ok := env.Vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q", n.name)
+	}
+	return normalize(v), nil
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+func (n call) eval(env Env) (any, error) {
+	fn, ok := env.Funcs[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", n.name)
+	}
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return normalize(v), nil
+}
+
+type not struct{ operand node }
+
+func (n not) eval(env Env) (any, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("! needs a boolean, not %s", typeName(v))
+	}
+	return !b, nil
+}
+
+// logical is && or ||, evaluated left to right with short-circuiting
+type logical struct {
+	or          bool
+	left, right node
+}
+
+func (n logical) eval(env Env) (any, error) {
+	left, err := n.operand(env, n.left)
+	if err != nil || left == n.or {
+		return left, err
+	}
+	return n.operand(env, n.right)
+}
+
+// operand evaluates one side of a logical operator
+func (n logical) operand(env Env, side node) (bool, error) {
+	v, err := side.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		op := "&&"
+		if n.or {
+			op = "||"
+		}
+		return false, fmt.Errorf("%s needs booleans, not %s", op, typeName(v))
+	}
+	return b, nil
+}
+
+type compare struct {
+	op          string
+	left, right node
+}
+
+func (n compare) eval(env Env) (any, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if typeName(l) != typeName(r) {
+		return nil, fmt.Errorf("cannot compare %s with %s", typeName(l), typeName(r))
+	}
+
+	var cmp int
+	switch l := l.(type) {
+	case bool:
+		if n.op != "==" && n.op != "!=" {
+			return nil, fmt.Errorf("cannot order booleans with %s", n.op)
+		}
+		if l != r.(bool) {
+			cmp = 1
+		}
+	case string:
+		cmp = strings.Compare(l, r.(string))
+	case float64:
+		cmp = compareOrdered(l, r.(float64))
+	case time.Duration:
+		cmp = compareOrdered(l, r.(time.Duration))
+	}
+
+	switch n.op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func compareOrdered[T float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// normalize converts integer and float values to float64
+func normalize(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+// typeName names the type of a value in error messages
+func typeName(v any) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case time.Duration:
+		return "duration"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,330 @@
+// internal/graphql/graphql.go
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// maxDepth bounds how deeply selections may nest
+const maxDepth = 12
+
+// Field resolves one field of an object from its arguments. It may return
+// an Object, a struct (exposed by its JSON fields), a slice of either, or
+// a scalar.
+type Field func(ctx context.Context, args map[string]any) (any, error)
+
+// Object is a value of a named type whose fields resolve on demand
+type Object struct {
+	Type   string
+	Fields map[string]Field
+}
+
+// Struct exposes a struct's JSON fields (by their JSON names) as an Object
+// of type typeName, together with extra computed fields
+func Struct(typeName string, v any, extra map[string]Field) Object {
+	obj := Object{Type: typeName, Fields: make(map[string]Field)}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Struct {
+		rt := rv.Type()
+		for i := range rt.NumField() {
+			f := rt.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			value := rv.Field(i).Interface()
+			obj.Fields[name] = func(context.Context, map[string]any) (any, error) { return value, nil }
+		}
+	}
+	for name, field := range extra {
+		obj.Fields[name] = field
+	}
+	return obj
+}
+
+// Request is a GraphQL request as POSTed in JSON
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response carries the result of a request and any errors
+type Response struct {
+	Data   any     `json:"data"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is an error of a request, with the path of the field it concerns
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Execute runs a query against root, the Query type. Fields that fail
+// resolve to null and add an error, as GraphQL specifies.
+func Execute(ctx context.Context, root Object, req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: "syntax error: " + err.Error()}}}
+	}
+
+	var op *operation
+	for _, candidate := range doc.operations {
+		if req.OperationName == "" || candidate.name == req.OperationName {
+			if op != nil {
+				return Response{Errors: []Error{{Message: "operationName is required when the query has several operations"}}}
+			}
+			op = candidate
+		}
+	}
+	if op == nil {
+		return Response{Errors: []Error{{Message: fmt.Sprintf("unknown operation %q", req.OperationName)}}}
+	}
+
+	vars := make(map[string]any)
+	for _, def := range op.variables {
+		if v, ok := req.Variables[def.name]; ok {
+			vars[def.name] = v
+		} else if def.hasValue {
+			vars[def.name] = def.fallback
+		}
+	}
+
+	e := &executor{doc: doc, vars: vars}
+	data := e.object(ctx, root, op.selection, nil)
+	return Response{Data: data, Errors: e.errors}
+}
+
+// executor holds the state of one request
+type executor struct {
+	doc    *document
+	vars   map[string]any
+	errors []Error
+}
+
+func (e *executor) fail(path []any, format string, args ...any) {
+	e.errors = append(e.errors, Error{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+// collected is a response key with the merged selections of its field
+type collected struct {
+	key, name string
+	args      map[string]any
+	selection []selection
+}
+
+// collect flattens fragments and applies @skip and @include, merging
+// fields that share a response key
+func (e *executor) collect(typeName string, set []selection, into []*collected, seen map[string]bool) []*collected {
+	for _, sel := range set {
+		if !e.included(sel.directives) {
+			continue
+		}
+		switch {
+		case sel.spread != "":
+			frag, ok := e.doc.fragments[sel.spread]
+			if !ok {
+				e.fail(nil, "unknown fragment %q", sel.spread)
+				continue
+			}
+			if frag.typeCond == typeName && !seen[sel.spread] {
+				seen[sel.spread] = true
+				into = e.collect(typeName, frag.selection, into, seen)
+			}
+		case sel.inline:
+			if sel.typeCond == "" || sel.typeCond == typeName {
+				into = e.collect(typeName, sel.selection, into, seen)
+			}
+		default:
+			merged := false
+			for _, c := range into {
+				if c.key == sel.alias {
+					c.selection = append(c.selection, sel.selection...)
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				into = append(into, &collected{key: sel.alias, name: sel.name, args: sel.args, selection: sel.selection})
+			}
+		}
+	}
+	return into
+}
+
+// included evaluates @skip(if:) and @include(if:)
+func (e *executor) included(directives []directive) bool {
+	for _, d := range directives {
+		cond, _ := e.value(d.args["if"]).(bool)
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// value substitutes variables and turns enum values into strings
+func (e *executor) value(v any) any {
+	switch v := v.(type) {
+	case variableRef:
+		return e.vars[string(v)]
+	case enumValue:
+		return string(v)
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = e.value(item)
+		}
+		return list
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for k, item := range v {
+			obj[k] = e.value(item)
+		}
+		return obj
+	}
+	return v
+}
+
+// object resolves the selected fields of obj
+func (e *executor) object(ctx context.Context, obj Object, set []selection, path []any) *orderedMap {
+	if len(path) > maxDepth {
+		e.fail(path, "query is nested deeper than %d levels", maxDepth)
+		return nil
+	}
+
+	result := &orderedMap{values: make(map[string]any)}
+	for _, field := range e.collect(obj.Type, set, nil, make(map[string]bool)) {
+		fieldPath := append(path[:len(path):len(path)], field.key)
+		if field.name == "__typename" {
+			result.set(field.key, obj.Type)
+			continue
+		}
+		resolve, ok := obj.Fields[field.name]
+		if !ok {
+			e.fail(fieldPath, "cannot query field %q on type %q", field.name, obj.Type)
+			result.set(field.key, nil)
+			continue
+		}
+		args := make(map[string]any, len(field.args))
+		for name, v := range field.args {
+			args[name] = e.value(v)
+		}
+		v, err := resolve(ctx, args)
+		if err != nil {
+			e.fail(fieldPath, "%s", err.Error())
+			result.set(field.key, nil)
+			continue
+		}
+		result.set(field.key, e.complete(ctx, field.name, v, field.selection, fieldPath))
+	}
+	return result
+}
+
+// complete shapes a resolved value according to its selection
+func (e *executor) complete(ctx context.Context, name string, v any, set []selection, path []any) any {
+	if obj, ok := v.(Object); ok {
+		if len(set) == 0 {
+			e.fail(path, "field %q of type %q must have a selection of subfields", name, obj.Type)
+			return nil
+		}
+		return e.object(ctx, obj, set, path)
+	}
+	if _, ok := v.(time.Time); ok || v == nil {
+		return e.leaf(name, v, set, path)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return e.complete(ctx, name, rv.Elem().Interface(), set, path)
+	case reflect.Struct:
+		return e.complete(ctx, name, Struct(rv.Type().Name(), v, nil), set, path)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = e.complete(ctx, name, rv.Index(i).Interface(), set, append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+	return e.leaf(name, v, set, path)
+}
+
+// leaf returns a scalar, which may not have a selection
+func (e *executor) leaf(name string, v any, set []selection, path []any) any {
+	if len(set) > 0 {
+		e.fail(path, "field %q is a scalar and has no subfields", name)
+		return nil
+	}
+	return v
+}
+
+// orderedMap is a JSON object keeping the order of the query's fields
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *orderedMap) set(key string, v any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+// MarshalJSON implements json.Marshaler
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// StringArg returns a string argument, or "" when it is missing
+func StringArg(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// IntArg returns an integer argument, or fallback when it is missing
+func IntArg(args map[string]any, name string, fallback int) int {
+	switch n := args[name].(type) {
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return fallback
+}
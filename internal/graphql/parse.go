@@ -0,0 +1,454 @@
+// internal/graphql/parse.go
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// document is a parsed request: its operations and fragments
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	name      string
+	variables []variableDef
+	selection []selection
+}
+
+type variableDef struct {
+	name     string
+	fallback any
+	hasValue bool
+}
+
+type fragment struct {
+	typeCond  string
+	selection []selection
+}
+
+// selection is a field, a fragment spread or an inline fragment
+type selection struct {
+	alias, name string
+	args        map[string]any
+	directives  []directive
+	selection   []selection
+
+	// spread names a fragment; inline fragments set typeCond and
+	// selection without a name
+	spread   string
+	inline   bool
+	typeCond string
+}
+
+type directive struct {
+	name string
+	args map[string]any
+}
+
+// variableRef is a $name in a value, resolved at execution
+type variableRef string
+
+// enumValue is a bare name used as a value
+type enumValue string
+
+// token kinds
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokString
+	tokInt
+	tokFloat
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// lex splits a query into tokens, dropping whitespace, commas and comments
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, token{tokPunct, "..."})
+			i += 3
+		case strings.ContainsRune("{}()[]:$!=@", rune(c)):
+			tokens = append(tokens, token{tokPunct, string(c)})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d", i)
+			}
+			tokens = append(tokens, token{tokString, s})
+			i = j + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			kind := tokInt
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || strings.ContainsRune(".eE+-", rune(src[j]))) {
+				if src[j] == '.' || src[j] == 'e' || src[j] == 'E' {
+					kind = tokFloat
+				}
+				j++
+			}
+			tokens = append(tokens, token{kind, src[i:j]})
+			i = j
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{tokName, src[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// parser is a recursive-descent parser over the tokens of a query
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the punctuator punct if it comes next
+func (p *parser) accept(punct string) bool {
+	if t := p.peek(); t.kind == tokPunct && t.text == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.accept(punct) {
+		return fmt.Errorf("expected %q, found %s", punct, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokName {
+		return "", fmt.Errorf("expected a name, found %s", describe(t))
+	}
+	return t.text, nil
+}
+
+func describe(t token) string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return strconv.Quote(t.text)
+}
+
+// parse parses a query document
+func parse(src string) (*document, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	doc := &document{fragments: make(map[string]*fragment)}
+
+	for p.peek().kind != tokEOF {
+		t := p.peek()
+		switch {
+		case t.kind == tokPunct && t.text == "{":
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{selection: sel})
+		case t.kind == tokName && t.text == "query":
+			p.next()
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case t.kind == tokName && (t.text == "mutation" || t.text == "subscription"):
+			return nil, fmt.Errorf("%s operations are not supported", t.text)
+		case t.kind == tokName && t.text == "fragment":
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if on, err := p.name(); err != nil || on != "on" {
+				return nil, fmt.Errorf("expected \"on\" after fragment %s", name)
+			}
+			typeCond, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = &fragment{typeCond: typeCond, selection: sel}
+		default:
+			return nil, fmt.Errorf("unexpected %s", describe(t))
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("no operation in query")
+	}
+	return doc, nil
+}
+
+// operation parses what follows the query keyword
+func (p *parser) operation() (*operation, error) {
+	op := &operation{}
+	if p.peek().kind == tokName {
+		op.name = p.next().text
+	}
+	if p.accept("(") {
+		for !p.accept(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if err := p.skipType(); err != nil {
+				return nil, err
+			}
+			def := variableDef{name: name}
+			if p.accept("=") {
+				if def.fallback, err = p.value(); err != nil {
+					return nil, err
+				}
+				def.hasValue = true
+			}
+			op.variables = append(op.variables, def)
+		}
+	}
+	var err error
+	if _, err = p.directives(); err != nil {
+		return nil, err
+	}
+	op.selection, err = p.selectionSet()
+	return op, err
+}
+
+// skipType consumes a type reference such as [String!]!; variable types
+// are not checked
+func (p *parser) skipType() error {
+	if p.accept("[") {
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	p.accept("!")
+	return nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var set []selection
+	for !p.accept("}") {
+		if p.peek().kind == tokEOF {
+			return nil, fmt.Errorf("expected \"}\", found end of query")
+		}
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+	}
+	return set, nil
+}
+
+func (p *parser) selection() (selection, error) {
+	var sel selection
+	var err error
+	if p.accept("...") {
+		if t := p.peek(); t.kind == tokName && t.text != "on" {
+			sel.spread = p.next().text
+			sel.directives, err = p.directives()
+			return sel, err
+		}
+		sel.inline = true
+		if t := p.peek(); t.kind == tokName && t.text == "on" {
+			p.next()
+			if sel.typeCond, err = p.name(); err != nil {
+				return sel, err
+			}
+		}
+		if sel.directives, err = p.directives(); err != nil {
+			return sel, err
+		}
+		sel.selection, err = p.selectionSet()
+		return sel, err
+	}
+
+	if sel.name, err = p.name(); err != nil {
+		return sel, err
+	}
+	sel.alias = sel.name
+	if p.accept(":") {
+		if sel.name, err = p.name(); err != nil {
+			return sel, err
+		}
+	}
+	if sel.args, err = p.arguments(); err != nil {
+		return sel, err
+	}
+	if sel.directives, err = p.directives(); err != nil {
+		return sel, err
+	}
+	if t := p.peek(); t.kind == tokPunct && t.text == "{" {
+		sel.selection, err = p.selectionSet()
+	}
+	return sel, err
+}
+
+func (p *parser) arguments() (map[string]any, error) {
+	args := make(map[string]any)
+	if !p.accept("(") {
+		return args, nil
+	}
+	for !p.accept(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var list []directive
+	for p.accept("@") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, directive{name: name, args: args})
+	}
+	return list, nil
+}
+
+func (p *parser) value() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+	case tokInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s", t.text)
+		}
+		return n, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return f, nil
+	case tokName:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return enumValue(t.text), nil
+	case tokPunct:
+		switch t.text {
+		case "$":
+			name, err := p.name()
+			return variableRef(name), err
+		case "[":
+			list := []any{}
+			for !p.accept("]") {
+				if p.peek().kind == tokEOF {
+					return nil, fmt.Errorf("unterminated list")
+				}
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		case "{":
+			obj := make(map[string]any)
+			for !p.accept("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("expected a value, found %s", describe(t))
+}
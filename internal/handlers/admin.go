@@ -0,0 +1,162 @@
+// internal/handlers/admin.go
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/logging"
+)
+
+// defaultReadOnlyMessage is shown when read-only mode has no explicit reason
+const defaultReadOnlyMessage = "The panel is in read-only mode; control actions are disabled"
+
+// readOnlyMode is the global read-only / maintenance toggle
+type readOnlyMode struct {
+	mu      sync.RWMutex
+	enabled bool
+	message string
+}
+
+// ReadOnlyState describes the current read-only mode
+type ReadOnlyState struct {
+	ReadOnly bool   `json:"read_only"`
+	Message  string `json:"message,omitempty"`
+}
+
+// SetReadOnly enables or disables read-only mode. An empty message uses
+// the default explanation.
+func (h *Handler) SetReadOnly(enabled bool, message string) {
+	if message == "" {
+		message = defaultReadOnlyMessage
+	}
+
+	h.readOnly.mu.Lock()
+	defer h.readOnly.mu.Unlock()
+	h.readOnly.enabled = enabled
+	h.readOnly.message = message
+}
+
+// ReadOnlyState returns the current read-only mode
+func (h *Handler) ReadOnlyState() ReadOnlyState {
+	h.readOnly.mu.RLock()
+	defer h.readOnly.mu.RUnlock()
+
+	if !h.readOnly.enabled {
+		return ReadOnlyState{}
+	}
+	return ReadOnlyState{ReadOnly: true, Message: h.readOnly.message}
+}
+
+// rejectIfReadOnly writes a 503 JSON response and returns true when
+// control actions are disabled
+func (h *Handler) rejectIfReadOnly(w http.ResponseWriter, r *http.Request) bool {
+	state := h.ReadOnlyState()
+	if !state.ReadOnly {
+		return false
+	}
+
+	h.logger.WarnContext(r.Context(), "control action rejected in read-only mode",
+		"path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(APIResponse{Success: false, Error: state.Message}); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+	return true
+}
+
+// ReadOnly reports (GET) or changes (PUT) the read-only mode
+func (h *Handler) ReadOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req ReadOnlyState
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"Invalid JSON body. Expected {\"read_only\":bool,\"message\":string}"}`, bodyErrorCode(err))
+			return
+		}
+		h.SetReadOnly(req.ReadOnly, req.Message)
+
+		state := h.ReadOnlyState()
+		h.logger.InfoContext(r.Context(), "read-only mode changed",
+			"read_only", state.ReadOnly, "remote_addr", r.RemoteAddr)
+		h.audit.Record(audit.Event{
+			Type:       audit.EventReadOnlyChanged,
+			User:       auth.UserFromContext(r.Context()),
+			RemoteAddr: r.RemoteAddr,
+			Message:    "read-only mode changed",
+			Fields:     map[string]any{"read_only": state.ReadOnly},
+		})
+	default:
+		h.logger.WarnContext(r.Context(), "invalid method for read-only endpoint",
+			"method", r.Method, "remote_addr", r.RemoteAddr)
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(h.ReadOnlyState()); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
+
+// SetLogLevel sets the level variable changed by the log-level endpoint
+func (h *Handler) SetLogLevel(level *slog.LevelVar) {
+	h.logLevel = level
+}
+
+// logLevelState is the body of the log-level endpoint
+type logLevelState struct {
+	Level string `json:"level"`
+}
+
+// LogLevel reports (GET) or changes (PUT) the application log level
+func (h *Handler) LogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.logLevel == nil {
+		http.Error(w, `{"error":"Log level is not adjustable"}`, http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req logLevelState
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"Invalid JSON body. Expected {\"level\":\"debug|info|warn|error\"}"}`, bodyErrorCode(err))
+			return
+		}
+		if err := logging.SetLevel(h.logLevel, req.Level); err != nil {
+			http.Error(w, `{"error":"Invalid level. Supported: debug, info, warn, error"}`, http.StatusBadRequest)
+			return
+		}
+
+		level := strings.ToLower(h.logLevel.Level().String())
+		h.logger.InfoContext(r.Context(), "log level changed",
+			"level", level, "remote_addr", r.RemoteAddr)
+		h.audit.Record(audit.Event{
+			Type:       audit.EventLogLevelChanged,
+			User:       auth.UserFromContext(r.Context()),
+			RemoteAddr: r.RemoteAddr,
+			Message:    "log level changed to " + level,
+			Fields:     map[string]any{"level": level},
+		})
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(logLevelState{Level: strings.ToLower(h.logLevel.Level().String())}); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
@@ -0,0 +1,123 @@
+// internal/handlers/badge.go
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"sysdwitch/internal/service"
+)
+
+// badgeTemplate renders a flat shields.io-style badge
+var badgeTemplate = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Message}}">
+<title>{{.Label}}: {{.Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="14">{{.Label}}</text>
+<text x="{{.MessageX}}" y="14">{{.Message}}</text>
+</g>
+</svg>
+`))
+
+// badgeColors maps unit states to badge colours
+var badgeColors = map[service.State]string{
+	service.StateRunning:  "#4c1",
+	service.StateFailed:   "#e05d44",
+	service.StateDegraded: "#fe7d37",
+	service.StateStarting: "#dfb317",
+	service.StateStopping: "#dfb317",
+}
+
+// badgeTextWidth approximates the rendered width of s in 11px Verdana
+func badgeTextWidth(s string) int {
+	return len(s)*7 + 10
+}
+
+// SetPublicBadges sets the services whose badges are served without auth
+func (h *Handler) SetPublicBadges(services []string) {
+	h.publicBadges = make(map[string]bool, len(services))
+	for _, s := range services {
+		h.publicBadges[normalizeServiceName(s)] = true
+	}
+}
+
+// Badge serves GET /badge/{service}.svg. Public badges skip authentication;
+// all others require the usual credentials.
+func (h *Handler) Badge(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/badge/"), ".svg")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	serviceName := normalizeServiceName(name)
+
+	if h.publicBadges[serviceName] {
+		h.renderBadge(w, r, serviceName)
+		return
+	}
+
+	h.authConfig.BasicAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		h.renderBadge(w, r, serviceName)
+	})(w, r)
+}
+
+// renderBadge writes the SVG badge for serviceName
+func (h *Handler) renderBadge(w http.ResponseWriter, r *http.Request, serviceName string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Units that cannot be read show as unknown
+	status, err := h.serviceManager.GetServiceStatus(r.Context(), serviceName)
+	if errors.Is(err, service.ErrNotAllowed) {
+		http.NotFound(w, r)
+		return
+	}
+
+	label := strings.TrimSuffix(serviceName, ".service")
+	message := string(status.Status)
+	color, ok := badgeColors[status.Status]
+	if !ok {
+		color = "#9f9f9f"
+	}
+
+	labelWidth, messageWidth := badgeTextWidth(label), badgeTextWidth(message)
+	data := struct {
+		Label, Message, Color           string
+		Width, LabelWidth, MessageWidth int
+		LabelX, MessageX                float64
+	}{
+		Label:        label,
+		Message:      message,
+		Color:        color,
+		Width:        labelWidth + messageWidth,
+		LabelWidth:   labelWidth,
+		MessageWidth: messageWidth,
+		LabelX:       float64(labelWidth) / 2,
+		MessageX:     float64(labelWidth) + float64(messageWidth)/2,
+	}
+
+	var buf bytes.Buffer
+	if err := badgeTemplate.Execute(&buf, data); err != nil {
+		h.logger.ErrorContext(r.Context(), "badge rendering failed",
+			"error", err, "service", serviceName, "remote_addr", r.RemoteAddr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+	w.Write(buf.Bytes())
+}
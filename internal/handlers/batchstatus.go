@@ -0,0 +1,115 @@
+// internal/handlers/batchstatus.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"sysdwitch/internal/service"
+)
+
+// statusFields are the JSON names of service.ServiceStatus, plus the latest
+// usage sample as cpu and memory
+var statusFields = func() []string {
+	fields := []string{"cpu", "memory"}
+	t := reflect.TypeFor[service.ServiceStatus]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}()
+
+// selectedStatus answers GET /api/services/status?names=a,b&fields=name,active
+// with only the requested services and fields, keeping the payload small
+// for constrained clients. Empty fields are left out as in the full
+// response.
+func (h *Handler) selectedStatus(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var fields []string
+	for field := range strings.SplitSeq(query.Get("fields"), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(statusFields, field) {
+			w.WriteHeader(http.StatusBadRequest)
+			h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("unknown field %q", field)})
+			return
+		}
+		fields = append(fields, field)
+	}
+
+	ctx := r.Context()
+	var statuses []service.ServiceStatus
+	if names := query.Get("names"); names != "" {
+		for name := range strings.SplitSeq(names, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			status, err := h.serviceManager.GetServiceStatus(ctx, normalizeServiceName(name))
+			if errors.Is(err, service.ErrNotAllowed) {
+				w.WriteHeader(http.StatusNotFound)
+				h.writeJSON(w, r, APIResponse{Success: false, Error: "Service not allowed: " + name})
+				return
+			}
+			statuses = append(statuses, status)
+		}
+	} else {
+		statuses = h.serviceManager.GetAllServicesStatus(ctx)
+	}
+
+	if len(fields) == 0 {
+		h.writeJSONWithETag(w, r, APIResponse{Success: true, Services: statuses})
+		return
+	}
+	services := make([]map[string]any, 0, len(statuses))
+	for _, status := range statuses {
+		selected, err := h.selectFields(status, fields)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to select status fields", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		services = append(services, selected)
+	}
+	h.writeJSONWithETag(w, r, struct {
+		Success  bool             `json:"success"`
+		Services []map[string]any `json:"services"`
+	}{true, services})
+}
+
+// selectFields reduces a status to the named fields
+func (h *Handler) selectFields(status service.ServiceStatus, fields []string) (map[string]any, error) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]any
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	if slices.Contains(fields, "cpu") || slices.Contains(fields, "memory") {
+		if samples := h.serviceManager.Samples(status.Name); len(samples) > 0 {
+			last := samples[len(samples)-1]
+			all["cpu"] = last.CPUPercent
+			all["memory"] = last.MemoryBytes
+		}
+	}
+
+	selected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
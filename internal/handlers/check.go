@@ -0,0 +1,127 @@
+// internal/handlers/check.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// Nagios plugin states; their order is the plugin exit code
+const (
+	CheckOK = iota
+	CheckWarning
+	CheckCritical
+	CheckUnknown
+)
+
+// checkStates names the plugin states
+var checkStates = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// CheckHTTPStatus maps plugin states to response codes. check_http reads
+// 4xx as WARNING and 5xx as CRITICAL, so it agrees with the body.
+var CheckHTTPStatus = [...]int{
+	CheckOK:       http.StatusOK,
+	CheckWarning:  http.StatusConflict,
+	CheckCritical: http.StatusServiceUnavailable,
+	CheckUnknown:  http.StatusInternalServerError,
+}
+
+// Check answers GET /api/check/{service} like a Nagios or Icinga plugin:
+// a line such as "SYSDWITCH OK - jellyfin.service is active | active=1"
+// with the plugin state as the response code. Services are expected to
+// run unless ?expect=stopped is given.
+func (h *Handler) Check(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	expect := r.URL.Query().Get("expect")
+	if expect != "" && expect != "running" && expect != "stopped" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "SYSDWITCH UNKNOWN - expect must be running or stopped")
+		return
+	}
+
+	serviceName := normalizeServiceName(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/check/"), "/"))
+	// Units that cannot be read are UNKNOWN, judged by serviceCheck
+	status, err := h.serviceManager.GetServiceStatus(r.Context(), serviceName)
+	if errors.Is(err, service.ErrNotAllowed) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "SYSDWITCH UNKNOWN - %s is not allowed\n", serviceName)
+		return
+	}
+
+	state, output := serviceCheck(status, expect == "stopped")
+	perfdata := checkPerfdata(status, h.serviceManager.Samples(serviceName), time.Now())
+	w.WriteHeader(CheckHTTPStatus[state])
+	fmt.Fprintf(w, "SYSDWITCH %s - %s | %s\n", checkStates[state], output, perfdata)
+}
+
+// serviceCheck judges a service's status. Transitions and flapping warn;
+// a service in the wrong state is critical, or only a warning while a
+// maintenance window covers it.
+func serviceCheck(status service.ServiceStatus, expectStopped bool) (int, string) {
+	output := fmt.Sprintf("%s is %s", status.Name, status.Status)
+	if status.Active && status.Since != nil {
+		output += " for " + formatUptime(status.Since)
+	}
+
+	var state int
+	switch status.Status {
+	case service.StateUnknown:
+		return CheckUnknown, "cannot read the state of " + status.Name
+	case service.StateStarting, service.StateStopping, service.StateDegraded:
+		state = CheckWarning
+	case service.StateRunning:
+		state = CheckOK
+		if expectStopped {
+			state = CheckCritical
+		}
+	case service.StateStopped:
+		state = CheckCritical
+		if expectStopped {
+			state = CheckOK
+		}
+	default:
+		state = CheckCritical
+	}
+
+	if status.Flapping && state == CheckOK {
+		state = CheckWarning
+		output += ", flapping"
+	}
+	if status.Maintenance != "" {
+		if state == CheckCritical {
+			state = CheckWarning
+		}
+		output += " (maintenance window " + status.Maintenance + ")"
+	}
+	return state, output
+}
+
+// checkPerfdata formats performance data: whether the service is active,
+// its uptime and its latest CPU and memory sample
+func checkPerfdata(status service.ServiceStatus, samples []service.Sample, now time.Time) string {
+	active := 0
+	if status.Active {
+		active = 1
+	}
+	perfdata := []string{fmt.Sprintf("active=%d;;;0;1", active)}
+	if status.Active && status.Since != nil {
+		perfdata = append(perfdata, fmt.Sprintf("uptime=%ds;;;0", int64(now.Sub(*status.Since).Seconds())))
+	}
+	if len(samples) > 0 {
+		latest := samples[len(samples)-1]
+		perfdata = append(perfdata,
+			fmt.Sprintf("cpu=%.1f%%;;;0", latest.CPUPercent),
+			fmt.Sprintf("memory=%dB;;;0", latest.MemoryBytes))
+	}
+	return strings.Join(perfdata, " ")
+}
@@ -0,0 +1,87 @@
+// internal/handlers/debug.go
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"sysdwitch/internal/events"
+)
+
+// BuildInfo identifies the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+// SetBuildInfo records the version information compiled into the binary
+// and marks the process start time
+func (h *Handler) SetBuildInfo(info BuildInfo) {
+	h.build = info
+	h.started = time.Now()
+}
+
+// debugInfo is the body of GET /api/admin/debug
+type debugInfo struct {
+	BuildInfo
+	GoVersion  string        `json:"go_version"`
+	StartedAt  time.Time     `json:"started_at"`
+	Uptime     string        `json:"uptime"`
+	Goroutines int           `json:"goroutines"`
+	Memory     memoryStats   `json:"memory"`
+	Events     *events.Stats `json:"events,omitempty"`
+}
+
+// memoryStats is the subset of runtime.MemStats useful for spotting leaks
+type memoryStats struct {
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapInuse    uint64 `json:"heap_inuse_bytes"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	TotalAlloc   uint64 `json:"total_alloc_bytes"`
+	Sys          uint64 `json:"sys_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+}
+
+// Debug reports runtime statistics of the panel process
+func (h *Handler) Debug(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	info := debugInfo{
+		BuildInfo:  h.build,
+		GoVersion:  runtime.Version(),
+		StartedAt:  h.started,
+		Uptime:     time.Since(h.started).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: memoryStats{
+			HeapAlloc:    mem.HeapAlloc,
+			HeapInuse:    mem.HeapInuse,
+			HeapObjects:  mem.HeapObjects,
+			TotalAlloc:   mem.TotalAlloc,
+			Sys:          mem.Sys,
+			NumGC:        mem.NumGC,
+			PauseTotalNs: mem.PauseTotalNs,
+		},
+	}
+
+	if h.events != nil {
+		stats := h.events.Stats()
+		info.Events = &stats
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response for debug",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
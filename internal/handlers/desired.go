@@ -0,0 +1,40 @@
+// internal/handlers/desired.go
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"sysdwitch/internal/desired"
+)
+
+// SetDesiredState sets the reconciler whose reports drive the drift banner;
+// nil disables desired-state reconciliation
+func (h *Handler) SetDesiredState(reconciler *desired.Reconciler) {
+	h.desired = reconciler
+}
+
+// DesiredState returns the last reconciliation report at
+// GET /api/desired-state; POST runs a pass right away, e.g. from a hook
+// after pushing to the repository
+func (h *Handler) DesiredState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.desired == nil {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Desired state is not configured"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.writeJSON(w, r, map[string]any{"success": true, "desired_state": h.desired.Latest()})
+	case http.MethodPost:
+		if h.rejectIfReadOnly(w, r) {
+			return
+		}
+		report := h.desired.Reconcile(context.WithoutCancel(r.Context()))
+		h.writeJSON(w, r, map[string]any{"success": report.Error == "", "desired_state": report})
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
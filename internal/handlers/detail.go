@@ -0,0 +1,102 @@
+// internal/handlers/detail.go
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// detailJournalLines is how many journal lines the detail page shows
+const detailJournalLines = 50
+
+// uptimeWindow is the period covered by the uptime history chart
+const uptimeWindow = 24 * time.Hour
+
+// uptimeSegment is one bar of the uptime chart, positioned in a 0-1000
+// wide SVG viewBox
+type uptimeSegment struct {
+	X, Width float64
+	Status   string
+	Up       bool
+	From     time.Time
+}
+
+// ServiceDetail renders the detail page for a single service at
+// /services/{name}
+func (h *Handler) ServiceDetail(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/services/")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	details, err := h.serviceManager.GetServiceDetails(r.Context(), normalizeServiceName(name), detailJournalLines)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	readOnly := h.ReadOnlyState()
+	data := struct {
+		service.Details
+		Card     cardView
+		Uptime   []uptimeSegment
+		ReadOnly ReadOnlyState
+		Theme    themeData
+	}{
+		Details: details,
+		Card: cardView{
+			ServiceStatus: details.ServiceStatus,
+			ReadOnly:      readOnly.ReadOnly,
+			Usage:         h.serviceManager.Samples(details.Name),
+		},
+		Uptime:   uptimeSegments(details.History, time.Now()),
+		ReadOnly: readOnly,
+		Theme:    h.themeFor(r),
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if err := h.templates.ExecuteTemplate(w, "detail.html", data); err != nil {
+		h.logger.ErrorContext(r.Context(), "template execution error",
+			"error", err, "template", "detail.html", "remote_addr", r.RemoteAddr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// uptimeSegments turns observed transitions into chart segments covering
+// the uptimeWindow before now. Time before the first observation is left
+// blank.
+func uptimeSegments(history []service.Transition, now time.Time) []uptimeSegment {
+	start := now.Add(-uptimeWindow)
+	scale := 1000 / float64(uptimeWindow)
+
+	var segments []uptimeSegment
+	for i, t := range history {
+		end := now
+		if i+1 < len(history) {
+			end = history[i+1].At
+		}
+		if !end.After(start) {
+			continue
+		}
+		from := t.At
+		if from.Before(start) {
+			from = start
+		}
+		segments = append(segments, uptimeSegment{
+			X:      float64(from.Sub(start)) * scale,
+			Width:  float64(end.Sub(from)) * scale,
+			Status: string(t.Status),
+			Up:     t.Status.Active(),
+			From:   t.At,
+		})
+	}
+	return segments
+}
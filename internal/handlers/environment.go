@@ -0,0 +1,64 @@
+// internal/handlers/environment.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"sysdwitch/internal/service"
+)
+
+// maxEnvBody bounds the JSON body of a control action
+const maxEnvBody = 16 << 10
+
+// actionBody is the optional JSON body of a control action. Action, Wait
+// and DryRun are only read by POST /api/services/{name}, where the action
+// is not part of the path.
+type actionBody struct {
+	Action string            `json:"action,omitempty"`
+	Wait   string            `json:"wait,omitempty"`
+	DryRun bool              `json:"dry_run,omitempty"`
+	Env    map[string]string `json:"env"`
+}
+
+// readActionBody decodes the JSON body of a control action; requests
+// without a body yield an empty one
+func readActionBody(r *http.Request) (actionBody, error) {
+	var body actionBody
+	if r.Body == nil || r.ContentLength == 0 {
+		return body, nil
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxEnvBody)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		return body, fmt.Errorf("invalid request body: %w", err)
+	}
+	return body, nil
+}
+
+// withEnvironment reads environment overrides from a JSON body such as
+// {"env":{"WORLD":"alpha"}} and attaches them to ctx for a start action.
+// Requests without a body are passed through unchanged.
+func (h *Handler) withEnvironment(ctx context.Context, r *http.Request, serviceName, action string) (context.Context, error) {
+	body, err := readActionBody(r)
+	if err != nil {
+		return ctx, err
+	}
+	return h.applyEnvironment(ctx, body, serviceName, action)
+}
+
+// applyEnvironment attaches the validated overrides of body to ctx
+func (h *Handler) applyEnvironment(ctx context.Context, body actionBody, serviceName, action string) (context.Context, error) {
+	if len(body.Env) == 0 {
+		return ctx, nil
+	}
+	if action != "start" && action != "restart" {
+		return ctx, errors.New("environment overrides only apply to start and restart")
+	}
+	if err := h.serviceManager.ValidateEnvironment(serviceName, body.Env); err != nil {
+		return ctx, err
+	}
+	return service.WithEnvironment(ctx, body.Env), nil
+}
@@ -0,0 +1,59 @@
+// internal/handlers/etag.go
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// writeJSONWithETag encodes v like writeJSON, tagging the body with a hash
+// of its content. When the request's If-None-Match already names that tag
+// it answers 304 Not Modified without a body, so pollers only transfer
+// statuses that changed.
+func (h *Handler) writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response",
+			"error", err, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	etag := bodyETag(body)
+	w.Header().Set("ETag", etag)
+	if w.Header().Get("Cache-Control") == "" {
+		// Let caches keep the body but revalidate it on every request
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		h.logger.DebugContext(r.Context(), "failed to write JSON response",
+			"error", err, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	}
+}
+
+// bodyETag is the strong entity tag of a response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header names etag, using
+// the weak comparison RFC 9110 prescribes for it
+func etagMatches(header, etag string) bool {
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
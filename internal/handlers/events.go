@@ -0,0 +1,165 @@
+// internal/handlers/events.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/events"
+)
+
+const (
+	// eventWriteTimeout bounds each write to an event stream, so a client
+	// that stopped reading is dropped instead of holding its handler
+	eventWriteTimeout = 10 * time.Second
+	// eventKeepalive is how often an idle stream gets a comment, keeping
+	// proxies from closing it
+	eventKeepalive = 25 * time.Second
+	// eventRetry is how soon browsers reconnect after a stream ended, as
+	// when the panel shuts down for an upgrade
+	eventRetry = time.Second
+)
+
+// recentEventsShown is how many recent events the dashboard's activity
+// feed starts with
+const recentEventsShown = 10
+
+// SetEvents sets the hub streamed at GET /api/events
+func (h *Handler) SetEvents(hub *events.Hub) {
+	h.events = hub
+}
+
+// SetHistory sets the recent events served at GET /api/events/recent and
+// shown on the dashboard
+func (h *Handler) SetHistory(history *events.History) {
+	h.history = history
+}
+
+// recentEventsResponse is the body of GET /api/events/recent
+type recentEventsResponse struct {
+	Success bool          `json:"success"`
+	Events  []audit.Event `json:"events"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// RecentEvents lists the latest state changes and actions, newest first,
+// at most ?limit= of them and of ?service= only when given
+func (h *Handler) RecentEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		h.writeJSON(w, r, recentEventsResponse{Error: "Method not allowed"})
+		return
+	}
+	if h.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, recentEventsResponse{Error: "Event history disabled"})
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			h.writeJSON(w, r, recentEventsResponse{Error: fmt.Sprintf("invalid limit %q", value)})
+			return
+		}
+		limit = n
+	}
+	serviceName := query.Get("service")
+	if serviceName != "" {
+		serviceName = normalizeServiceName(serviceName)
+		if !h.serviceManager.IsAllowed(serviceName) {
+			w.WriteHeader(http.StatusNotFound)
+			h.writeJSON(w, r, recentEventsResponse{Error: "Service not allowed"})
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, recentEventsResponse{Success: true, Events: h.history.Recent(limit, serviceName)})
+}
+
+// recentActivity is the start of the dashboard's activity feed
+func (h *Handler) recentActivity() []audit.Event {
+	if h.history == nil {
+		return nil
+	}
+	return h.history.Recent(recentEventsShown, "")
+}
+
+// Events streams audit events as server-sent events, one JSON event per
+// message with its sequence number as id. The stream ends when the client
+// falls too far behind or the panel shuts down; browsers reconnect on their
+// own and should reload state, as events in between are lost. Users
+// without the admin role only get events about services, as in the
+// history; logins, tasks and admin changes are not theirs to see.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.events == nil {
+		http.Error(w, "Event stream disabled", http.StatusNotFound)
+		return
+	}
+
+	client := h.events.Subscribe()
+	defer client.Close()
+
+	ctx := r.Context()
+	admin := auth.IsAdmin(ctx)
+	rc := http.NewResponseController(w)
+	write := func(format string, args ...any) bool {
+		// Each write gets its own deadline in place of the server's
+		if err := rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout)); err != nil {
+			h.logger.DebugContext(ctx, "cannot set write deadline for event stream", "error", err)
+		}
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// Keep nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	if !write(": connected\nretry: %d\n\n", eventRetry.Milliseconds()) {
+		return
+	}
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.Evicted():
+			return
+		case <-keepalive.C:
+			if !write(": keepalive\n\n") {
+				return
+			}
+		case msg := <-client.Messages():
+			if !admin && !events.ServiceEvent(msg.Event.Type) {
+				continue
+			}
+			data, err := json.Marshal(msg.Event)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to encode event", "error", err, "event", msg.Event.Type)
+				continue
+			}
+			if !write("id: %d\ndata: %s\n\n", msg.ID, data) {
+				return
+			}
+		}
+	}
+}
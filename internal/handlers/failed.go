@@ -0,0 +1,62 @@
+// internal/handlers/failed.go
+package handlers
+
+import (
+	"net/http"
+
+	"sysdwitch/internal/service"
+)
+
+// failedJournalLines is how many journal lines are shown per failed unit
+const failedJournalLines = 20
+
+// failedResponse is the body of GET /api/failed
+type failedResponse struct {
+	Success bool                 `json:"success"`
+	Units   []service.FailedUnit `json:"units"`
+	Error   string               `json:"error,omitempty"`
+}
+
+// Failed lists all failed user units at /api/failed, as JSON, or as a
+// triage page at /failed
+func (h *Handler) Failed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	units, err := h.serviceManager.FailedUnits(r.Context(), failedJournalLines)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to list failed units",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+
+	if r.URL.Path == "/failed" {
+		data := struct {
+			Units    []service.FailedUnit
+			Error    bool
+			ReadOnly ReadOnlyState
+			Theme    themeData
+		}{
+			Units:    units,
+			Error:    err != nil,
+			ReadOnly: h.ReadOnlyState(),
+			Theme:    h.themeFor(r),
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if err := h.templates.ExecuteTemplate(w, "failed.html", data); err != nil {
+			h.logger.ErrorContext(r.Context(), "template execution error",
+				"error", err, "template", "failed.html", "remote_addr", r.RemoteAddr)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		h.writeJSON(w, r, failedResponse{Units: []service.FailedUnit{}, Error: "Failed to list units"})
+		return
+	}
+	h.writeJSON(w, r, failedResponse{Success: true, Units: units})
+}
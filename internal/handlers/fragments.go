@@ -0,0 +1,111 @@
+// internal/handlers/fragments.go
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/service"
+)
+
+// ServiceFragment serves server-rendered service cards for in-place updates:
+//
+//	GET  /ui/services/{name}/card      the current card
+//	POST /ui/services/{name}/{action}  run the action, return the updated card
+//
+// Actions outlasting the async threshold return the card marked busy and
+// the job to poll in the X-Job-ID header.
+// Failures are reported in the X-Error header alongside the current card so
+// the client can still swap it in.
+func (h *Handler) ServiceFragment(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/ui/services/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	serviceName := normalizeServiceName(parts[0])
+	action := parts[1]
+	ctx := r.Context()
+
+	var status service.ServiceStatus
+	var err error
+	pending := ""
+	switch {
+	case action == "card" && r.Method == http.MethodGet:
+		status, err = h.serviceManager.GetServiceStatus(ctx, serviceName)
+
+	case action != "card" && r.Method == http.MethodPost:
+		if state := h.ReadOnlyState(); state.ReadOnly {
+			w.Header().Set("X-Error", state.Message)
+			status, err = h.serviceManager.GetServiceStatus(ctx, serviceName)
+			break
+		}
+		if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+			ctx = service.WithDryRun(ctx)
+		}
+		ctx, err = h.withEnvironment(ctx, r, serviceName, action)
+		if err != nil {
+			http.Error(w, err.Error(), bodyErrorCode(err))
+			return
+		}
+		var job *jobs.Job
+		status, job, err = h.runAction(ctx, r, serviceName, action, 0)
+		if errors.Is(err, service.ErrNotAllowed) {
+			break
+		}
+		if errors.Is(err, jobs.ErrBusy) {
+			// Show the card busy with the in-flight job instead
+			w.Header().Set("X-Error", err.Error())
+			err = nil
+		} else if err != nil && status.Status != "" {
+			// Failed or refused: show the unit as it is
+			w.Header().Set("X-Error", err.Error())
+			err = nil
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if job != nil {
+			// Render the card as busy; the client polls the job and
+			// fetches the card again when it finishes
+			w.Header().Set("X-Job-ID", job.ID)
+			status, _ = h.serviceManager.GetServiceStatus(ctx, serviceName)
+			pending = job.Status
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if errors.Is(err, service.ErrNotAllowed) {
+		http.NotFound(w, r)
+		return
+	}
+	if status.Status == service.StateUnknown && pending == "" && w.Header().Get("X-Error") == "" {
+		w.Header().Set("X-Error", "Operation failed: "+action+" "+serviceName)
+	}
+
+	view := cardView{
+		ServiceStatus: status,
+		ReadOnly:      h.ReadOnlyState().ReadOnly,
+		Usage:         h.serviceManager.Samples(status.Name),
+		Pending:       pending,
+	}
+	card, err := h.templates.Card(view)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "template execution error",
+			"error", err, "template", "service-card", "remote_addr", r.RemoteAddr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	io.WriteString(w, string(card))
+}
@@ -0,0 +1,257 @@
+// internal/handlers/grafana.go
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// maxGrafanaBody caps the size of Grafana search and query requests
+const maxGrafanaBody = 64 << 10
+
+// grafanaMetrics are the series of a service, queried as "<unit>:<metric>"
+var grafanaMetrics = []string{"up", "availability", "cpu", "memory"}
+
+// grafanaServicesTable is the target of the table of all services
+const grafanaServicesTable = "services"
+
+// grafanaQuery is the body of POST /query from the Grafana JSON datasource
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is a time series; each point is [value, unix milliseconds]
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// grafanaColumn is a column of a table response
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// grafanaTable is a table response
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]any         `json:"rows"`
+}
+
+// Grafana implements the contract of the Grafana JSON and Infinity
+// datasources under /api/grafana: GET / tests the connection, POST /search
+// lists targets and POST /query returns series over the requested range
+func (h *Handler) Grafana(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/grafana"), "/") {
+	case "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.writeJSON(w, r, APIResponse{Success: true})
+	case "search":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Target string `json:"target"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGrafanaBody)).Decode(&body); err != nil {
+				w.WriteHeader(bodyErrorCode(err))
+				h.writeJSON(w, r, APIResponse{Success: false, Error: "invalid request body: " + err.Error()})
+				return
+			}
+		}
+		h.writeJSON(w, r, h.grafanaTargets(body.Target))
+	case "query":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var query grafanaQuery
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGrafanaBody)).Decode(&query); err != nil {
+			w.WriteHeader(bodyErrorCode(err))
+			h.writeJSON(w, r, APIResponse{Success: false, Error: "invalid request body: " + err.Error()})
+			return
+		}
+		h.grafanaQuery(w, r, query)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Not found"})
+	}
+}
+
+// grafanaTargets lists the targets containing filter, ignoring case
+func (h *Handler) grafanaTargets(filter string) []string {
+	filter = strings.ToLower(filter)
+	targets := []string{}
+	if strings.Contains(grafanaServicesTable, filter) {
+		targets = append(targets, grafanaServicesTable)
+	}
+	for _, name := range h.serviceManager.AllowedServices() {
+		for _, metric := range grafanaMetrics {
+			if target := name + ":" + metric; strings.Contains(strings.ToLower(target), filter) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// grafanaQuery answers each target with a series, or the services target
+// with a table
+func (h *Handler) grafanaQuery(w http.ResponseWriter, r *http.Request, query grafanaQuery) {
+	now := time.Now()
+	from, to := query.Range.From, query.Range.To
+	if to.IsZero() || to.After(now) {
+		to = now
+	}
+	if from.IsZero() || from.After(to) {
+		from = to.Add(-time.Hour)
+	}
+
+	results := []any{}
+	for _, t := range query.Targets {
+		if t.Hide || t.Target == "" {
+			continue
+		}
+		if t.Target == grafanaServicesTable {
+			results = append(results, h.grafanaServices(r, from, to))
+			continue
+		}
+
+		name, metric, _ := strings.Cut(t.Target, ":")
+		name = normalizeServiceName(name)
+		if !h.serviceManager.IsAllowed(name) || !slices.Contains(grafanaMetrics, metric) {
+			w.WriteHeader(http.StatusBadRequest)
+			h.writeJSON(w, r, APIResponse{Success: false, Error: "unknown target " + t.Target})
+			return
+		}
+
+		series := grafanaSeries{Target: t.Target, Datapoints: [][2]float64{}}
+		switch metric {
+		case "up":
+			series.Datapoints = upSeries(h.serviceManager.History(name), from, to)
+		case "availability":
+			if pct, ok := availability(h.serviceManager.History(name), from, to); ok {
+				series.Datapoints = append(series.Datapoints, [2]float64{pct, float64(to.UnixMilli())})
+			}
+		case "cpu", "memory":
+			for _, s := range h.serviceManager.Samples(name) {
+				if s.At.Before(from) || s.At.After(to) {
+					continue
+				}
+				value := s.CPUPercent
+				if metric == "memory" {
+					value = float64(s.MemoryBytes)
+				}
+				series.Datapoints = append(series.Datapoints, [2]float64{value, float64(s.At.UnixMilli())})
+			}
+		}
+		results = append(results, series)
+	}
+	h.writeJSON(w, r, results)
+}
+
+// grafanaServices builds the table of services with their status and
+// availability over the range
+func (h *Handler) grafanaServices(r *http.Request, from, to time.Time) grafanaTable {
+	table := grafanaTable{
+		Type: "table",
+		Columns: []grafanaColumn{
+			{Text: "Service", Type: "string"},
+			{Text: "Name", Type: "string"},
+			{Text: "Status", Type: "string"},
+			{Text: "Availability", Type: "number"},
+		},
+		Rows: [][]any{},
+	}
+	for _, s := range h.serviceManager.GetAllServicesStatus(r.Context()) {
+		var pct any
+		if v, ok := availability(h.serviceManager.History(s.Name), from, to); ok {
+			pct = v
+		}
+		table.Rows = append(table.Rows, []any{s.Name, s.Label(), s.Status, pct})
+	}
+	return table
+}
+
+// upSeries turns state transitions into 1 (active) and 0 points: one at
+// the start of the range, one per transition and one at its end. Draw it
+// with step interpolation.
+func upSeries(history []service.Transition, from, to time.Time) [][2]float64 {
+	points := [][2]float64{}
+	// Index of the first transition after from
+	i := sort.Search(len(history), func(i int) bool { return history[i].At.After(from) })
+	last := -1.0
+	if i > 0 {
+		last = upValue(history[i-1].Status)
+		points = append(points, [2]float64{last, float64(from.UnixMilli())})
+	}
+	for ; i < len(history) && !history[i].At.After(to); i++ {
+		last = upValue(history[i].Status)
+		points = append(points, [2]float64{last, float64(history[i].At.UnixMilli())})
+	}
+	if last >= 0 {
+		points = append(points, [2]float64{last, float64(to.UnixMilli())})
+	}
+	return points
+}
+
+// upValue is 1 for running states and 0 otherwise
+func upValue(status service.State) float64 {
+	if status.Active() {
+		return 1
+	}
+	return 0
+}
+
+// availability is the percentage of the observed part of the range the
+// service was active; ok is false when nothing was observed
+func availability(history []service.Transition, from, to time.Time) (pct float64, ok bool) {
+	var observed, active time.Duration
+	for i, t := range history {
+		start := t.At
+		end := to
+		if i+1 < len(history) {
+			end = history[i+1].At
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		observed += end.Sub(start)
+		if t.Status.Active() {
+			active += end.Sub(start)
+		}
+	}
+	if observed == 0 {
+		return 0, false
+	}
+	return math.Round(float64(active)/float64(observed)*10000) / 100, true
+}
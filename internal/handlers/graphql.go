@@ -0,0 +1,161 @@
+// internal/handlers/graphql.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"sysdwitch/internal/graphql"
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/service"
+)
+
+// maxGraphQLBody caps the size of a GraphQL request
+const maxGraphQLBody = 64 << 10
+
+// SetGraphQL enables the GraphQL endpoint at /api/graphql
+func (h *Handler) SetGraphQL(enabled bool) {
+	h.graphql = enabled
+}
+
+// GraphQL answers read-only queries over services, their history and
+// samples, and jobs at /api/graphql. Queries come as a JSON body in a POST
+// or in the query, operationName and variables parameters of a GET.
+func (h *Handler) GraphQL(w http.ResponseWriter, r *http.Request) {
+	if !h.graphql {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if vars := query.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				h.writeJSON(w, r, graphql.Response{Errors: []graphql.Error{{Message: "invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBody)).Decode(&req); err != nil {
+			w.WriteHeader(bodyErrorCode(err))
+			h.writeJSON(w, r, graphql.Response{Errors: []graphql.Error{{Message: "invalid request body: " + err.Error()}}})
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		h.writeJSON(w, r, graphql.Response{Errors: []graphql.Error{{Message: "Method not allowed"}}})
+		return
+	}
+	if req.Query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		h.writeJSON(w, r, graphql.Response{Errors: []graphql.Error{{Message: "missing query"}}})
+		return
+	}
+
+	h.writeJSON(w, r, graphql.Execute(r.Context(), h.graphqlRoot(), req))
+}
+
+// graphqlRoot builds the Query type:
+//
+//	services(status, group): [Service]
+//	service(name): Service
+//	jobs(service): [Job]
+//	job(id): Job
+//
+// Service has the fields of the status API plus history and samples; Job
+// has the fields of the jobs API.
+func (h *Handler) graphqlRoot() graphql.Object {
+	return graphql.Object{Type: "Query", Fields: map[string]graphql.Field{
+		"services": func(ctx context.Context, args map[string]any) (any, error) {
+			state, group := graphql.StringArg(args, "status"), graphql.StringArg(args, "group")
+			want, _ := service.ParseState(state)
+			var list []graphql.Object
+			for _, status := range h.serviceManager.GetAllServicesStatus(ctx) {
+				if (state == "" || status.Status == want) && (group == "" || status.Group == group) {
+					list = append(list, h.graphqlService(status))
+				}
+			}
+			return list, nil
+		},
+		"service": func(ctx context.Context, args map[string]any) (any, error) {
+			name := graphql.StringArg(args, "name")
+			if name == "" {
+				return nil, errors.New("argument name is required")
+			}
+			status, err := h.serviceManager.GetServiceStatus(ctx, normalizeServiceName(name))
+			if errors.Is(err, service.ErrNotAllowed) {
+				return nil, nil
+			}
+			return h.graphqlService(status), nil
+		},
+		"jobs": func(ctx context.Context, args map[string]any) (any, error) {
+			if h.jobs == nil {
+				return []graphql.Object{}, nil
+			}
+			filter := graphql.StringArg(args, "service")
+			if filter != "" {
+				filter = normalizeServiceName(filter)
+			}
+			list := []graphql.Object{}
+			for _, job := range h.jobs.List() {
+				if filter == "" || job.Service == filter {
+					list = append(list, h.graphqlJob(job))
+				}
+			}
+			return list, nil
+		},
+		"job": func(ctx context.Context, args map[string]any) (any, error) {
+			if h.jobs == nil {
+				return nil, nil
+			}
+			job, ok := h.jobs.Get(graphql.StringArg(args, "id"))
+			if !ok {
+				return nil, nil
+			}
+			return h.graphqlJob(job), nil
+		},
+	}}
+}
+
+// graphqlService exposes a service status with its history and samples
+func (h *Handler) graphqlService(status service.ServiceStatus) graphql.Object {
+	return graphql.Struct("Service", status, map[string]graphql.Field{
+		"history": func(context.Context, map[string]any) (any, error) {
+			return h.serviceManager.History(status.Name), nil
+		},
+		"samples": func(_ context.Context, args map[string]any) (any, error) {
+			samples := h.serviceManager.Samples(status.Name)
+			if last := graphql.IntArg(args, "last", 0); last > 0 && last < len(samples) {
+				samples = samples[len(samples)-last:]
+			}
+			return samples, nil
+		},
+	})
+}
+
+// graphqlJob exposes a job, its results as Service objects
+func (h *Handler) graphqlJob(job jobs.Job) graphql.Object {
+	return graphql.Struct("Job", job, map[string]graphql.Field{
+		"result": func(context.Context, map[string]any) (any, error) {
+			if job.Result == nil {
+				return nil, nil
+			}
+			return h.graphqlService(*job.Result), nil
+		},
+		"results": func(context.Context, map[string]any) (any, error) {
+			list := make([]graphql.Object, len(job.Results))
+			for i, status := range job.Results {
+				list[i] = h.graphqlService(status)
+			}
+			return list, nil
+		},
+	})
+}
@@ -0,0 +1,74 @@
+// internal/handlers/groups.go
+package handlers
+
+import (
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/service"
+)
+
+// serviceGroup is a dashboard section
+type serviceGroup struct {
+	Name      string
+	Collapsed bool
+	Services  []service.ServiceStatus
+}
+
+// SetGroups sets the configured dashboard section order
+func (h *Handler) SetGroups(groups []config.GroupConfig) {
+	h.groupsMu.Lock()
+	defer h.groupsMu.Unlock()
+	h.groups = groups
+}
+
+// groupServices splits services into dashboard sections: configured groups
+// first in their declared order, then undeclared groups in order of first
+// appearance, then ungrouped services. Service order within a group is kept.
+func (h *Handler) groupServices(services []service.ServiceStatus) []serviceGroup {
+	index := make(map[string]int)
+	var sections []serviceGroup
+
+	h.groupsMu.RLock()
+	groups := h.groups
+	h.groupsMu.RUnlock()
+	for _, g := range groups {
+		if _, ok := index[g.Name]; ok {
+			continue
+		}
+		index[g.Name] = len(sections)
+		sections = append(sections, serviceGroup{Name: g.Name, Collapsed: g.Collapsed})
+	}
+
+	var ungrouped []service.ServiceStatus
+	for _, s := range services {
+		if s.Group == "" {
+			ungrouped = append(ungrouped, s)
+			continue
+		}
+		i, ok := index[s.Group]
+		if !ok {
+			i = len(sections)
+			index[s.Group] = i
+			sections = append(sections, serviceGroup{Name: s.Group})
+		}
+		sections[i].Services = append(sections[i].Services, s)
+	}
+
+	// Drop configured groups that ended up empty
+	nonEmpty := sections[:0]
+	for _, g := range sections {
+		if len(g.Services) > 0 {
+			nonEmpty = append(nonEmpty, g)
+		}
+	}
+	sections = nonEmpty
+
+	if len(ungrouped) > 0 {
+		name := ""
+		if len(sections) > 0 {
+			name = "Other"
+		}
+		sections = append(sections, serviceGroup{Name: name, Services: ungrouped})
+	}
+
+	return sections
+}
@@ -2,14 +2,27 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"html/template"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"sysdwitch/internal/audit"
 	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/desired"
+	"sysdwitch/internal/events"
+	"sysdwitch/internal/hoststats"
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/requestid"
 	"sysdwitch/internal/service"
+	"sysdwitch/internal/update"
 )
 
 // Handler holds dependencies for HTTP handlers
@@ -17,23 +30,76 @@ type Handler struct {
 	logger         *slog.Logger
 	serviceManager *service.ServiceManager
 	authConfig     *auth.AuthConfig
-	templates      *template.Template
+	templates      *Templates
+	audit          *audit.Recorder
+	readOnly       readOnlyMode
+	publicBadges   map[string]bool
+	publicStatus   []string
+	refreshEvery   time.Duration
+	groups         []config.GroupConfig
+	groupsMu       sync.RWMutex
+	theme          config.ThemeConfig
+	build          BuildInfo
+	started        time.Time
+	updates        *update.Checker
+	logLevel       *slog.LevelVar
+	jobs           *jobs.Manager
+	asyncAfter     time.Duration
+	profiles       map[string]config.ProfileConfig
+	profileOrder   []string
+	tasks          map[string]config.TaskConfig
+	taskOrder      []string
+	hooks          map[string]config.HookConfig
+	desired        *desired.Reconciler
+	inventoryHost  string
+	powerActions   []string
+	wakeHosts      []config.WakeHost
+	hostStats      *hoststats.Collector
+	graphql        bool
+	basePath       string
+	statusJSON     statusEncoder
+	events         *events.Hub
+	history        *events.History
+
+	// Configuration resources managed through the API
+	config      *config.Config
+	configPath  string
+	applyConfig func(*config.Config)
+	configMu    sync.RWMutex
+}
+
+// normalizeServiceName appends the .service suffix when missing
+func normalizeServiceName(name string) string {
+	return config.NormalizeServiceName(name)
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(logger *slog.Logger, serviceManager *service.ServiceManager, authConfig *auth.AuthConfig, templates *template.Template) *Handler {
+func NewHandler(logger *slog.Logger, serviceManager *service.ServiceManager, authConfig *auth.AuthConfig, templates *Templates, recorder *audit.Recorder) *Handler {
 	return &Handler{
 		logger:         logger,
 		serviceManager: serviceManager,
 		authConfig:     authConfig,
 		templates:      templates,
+		audit:          recorder,
 	}
 }
 
+// SetRefreshInterval sets the default dashboard auto-refresh interval;
+// zero disables auto-refresh by default
+func (h *Handler) SetRefreshInterval(interval time.Duration) {
+	h.refreshEvery = interval
+}
+
+// SetBasePath sets the URL prefix the panel is served under, used in the
+// links the handlers return
+func (h *Handler) SetBasePath(prefix string) {
+	h.basePath = prefix
+}
+
 // Dashboard renders the main dashboard page
 func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		h.logger.Warn("invalid method for dashboard",
+		h.logger.WarnContext(r.Context(), "invalid method for dashboard",
 			"method", r.Method, "remote_addr", r.RemoteAddr)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -41,97 +107,299 @@ func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	services := h.serviceManager.GetAllServicesStatus(ctx)
+	// Tasks, power actions and Wake-on-LAN are for admins only
+	var tasks []taskView
+	var powerActions []string
+	var wakeHosts []wakeHostView
+	if auth.IsAdmin(ctx) {
+		tasks = h.taskViews()
+		powerActions = h.powerActions
+		wakeHosts = h.wakeHostViews()
+	}
 	data := struct {
-		Services []service.ServiceStatus
+		Services        []service.ServiceStatus
+		Summary         statusSummary
+		Groups          []serviceGroup
+		Profiles        []profileView
+		Tasks           []taskView
+		PowerActions    []string
+		WakeHosts       []wakeHostView
+		Host            *hoststats.Stats
+		Usage           map[string][]service.Sample
+		ReadOnly        ReadOnlyState
+		RefreshInterval int
+		Theme           themeData
+		Update          *update.Release
+		DesiredState    *desired.Report
+		ActivityFeed    bool
+		Activity        []audit.Event
 	}{
-		Services: services,
+		Services:        services,
+		Summary:         summarize(services),
+		Groups:          h.groupServices(services),
+		Profiles:        h.profileViews(services),
+		Tasks:           tasks,
+		PowerActions:    powerActions,
+		WakeHosts:       wakeHosts,
+		Host:            h.collectHostStats(ctx),
+		Usage:           h.usage(services),
+		ReadOnly:        h.ReadOnlyState(),
+		RefreshInterval: int(h.refreshEvery.Seconds()),
+		Theme:           h.themeFor(r),
+		Update:          h.availableUpdate(),
+		DesiredState:    h.desired.Latest(),
+		ActivityFeed:    h.history != nil,
+		Activity:        h.recentActivity(),
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "index.html", data); err != nil {
-		h.logger.Error("template execution error",
+		h.logger.ErrorContext(r.Context(), "template execution error",
 			"error", err, "template", "index.html", "remote_addr", r.RemoteAddr)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
-// ServiceControl handles service start/stop operations
+// statusSummary counts services by state for the dashboard header
+type statusSummary struct {
+	Running, Stopped, Failed int
+}
+
+// summarize counts services using the same buckets as the dashboard's
+// state filter
+func summarize(services []service.ServiceStatus) statusSummary {
+	var sum statusSummary
+	for _, s := range services {
+		switch s.Status {
+		case service.StateRunning:
+			sum.Running++
+		case service.StateFailed, service.StateDegraded, service.StateUnknown, service.StateNotInstalled:
+			sum.Failed++
+		case service.StateStopped, service.StateStopping:
+			sum.Stopped++
+		}
+	}
+	return sum
+}
+
+// ServiceControl handles service start/stop operations, named in the path
+// (POST /api/services/{name}/{action}) or in a JSON body
+// (POST /api/services/{name} with {"action":"start","wait":"30s"})
 func (h *Handler) ServiceControl(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Extract service name from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/services/")
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/services/"), "/")
 	parts := strings.Split(path, "/")
-	if len(parts) < 2 {
-		h.logger.Warn("invalid API path format",
+	if parts[0] == "" || len(parts) > 2 {
+		h.logger.WarnContext(r.Context(), "invalid API path format",
 			"path", r.URL.Path, "remote_addr", r.RemoteAddr)
 		http.Error(w, `{"error":"Invalid path format. Expected /api/services/{name}/{action}"}`, http.StatusBadRequest)
 		return
 	}
 
-	serviceName := parts[0]
-	if !strings.HasSuffix(serviceName, ".service") {
-		serviceName += ".service"
+	serviceName := normalizeServiceName(parts[0])
+	var action string
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "metrics":
+		h.serviceMetrics(w, r, serviceName)
+		return
+	case "dependencies":
+		h.serviceDependencies(w, r, serviceName)
+		return
+	case "status":
+		h.serviceStatus(w, r, serviceName)
+		return
+	}
+
+	if h.rejectIfReadOnly(w, r) {
+		return
 	}
-	action := parts[1]
 
 	ctx := r.Context()
 	var response APIResponse
+	wait, body, err := h.actionParams(r, &action)
+	if err == nil {
+		if wait > 0 {
+			// The answer may come only after the async threshold plus the wait
+			h.holdResponse(w, r, h.asyncAfter+wait)
+		}
+		if body.DryRun {
+			ctx = service.WithDryRun(ctx)
+		}
+		ctx, err = h.applyEnvironment(ctx, body, serviceName, action)
+	}
 
-	switch action {
-	case "start":
-		if r.Method != http.MethodPost {
-			h.logger.Warn("invalid method for service start",
-				"method", r.Method, "service", serviceName, "remote_addr", r.RemoteAddr)
-			response = APIResponse{Success: false, Error: "Method not allowed"}
-			break
+	if r.Method != http.MethodPost {
+		h.logger.WarnContext(r.Context(), "invalid method for service action",
+			"method", r.Method, "action", action, "service", serviceName, "remote_addr", r.RemoteAddr)
+		response = APIResponse{Success: false, Error: "Method not allowed"}
+	} else if err != nil {
+		w.WriteHeader(bodyErrorCode(err))
+		response = APIResponse{Success: false, Error: err.Error()}
+	} else if service, job, err := h.runAction(ctx, r, serviceName, action, wait); errors.Is(err, jobs.ErrBusy) {
+		// Another action on this unit is in flight
+		w.WriteHeader(http.StatusConflict)
+		response = APIResponse{Success: false, Job: job, Error: err.Error()}
+	} else if err != nil {
+		if code := serviceErrorCode(err); code != 0 {
+			w.WriteHeader(code)
 		}
-		service := h.serviceManager.StartService(ctx, serviceName)
+		response = APIResponse{Success: false, Error: err.Error()}
+		// The action ran but failed, or the unit did not settle as asked
+		if service.Status != "" {
+			response.Service = &service
+		}
+	} else if job != nil {
+		// Still running: point the client at the job to poll
+		w.Header().Set("Location", h.basePath+"/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		response = APIResponse{Success: true, Job: job}
+	} else {
 		response = APIResponse{Success: true, Service: &service}
-		h.logger.Info("service start requested",
-			"service", serviceName, "status", service.Status, "remote_addr", r.RemoteAddr)
+	}
 
-	case "stop":
-		if r.Method != http.MethodPost {
-			h.logger.Warn("invalid method for service stop",
-				"method", r.Method, "service", serviceName, "remote_addr", r.RemoteAddr)
-			response = APIResponse{Success: false, Error: "Method not allowed"}
-			break
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
+
+// actionParams reads the body and the wait and dry_run parameters of a
+// control action. When the path names no action it must come from the
+// body, which then also carries wait and dry_run; query parameters apply
+// otherwise.
+func (h *Handler) actionParams(r *http.Request, action *string) (time.Duration, actionBody, error) {
+	body, err := readActionBody(r)
+	if err != nil {
+		return 0, body, err
+	}
+	if *action != "" {
+		if body.Action != "" || body.Wait != "" {
+			return 0, body, errors.New("action and wait belong in the body only with POST /api/services/{name}")
 		}
-		service := h.serviceManager.StopService(ctx, serviceName)
-		response = APIResponse{Success: true, Service: &service}
-		h.logger.Info("service stop requested",
-			"service", serviceName, "status", service.Status, "remote_addr", r.RemoteAddr)
+		body.DryRun, _ = strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		wait, err := parseWait(r, "wait")
+		return wait, body, err
+	}
+
+	if body.Action == "" {
+		return 0, body, errors.New(`missing action: expected a body such as {"action":"start"}`)
+	}
+	*action = body.Action
+	wait, err := parseWaitValue("wait", body.Wait)
+	return wait, body, err
+}
 
+// serviceErrorCode maps an error of a control action to a response
+// code, or 0 for errors without a more specific code than the caller's
+func serviceErrorCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotAllowed), errors.Is(err, service.ErrUnitNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, service.ErrBackend):
+		return http.StatusBadGateway
+	case errors.Is(err, jobs.ErrBusy), errors.Is(err, service.ErrCanceled):
+		return http.StatusConflict
+	case errors.Is(err, errInvalidAction):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// bodyErrorCode maps an error reading a request body to a response code:
+// 413 when the body is over its limit, such as a chunked body past
+// MAX_BODY_BYTES, and 400 otherwise
+func bodyErrorCode(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// errInvalidAction is returned for unsupported control actions
+var errInvalidAction = errors.New("Invalid action. Supported: start, stop, restart")
+
+// isValidAction reports whether performAction supports action
+func isValidAction(action string) bool {
+	return action == "start" || action == "stop" || action == "restart"
+}
+
+// performAction runs a control action on an allowed service and records it
+// in the audit log. The caller is responsible for method and read-only checks.
+func (h *Handler) performAction(ctx context.Context, r *http.Request, serviceName, action string) (service.ServiceStatus, error) {
+	var status service.ServiceStatus
+	var err error
+
+	switch action {
+	case "start":
+		status, err = h.serviceManager.StartService(ctx, serviceName)
+	case "stop":
+		status, err = h.serviceManager.StopService(ctx, serviceName)
+	case "restart":
+		status, err = h.serviceManager.RestartService(ctx, serviceName)
 	default:
-		h.logger.Warn("invalid action requested",
+		h.logger.WarnContext(r.Context(), "invalid action requested",
 			"action", action, "service", serviceName, "remote_addr", r.RemoteAddr)
-		response = APIResponse{Success: false, Error: "Invalid action. Supported: start, stop"}
+		return status, errInvalidAction
 	}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error("failed to encode JSON response",
-			"error", err, "remote_addr", r.RemoteAddr)
+	h.logger.InfoContext(r.Context(), "service "+action+" requested",
+		"service", serviceName, "status", status.Status, "remote_addr", r.RemoteAddr)
+
+	if !status.DryRun {
+		fields := map[string]any{"action": action, "status": status.Status, "request_id": requestid.FromContext(ctx)}
+		if status.Refused != "" {
+			fields["refused"] = status.Refused
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		if env := service.EnvironmentFrom(ctx); len(env) > 0 {
+			fields["env"] = env
+		}
+		h.audit.Record(audit.Event{
+			Type:       audit.EventServiceAction,
+			User:       auth.UserFromContext(r.Context()),
+			Service:    serviceName,
+			RemoteAddr: r.RemoteAddr,
+			Message:    fmt.Sprintf("%s requested for %s", action, serviceName),
+			Fields:     fields,
+		})
 	}
+
+	if err != nil {
+		return status, fmt.Errorf("%s %s: %w", action, serviceName, err)
+	}
+	if status.Refused != "" {
+		return status, errors.New(status.Refused)
+	}
+	return status, nil
 }
 
-// ServiceStatus returns the status of all services
+// ServiceStatus returns the status of all services, or of those picked
+// with ?names= and ?fields=
 func (h *Handler) ServiceStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		h.logger.Warn("invalid method for status endpoint",
+		h.logger.WarnContext(r.Context(), "invalid method for status endpoint",
 			"method", r.Method, "remote_addr", r.RemoteAddr)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	ctx := r.Context()
-	services := h.serviceManager.GetAllServicesStatus(ctx)
-	response := APIResponse{Success: true, Services: services}
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error("failed to encode JSON response for status",
-			"error", err, "remote_addr", r.RemoteAddr)
+	if r.URL.Query().Has("names") || r.URL.Query().Has("fields") {
+		h.selectedStatus(w, r)
+		return
 	}
+	ctx := r.Context()
+	h.writeStatusList(w, r, h.serviceManager.GetAllServicesStatus(ctx))
 }
 
 // APIResponse represents API response structure
@@ -139,5 +407,16 @@ type APIResponse struct {
 	Success  bool                    `json:"success"`
 	Service  *service.ServiceStatus  `json:"service,omitempty"`
 	Services []service.ServiceStatus `json:"services,omitempty"`
-	Error    string                  `json:"error,omitempty"`
+	Job      *jobs.Job               `json:"job,omitempty"`
+	// Changed reports whether a status long-poll saw the state change
+	Changed bool   `json:"changed,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// writeJSON encodes v as the response body, logging encoding failures
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response",
+			"error", err, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	}
 }
@@ -0,0 +1,77 @@
+// internal/handlers/health.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds the checks run by /readyz
+const readinessTimeout = 5 * time.Second
+
+// healthResponse is the body of /healthz and /readyz
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz reports that the process is up and serving requests
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the panel can serve its purpose: the systemd user
+// manager is reachable and at least one account can log in
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := healthResponse{Status: "ok", Checks: map[string]string{}}
+
+	// Error details are logged rather than returned, as the probes are public
+	if err := h.serviceManager.Ping(ctx); err != nil {
+		h.logger.WarnContext(r.Context(), "readiness: systemd unreachable", "error", err)
+		resp.Checks["systemd"] = "unreachable"
+		resp.Status = "unavailable"
+	} else {
+		resp.Checks["systemd"] = "ok"
+	}
+
+	switch ok, err := h.authConfig.Configured(ctx); {
+	case err != nil:
+		h.logger.WarnContext(r.Context(), "readiness: user store error", "error", err)
+		resp.Checks["auth"] = "store error"
+		resp.Status = "unavailable"
+	case !ok:
+		resp.Checks["auth"] = "no accounts configured"
+		resp.Status = "unavailable"
+	default:
+		resp.Checks["auth"] = "ok"
+	}
+
+	code := http.StatusOK
+	if resp.Status != "ok" {
+		h.logger.WarnContext(r.Context(), "readiness check failed", "checks", resp.Checks)
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, code, resp)
+}
+
+// writeHealth writes an uncached JSON health response
+func writeHealth(w http.ResponseWriter, code int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,180 @@
+// internal/handlers/hooks.go
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/requestid"
+	"sysdwitch/internal/service"
+)
+
+// maxHookBody bounds the payloads read to check webhook signatures
+const maxHookBody = 1 << 20
+
+// SetHooks sets the inbound webhooks
+func (h *Handler) SetHooks(hooks []config.HookConfig) {
+	h.hooks = make(map[string]config.HookConfig, len(hooks))
+	for _, hook := range hooks {
+		for i, action := range hook.Actions {
+			hook.Actions[i].Service = normalizeServiceName(action.Service)
+		}
+		h.hooks[hook.Name] = hook
+	}
+}
+
+// Hook runs the actions of the webhook at POST /hooks/{name}. Callers
+// authenticate with the hook's secret instead of an account: in an
+// X-Hook-Secret or "Authorization: Bearer" header, or as the HMAC-SHA256
+// signature of the body in X-Hub-Signature-256 as GitHub and Gitea send it.
+func (h *Handler) Hook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	hook, ok := h.hooks[strings.Trim(strings.TrimPrefix(r.URL.Path, "/hooks/"), "/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Hook not found"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHookBody))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Payload too large"})
+		return
+	}
+	if !hookAuthorized(r, body, hook.Secret) {
+		h.logger.WarnContext(r.Context(), "hook rejected: wrong secret",
+			"hook", hook.Name, "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Unauthorized"})
+		return
+	}
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+
+	ctx := auth.ContextWithUser(r.Context(), "hook:"+hook.Name)
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		ctx = service.WithDryRun(ctx)
+	}
+
+	var response APIResponse
+	if statuses, job, err := h.runHook(ctx, r.WithContext(ctx), hook); errors.Is(err, jobs.ErrBusy) {
+		w.WriteHeader(http.StatusConflict)
+		response = APIResponse{Success: false, Job: job, Error: err.Error()}
+	} else if job != nil {
+		w.WriteHeader(http.StatusAccepted)
+		response = APIResponse{Success: true, Job: job}
+	} else if err != nil {
+		response = APIResponse{Success: false, Services: statuses, Error: err.Error()}
+	} else {
+		response = APIResponse{Success: true, Services: statuses}
+	}
+	h.writeJSON(w, r, response)
+}
+
+// hookAuthorized checks the secret or body signature of a webhook call
+func hookAuthorized(r *http.Request, body []byte, secret string) bool {
+	if signature, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		got, err := hex.DecodeString(signature)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+
+	given := r.Header.Get("X-Hook-Secret")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && given == "" {
+		given = strings.TrimSpace(bearer)
+	}
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(secret)) == 1
+}
+
+// runHook queues a hook's actions as one job, waiting for it like
+// runProfile
+func (h *Handler) runHook(ctx context.Context, r *http.Request, hook config.HookConfig) ([]service.ServiceStatus, *jobs.Job, error) {
+	ctx = context.WithoutCancel(ctx)
+	run := func() ([]service.ServiceStatus, error) {
+		var statuses []service.ServiceStatus
+		var err error
+		for _, action := range hook.Actions {
+			var status service.ServiceStatus
+			status, err = h.performAction(ctx, r, action.Service, action.Action)
+			statuses = append(statuses, status)
+			if err != nil {
+				break
+			}
+		}
+		h.recordHook(ctx, r, hook, err)
+		return statuses, err
+	}
+	if h.jobs == nil {
+		statuses, err := run()
+		return statuses, nil, err
+	}
+
+	job, done, err := h.jobs.Submit("hook/"+hook.Name, "run", run)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "hook rejected: already running",
+			"hook", hook.Name, "job", job.ID, "remote_addr", r.RemoteAddr)
+		return nil, &job, &busyError{job: job}
+	}
+
+	result, pending := h.awaitJob(job, done, 0)
+	if pending != nil {
+		return nil, pending, nil
+	}
+	return result.Statuses, nil, result.Err
+}
+
+// recordHook logs and audits a finished webhook run
+func (h *Handler) recordHook(ctx context.Context, r *http.Request, hook config.HookConfig, err error) {
+	dryRun := service.IsDryRun(ctx)
+	actions := make([]string, len(hook.Actions))
+	for i, action := range hook.Actions {
+		actions[i] = action.Action + " " + action.Service
+	}
+	if err != nil {
+		h.logger.WarnContext(ctx, "hook failed",
+			"hook", hook.Name, "error", err, "dry_run", dryRun, "remote_addr", r.RemoteAddr)
+	} else {
+		h.logger.InfoContext(ctx, "hook completed",
+			"hook", hook.Name, "actions", len(actions), "dry_run", dryRun, "remote_addr", r.RemoteAddr)
+	}
+	if dryRun {
+		return
+	}
+
+	fields := map[string]any{"hook": hook.Name, "actions": actions, "request_id": requestid.FromContext(ctx)}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	h.audit.Record(audit.Event{
+		Type:       audit.EventHookTriggered,
+		User:       "hook:" + hook.Name,
+		Service:    hook.Actions[0].Service,
+		RemoteAddr: r.RemoteAddr,
+		Message:    fmt.Sprintf("hook %s triggered: %s", hook.Name, strings.Join(actions, ", ")),
+		Fields:     fields,
+	})
+}
@@ -0,0 +1,117 @@
+// internal/handlers/host.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/requestid"
+	"sysdwitch/internal/service"
+)
+
+// powerDelay gives the response time to reach the client before the host
+// goes down
+const powerDelay = 2 * time.Second
+
+// SetPowerActions sets which host power actions admins may run
+func (h *Handler) SetPowerActions(actions []string) {
+	h.powerActions = actions
+}
+
+// Host lists the enabled power actions at /api/admin/host and runs one at
+// /api/admin/host/{action}. With stop_services=true every allowed service
+// is stopped first. The action runs in the background after a short delay,
+// so the response is always 202 with a job to poll.
+func (h *Handler) Host(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/host"), "/")
+	if action == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		h.writeJSON(w, r, map[string]any{"success": true, "actions": h.powerActions})
+		return
+	}
+
+	if !slices.Contains(h.powerActions, action) {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Power action not enabled"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+
+	stopServices, _ := strconv.ParseBool(r.URL.Query().Get("stop_services"))
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		ctx := service.WithDryRun(r.Context())
+		command, err := h.serviceManager.HostPower(ctx, action)
+		if stopServices {
+			command = "stop all services && " + command
+		}
+		h.writeJSON(w, r, map[string]any{"success": err == nil, "dry_run": true, "command": command})
+		return
+	}
+
+	h.recordPower(r, action, stopServices)
+	ctx := context.WithoutCancel(r.Context())
+	run := func() ([]service.ServiceStatus, error) {
+		var statuses []service.ServiceStatus
+		if stopServices {
+			var err error
+			statuses, err = h.serviceManager.RunSequence(ctx, h.serviceManager.AllServices(), "stop", defaultProfileWait)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "not running power action: services did not stop",
+					"action", action, "error", err)
+				return statuses, err
+			}
+		}
+		time.Sleep(powerDelay)
+		if _, err := h.serviceManager.HostPower(ctx, action); err != nil {
+			h.logger.ErrorContext(ctx, "host power action failed",
+				"action", action, "error", err)
+			return statuses, fmt.Errorf("%s failed: %w", action, err)
+		}
+		return statuses, nil
+	}
+
+	if h.jobs == nil {
+		go run()
+		w.WriteHeader(http.StatusAccepted)
+		h.writeJSON(w, r, APIResponse{Success: true})
+		return
+	}
+	job, _, err := h.jobs.Submit("host", action, run)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		h.writeJSON(w, r, APIResponse{Success: false, Job: &job, Error: err.Error()})
+		return
+	}
+	w.Header().Set("Location", h.basePath+"/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	h.writeJSON(w, r, APIResponse{Success: true, Job: &job})
+}
+
+// recordPower audits a requested power action
+func (h *Handler) recordPower(r *http.Request, action string, stopServices bool) {
+	h.audit.Record(audit.Event{
+		Type:       audit.EventHostPower,
+		User:       auth.UserFromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+		Message:    fmt.Sprintf("host %s requested", action),
+		Fields:     map[string]any{"action": action, "stop_services": stopServices, "request_id": requestid.FromContext(r.Context())},
+	})
+}
@@ -0,0 +1,109 @@
+// internal/handlers/hosts.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/requestid"
+	"sysdwitch/internal/wol"
+)
+
+// wakeHostView is a wake host as listed by the API and the dashboard
+type wakeHostView struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	MAC   string `json:"mac"`
+}
+
+// SetWakeHosts sets the remote machines that can be woken with
+// Wake-on-LAN
+func (h *Handler) SetWakeHosts(hosts []config.WakeHost) {
+	h.wakeHosts = hosts
+}
+
+// wakeHostViews lists the wake hosts in declaration order
+func (h *Handler) wakeHostViews() []wakeHostView {
+	views := make([]wakeHostView, 0, len(h.wakeHosts))
+	for _, host := range h.wakeHosts {
+		view := wakeHostView{Name: host.Name, Label: host.DisplayName, MAC: host.MAC}
+		if view.Label == "" {
+			view.Label = host.Name
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// Hosts lists wake hosts at /api/hosts and sends a Wake-on-LAN packet at
+// /api/hosts/{host}/wake, to admins
+func (h *Handler) Hosts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/hosts"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		h.writeJSON(w, r, map[string]any{"success": true, "hosts": h.wakeHostViews()})
+		return
+	}
+
+	name, action, _ := strings.Cut(path, "/")
+	var host *config.WakeHost
+	for i := range h.wakeHosts {
+		if h.wakeHosts[i].Name == name {
+			host = &h.wakeHosts[i]
+		}
+	}
+	if host == nil || action != "wake" {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Host not found"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+
+	broadcast := wol.BroadcastAddr(host.Broadcast)
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		h.writeJSON(w, r, map[string]any{"success": true, "dry_run": true, "host": host.Name, "mac": host.MAC, "broadcast": broadcast})
+		return
+	}
+
+	err := wol.Send(host.MAC, host.Broadcast)
+	h.recordWake(r, host, broadcast, err)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to send Wake-on-LAN packet",
+			"host", host.Name, "broadcast", broadcast, "error", err)
+		w.WriteHeader(http.StatusBadGateway)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Failed to send Wake-on-LAN packet"})
+		return
+	}
+	h.writeJSON(w, r, map[string]any{"success": true, "host": host.Name, "mac": host.MAC, "broadcast": broadcast})
+}
+
+// recordWake audits a Wake-on-LAN request
+func (h *Handler) recordWake(r *http.Request, host *config.WakeHost, broadcast string, err error) {
+	fields := map[string]any{"host": host.Name, "mac": host.MAC, "broadcast": broadcast, "request_id": requestid.FromContext(r.Context())}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	h.audit.Record(audit.Event{
+		Type:       audit.EventHostWake,
+		User:       auth.UserFromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+		Message:    fmt.Sprintf("wake-on-LAN sent to %s", host.Name),
+		Fields:     fields,
+	})
+}
@@ -0,0 +1,61 @@
+// internal/handlers/hoststats.go
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"sysdwitch/internal/hoststats"
+)
+
+// SetHostStats sets the collector behind the host resource panel
+func (h *Handler) SetHostStats(collector *hoststats.Collector) {
+	h.hostStats = collector
+}
+
+// collectHostStats takes a snapshot, or returns nil when host stats are
+// disabled or unreadable
+func (h *Handler) collectHostStats(ctx context.Context) *hoststats.Stats {
+	if h.hostStats == nil {
+		return nil
+	}
+	stats, err := h.hostStats.Collect()
+	if err != nil {
+		h.logger.WarnContext(ctx, "failed to collect host stats", "error", err)
+		return nil
+	}
+	return &stats
+}
+
+// HostStats serves load, memory, disk and network usage of the host as
+// JSON at /api/host/stats and as the dashboard widget at /ui/host/stats
+func (h *Handler) HostStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := h.collectHostStats(r.Context())
+	if r.URL.Path == "/ui/host/stats" {
+		var buf bytes.Buffer
+		if err := h.templates.ExecuteTemplate(&buf, "host-stats", stats); err != nil {
+			h.logger.ErrorContext(r.Context(), "template execution error",
+				"error", err, "template", "host-stats", "remote_addr", r.RemoteAddr)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf.Bytes())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if stats == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Host stats unavailable"})
+		return
+	}
+	h.writeJSON(w, r, map[string]any{"success": true, "host": stats})
+}
@@ -0,0 +1,91 @@
+// internal/handlers/inventory.go
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// inventoryGroup is a group of an Ansible dynamic inventory
+type inventoryGroup struct {
+	Hosts    []string `json:"hosts"`
+	Children []string `json:"children,omitempty"`
+}
+
+// inventoryService is how a service appears in the host variables
+type inventoryService struct {
+	Unit   string     `json:"unit"`
+	Status string     `json:"status"`
+	Active bool       `json:"active"`
+	Since  *time.Time `json:"since,omitempty"`
+}
+
+// SetInventoryHost names this host in the inventory; empty uses the
+// hostname
+func (h *Handler) SetInventoryHost(name string) {
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	h.inventoryHost = name
+}
+
+// Inventory answers GET /api/inventory with the panel's host and the
+// states of its services as an Ansible dynamic inventory. Each service
+// puts the host in a group service_<name>_<status>, such as
+// service_jellyfin_active, for playbooks to target.
+func (h *Handler) Inventory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	h.writeJSON(w, r, h.inventory(h.serviceManager.GetAllServicesStatus(r.Context())))
+}
+
+// inventory builds the inventory document from service statuses
+func (h *Handler) inventory(statuses []service.ServiceStatus) map[string]any {
+	host := h.inventoryHost
+	services := make(map[string]inventoryService, len(statuses))
+	groups := make(map[string]inventoryGroup)
+	for _, s := range statuses {
+		name := inventoryName(s.Name)
+		services[name] = inventoryService{Unit: s.Name, Status: string(s.Status), Active: s.Active, Since: s.Since}
+		groups["service_"+name+"_"+inventoryName(string(s.Status))] = inventoryGroup{Hosts: []string{host}}
+	}
+
+	children := make([]string, 0, len(groups))
+	for name := range groups {
+		children = append(children, name)
+	}
+	sort.Strings(children)
+
+	inventory := map[string]any{
+		"all": inventoryGroup{Hosts: []string{host}, Children: children},
+		"_meta": map[string]any{
+			"hostvars": map[string]any{
+				host: map[string]any{"sysdwitch_services": services},
+			},
+		},
+	}
+	for name, group := range groups {
+		inventory[name] = group
+	}
+	return inventory
+}
+
+// inventoryName turns a unit or status into a valid Ansible group name
+// part: jellyfin.service becomes jellyfin, docker:web becomes docker_web
+func inventoryName(name string) string {
+	name = strings.TrimSuffix(name, ".service")
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,175 @@
+// internal/handlers/jobs.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/service"
+)
+
+// SetJobs routes control actions through the job queue, which serializes
+// actions per service. Requests wait up to asyncAfter for their job and
+// then return it for polling; zero asyncAfter always waits.
+func (h *Handler) SetJobs(manager *jobs.Manager, asyncAfter time.Duration) {
+	h.jobs = manager
+	h.asyncAfter = asyncAfter
+}
+
+// runAction queues an action and waits for it, returning its job instead
+// of a status when it outlasts the async threshold. A positive wait makes
+// the action also wait for the unit to settle, extending the threshold by
+// as much. The action is canceled if the client goes away before it was
+// answered; a job handed out keeps running until it finishes or is
+// canceled through /api/admin/operations.
+func (h *Handler) runAction(ctx context.Context, r *http.Request, serviceName, action string, wait time.Duration) (service.ServiceStatus, *jobs.Job, error) {
+	if h.jobs == nil {
+		status, err := h.performAction(ctx, r, serviceName, action)
+		if err == nil {
+			status, err = h.awaitState(ctx, status, action, wait)
+		}
+		return status, nil, err
+	}
+	if !isValidAction(action) {
+		return service.ServiceStatus{}, nil, errInvalidAction
+	}
+
+	// The job outlives the request once handed out to be polled, but a
+	// client leaving before that takes the action with it
+	jobCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() { cancel(context.Cause(ctx)) })
+	job, done, err := h.jobs.Submit(serviceName, action, func() ([]service.ServiceStatus, error) {
+		defer cancel(nil)
+		status, err := h.performAction(jobCtx, r, serviceName, action)
+		if err == nil {
+			status, err = h.awaitState(jobCtx, status, action, wait)
+		}
+		return []service.ServiceStatus{status}, err
+	})
+	if err != nil {
+		stop()
+		cancel(nil)
+		h.logger.WarnContext(r.Context(), "action rejected: service busy",
+			"service", serviceName, "action", action, "job", job.ID, "remote_addr", r.RemoteAddr)
+		return service.ServiceStatus{}, &job, &busyError{job: job}
+	}
+
+	result, pending := h.awaitJob(job, done, wait)
+	stop()
+	if pending != nil {
+		h.logger.InfoContext(r.Context(), "action still running, returning job",
+			"service", serviceName, "action", action, "job", job.ID)
+		return service.ServiceStatus{}, pending, nil
+	}
+	return result.Statuses[0], nil, result.Err
+}
+
+// awaitJob waits up to the async threshold plus extra for a submitted job.
+// It returns the job's result, or the job itself when it is still running.
+func (h *Handler) awaitJob(job jobs.Job, done <-chan jobs.Result, extra time.Duration) (jobs.Result, *jobs.Job) {
+	var timeout <-chan time.Time
+	if h.asyncAfter > 0 {
+		timer := time.NewTimer(h.asyncAfter + extra)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case result := <-done:
+		return result, nil
+	case <-timeout:
+		if current, ok := h.jobs.Get(job.ID); ok {
+			job = current
+		}
+		return jobs.Result{}, &job
+	}
+}
+
+// maxWait caps the wait parameters of control actions and status polls
+const maxWait = 5 * time.Minute
+
+// parseWait reads an optional wait parameter ("30s", or plain seconds)
+// such as wait or wait_change
+func parseWait(r *http.Request, param string) (time.Duration, error) {
+	return parseWaitValue(param, r.URL.Query().Get(param))
+}
+
+// parseWaitValue parses a wait given as a duration or in seconds
+func parseWaitValue(param, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		secs, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid %s %q: %w", param, value, err)
+		}
+		wait = time.Duration(secs) * time.Second
+	}
+	if wait < 0 || wait > maxWait {
+		return 0, fmt.Errorf("%s must be between 0 and %s", param, maxWait)
+	}
+	return wait, nil
+}
+
+// awaitState waits up to wait for a unit acted on to be running (after
+// start) or stopped (after stop). It returns early when the unit fails,
+// so callers get a definitive outcome rather than starting.
+func (h *Handler) awaitState(ctx context.Context, status service.ServiceStatus, action string, wait time.Duration) (service.ServiceStatus, error) {
+	if wait <= 0 || status.DryRun {
+		return status, nil
+	}
+	want := service.StateRunning
+	if action == "stop" {
+		want = service.StateStopped
+	}
+	if status.Status == want {
+		return status, nil
+	}
+	return h.serviceManager.WaitForState(ctx, status.Name, want, wait)
+}
+
+// busyError reports an action rejected because another one is in flight
+type busyError struct {
+	job jobs.Job
+}
+
+func (e *busyError) Error() string {
+	return fmt.Sprintf("%s is busy: %s is %s", e.job.Service, e.job.Action, e.job.Status)
+}
+
+func (e *busyError) Unwrap() error {
+	return jobs.ErrBusy
+}
+
+// Job reports the state of an asynchronous action at /api/jobs/{id}
+func (h *Handler) Job(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	var job jobs.Job
+	var ok bool
+	if h.jobs != nil {
+		job, ok = h.jobs.Get(id)
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Job not found"})
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(APIResponse{Success: job.Status != jobs.StatusFailed, Job: &job, Error: job.Error}); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response for job",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
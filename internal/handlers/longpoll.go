@@ -0,0 +1,68 @@
+// internal/handlers/longpoll.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// waitSlack is added to the write deadline of a request held for a wait,
+// leaving time to write the response
+const waitSlack = 10 * time.Second
+
+// holdResponse moves the write deadline of a request that waits up to wait
+// before answering past the wait, beyond the server's write timeout
+func (h *Handler) holdResponse(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + waitSlack)); err != nil {
+		h.logger.DebugContext(r.Context(), "cannot extend write deadline", "error", err, "path", r.URL.Path)
+	}
+}
+
+// serviceStatus reports one service at GET /api/services/{name}/status.
+// With wait_change=60s the request is held until the unit's state differs
+// from state (default: its state when the request arrived) or the wait
+// expires; "changed" tells the two apart.
+func (h *Handler) serviceStatus(w http.ResponseWriter, r *http.Request, serviceName string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Method not allowed"})
+		return
+	}
+	wait, err := parseWait(r, "wait_change")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	ctx := r.Context()
+	status, err := h.serviceManager.GetServiceStatus(ctx, serviceName)
+	if errors.Is(err, service.ErrNotAllowed) {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Service not allowed"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(serviceErrorCode(err))
+		h.writeJSON(w, r, APIResponse{Success: false, Service: &status, Error: err.Error()})
+		return
+	}
+
+	if wait == 0 {
+		h.writeJSONWithETag(w, r, APIResponse{Success: true, Service: &status})
+		return
+	}
+
+	from := status.Status
+	if state := r.URL.Query().Get("state"); state != "" {
+		from, _ = service.ParseState(state)
+	}
+	h.holdResponse(w, r, wait)
+	status, changed := h.serviceManager.WaitForChange(ctx, serviceName, from, wait)
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, APIResponse{Success: true, Service: &status, Changed: changed})
+}
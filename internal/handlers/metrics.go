@@ -0,0 +1,90 @@
+// internal/handlers/metrics.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"sysdwitch/internal/service"
+)
+
+// metricsResponse is the body of GET /api/services/{name}/metrics
+type metricsResponse struct {
+	Success bool             `json:"success"`
+	Service string           `json:"service"`
+	Samples []service.Sample `json:"samples"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// serviceMetrics returns the recent CPU and memory samples of a service
+func (h *Handler) serviceMetrics(w http.ResponseWriter, r *http.Request, serviceName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.serviceManager.IsAllowed(serviceName) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(metricsResponse{Service: serviceName, Error: "Service not allowed"})
+		return
+	}
+
+	samples := h.serviceManager.Samples(serviceName)
+	if samples == nil {
+		samples = []service.Sample{}
+	}
+	if err := json.NewEncoder(w).Encode(metricsResponse{Success: true, Service: serviceName, Samples: samples}); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response for metrics",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
+
+// dependenciesResponse is the body of GET /api/services/{name}/dependencies
+type dependenciesResponse struct {
+	Success bool                `json:"success"`
+	Service string              `json:"service"`
+	Tree    *service.Dependency `json:"tree,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// serviceDependencies returns the dependency tree of a service, or with
+// ?reverse=true the units that depend on it
+func (h *Handler) serviceDependencies(w http.ResponseWriter, r *http.Request, serviceName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	reverse, _ := strconv.ParseBool(r.URL.Query().Get("reverse"))
+	tree, err := h.serviceManager.Dependencies(r.Context(), serviceName, reverse)
+	if errors.Is(err, service.ErrNotAllowed) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(dependenciesResponse{Service: serviceName, Error: "Service not allowed"})
+		return
+	}
+	if errors.Is(err, errors.ErrUnsupported) {
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(dependenciesResponse{Service: serviceName, Error: err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to list dependencies",
+			"service", serviceName, "error", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(dependenciesResponse{Service: serviceName, Error: "Failed to list dependencies"})
+		return
+	}
+
+	h.writeJSON(w, r, dependenciesResponse{Success: true, Service: serviceName, Tree: &tree})
+}
+
+// usage collects the recent samples of each service for the dashboard
+func (h *Handler) usage(services []service.ServiceStatus) map[string][]service.Sample {
+	usage := make(map[string][]service.Sample, len(services))
+	for _, s := range services {
+		usage[s.Name] = h.serviceManager.Samples(s.Name)
+	}
+	return usage
+}
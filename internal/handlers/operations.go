@@ -0,0 +1,63 @@
+// internal/handlers/operations.go
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/service"
+)
+
+// operationView is an operation in flight with how long it has been running
+type operationView struct {
+	service.Operation
+	RunningFor string `json:"running_for"`
+}
+
+// Operations lists the control actions in flight at /api/admin/operations
+// and cancels one, killing its command, with DELETE
+// /api/admin/operations/{id}
+func (h *Handler) Operations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/operations"), "/")
+	if id == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		now := time.Now()
+		views := []operationView{}
+		for _, op := range h.serviceManager.Operations() {
+			views = append(views, operationView{Operation: op, RunningFor: now.Sub(op.Started).Round(time.Second).String()})
+		}
+		h.writeJSON(w, r, map[string]any{"success": true, "operations": views})
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	op, ok := h.serviceManager.CancelOperation(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Operation not found"})
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "operation canceled",
+		"operation", op.ID, "service", op.Service, "action", op.Action, "remote_addr", r.RemoteAddr)
+	h.audit.Record(audit.Event{
+		Type:       audit.EventOperationCanceled,
+		User:       auth.UserFromContext(r.Context()),
+		Service:    op.Service,
+		RemoteAddr: r.RemoteAddr,
+		Message:    "canceled " + op.Action + " of " + op.Service,
+		Fields:     map[string]any{"action": op.Action, "operation": op.ID, "request_id": op.RequestID},
+	})
+	h.writeJSON(w, r, map[string]any{"success": true, "operation": op})
+}
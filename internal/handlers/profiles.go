@@ -0,0 +1,199 @@
+// internal/handlers/profiles.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/requestid"
+	"sysdwitch/internal/service"
+)
+
+// defaultProfileWait bounds each profile step when wait_timeout is unset
+const defaultProfileWait = 60 * time.Second
+
+// profileView is a profile as listed by the API and the dashboard
+type profileView struct {
+	Name     string   `json:"name"`
+	Label    string   `json:"label"`
+	Services []string `json:"services"`
+	Running  int      `json:"running"`
+}
+
+// Active reports whether every service in the profile is running
+func (p profileView) Active() bool {
+	return p.Running == len(p.Services)
+}
+
+// SetProfiles sets the configured service profiles
+func (h *Handler) SetProfiles(profiles []config.ProfileConfig) {
+	h.profiles = make(map[string]config.ProfileConfig, len(profiles))
+	h.profileOrder = h.profileOrder[:0]
+	for _, p := range profiles {
+		services := make([]string, len(p.Services))
+		for i, name := range p.Services {
+			services[i] = normalizeServiceName(name)
+		}
+		p.Services = services
+		h.profiles[p.Name] = p
+		h.profileOrder = append(h.profileOrder, p.Name)
+	}
+}
+
+// profileViews summarizes the configured profiles against current statuses
+func (h *Handler) profileViews(services []service.ServiceStatus) []profileView {
+	active := make(map[string]bool, len(services))
+	for _, s := range services {
+		active[s.Name] = s.Active
+	}
+
+	views := make([]profileView, 0, len(h.profileOrder))
+	for _, name := range h.profileOrder {
+		p := h.profiles[name]
+		view := profileView{Name: p.Name, Label: p.DisplayName, Services: p.Services}
+		if view.Label == "" {
+			view.Label = p.Name
+		}
+		for _, svc := range p.Services {
+			if active[svc] {
+				view.Running++
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// Profiles lists profiles at /api/profiles and runs them at
+// /api/profiles/{name}/{start|stop}
+func (h *Handler) Profiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/profiles"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		profiles := h.profileViews(h.serviceManager.GetAllServicesStatus(r.Context()))
+		if err := json.NewEncoder(w).Encode(map[string]any{"success": true, "profiles": profiles}); err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to encode JSON response for profiles",
+				"error", err, "remote_addr", r.RemoteAddr)
+		}
+		return
+	}
+
+	name, action, ok := strings.Cut(path, "/")
+	profile, found := h.profiles[name]
+	if !ok || !found {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: "Profile not found"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isValidAction(action) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: errInvalidAction.Error()})
+		return
+	}
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+
+	ctx := r.Context()
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		ctx = service.WithDryRun(ctx)
+	}
+
+	var response APIResponse
+	if statuses, job, err := h.runProfile(ctx, r, profile, action); errors.Is(err, jobs.ErrBusy) {
+		w.WriteHeader(http.StatusConflict)
+		response = APIResponse{Success: false, Job: job, Error: err.Error()}
+	} else if job != nil {
+		w.Header().Set("Location", h.basePath+"/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		response = APIResponse{Success: true, Job: job}
+	} else if err != nil {
+		response = APIResponse{Success: false, Services: statuses, Error: err.Error()}
+	} else {
+		response = APIResponse{Success: true, Services: statuses}
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response for profile",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
+
+// runProfile queues a profile sequence as one job, waiting for it the same
+// way runAction does
+func (h *Handler) runProfile(ctx context.Context, r *http.Request, profile config.ProfileConfig, action string) ([]service.ServiceStatus, *jobs.Job, error) {
+	wait := time.Duration(profile.WaitTimeout)
+	if wait <= 0 {
+		wait = defaultProfileWait
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	sequence := func() ([]service.ServiceStatus, error) {
+		statuses, err := h.serviceManager.RunSequence(ctx, profile.Services, action, wait)
+		h.recordProfileAction(ctx, r, profile.Name, action, statuses, err)
+		return statuses, err
+	}
+	if h.jobs == nil {
+		statuses, err := sequence()
+		return statuses, nil, err
+	}
+
+	job, done, err := h.jobs.Submit("profile/"+profile.Name, action, sequence)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "profile action rejected: profile busy",
+			"profile", profile.Name, "action", action, "job", job.ID, "remote_addr", r.RemoteAddr)
+		return nil, &job, &busyError{job: job}
+	}
+
+	result, pending := h.awaitJob(job, done, 0)
+	if pending != nil {
+		return nil, pending, nil
+	}
+	return result.Statuses, nil, result.Err
+}
+
+// recordProfileAction logs and audits a finished profile sequence
+func (h *Handler) recordProfileAction(ctx context.Context, r *http.Request, name, action string, statuses []service.ServiceStatus, err error) {
+	dryRun := service.IsDryRun(ctx)
+	if err != nil {
+		h.logger.WarnContext(ctx, "profile "+action+" failed",
+			"profile", name, "error", err, "dry_run", dryRun, "remote_addr", r.RemoteAddr)
+	} else {
+		h.logger.InfoContext(ctx, "profile "+action+" completed",
+			"profile", name, "services", len(statuses), "dry_run", dryRun, "remote_addr", r.RemoteAddr)
+	}
+	if dryRun {
+		return
+	}
+
+	fields := map[string]any{"profile": name, "action": action, "request_id": requestid.FromContext(ctx)}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	h.audit.Record(audit.Event{
+		Type:       audit.EventProfileAction,
+		User:       auth.UserFromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+		Message:    fmt.Sprintf("%s requested for profile %s", action, name),
+		Fields:     fields,
+	})
+}
@@ -0,0 +1,139 @@
+// internal/handlers/render.go
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"html/template"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// cardCacheSize bounds each generation of rendered cards; about two
+// generations are kept, enough for every card of a big fleet in both the
+// read-only and the writable variant
+const cardCacheSize = 2048
+
+// Templates parses the page templates and renders them. In dev mode the
+// templates are parsed again for every page, so edits on disk show up on
+// reload; otherwise they are parsed once and rendered service cards are
+// cached.
+type Templates struct {
+	fsys     fs.FS
+	patterns []string
+	funcs    template.FuncMap
+	dev      bool
+	parsed   *template.Template
+
+	// Rendered cards by a digest of their data. Cards whose data is unused
+	// for a generation are dropped, so stale samples do not pile up.
+	cardsMu  sync.Mutex
+	cards    map[[sha256.Size]byte]template.HTML
+	oldCards map[[sha256.Size]byte]template.HTML
+}
+
+// NewTemplates parses the templates of fsys matching patterns, with
+// TemplateFuncs and funcs available to them. In dev mode they are parsed
+// up front only to report errors early.
+func NewTemplates(fsys fs.FS, funcs template.FuncMap, dev bool, patterns ...string) (*Templates, error) {
+	t := &Templates{fsys: fsys, patterns: patterns, funcs: funcs, dev: dev}
+	parsed, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.parsed = parsed
+	return t, nil
+}
+
+// parse reads the templates. Each parse gets its own "renderCard" bound to
+// it, so a page rendered in dev mode uses the card it was parsed with.
+func (t *Templates) parse() (*template.Template, error) {
+	tmpl := template.New("")
+	tmpl.Funcs(TemplateFuncs()).Funcs(t.funcs).Funcs(template.FuncMap{
+		"renderCard": func(view cardView) (template.HTML, error) {
+			return t.renderCard(tmpl, view)
+		},
+	})
+	return tmpl.ParseFS(t.fsys, t.patterns...)
+}
+
+// ExecuteTemplate renders the named template to w
+func (t *Templates) ExecuteTemplate(w io.Writer, name string, data any) error {
+	tmpl := t.parsed
+	if t.dev {
+		var err error
+		if tmpl, err = t.parse(); err != nil {
+			return err
+		}
+	}
+	return tmpl.ExecuteTemplate(w, name, data)
+}
+
+// Card renders the "service-card" fragment of view
+func (t *Templates) Card(view cardView) (template.HTML, error) {
+	tmpl := t.parsed
+	if t.dev {
+		var err error
+		if tmpl, err = t.parse(); err != nil {
+			return "", err
+		}
+	}
+	return t.renderCard(tmpl, view)
+}
+
+// renderCard renders a card with tmpl, from the cache when a card with the
+// same data was rendered before
+func (t *Templates) renderCard(tmpl *template.Template, view cardView) (template.HTML, error) {
+	if t.dev {
+		return executeCard(tmpl, view)
+	}
+
+	data, err := json.Marshal(view)
+	if err != nil {
+		return executeCard(tmpl, view)
+	}
+	key := sha256.Sum256(data)
+
+	t.cardsMu.Lock()
+	html, ok := t.cards[key]
+	if !ok {
+		if html, ok = t.oldCards[key]; ok {
+			t.storeCard(key, html)
+		}
+	}
+	t.cardsMu.Unlock()
+	if ok {
+		return html, nil
+	}
+
+	if html, err = executeCard(tmpl, view); err != nil {
+		return "", err
+	}
+	t.cardsMu.Lock()
+	t.storeCard(key, html)
+	t.cardsMu.Unlock()
+	return html, nil
+}
+
+// storeCard caches a rendered card, starting a new generation when the
+// current one is full; cardsMu must be held
+func (t *Templates) storeCard(key [sha256.Size]byte, html template.HTML) {
+	if len(t.cards) >= cardCacheSize {
+		t.oldCards, t.cards = t.cards, nil
+	}
+	if t.cards == nil {
+		t.cards = make(map[[sha256.Size]byte]template.HTML)
+	}
+	t.cards[key] = html
+}
+
+// executeCard renders the "service-card" template of tmpl
+func executeCard(tmpl *template.Template, view cardView) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "service-card", view); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,541 @@
+// internal/handlers/resources.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/store"
+)
+
+// maxResourceBody bounds the request bodies of configuration resources
+const maxResourceBody = 1 << 20
+
+// errOverridden rejects changes the environment would undo on restart
+var errOverridden = errors.New("overridden by the environment")
+
+// configItem is a configuration resource with its stable ID
+type configItem struct {
+	id    string
+	value any
+}
+
+// configKind is a kind of resource kept in the config file
+type configKind struct {
+	// singular names one resource in messages
+	singular string
+	// list returns the resources of cfg in order
+	list func(cfg *config.Config) []configItem
+	// decode reads a resource from a request body
+	decode func(dec *json.Decoder) (configItem, error)
+	// put adds or replaces a resource in the config file
+	put func(file *config.Config, item configItem)
+	// remove deletes the resource id from the config file
+	remove func(file *config.Config, id string)
+}
+
+// configKinds are the configuration resources by their collection name
+var configKinds = map[string]configKind{
+	"services": {
+		singular: "service",
+		list: func(cfg *config.Config) []configItem {
+			var items []configItem
+			for _, name := range cfg.ServiceNames() {
+				id := normalizeServiceName(name)
+				svc := config.ServiceConfig{Name: name}
+				if i := slices.IndexFunc(cfg.Services, func(s config.ServiceConfig) bool { return normalizeServiceName(s.Name) == id }); i >= 0 {
+					svc = cfg.Services[i]
+				}
+				items = append(items, configItem{id: id, value: svc})
+			}
+			return items
+		},
+		decode: func(dec *json.Decoder) (configItem, error) {
+			var svc config.ServiceConfig
+			if err := dec.Decode(&svc); err != nil {
+				return configItem{}, err
+			}
+			if svc.Name == "" {
+				return configItem{}, errors.New("name is required")
+			}
+			return configItem{id: normalizeServiceName(svc.Name), value: svc}, nil
+		},
+		put: func(file *config.Config, item configItem) {
+			*file.Service(item.id) = item.value.(config.ServiceConfig)
+		},
+		remove: func(file *config.Config, id string) {
+			file.AllowedServices = slices.DeleteFunc(file.AllowedServices, func(name string) bool { return normalizeServiceName(name) == id })
+			file.Services = slices.DeleteFunc(file.Services, func(s config.ServiceConfig) bool { return normalizeServiceName(s.Name) == id })
+		},
+	},
+	"groups": {
+		singular: "group",
+		list: func(cfg *config.Config) []configItem {
+			items := make([]configItem, len(cfg.Groups))
+			for i, g := range cfg.Groups {
+				items[i] = configItem{id: g.Name, value: g}
+			}
+			return items
+		},
+		decode: func(dec *json.Decoder) (configItem, error) {
+			var g config.GroupConfig
+			if err := dec.Decode(&g); err != nil {
+				return configItem{}, err
+			}
+			if g.Name == "" {
+				return configItem{}, errors.New("name is required")
+			}
+			return configItem{id: g.Name, value: g}, nil
+		},
+		put: func(file *config.Config, item configItem) {
+			file.Groups = upsert(file.Groups, item.value.(config.GroupConfig), func(g config.GroupConfig) string { return g.Name })
+		},
+		remove: func(file *config.Config, id string) {
+			file.Groups = slices.DeleteFunc(file.Groups, func(g config.GroupConfig) bool { return g.Name == id })
+		},
+	},
+	"schedules": {
+		singular: "schedule",
+		list: func(cfg *config.Config) []configItem {
+			items := make([]configItem, len(cfg.Maintenance))
+			for i, w := range cfg.Maintenance {
+				items[i] = configItem{id: w.Name, value: w}
+			}
+			return items
+		},
+		decode: func(dec *json.Decoder) (configItem, error) {
+			var w config.MaintenanceWindow
+			if err := dec.Decode(&w); err != nil {
+				return configItem{}, err
+			}
+			if w.Name == "" {
+				return configItem{}, errors.New("name is required")
+			}
+			return configItem{id: w.Name, value: w}, nil
+		},
+		put: func(file *config.Config, item configItem) {
+			file.Maintenance = upsert(file.Maintenance, item.value.(config.MaintenanceWindow), func(w config.MaintenanceWindow) string { return w.Name })
+		},
+		remove: func(file *config.Config, id string) {
+			file.Maintenance = slices.DeleteFunc(file.Maintenance, func(w config.MaintenanceWindow) bool { return w.Name == id })
+		},
+	},
+}
+
+// upsert replaces the element of items with v's key, or appends v
+func upsert[T any](items []T, v T, key func(T) string) []T {
+	if i := slices.IndexFunc(items, func(item T) bool { return key(item) == key(v) }); i >= 0 {
+		items[i] = v
+		return items
+	}
+	return append(items, v)
+}
+
+// findItem returns the resource id among items
+func findItem(items []configItem, id string) (configItem, bool) {
+	i := slices.IndexFunc(items, func(item configItem) bool { return item.id == id })
+	if i < 0 {
+		return configItem{}, false
+	}
+	return items[i], true
+}
+
+// resourceETag is the ETag of a resource as writeJSONWithETag serves it
+func resourceETag(v any) string {
+	body, _ := json.Marshal(v)
+	return bodyETag(append(body, '\n'))
+}
+
+// SetConfig enables the configuration resources. Changes are saved to the
+// config file at path, without which they are refused, and apply hands
+// the new effective configuration to the running components.
+func (h *Handler) SetConfig(cfg *config.Config, path string, apply func(*config.Config)) {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	h.config = cfg
+	h.configPath = path
+	h.applyConfig = apply
+}
+
+// ConfigResource serves the allowlist (services), dashboard groups,
+// maintenance schedules and API tokens as REST resources under
+// /api/admin/config/{kind}: GET lists a kind and POST creates one, while
+// GET, PUT and DELETE at /{kind}/{id} read, replace and delete it. IDs are
+// the resources' names, so they stay stable for declarative clients such
+// as a Terraform provider, and If-Match with a resource's ETag guards
+// against overwriting changes made meanwhile.
+func (h *Handler) ConfigResource(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/config"), "/")
+	name, id, _ := strings.Cut(path, "/")
+	if name == "tokens" {
+		h.serveTokens(w, r, id)
+		return
+	}
+	kind, ok := configKinds[name]
+	h.configMu.RLock()
+	cfg := h.config
+	h.configMu.RUnlock()
+	if !ok || cfg == nil {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Resource not found"})
+		return
+	}
+	if name == "services" && id != "" {
+		id = normalizeServiceName(id)
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		items := kind.list(cfg)
+		values := make([]any, len(items))
+		for i, item := range items {
+			values[i] = item.value
+		}
+		h.writeJSON(w, r, map[string]any{"success": true, name: values})
+	case id != "" && r.Method == http.MethodGet:
+		item, ok := findItem(kind.list(cfg), id)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("No %s %s", kind.singular, id)})
+			return
+		}
+		h.writeJSONWithETag(w, r, item.value)
+	case id == "" && r.Method == http.MethodPost,
+		id != "" && (r.Method == http.MethodPut || r.Method == http.MethodDelete):
+		h.changeConfig(w, r, name, kind, id)
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// changeConfig creates (without id), replaces or deletes a resource,
+// saving the config file and applying the result
+func (h *Handler) changeConfig(w http.ResponseWriter, r *http.Request, name string, kind configKind, id string) {
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+	if h.configPath == "" {
+		w.WriteHeader(http.StatusConflict)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Configuration changes need a config file (-config or CONFIG_FILE)"})
+		return
+	}
+
+	var item configItem
+	if r.Method != http.MethodDelete {
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxResourceBody))
+		dec.DisallowUnknownFields()
+		var err error
+		if item, err = kind.decode(dec); err != nil {
+			w.WriteHeader(bodyErrorCode(err))
+			h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("Invalid %s: %v", kind.singular, err)})
+			return
+		}
+		if id != "" && item.id != id {
+			w.WriteHeader(http.StatusBadRequest)
+			h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("The name of a %s cannot change; delete and recreate it", kind.singular)})
+			return
+		}
+	}
+	key := id
+	if key == "" {
+		key = item.id
+	}
+
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+
+	current, exists := findItem(kind.list(h.config), key)
+	switch {
+	case id == "" && exists:
+		w.WriteHeader(http.StatusConflict)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("There is already a %s %s", kind.singular, key)})
+		return
+	case id != "" && !exists:
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("No %s %s", kind.singular, key)})
+		return
+	case id != "" && r.Header.Get("If-Match") != "" && !etagMatches(r.Header.Get("If-Match"), resourceETag(current.value)):
+		w.WriteHeader(http.StatusPreconditionFailed)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("The %s changed since it was read", kind.singular)})
+		return
+	}
+
+	cfg, err := config.Update(h.configPath, func(file *config.Config) error {
+		if item.value == nil {
+			kind.remove(file, key)
+		} else {
+			kind.put(file, item)
+		}
+		return nil
+	}, func(effective *config.Config) error {
+		// Environment variables take precedence over the file
+		after, ok := findItem(kind.list(effective), key)
+		if item.value == nil && ok || item.value != nil && (!ok || resourceETag(after.value) != resourceETag(item.value)) {
+			return errOverridden
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errOverridden):
+		w.WriteHeader(http.StatusConflict)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: fmt.Sprintf("The %s %s is set by an environment variable, which would override the change", kind.singular, key)})
+		return
+	case errors.Is(err, config.ErrInvalid):
+		w.WriteHeader(http.StatusBadRequest)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: err.Error()})
+		return
+	case err != nil:
+		h.logger.ErrorContext(r.Context(), "failed to save configuration",
+			"error", err, "path", h.configPath, "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Failed to save configuration"})
+		return
+	}
+
+	h.config = cfg
+	if h.applyConfig != nil {
+		h.applyConfig(cfg)
+	}
+	h.recordResourceChange(r, kind.singular, key)
+
+	switch {
+	case item.value == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case id == "":
+		w.Header().Set("Location", h.basePath+"/api/admin/config/"+name+"/"+url.PathEscape(key))
+		w.Header().Set("ETag", resourceETag(item.value))
+		w.WriteHeader(http.StatusCreated)
+		h.writeJSON(w, r, item.value)
+	default:
+		w.Header().Set("ETag", resourceETag(item.value))
+		h.writeJSON(w, r, item.value)
+	}
+}
+
+// recordResourceChange logs and audits a change to a resource
+func (h *Handler) recordResourceChange(r *http.Request, singular, id string) {
+	change := map[string]string{http.MethodPost: "created", http.MethodPut: "updated", http.MethodDelete: "deleted"}[r.Method]
+	h.logger.InfoContext(r.Context(), "configuration changed",
+		"resource", singular, "id", id, "change", change, "remote_addr", r.RemoteAddr)
+	h.audit.Record(audit.Event{
+		Type:       audit.EventConfigChanged,
+		User:       auth.UserFromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+		Message:    fmt.Sprintf("%s %s %s", singular, id, change),
+		Fields:     map[string]any{"resource": singular, "id": id, "change": change},
+	})
+}
+
+// tokenResource is an API token as a resource. Secret is only set in the
+// response that creates the token; ID, CreatedAt and Secret are ignored in
+// requests.
+type tokenResource struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	User      string     `json:"user"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Secret    string     `json:"secret,omitempty"`
+}
+
+func newTokenResource(t store.Token) tokenResource {
+	return tokenResource{ID: t.ID, Name: t.Name, User: t.User, CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+}
+
+// serveTokens serves API tokens at /api/admin/config/tokens, to admins.
+// Tokens are created for the calling admin unless it names another user; only
+// their name and expiry can be changed later, never past the expiry of a
+// token making the request.
+func (h *Handler) serveTokens(w http.ResponseWriter, r *http.Request, id string) {
+	users := h.authConfig.Users
+	if users == nil {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "API tokens need a user store (STORE_PATH or STORE_DRIVER)"})
+		return
+	}
+	ctx := r.Context()
+
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			tokens, err := users.ListTokens(ctx)
+			if err != nil {
+				h.storeError(w, r, err)
+				return
+			}
+			resources := make([]tokenResource, len(tokens))
+			for i, t := range tokens {
+				resources[i] = newTokenResource(t)
+			}
+			h.writeJSON(w, r, map[string]any{"success": true, "tokens": resources})
+		case http.MethodPost:
+			h.createToken(w, r)
+		default:
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	token, err := users.GetToken(ctx, id)
+	if err != nil {
+		h.storeError(w, r, err)
+		return
+	}
+	if r.Method == http.MethodGet {
+		h.writeJSONWithETag(w, r, newTokenResource(token))
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" && !etagMatches(match, resourceETag(newTokenResource(token))) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "The token changed since it was read"})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := users.DeleteToken(ctx, id); err != nil {
+			h.storeError(w, r, err)
+			return
+		}
+		h.recordResourceChange(r, "token", id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	req, ok := h.decodeToken(w, r)
+	if !ok {
+		return
+	}
+	if req.User != "" && req.User != token.User {
+		w.WriteHeader(http.StatusBadRequest)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "The user of a token cannot change; create a new token instead"})
+		return
+	}
+	token.Name = req.Name
+	token.ExpiresAt = capExpiry(ctx, req.ExpiresAt)
+	if err := users.PutToken(ctx, token); err != nil {
+		h.storeError(w, r, err)
+		return
+	}
+	h.recordResourceChange(r, "token", id)
+	resource := newTokenResource(token)
+	w.Header().Set("ETag", resourceETag(resource))
+	h.writeJSON(w, r, resource)
+}
+
+// createToken creates an API token, returning its secret this once
+func (h *Handler) createToken(w http.ResponseWriter, r *http.Request) {
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+	req, ok := h.decodeToken(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	users := h.authConfig.Users
+
+	user := req.User
+	if user == "" {
+		user = auth.UserFromContext(ctx)
+	}
+	// The environment admin account is not in the store
+	if user != h.authConfig.Username {
+		if _, err := users.GetUser(ctx, user); errors.Is(err, store.ErrNotFound) {
+			w.WriteHeader(http.StatusBadRequest)
+			h.writeJSON(w, r, APIResponse{Success: false, Error: "No user " + user})
+			return
+		} else if err != nil {
+			h.storeError(w, r, err)
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		w.WriteHeader(http.StatusBadRequest)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Invalid token: expires_at is in the past"})
+		return
+	}
+	expiresAt := capExpiry(ctx, req.ExpiresAt)
+	var ttl time.Duration
+	if expiresAt != nil {
+		ttl = time.Until(*expiresAt)
+	}
+	token, secret, err := auth.NewToken(req.Name, user, ttl)
+	if err != nil {
+		h.storeError(w, r, err)
+		return
+	}
+	token.ExpiresAt = expiresAt
+	if err := users.PutToken(ctx, token); err != nil {
+		h.storeError(w, r, err)
+		return
+	}
+	h.recordResourceChange(r, "token", token.ID)
+
+	resource := newTokenResource(token)
+	w.Header().Set("Location", h.basePath+"/api/admin/config/tokens/"+token.ID)
+	w.Header().Set("ETag", resourceETag(resource))
+	w.WriteHeader(http.StatusCreated)
+	resource.Secret = secret
+	h.writeJSON(w, r, resource)
+}
+
+// capExpiry limits the expiry of a token created or changed with an API
+// token to that token's, so a short-lived token cannot mint a longer one
+func capExpiry(ctx context.Context, expiresAt *time.Time) *time.Time {
+	limit := auth.SessionFromContext(ctx).Expires
+	if limit == nil || (expiresAt != nil && !expiresAt.After(*limit)) {
+		return expiresAt
+	}
+	capped := limit.UTC()
+	return &capped
+}
+
+// decodeToken reads a token resource from the request body
+func (h *Handler) decodeToken(w http.ResponseWriter, r *http.Request) (tokenResource, bool) {
+	var req tokenResource
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxResourceBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		w.WriteHeader(bodyErrorCode(err))
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Invalid token: " + err.Error()})
+		return tokenResource{}, false
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		req.ExpiresAt = &expiresAt
+	}
+	return req, true
+}
+
+// storeError answers a failed store call: 404 for missing records
+func (h *Handler) storeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Resource not found"})
+		return
+	}
+	h.logger.ErrorContext(r.Context(), "store request failed",
+		"error", err, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	w.WriteHeader(http.StatusInternalServerError)
+	h.writeJSON(w, r, APIResponse{Success: false, Error: "Internal server error"})
+}
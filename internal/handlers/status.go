@@ -0,0 +1,59 @@
+// internal/handlers/status.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"sysdwitch/internal/service"
+)
+
+// SetPublicStatusServices sets the curated services shown on the public
+// status page. An empty list disables the page.
+func (h *Handler) SetPublicStatusServices(services []string) {
+	h.publicStatus = make([]string, len(services))
+	for i, s := range services {
+		h.publicStatus[i] = normalizeServiceName(s)
+	}
+}
+
+// PublicStatus renders the unauthenticated read-only status page
+func (h *Handler) PublicStatus(w http.ResponseWriter, r *http.Request) {
+	if len(h.publicStatus) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	services := make([]service.ServiceStatus, 0, len(h.publicStatus))
+	allUp := true
+	for _, name := range h.publicStatus {
+		status, err := h.serviceManager.GetServiceStatus(ctx, name)
+		if errors.Is(err, service.ErrNotAllowed) {
+			continue
+		}
+		allUp = allUp && status.Active
+		services = append(services, status)
+	}
+
+	data := struct {
+		Services []service.ServiceStatus
+		AllUp    bool
+		Theme    themeData
+	}{
+		Services: services,
+		AllUp:    allUp,
+		Theme:    h.themeFor(r),
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := h.templates.ExecuteTemplate(w, "status.html", data); err != nil {
+		h.logger.ErrorContext(r.Context(), "template execution error",
+			"error", err, "template", "status.html", "remote_addr", r.RemoteAddr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
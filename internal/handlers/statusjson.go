@@ -0,0 +1,153 @@
+// internal/handlers/statusjson.go
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"sysdwitch/internal/service"
+)
+
+// Framing of the status list, the encoding of APIResponse{Success: true,
+// Services: ...} around the services
+var (
+	statusListPrefix    = []byte(`{"success":true,"services":[`)
+	statusListSeparator = []byte(",")
+	statusListSuffix    = []byte("]}\n")
+)
+
+// encodeBuffers are reused for encoding single statuses
+var encodeBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// statusEncoder keeps the JSON of each service's last status, so a poll
+// only encodes the services that changed since the previous one
+type statusEncoder struct {
+	mu      sync.Mutex
+	entries map[string]*encodedStatus
+	// generation counts encodes; entries not seen in the current one
+	// belong to services no longer allowed
+	generation uint64
+}
+
+// encodedStatus is a status with its JSON and the JSON's digest
+type encodedStatus struct {
+	status service.ServiceStatus
+	json   []byte
+	sum    [sha256.Size]byte
+	seen   uint64
+}
+
+// encode returns the encoded statuses in order, reusing the encoding of
+// statuses equal to the last one of their service
+func (e *statusEncoder) encode(statuses []service.ServiceStatus) ([]*encodedStatus, error) {
+	e.mu.Lock()
+	if e.entries == nil {
+		e.entries = make(map[string]*encodedStatus)
+	}
+	e.generation++
+	generation := e.generation
+	encoded := make([]*encodedStatus, len(statuses))
+	for i, status := range statuses {
+		if entry, ok := e.entries[status.Name]; ok && entry.status.Equal(status) {
+			entry.seen = generation
+			encoded[i] = entry
+		}
+	}
+	e.mu.Unlock()
+
+	// Encode the changed ones without holding the lock, so concurrent
+	// polls do not queue behind each other
+	changed := false
+	for i, status := range statuses {
+		if encoded[i] != nil {
+			continue
+		}
+		data, err := encodeStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = &encodedStatus{status: status, json: data, sum: sha256.Sum256(data), seen: generation}
+		changed = true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if changed {
+		for _, entry := range encoded {
+			e.entries[entry.status.Name] = entry
+		}
+	}
+	if len(e.entries) > len(statuses) {
+		for name, entry := range e.entries {
+			if entry.seen < generation {
+				delete(e.entries, name)
+			}
+		}
+	}
+	return encoded, nil
+}
+
+// encodeStatus encodes a status as json.Marshal does, in a pooled buffer
+func encodeStatus(status service.ServiceStatus) ([]byte, error) {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	defer encodeBuffers.Put(buf)
+	buf.Reset()
+	if err := json.NewEncoder(buf).Encode(status); err != nil {
+		return nil, err
+	}
+	// Drop the newline Encode ends with
+	return bytes.Clone(buf.Bytes()[:buf.Len()-1]), nil
+}
+
+// writeStatusList writes the status list as writeJSONWithETag would
+// write APIResponse{Success: true, Services: statuses}, but streams the
+// cached encoding of each service instead of building the body. The ETag
+// is derived from the services' digests, so it is known before the body.
+func (h *Handler) writeStatusList(w http.ResponseWriter, r *http.Request, statuses []service.ServiceStatus) {
+	if len(statuses) == 0 {
+		h.writeJSONWithETag(w, r, APIResponse{Success: true})
+		return
+	}
+	encoded, err := h.statusJSON.encode(statuses)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response",
+			"error", err, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	digest := sha256.New()
+	digest.Write(statusListPrefix)
+	for _, entry := range encoded {
+		digest.Write(entry.sum[:])
+	}
+	etag := `"` + hex.EncodeToString(digest.Sum(nil)[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// The ResponseWriter buffers, so the pieces go out in few writes
+	pieces := make([][]byte, 0, 2*len(encoded)+1)
+	pieces = append(pieces, statusListPrefix)
+	for i, entry := range encoded {
+		if i > 0 {
+			pieces = append(pieces, statusListSeparator)
+		}
+		pieces = append(pieces, entry.json)
+	}
+	pieces = append(pieces, statusListSuffix)
+	if _, err := (*net.Buffers)(&pieces).WriteTo(w); err != nil {
+		h.logger.DebugContext(r.Context(), "failed to write JSON response",
+			"error", err, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	}
+}
@@ -0,0 +1,175 @@
+// internal/handlers/tasks.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/auth"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/jobs"
+	"sysdwitch/internal/requestid"
+	"sysdwitch/internal/service"
+)
+
+// defaultTaskTimeout bounds tasks without a configured timeout
+const defaultTaskTimeout = time.Hour
+
+// taskLogLines is how many journal lines the task log endpoint returns
+const taskLogLines = 200
+
+// taskView is a task as listed by the API and the dashboard
+type taskView struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label"`
+	Description string   `json:"description,omitempty"`
+	Command     []string `json:"command"`
+	Unit        string   `json:"unit"`
+}
+
+// SetTasks sets the predefined one-shot tasks
+func (h *Handler) SetTasks(tasks []config.TaskConfig) {
+	h.tasks = make(map[string]config.TaskConfig, len(tasks))
+	h.taskOrder = h.taskOrder[:0]
+	for _, t := range tasks {
+		h.tasks[t.Name] = t
+		h.taskOrder = append(h.taskOrder, t.Name)
+	}
+}
+
+// taskViews lists the configured tasks in declaration order
+func (h *Handler) taskViews() []taskView {
+	views := make([]taskView, 0, len(h.taskOrder))
+	for _, name := range h.taskOrder {
+		t := h.tasks[name]
+		view := taskView{Name: t.Name, Label: t.DisplayName, Description: t.Description, Command: t.Command, Unit: service.TransientUnit(t.Name)}
+		if view.Label == "" {
+			view.Label = t.Name
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// Tasks lists tasks at /api/admin/tasks, runs one at
+// /api/admin/tasks/{name}/run and returns its journal at
+// /api/admin/tasks/{name}/logs
+func (h *Handler) Tasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/tasks"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		h.writeJSON(w, r, map[string]any{"success": true, "tasks": h.taskViews()})
+		return
+	}
+
+	name, action, _ := strings.Cut(path, "/")
+	task, ok := h.tasks[name]
+	if !ok || (action != "run" && action != "logs") {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Task not found"})
+		return
+	}
+
+	if action == "logs" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		lines, err := h.serviceManager.TransientJournal(r.Context(), task.Name, taskLogLines)
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "failed to read task journal",
+				"task", task.Name, "error", err)
+		}
+		h.writeJSON(w, r, map[string]any{"success": err == nil, "unit": service.TransientUnit(task.Name), "lines": lines})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.rejectIfReadOnly(w, r) {
+		return
+	}
+
+	ctx := r.Context()
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		ctx = service.WithDryRun(ctx)
+	}
+
+	var response APIResponse
+	if status, job, err := h.runTask(ctx, r, task); errors.Is(err, jobs.ErrBusy) {
+		w.WriteHeader(http.StatusConflict)
+		response = APIResponse{Success: false, Job: job, Error: err.Error()}
+	} else if job != nil {
+		w.Header().Set("Location", h.basePath+"/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		response = APIResponse{Success: true, Job: job}
+	} else if err != nil {
+		response = APIResponse{Success: false, Service: &status, Error: err.Error()}
+	} else {
+		response = APIResponse{Success: true, Service: &status}
+	}
+	h.writeJSON(w, r, response)
+}
+
+// runTask queues a task as a job and waits for it like runAction
+func (h *Handler) runTask(ctx context.Context, r *http.Request, task config.TaskConfig) (service.ServiceStatus, *jobs.Job, error) {
+	timeout := time.Duration(task.Timeout)
+	if timeout <= 0 {
+		timeout = defaultTaskTimeout
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	run := func() ([]service.ServiceStatus, error) {
+		status, err := h.serviceManager.RunTransient(ctx, task.Name, task.Command, timeout)
+		h.recordTask(ctx, r, task.Name, status, err)
+		return []service.ServiceStatus{status}, err
+	}
+	if h.jobs == nil {
+		statuses, err := run()
+		return statuses[0], nil, err
+	}
+
+	job, done, err := h.jobs.Submit("task/"+task.Name, "run", run)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "task rejected: already running",
+			"task", task.Name, "job", job.ID, "remote_addr", r.RemoteAddr)
+		return service.ServiceStatus{}, &job, &busyError{job: job}
+	}
+
+	result, pending := h.awaitJob(job, done, 0)
+	if pending != nil {
+		return service.ServiceStatus{}, pending, nil
+	}
+	return result.Statuses[0], nil, result.Err
+}
+
+// recordTask audits a finished task run
+func (h *Handler) recordTask(ctx context.Context, r *http.Request, name string, status service.ServiceStatus, err error) {
+	if status.DryRun {
+		return
+	}
+	fields := map[string]any{"task": name, "unit": status.Name, "status": status.Status, "request_id": requestid.FromContext(ctx)}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	h.audit.Record(audit.Event{
+		Type:       audit.EventTaskRun,
+		User:       auth.UserFromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+		Message:    fmt.Sprintf("task %s ran: %s", name, status.Status),
+		Fields:     fields,
+	})
+}
@@ -0,0 +1,108 @@
+// internal/handlers/templates.go
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// cardView is the data rendered by the "service-card" template
+type cardView struct {
+	service.ServiceStatus
+	ReadOnly bool
+	// Usage holds recent resource samples for the sparklines
+	Usage []service.Sample
+	// Pending is the job state (queued, running) of an action still in
+	// flight, or empty
+	Pending string
+}
+
+// TemplateFuncs returns the functions available to the page templates
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"trimSuffix": strings.TrimSuffix,
+		"uptime":     formatUptime,
+		"isImage":    isImageRef,
+		"card": func(status service.ServiceStatus, readOnly bool, usage []service.Sample) cardView {
+			return cardView{ServiceStatus: status, ReadOnly: readOnly, Usage: usage}
+		},
+		"sparkline":   sparkline,
+		"formatBytes": formatBytes,
+		"last": func(samples []service.Sample) service.Sample {
+			if len(samples) == 0 {
+				return service.Sample{}
+			}
+			return samples[len(samples)-1]
+		},
+	}
+}
+
+// sparkline renders samples as SVG polyline points in a 100x20 viewBox,
+// scaled to the largest value; metric is "cpu" or "memory"
+func sparkline(samples []service.Sample, metric string) string {
+	values := make([]float64, len(samples))
+	peak := 0.0
+	for i, s := range samples {
+		if metric == "cpu" {
+			values[i] = s.CPUPercent
+		} else {
+			values[i] = float64(s.MemoryBytes)
+		}
+		peak = max(peak, values[i])
+	}
+	if len(values) < 2 {
+		return ""
+	}
+	if peak == 0 {
+		peak = 1
+	}
+
+	var b strings.Builder
+	step := 100 / float64(len(values)-1)
+	for i, v := range values {
+		fmt.Fprintf(&b, "%.1f,%.1f ", float64(i)*step, 20-v/peak*18-1)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// formatBytes renders a byte count with a binary unit like "12.3 MiB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isImageRef reports whether an icon refers to an image rather than an emoji
+func isImageRef(icon string) bool {
+	return strings.HasPrefix(icon, "http://") || strings.HasPrefix(icon, "https://") || strings.HasPrefix(icon, "/")
+}
+
+// formatUptime renders the time since t as a compact duration like "3d 4h"
+func formatUptime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	d := time.Since(*t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,39 @@
+// internal/handlers/theme.go
+package handlers
+
+import (
+	"net/http"
+
+	"sysdwitch/internal/config"
+)
+
+// themeCookie stores the user's theme choice
+const themeCookie = "theme"
+
+// themeData is passed to page templates
+type themeData struct {
+	// Mode is "light", "dark" or "system"
+	Mode   string
+	Accent string
+}
+
+// SetTheme sets the server-wide theme defaults
+func (h *Handler) SetTheme(theme config.ThemeConfig) {
+	h.theme = theme
+}
+
+// themeFor resolves the theme for a request from the user's cookie,
+// falling back to the configured default
+func (h *Handler) themeFor(r *http.Request) themeData {
+	mode := h.theme.Default
+	if c, err := r.Cookie(themeCookie); err == nil {
+		switch c.Value {
+		case "light", "dark", "system":
+			mode = c.Value
+		}
+	}
+	if mode == "" {
+		mode = "system"
+	}
+	return themeData{Mode: mode, Accent: h.theme.Accent}
+}
@@ -0,0 +1,46 @@
+// internal/handlers/version.go
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"sysdwitch/internal/update"
+)
+
+// SetUpdateChecker sets the checker whose result drives the update banner;
+// nil disables update checks
+func (h *Handler) SetUpdateChecker(checker *update.Checker) {
+	h.updates = checker
+}
+
+// versionResponse is the body of GET /api/version
+type versionResponse struct {
+	BuildInfo
+	Update *update.Release `json:"update,omitempty"`
+}
+
+// Version reports the running build and, if update checks are enabled, the
+// latest known release
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := versionResponse{BuildInfo: h.build, Update: h.updates.Latest()}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response for version",
+			"error", err, "remote_addr", r.RemoteAddr)
+	}
+}
+
+// availableUpdate returns the latest release if it is newer than the
+// running version
+func (h *Handler) availableUpdate() *update.Release {
+	if latest := h.updates.Latest(); latest != nil && latest.Available {
+		return latest
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// internal/handlers/widget.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// widgetSummary is the flat body of GET /api/widget, shaped for the
+// "customapi" widget of gethomepage.dev and similar dashboards
+type widgetSummary struct {
+	Total   int `json:"total"`
+	Running int `json:"running"`
+	Stopped int `json:"stopped"`
+	Failed  int `json:"failed"`
+	// Services maps each service's label to its status
+	Services map[string]string `json:"services"`
+}
+
+// widgetService is the body of GET /api/widget?service={name}
+type widgetService struct {
+	Name        string     `json:"name"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Status      string     `json:"status"`
+	Active      bool       `json:"active"`
+	Since       *time.Time `json:"since,omitempty"`
+	Uptime      string     `json:"uptime,omitempty"`
+}
+
+// Widget serves compact status for homelab dashboard widgets: counts of
+// running, stopped and failed services, or one service with ?service=
+func (h *Handler) Widget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	ctx := r.Context()
+
+	if name := r.URL.Query().Get("service"); name != "" {
+		status, err := h.serviceManager.GetServiceStatus(ctx, normalizeServiceName(name))
+		if errors.Is(err, service.ErrNotAllowed) {
+			w.WriteHeader(http.StatusNotFound)
+			h.writeJSON(w, r, APIResponse{Success: false, Error: "Service not allowed"})
+			return
+		}
+		body := widgetService{
+			Name:        status.Label(),
+			DisplayName: status.DisplayName,
+			Status:      string(status.Status),
+			Active:      status.Active,
+			Since:       status.Since,
+		}
+		if status.Active {
+			body.Uptime = formatUptime(status.Since)
+		}
+		h.writeJSONWithETag(w, r, body)
+		return
+	}
+
+	services := h.serviceManager.GetAllServicesStatus(ctx)
+	sum := summarize(services)
+	body := widgetSummary{
+		Total:    len(services),
+		Running:  sum.Running,
+		Stopped:  sum.Stopped,
+		Failed:   sum.Failed,
+		Services: make(map[string]string, len(services)),
+	}
+	for _, s := range services {
+		body.Services[s.Label()] = string(s.Status)
+	}
+	h.writeJSONWithETag(w, r, body)
+}
@@ -0,0 +1,71 @@
+// internal/handlers/zabbix.go
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// zabbixService is a service's entry in /api/zabbix/status. Booleans are
+// 0 or 1 so Zabbix can store and graph them as numbers.
+type zabbixService struct {
+	Status      string `json:"status"`
+	Active      int    `json:"active"`
+	Uptime      int64  `json:"uptime"`
+	Flapping    int    `json:"flapping"`
+	Maintenance int    `json:"maintenance"`
+}
+
+// Zabbix serves Zabbix low-level discovery at /api/zabbix/discovery, one
+// entry of {#SERVICE} macros per allowed service, and the states of all
+// services keyed by unit at /api/zabbix/status for dependent items to
+// pick apart with JSONPath
+func (h *Handler) Zabbix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/zabbix"), "/")
+	if path != "discovery" && path != "status" {
+		w.WriteHeader(http.StatusNotFound)
+		h.writeJSON(w, r, APIResponse{Success: false, Error: "Not found"})
+		return
+	}
+	services := h.serviceManager.GetAllServicesStatus(r.Context())
+
+	if path == "discovery" {
+		discovery := make([]map[string]string, len(services))
+		for i, s := range services {
+			discovery[i] = map[string]string{
+				"{#SERVICE}":       s.Name,
+				"{#SERVICE.NAME}":  s.Label(),
+				"{#SERVICE.GROUP}": s.Group,
+			}
+		}
+		h.writeJSON(w, r, discovery)
+		return
+	}
+
+	now := time.Now()
+	states := make(map[string]zabbixService, len(services))
+	for _, s := range services {
+		state := zabbixService{Status: string(s.Status)}
+		if s.Active {
+			state.Active = 1
+			if s.Since != nil {
+				state.Uptime = int64(now.Sub(*s.Since).Seconds())
+			}
+		}
+		if s.Flapping {
+			state.Flapping = 1
+		}
+		if s.Maintenance != "" {
+			state.Maintenance = 1
+		}
+		states[s.Name] = state
+	}
+	h.writeJSON(w, r, states)
+}
@@ -0,0 +1,228 @@
+// internal/hoststats/hoststats.go
+package hoststats
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Stats is a snapshot of host resource usage
+type Stats struct {
+	At time.Time `json:"at"`
+	// Load is the 1, 5 and 15 minute load average
+	Load [3]float64 `json:"load"`
+	CPUs int        `json:"cpus"`
+
+	MemoryTotal     uint64 `json:"memory_total"`
+	MemoryAvailable uint64 `json:"memory_available"`
+
+	Disks []Disk `json:"disks"`
+
+	// Network holds per-interface throughput since the previous snapshot;
+	// it is empty on the first one
+	Network []Interface `json:"network"`
+	// RxBytesPerSec and TxBytesPerSec sum all interfaces
+	RxBytesPerSec uint64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec uint64 `json:"tx_bytes_per_sec"`
+}
+
+// Disk is the usage of a mounted filesystem
+type Disk struct {
+	Mount string `json:"mount"`
+	Total uint64 `json:"total"`
+	// Free is the space available to unprivileged users
+	Free  uint64 `json:"free"`
+	Error string `json:"error,omitempty"`
+}
+
+// Interface is the throughput of a network interface
+type Interface struct {
+	Name          string `json:"name"`
+	RxBytesPerSec uint64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec uint64 `json:"tx_bytes_per_sec"`
+}
+
+// MemoryPercent is the share of memory in use
+func (s Stats) MemoryPercent() float64 {
+	if s.MemoryTotal == 0 {
+		return 0
+	}
+	return float64(s.MemoryTotal-s.MemoryAvailable) / float64(s.MemoryTotal) * 100
+}
+
+// UsedPercent is the share of the filesystem in use
+func (d Disk) UsedPercent() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Total-d.Free) / float64(d.Total) * 100
+}
+
+// netCounters are cumulative byte counters of an interface
+type netCounters struct {
+	rx, tx uint64
+}
+
+// Collector reads host statistics from /proc and statfs. Network
+// throughput is averaged over the time between two Collect calls.
+type Collector struct {
+	mounts  []string
+	procDir string
+
+	mu      sync.Mutex
+	lastNet map[string]netCounters
+	lastAt  time.Time
+}
+
+// NewCollector creates a collector reporting disk usage of mounts
+func NewCollector(mounts []string) *Collector {
+	return &Collector{mounts: mounts, procDir: "/proc"}
+}
+
+// Collect takes a snapshot. It fails when /proc cannot be read; mounts
+// that cannot be read carry an Error instead.
+func (c *Collector) Collect() (Stats, error) {
+	stats := Stats{At: time.Now(), CPUs: runtime.NumCPU(), Disks: []Disk{}, Network: []Interface{}}
+
+	load, err := c.loadAverage()
+	if err != nil {
+		return stats, err
+	}
+	stats.Load = load
+	stats.MemoryTotal, stats.MemoryAvailable, err = c.memory()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, mount := range c.mounts {
+		disk := Disk{Mount: mount}
+		var fs syscall.Statfs_t
+		if err := syscall.Statfs(mount, &fs); err != nil {
+			disk.Error = err.Error()
+		} else {
+			disk.Total = fs.Blocks * uint64(fs.Bsize)
+			disk.Free = fs.Bavail * uint64(fs.Bsize)
+		}
+		stats.Disks = append(stats.Disks, disk)
+	}
+
+	counters, err := c.netCounters()
+	if err != nil {
+		return stats, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elapsed := stats.At.Sub(c.lastAt).Seconds(); !c.lastAt.IsZero() && elapsed > 0 {
+		for _, name := range sortedKeys(counters) {
+			prev, ok := c.lastNet[name]
+			now := counters[name]
+			// Counters reset when an interface is recreated
+			if !ok || now.rx < prev.rx || now.tx < prev.tx {
+				continue
+			}
+			iface := Interface{
+				Name:          name,
+				RxBytesPerSec: uint64(float64(now.rx-prev.rx) / elapsed),
+				TxBytesPerSec: uint64(float64(now.tx-prev.tx) / elapsed),
+			}
+			stats.Network = append(stats.Network, iface)
+			stats.RxBytesPerSec += iface.RxBytesPerSec
+			stats.TxBytesPerSec += iface.TxBytesPerSec
+		}
+	}
+	c.lastNet, c.lastAt = counters, stats.At
+	return stats, nil
+}
+
+// loadAverage parses /proc/loadavg
+func (c *Collector) loadAverage() ([3]float64, error) {
+	var load [3]float64
+	data, err := os.ReadFile(filepath.Join(c.procDir, "loadavg"))
+	if err != nil {
+		return load, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return load, fmt.Errorf("unexpected loadavg %q", data)
+	}
+	for i := range load {
+		if load[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load, err
+		}
+	}
+	return load, nil
+}
+
+// memory parses MemTotal and MemAvailable from /proc/meminfo
+func (c *Collector) memory() (total, available uint64, err error) {
+	f, err := os.Open(filepath.Join(c.procDir, "meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// MemTotal:       16318780 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, _ := strconv.ParseUint(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			available = kb * 1024
+		}
+	}
+	return total, available, scanner.Err()
+}
+
+// netCounters parses the byte counters of all interfaces but loopback from
+// /proc/net/dev
+func (c *Collector) netCounters() (map[string]netCounters, error) {
+	f, err := os.Open(filepath.Join(c.procDir, "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counters := make(map[string]netCounters)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// eth0: rx_bytes rx_packets ... (8 rx fields) tx_bytes ...
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "lo" {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 9 {
+			continue
+		}
+		rx, _ := strconv.ParseUint(fields[0], 10, 64)
+		tx, _ := strconv.ParseUint(fields[8], 10, 64)
+		counters[name] = netCounters{rx: rx, tx: tx}
+	}
+	return counters, scanner.Err()
+}
+
+// sortedKeys returns the interface names in order
+func sortedKeys(m map[string]netCounters) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,192 @@
+// internal/influx/influx.go
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/service"
+)
+
+// Exporter pushes service states, action counts and resource samples to
+// an InfluxDB or VictoriaMetrics write endpoint in line protocol
+type Exporter struct {
+	url      string
+	token    string
+	host     string
+	services *service.ServiceManager
+	client   *http.Client
+	logger   *slog.Logger
+
+	mu sync.Mutex
+	// actions counts control actions per service and action since start
+	actions map[actionKey]int64
+}
+
+// actionKey identifies an action counter
+type actionKey struct {
+	service string
+	action  string
+}
+
+// NewExporter creates an exporter writing to url, such as
+// "http://influx:8086/api/v2/write?org=home&bucket=sysdwitch". A token is
+// sent as "Authorization: Token ...". Points are tagged with host.
+func NewExporter(url, token, host string, services *service.ServiceManager, logger *slog.Logger) *Exporter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Exporter{
+		url:      url,
+		token:    token,
+		host:     host,
+		services: services,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		logger:   logger.With("component", "influx"),
+		actions:  make(map[actionKey]int64),
+	}
+}
+
+// Record counts service actions; subscribe it to the audit recorder
+func (e *Exporter) Record(event audit.Event) {
+	if event.Type != audit.EventServiceAction {
+		return
+	}
+	action, _ := event.Fields["action"].(string)
+	e.mu.Lock()
+	e.actions[actionKey{event.Service, action}]++
+	e.mu.Unlock()
+}
+
+// Run pushes every interval until ctx is done. Failed writes are logged
+// and the points dropped; the next push carries fresh ones.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Push(ctx); err != nil && ctx.Err() == nil {
+				e.logger.Warn("failed to push metrics", "error", err, "url", e.url)
+			}
+		}
+	}
+}
+
+// Push writes the current points once
+func (e *Exporter) Push(ctx context.Context) error {
+	body := e.points(ctx, time.Now())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.token != "" {
+		req.Header.Set("Authorization", "Token "+e.token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("write returned %s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// points renders one line per service state, latest resource sample and
+// action counter
+func (e *Exporter) points(ctx context.Context, now time.Time) []byte {
+	var buf bytes.Buffer
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+
+	for _, s := range e.services.GetAllServicesStatus(ctx) {
+		tags := e.tags("service", s.Name)
+		var uptime int64
+		if s.Active && s.Since != nil {
+			uptime = int64(now.Sub(*s.Since).Seconds())
+		}
+		fmt.Fprintf(&buf, "sysdwitch_service%s status=%s,active=%s,uptime=%di,flapping=%s,maintenance=%s %s\n",
+			tags, quote(string(s.Status)), boolInt(s.Active), uptime, boolInt(s.Flapping), boolInt(s.Maintenance != ""), ts)
+
+		if samples := e.services.Samples(s.Name); len(samples) > 0 {
+			latest := samples[len(samples)-1]
+			fmt.Fprintf(&buf, "sysdwitch_resources%s cpu_percent=%s,memory_bytes=%di %d\n",
+				tags, strconv.FormatFloat(latest.CPUPercent, 'f', -1, 64), latest.MemoryBytes, latest.At.UnixNano())
+		}
+	}
+
+	e.mu.Lock()
+	keys := make([]actionKey, 0, len(e.actions))
+	for key := range e.actions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].service != keys[j].service {
+			return keys[i].service < keys[j].service
+		}
+		return keys[i].action < keys[j].action
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "sysdwitch_actions%s count=%di %s\n",
+			e.tags("service", key.service, "action", key.action), e.actions[key], ts)
+	}
+	e.mu.Unlock()
+
+	return buf.Bytes()
+}
+
+// tags renders the host tag followed by the given key/value pairs,
+// skipping empty values
+func (e *Exporter) tags(pairs ...string) string {
+	var b strings.Builder
+	if e.host != "" {
+		b.WriteString(",host=" + escapeTag(e.host))
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		b.WriteString("," + pairs[i] + "=" + escapeTag(pairs[i+1]))
+	}
+	return b.String()
+}
+
+// tagEscaper escapes the characters line protocol reserves in tag values
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+
+// escapeTag escapes a tag value
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
+
+// quote renders a string field value
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// boolInt renders a boolean as an integer field so it can be summed and
+// graphed
+func boolInt(b bool) string {
+	if b {
+		return "1i"
+	}
+	return "0i"
+}
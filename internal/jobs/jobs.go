@@ -0,0 +1,274 @@
+// internal/jobs/jobs.go
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// Job states
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrBusy is returned by Submit while another job for the same service is
+// queued or running
+var ErrBusy = errors.New("service busy")
+
+// retention is how long finished jobs stay queryable
+const retention = time.Hour
+
+// Job is a control action whose progress can be polled
+type Job struct {
+	ID      string `json:"id"`
+	Service string `json:"service"`
+	Action  string `json:"action"`
+	Status  string `json:"status"`
+	// Result is the final unit status; Results lists every unit touched
+	// by jobs spanning several units
+	Result     *service.ServiceStatus  `json:"result,omitempty"`
+	Results    []service.ServiceStatus `json:"results,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
+	StartedAt  *time.Time              `json:"started_at,omitempty"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has finished
+func (j Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Func is the work of a job, returning the status of each unit it acted on
+type Func func() ([]service.ServiceStatus, error)
+
+// Result is the outcome of a job's function
+type Result struct {
+	Statuses []service.ServiceStatus
+	Err      error
+}
+
+// entry is a job with its function and completion channel
+type entry struct {
+	job  Job
+	fn   Func
+	done chan Result
+}
+
+// Manager queues jobs and runs them on a worker pool. A service has at
+// most one unfinished job at a time; jobs for different services run in
+// parallel.
+type Manager struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	jobs   map[string]*entry
+	queue  []*entry
+	busy   map[string]bool
+	closed bool
+}
+
+// NewManager creates an empty job manager; call Run to start its workers
+func NewManager() *Manager {
+	m := &Manager{
+		jobs: make(map[string]*entry),
+		busy: make(map[string]bool),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// Run starts workers goroutines executing queued jobs until ctx is done
+func (m *Manager) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.work()
+		}()
+	}
+
+	<-ctx.Done()
+	m.mu.Lock()
+	m.closed = true
+	m.cond.Broadcast()
+	m.mu.Unlock()
+	wg.Wait()
+}
+
+// drainPoll is how often Drain checks for unfinished jobs
+const drainPoll = 100 * time.Millisecond
+
+// Drain waits until no job is queued or running, including jobs submitted
+// meanwhile, or until ctx is done
+func (m *Manager) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for {
+		m.mu.Lock()
+		idle := len(m.queue) == 0 && len(m.busy) == 0
+		m.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Submit queues fn as a job for serviceName, which may also name a group of
+// units such as a profile. The returned channel receives fn's result once
+// it has run. While another job for serviceName is unfinished, Submit
+// returns that job and ErrBusy instead.
+func (m *Manager) Submit(serviceName, action string, fn Func) (Job, <-chan Result, error) {
+	e := &entry{
+		job: Job{
+			ID:        newID(),
+			Service:   serviceName,
+			Action:    action,
+			Status:    StatusQueued,
+			CreatedAt: time.Now(),
+		},
+		fn:   fn,
+		done: make(chan Result, 1),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if active := m.active(serviceName); active != nil {
+		return active.job, nil, ErrBusy
+	}
+	m.prune(e.job.CreatedAt)
+	m.jobs[e.job.ID] = e
+	m.queue = append(m.queue, e)
+	m.cond.Signal()
+	return e.job, e.done, nil
+}
+
+// active returns the unfinished job for serviceName, if any; the caller
+// must hold mu
+func (m *Manager) active(serviceName string) *entry {
+	for _, e := range m.jobs {
+		if e.job.Service == serviceName && !e.job.Done() {
+			return e
+		}
+	}
+	return nil
+}
+
+// Get returns a job by ID
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return e.job, true
+}
+
+// List returns the retained jobs, oldest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Job, 0, len(m.jobs))
+	for _, e := range m.jobs {
+		list = append(list, e.job)
+	}
+	slices.SortFunc(list, func(a, b Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return list
+}
+
+// work runs queued jobs until the manager is closed
+func (m *Manager) work() {
+	for {
+		m.mu.Lock()
+		e := m.next()
+		for e == nil && !m.closed {
+			m.cond.Wait()
+			e = m.next()
+		}
+		if e == nil {
+			m.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		e.job.Status = StatusRunning
+		e.job.StartedAt = &now
+		m.busy[e.job.Service] = true
+		m.mu.Unlock()
+
+		statuses, err := e.fn()
+
+		m.mu.Lock()
+		m.finish(e, statuses, err)
+		delete(m.busy, e.job.Service)
+		// A job for this service may now be runnable
+		m.cond.Broadcast()
+		m.mu.Unlock()
+
+		e.done <- Result{Statuses: statuses, Err: err}
+	}
+}
+
+// next removes and returns the oldest queued job whose service is idle;
+// the caller must hold mu
+func (m *Manager) next() *entry {
+	for i, e := range m.queue {
+		if !m.busy[e.job.Service] {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return e
+		}
+	}
+	return nil
+}
+
+// finish records a job's outcome; the caller must hold mu
+func (m *Manager) finish(e *entry, statuses []service.ServiceStatus, err error) {
+	now := time.Now()
+	job := &e.job
+	job.FinishedAt = &now
+	job.Status = StatusSucceeded
+
+	if len(statuses) > 0 {
+		last := statuses[len(statuses)-1]
+		job.Result = &last
+	}
+	if len(statuses) > 1 {
+		job.Results = statuses
+	}
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	}
+}
+
+// prune drops finished jobs older than the retention period; the caller
+// must hold mu
+func (m *Manager) prune(now time.Time) {
+	for id, e := range m.jobs {
+		if e.job.FinishedAt != nil && now.Sub(*e.job.FinishedAt) > retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// newID returns a random job ID
+func newID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,136 @@
+// internal/logging/access.go
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/config"
+)
+
+// clfTimeFormat is the timestamp layout of the Common Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessEntry describes one served request
+type AccessEntry struct {
+	Time      time.Time
+	ClientIP  string
+	User      string
+	Method    string
+	URI       string
+	Proto     string
+	Status    int
+	Bytes     int64
+	Duration  time.Duration
+	Referer   string
+	UserAgent string
+	RequestID string
+}
+
+// NewAccessEntry fills the request fields of an entry
+func NewAccessEntry(r *http.Request, clientIP string) AccessEntry {
+	user, _, _ := r.BasicAuth()
+	return AccessEntry{
+		Time:      time.Now(),
+		ClientIP:  clientIP,
+		User:      user,
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// AccessLog writes one line per request in CLF, combined or JSON format
+type AccessLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	format string
+}
+
+// OpenAccessLog opens the access log described by cfg, or returns nil when
+// none is configured. "stdout" and "stderr" write to those streams; any
+// other value is a file rotated with the application log limits.
+func OpenAccessLog(cfg config.LogConfig) (*AccessLog, error) {
+	al := &AccessLog{format: cfg.AccessFormat, closer: nopCloser{}}
+	if al.format == "" {
+		al.format = "combined"
+	}
+
+	switch cfg.AccessLog {
+	case "":
+		return nil, nil
+	case "stdout":
+		al.w = os.Stdout
+	case "stderr":
+		al.w = os.Stderr
+	default:
+		file, err := OpenRotatingFile(cfg.AccessLog, int64(cfg.MaxSizeMB)<<20, time.Duration(cfg.MaxAge), cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		al.w, al.closer = file, file
+	}
+	return al, nil
+}
+
+// Log writes an entry
+func (al *AccessLog) Log(e AccessEntry) {
+	var line []byte
+	switch al.format {
+	case "json":
+		line, _ = json.Marshal(struct {
+			Time       time.Time `json:"time"`
+			ClientIP   string    `json:"client_ip"`
+			User       string    `json:"user,omitempty"`
+			Method     string    `json:"method"`
+			URI        string    `json:"uri"`
+			Proto      string    `json:"proto"`
+			Status     int       `json:"status"`
+			Bytes      int64     `json:"bytes"`
+			DurationMS float64   `json:"duration_ms"`
+			Referer    string    `json:"referer,omitempty"`
+			UserAgent  string    `json:"user_agent,omitempty"`
+			RequestID  string    `json:"request_id,omitempty"`
+		}{e.Time, e.ClientIP, e.User, e.Method, e.URI, e.Proto, e.Status, e.Bytes,
+			float64(e.Duration.Microseconds()) / 1000, e.Referer, e.UserAgent, e.RequestID})
+		line = append(line, '\n')
+	default:
+		size := "-"
+		if e.Bytes > 0 {
+			size = strconv.FormatInt(e.Bytes, 10)
+		}
+		line = fmt.Appendf(nil, "%s - %s [%s] %s %d %s",
+			e.ClientIP, clfField(e.User), e.Time.Format(clfTimeFormat),
+			strconv.Quote(e.Method+" "+e.URI+" "+e.Proto), e.Status, size)
+		if al.format == "combined" {
+			line = fmt.Appendf(line, " %s %s", strconv.Quote(clfField(e.Referer)), strconv.Quote(clfField(e.UserAgent)))
+		}
+		line = append(line, '\n')
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.w.Write(line)
+}
+
+// Close closes the access log file, if any
+func (al *AccessLog) Close() error {
+	return al.closer.Close()
+}
+
+// clfField renders an empty value as "-"
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
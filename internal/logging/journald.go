@@ -0,0 +1,160 @@
+// internal/logging/journald.go
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"unicode"
+)
+
+// journalSocket is where systemd-journald accepts native protocol datagrams
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalHandler writes records to the local journal using the native
+// protocol, so attributes become structured, queryable journal fields
+type journalHandler struct {
+	conn       *net.UnixConn
+	level      slog.Leveler
+	identifier string
+	// fields are pre-rendered attributes from WithAttrs
+	fields []journalField
+	prefix string
+}
+
+// journalField is one KEY=value pair of a journal entry
+type journalField struct {
+	key, value string
+}
+
+// newJournalHandler connects to journald
+func newJournalHandler(identifier string, level slog.Leveler) (*journalHandler, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("connect to journald: %w", err)
+	}
+	return &journalHandler{conn: conn, level: level, identifier: identifier}, nil
+}
+
+// Enabled reports whether records at level are logged
+func (h *journalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle sends the record as one journal entry
+func (h *journalHandler) Handle(_ context.Context, record slog.Record) error {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", record.Message)
+	writeJournalField(&buf, "PRIORITY", journalPriority(record.Level))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", h.identifier)
+	for _, f := range h.fields {
+		writeJournalField(&buf, f.key, f.value)
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		for _, f := range flattenAttr(h.prefix, a) {
+			writeJournalField(&buf, f.key, f.value)
+		}
+		return true
+	})
+
+	_, err := h.conn.Write(buf.Bytes())
+	return err
+}
+
+// WithAttrs returns a handler that adds attrs to every entry
+func (h *journalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.fields = append([]journalField(nil), h.fields...)
+	for _, a := range attrs {
+		clone.fields = append(clone.fields, flattenAttr(h.prefix, a)...)
+	}
+	return &clone
+}
+
+// WithGroup returns a handler that prefixes later attribute keys
+func (h *journalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "_"
+	return &clone
+}
+
+// flattenAttr turns an attribute (and nested groups) into journal fields
+func flattenAttr(prefix string, a slog.Attr) []journalField {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return nil
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "_"
+		}
+		var fields []journalField
+		for _, ga := range a.Value.Group() {
+			fields = append(fields, flattenAttr(prefix, ga)...)
+		}
+		return fields
+	}
+	key := journalKey(prefix + a.Key)
+	if key == "" {
+		return nil
+	}
+	return []journalField{{key: key, value: a.Value.String()}}
+}
+
+// journalKey converts an attribute key to a valid journal field name:
+// uppercase letters, digits and underscores, not starting with an
+// underscore or digit (those are reserved or invalid)
+func journalKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return unicode.ToUpper(r)
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	key = strings.TrimLeft(key, "_0123456789")
+	if len(key) > 64 {
+		key = key[:64]
+	}
+	return key
+}
+
+// writeJournalField encodes one field; values containing newlines use the
+// length-prefixed binary form of the native protocol
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalPriority maps slog levels to syslog priorities
+func journalPriority(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "3"
+	case level >= slog.LevelWarn:
+		return "4"
+	case level >= slog.LevelInfo:
+		return "6"
+	default:
+		return "7"
+	}
+}
@@ -0,0 +1,81 @@
+// internal/logging/logging.go
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"sysdwitch/internal/config"
+)
+
+// nopCloser is returned when logs go to stdout
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// identifier tags entries sent to journald and syslog
+const identifier = "sysdwitch"
+
+// NewHandler builds the slog handler described by cfg. The level is read
+// from level so it can be changed at runtime. The returned closer closes
+// the log file or logging connection, if any.
+func NewHandler(cfg config.LogConfig, level *slog.LevelVar) (slog.Handler, io.Closer, error) {
+	if err := SetLevel(level, cfg.Level); err != nil {
+		return nil, nil, err
+	}
+
+	switch cfg.Output {
+	case "", "stdout":
+	case "journald":
+		h, err := newJournalHandler(identifier, level)
+		if err != nil {
+			return nil, nil, err
+		}
+		return h, h.conn, nil
+	case "syslog":
+		h, err := newSyslogHandler(identifier, level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("connect to syslog: %w", err)
+		}
+		if c, ok := h.(io.Closer); ok {
+			return h, c, nil
+		}
+		return h, nopCloser{}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown log output %q", cfg.Output)
+	}
+
+	var w io.Writer = os.Stdout
+	var closer io.Closer = nopCloser{}
+	if cfg.File != "" {
+		file, err := OpenRotatingFile(cfg.File, int64(cfg.MaxSizeMB)<<20, time.Duration(cfg.MaxAge), cfg.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closer = file, file
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch cfg.Format {
+	case "", "json":
+		return slog.NewJSONHandler(w, opts), closer, nil
+	case "text":
+		return slog.NewTextHandler(w, opts), closer, nil
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+}
+
+// SetLevel parses a level name (debug, info, warn, error) into level
+func SetLevel(level *slog.LevelVar, name string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("invalid log level %q", name)
+	}
+	level.Set(parsed)
+	return nil
+}
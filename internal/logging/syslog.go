@@ -0,0 +1,91 @@
+//go:build !windows && !plan9
+
+// internal/logging/syslog.go
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"log/syslog"
+	"strings"
+	"sync"
+)
+
+// syslogHandler formats records as logfmt text and sends them to the local
+// syslog daemon at the matching priority
+type syslogHandler struct {
+	inner  slog.Handler
+	shared *syslogShared
+}
+
+// syslogShared is the state common to a handler and its derivatives
+type syslogShared struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writer *syslog.Writer
+}
+
+// newSyslogHandler connects to the local syslog daemon
+func newSyslogHandler(tag string, level slog.Leveler) (slog.Handler, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	shared := &syslogShared{writer: writer}
+	inner := slog.NewTextHandler(&shared.buf, &slog.HandlerOptions{
+		Level: level,
+		// syslog adds its own timestamp and the priority carries the level
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return &syslogHandler{inner: inner, shared: shared}, nil
+}
+
+// Enabled reports whether records at level are logged
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle formats the record and writes it at the record's priority
+func (h *syslogHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.shared.mu.Lock()
+	defer h.shared.mu.Unlock()
+
+	h.shared.buf.Reset()
+	if err := h.inner.Handle(ctx, record); err != nil {
+		return err
+	}
+	line := strings.TrimSuffix(h.shared.buf.String(), "\n")
+
+	w := h.shared.writer
+	switch {
+	case record.Level >= slog.LevelError:
+		return w.Err(line)
+	case record.Level >= slog.LevelWarn:
+		return w.Warning(line)
+	case record.Level >= slog.LevelInfo:
+		return w.Info(line)
+	default:
+		return w.Debug(line)
+	}
+}
+
+// WithAttrs returns a handler that adds attrs to every line
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithAttrs(attrs), shared: h.shared}
+}
+
+// WithGroup returns a handler that nests later attributes under name
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithGroup(name), shared: h.shared}
+}
+
+// Close disconnects from the syslog daemon
+func (h *syslogHandler) Close() error {
+	return h.shared.writer.Close()
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+// internal/logging/syslog_other.go
+package logging
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// newSyslogHandler reports that syslog is unavailable on this platform
+func newSyslogHandler(tag string, level slog.Leveler) (slog.Handler, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
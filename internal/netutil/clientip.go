@@ -0,0 +1,140 @@
+// internal/netutil/clientip.go
+package netutil
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// contextKey is the type for values stored in request contexts by this package
+type contextKey struct{}
+
+// ParsePrefixes parses a list of IP addresses and CIDRs. Bare addresses
+// are treated as single-host prefixes.
+func ParsePrefixes(items []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// PrefixesContain reports whether addr falls within any of prefixes
+func PrefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteAddr parses the peer address of the connection, ignoring any
+// forwarding headers
+func RemoteAddr(r *http.Request) (netip.Addr, bool) {
+	return parseAddr(r.RemoteAddr)
+}
+
+// parseAddr parses "host:port" or a bare address, IPv4 or IPv6
+func parseAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	host, _, err := net.SplitHostPort(value)
+	if err != nil {
+		host = strings.Trim(value, "[]")
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// ClientIPResolver determines the real client address, honoring
+// forwarding headers only when the peer is a trusted proxy
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver creates a resolver trusting the given proxy networks
+func NewClientIPResolver(trusted []netip.Prefix) *ClientIPResolver {
+	return &ClientIPResolver{trusted: trusted}
+}
+
+// Resolve extracts the client IP from the request
+func (cr *ClientIPResolver) Resolve(r *http.Request) string {
+	peer, ok := RemoteAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !PrefixesContain(cr.trusted, peer) {
+		return peer.String()
+	}
+
+	// Walk X-Forwarded-For from the nearest hop and return the first
+	// address that is not one of our proxies
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		var leftmost netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, ok := parseAddr(hops[i])
+			if !ok {
+				break
+			}
+			leftmost = addr
+			if !PrefixesContain(cr.trusted, addr) {
+				return addr.String()
+			}
+		}
+		if leftmost.IsValid() {
+			return leftmost.String()
+		}
+	}
+
+	if addr, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+		return addr.String()
+	}
+
+	return peer.String()
+}
+
+// Middleware resolves the client IP once and stores it in the request context
+func (cr *ClientIPResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKey{}, cr.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClientIP returns the client IP resolved by Middleware, falling back to
+// the connection peer address
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(contextKey{}).(string); ok {
+		return ip
+	}
+	if addr, ok := RemoteAddr(r); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
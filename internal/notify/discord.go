@@ -0,0 +1,105 @@
+// internal/notify/discord.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/config"
+)
+
+// Discord embed colours by severity
+var discordColors = map[string]int{
+	"danger":  0xE74C3C,
+	"warning": 0xF1C40F,
+	"good":    0x2ECC71,
+	"info":    0x3498DB,
+}
+
+// discordPayload is the Discord execute-webhook request body
+type discordPayload struct {
+	Username string         `json:"username,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Timestamp   string         `json:"timestamp"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// DiscordNotifier posts rich embeds to Discord webhooks, routing events
+// for specific services to their own channels
+type DiscordNotifier struct {
+	cfg config.DiscordConfig
+}
+
+// NewDiscordNotifier creates a Discord notifier
+func NewDiscordNotifier(cfg config.DiscordConfig) *DiscordNotifier {
+	return &DiscordNotifier{cfg: cfg}
+}
+
+// Name implements Notifier
+func (n *DiscordNotifier) Name() string {
+	return "discord"
+}
+
+// webhookFor returns the webhook URL for a service, falling back to the
+// default channel
+func (n *DiscordNotifier) webhookFor(service string) string {
+	if service != "" {
+		for _, rt := range n.cfg.Routes {
+			if slices.Contains(rt.Services, unitName(service)) || slices.Contains(rt.Services, service) {
+				return rt.WebhookURL
+			}
+		}
+	}
+	return n.cfg.WebhookURL
+}
+
+// Notify implements Notifier
+func (n *DiscordNotifier) Notify(ctx context.Context, event audit.Event) error {
+	url := n.webhookFor(event.Service)
+	if url == "" {
+		return nil
+	}
+
+	embed := discordEmbed{
+		Title:     event.Message,
+		Color:     discordColors[eventSeverity(event)],
+		Timestamp: event.Time.UTC().Format(time.RFC3339),
+		Fields:    []discordField{{Name: "Event", Value: event.Type, Inline: true}},
+	}
+	if event.Service != "" {
+		embed.Fields = append(embed.Fields, discordField{Name: "Service", Value: unitName(event.Service), Inline: true})
+	}
+	if event.User != "" {
+		embed.Fields = append(embed.Fields, discordField{Name: "User", Value: event.User, Inline: true})
+	}
+
+	keys := make([]string, 0, len(event.Fields))
+	for k := range event.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		embed.Fields = append(embed.Fields, discordField{Name: k, Value: fmt.Sprint(event.Fields[k]), Inline: true})
+	}
+
+	return postJSON(ctx, url, discordPayload{
+		Username: n.cfg.Username,
+		Embeds:   []discordEmbed{embed},
+	})
+}
@@ -0,0 +1,105 @@
+// internal/notify/notify.go
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"sysdwitch/internal/audit"
+)
+
+// Notifier delivers audit events to an external channel
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event audit.Event) error
+}
+
+// route sends matching events to one notifier
+type route struct {
+	notifier Notifier
+	events   []string
+}
+
+// matches reports whether the route subscribes to event. An empty event
+// list subscribes to everything.
+func (rt route) matches(event audit.Event) bool {
+	return len(rt.events) == 0 || slices.Contains(rt.events, event.Type)
+}
+
+// Dispatcher queues audit events and delivers them to notifiers in the
+// background so slow channels never block request handling
+type Dispatcher struct {
+	routes []route
+	queue  chan audit.Event
+	logger *slog.Logger
+}
+
+// NewDispatcher creates a dispatcher with a bounded event queue
+func NewDispatcher(logger *slog.Logger) *Dispatcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Dispatcher{
+		queue:  make(chan audit.Event, 256),
+		logger: logger,
+	}
+}
+
+// Add registers a notifier for the given event types (all if empty).
+// Must be called before Run.
+func (d *Dispatcher) Add(notifier Notifier, events []string) {
+	d.routes = append(d.routes, route{notifier: notifier, events: events})
+	d.logger.Info("notifier enabled", "notifier", notifier.Name(), "events", events)
+}
+
+// Len returns the number of registered notifiers
+func (d *Dispatcher) Len() int {
+	return len(d.routes)
+}
+
+// Enqueue schedules an event for delivery, dropping it if the queue is
+// full. Suppressed events are never delivered.
+func (d *Dispatcher) Enqueue(event audit.Event) {
+	if event.Suppressed {
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		d.logger.Warn("notification queue full, dropping event", "event", event.Type)
+	}
+}
+
+// Run delivers queued events until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-d.queue:
+			d.deliver(ctx, event)
+		}
+	}
+}
+
+// deliver sends event to every matching notifier
+func (d *Dispatcher) deliver(ctx context.Context, event audit.Event) {
+	for _, rt := range d.routes {
+		if !rt.matches(event) {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := rt.notifier.Notify(sendCtx, event)
+		cancel()
+		if err != nil {
+			d.logger.Error("failed to send notification",
+				"notifier", rt.notifier.Name(),
+				"event", event.Type,
+				"error", err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// internal/notify/plugin.go
+package notify
+
+import (
+	"context"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/plugin"
+)
+
+// PluginNotifier hands events to an external plugin executable
+type PluginNotifier struct {
+	plugin *plugin.Plugin
+}
+
+// NewPluginNotifier creates a notifier served by p
+func NewPluginNotifier(p *plugin.Plugin) *PluginNotifier {
+	return &PluginNotifier{plugin: p}
+}
+
+// Name implements Notifier
+func (n *PluginNotifier) Name() string {
+	return "plugin " + n.plugin.Name
+}
+
+// Notify implements Notifier by calling the plugin's notify method with
+// the event
+func (n *PluginNotifier) Notify(ctx context.Context, event audit.Event) error {
+	return n.plugin.Call(ctx, "notify", map[string]any{"event": event}, nil)
+}
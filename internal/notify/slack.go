@@ -0,0 +1,124 @@
+// internal/notify/slack.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/ratelimit"
+)
+
+// Slack attachment colours by severity
+var slackColors = map[string]string{
+	"danger":  "danger",
+	"warning": "warning",
+	"good":    "good",
+	"info":    "#3498DB",
+}
+
+// slackPayload is the incoming-webhook body understood by Slack and Mattermost
+type slackPayload struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color    string       `json:"color"`
+	Fallback string       `json:"fallback"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Footer   string       `json:"footer,omitempty"`
+	Ts       int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// SlackNotifier posts to Slack-compatible incoming webhooks. Messages are
+// rate limited per service (or per event type for service-less events) so
+// a crash-looping unit cannot flood the channel.
+type SlackNotifier struct {
+	cfg     config.SlackConfig
+	limiter *ratelimit.Limiter
+
+	mu         sync.Mutex
+	suppressed map[string]int
+}
+
+// NewSlackNotifier creates a Slack/Mattermost notifier
+func NewSlackNotifier(cfg config.SlackConfig) *SlackNotifier {
+	return &SlackNotifier{
+		cfg:        cfg,
+		limiter:    ratelimit.New(cfg.RateLimit.PerMinute, cfg.RateLimit.Burst),
+		suppressed: make(map[string]int),
+	}
+}
+
+// Name implements Notifier
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+// Notify implements Notifier
+func (n *SlackNotifier) Notify(ctx context.Context, event audit.Event) error {
+	key := event.Type
+	if event.Service != "" {
+		key = "service:" + event.Service
+	}
+
+	n.mu.Lock()
+	if ok, _ := n.limiter.Allow(key); !ok {
+		n.suppressed[key]++
+		n.mu.Unlock()
+		return nil
+	}
+	suppressed := n.suppressed[key]
+	delete(n.suppressed, key)
+	n.mu.Unlock()
+
+	text := event.Message
+	if event.Service != "" {
+		text = fmt.Sprintf("*%s*: %s", unitName(event.Service), event.Message)
+	}
+
+	attachment := slackAttachment{
+		Color:    slackColors[eventSeverity(event)],
+		Fallback: event.Message,
+		Fields:   []slackField{{Title: "Event", Value: event.Type, Short: true}},
+		Footer:   "SysDwitch",
+		Ts:       event.Time.Unix(),
+	}
+	if event.User != "" {
+		attachment.Fields = append(attachment.Fields, slackField{Title: "User", Value: event.User, Short: true})
+	}
+
+	keys := make([]string, 0, len(event.Fields))
+	for k := range event.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attachment.Fields = append(attachment.Fields, slackField{Title: k, Value: fmt.Sprint(event.Fields[k]), Short: true})
+	}
+
+	if suppressed > 0 {
+		attachment.Footer = fmt.Sprintf("SysDwitch · %d similar notifications suppressed", suppressed)
+	}
+
+	return postJSON(ctx, n.cfg.WebhookURL, slackPayload{
+		Channel:     n.cfg.Channel,
+		Username:    n.cfg.Username,
+		IconEmoji:   n.cfg.IconEmoji,
+		Text:        text,
+		Attachments: []slackAttachment{attachment},
+	})
+}
@@ -0,0 +1,193 @@
+// internal/notify/smtp.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/config"
+)
+
+// Default email templates, used for any event type without an override
+const (
+	defaultSubjectTemplate = `[sysdwitch] {{.Message}}`
+	defaultBodyTemplate    = `{{.Message}}
+
+Event:   {{.Type}}
+Time:    {{.Time.Format "2006-01-02 15:04:05 MST"}}
+{{- if .Service}}
+Service: {{.Service}}
+{{- end}}
+{{- if .User}}
+User:    {{.User}}
+{{- end}}
+{{- if .RemoteAddr}}
+Client:  {{.RemoteAddr}}
+{{- end}}
+{{- range $k, $v := .Fields}}
+{{$k}}: {{$v}}
+{{- end}}
+`
+)
+
+// emailTemplate is a parsed subject/body pair
+type emailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// SMTPNotifier sends templated emails through an SMTP relay
+type SMTPNotifier struct {
+	cfg       config.SMTPConfig
+	fallback  emailTemplate
+	templates map[string]emailTemplate
+}
+
+// NewSMTPNotifier parses the configured templates and creates the notifier
+func NewSMTPNotifier(cfg config.SMTPConfig) (*SMTPNotifier, error) {
+	fallback, err := parseEmailTemplate("default", defaultSubjectTemplate, defaultBodyTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]emailTemplate, len(cfg.Templates))
+	for event, t := range cfg.Templates {
+		subject, body := t.Subject, t.Body
+		if subject == "" {
+			subject = defaultSubjectTemplate
+		}
+		if body == "" {
+			body = defaultBodyTemplate
+		}
+		parsed, err := parseEmailTemplate(event, subject, body)
+		if err != nil {
+			return nil, err
+		}
+		templates[event] = parsed
+	}
+
+	return &SMTPNotifier{cfg: cfg, fallback: fallback, templates: templates}, nil
+}
+
+func parseEmailTemplate(name, subject, body string) (emailTemplate, error) {
+	s, err := template.New(name + ".subject").Parse(subject)
+	if err != nil {
+		return emailTemplate{}, fmt.Errorf("invalid subject template for %s: %w", name, err)
+	}
+	b, err := template.New(name + ".body").Parse(body)
+	if err != nil {
+		return emailTemplate{}, fmt.Errorf("invalid body template for %s: %w", name, err)
+	}
+	return emailTemplate{subject: s, body: b}, nil
+}
+
+// Name implements Notifier
+func (n *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+// Notify implements Notifier
+func (n *SMTPNotifier) Notify(ctx context.Context, event audit.Event) error {
+	t, ok := n.templates[event.Type]
+	if !ok {
+		t = n.fallback
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, event); err != nil {
+		return fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := t.body.Execute(&body, event); err != nil {
+		return fmt.Errorf("failed to render body: %w", err)
+	}
+
+	msg := n.buildMessage(strings.TrimSpace(subject.String()), body.String(), event.Time)
+	return n.send(ctx, msg)
+}
+
+// buildMessage assembles an RFC 5322 plain-text message
+func (n *SMTPNotifier) buildMessage(subject, body string, date time.Time) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.ReplaceAll(subject, "\n", " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return msg.Bytes()
+}
+
+// send delivers msg, using implicit TLS when configured and STARTTLS
+// whenever the server offers it
+func (n *SMTPNotifier) send(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: n.cfg.Host}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if n.cfg.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !n.cfg.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		}
+	}
+
+	if n.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(n.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range n.cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,66 @@
+// internal/notify/webhook.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+)
+
+// webhookClient is shared by the chat webhook notifiers
+var webhookClient = &http.Client{Timeout: 15 * time.Second}
+
+// postJSON posts payload as JSON to url and fails on non-2xx responses
+func postJSON(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// eventSeverity classifies events for colouring chat messages
+func eventSeverity(event audit.Event) string {
+	switch event.Type {
+	case audit.EventServiceFailed, audit.EventAuthLockout:
+		return "danger"
+	case audit.EventServiceRestarted:
+		return "warning"
+	case audit.EventServiceStateChanged:
+		if event.Fields["to"] == "running" {
+			return "good"
+		}
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// unitName strips the .service suffix for display and route matching
+func unitName(service string) string {
+	return strings.TrimSuffix(service, ".service")
+}
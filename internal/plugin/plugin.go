@@ -0,0 +1,166 @@
+// internal/plugin/plugin.go
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of extension a plugin can provide
+const (
+	KindBackend  = "backend"
+	KindNotifier = "notifier"
+	KindAuth     = "auth"
+	KindDNS      = "dns"
+)
+
+// DefaultTimeout bounds a plugin call when none is configured
+const DefaultTimeout = 10 * time.Second
+
+// Plugin is an external executable speaking the plugin protocol: each call
+// runs it once with a JSON request on stdin and reads a JSON response from
+// stdout
+type Plugin struct {
+	// Name identifies the plugin in configuration; it defaults to the
+	// executable's file name
+	Name string
+	Path string
+	// Kinds lists what the plugin provides: backend, notifier, auth or dns
+	Kinds []string
+	// Events selects the event types a notifier receives; empty means all
+	Events []string
+
+	timeout time.Duration
+}
+
+// request is written to the plugin's stdin
+type request struct {
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+// response is read from the plugin's stdout
+type response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// description is the result of the describe method
+type description struct {
+	Name   string   `json:"name"`
+	Kinds  []string `json:"kinds"`
+	Events []string `json:"events,omitempty"`
+}
+
+// Provides reports whether the plugin provides kind
+func (p *Plugin) Provides(kind string) bool {
+	return slices.Contains(p.Kinds, kind)
+}
+
+// Call runs method with params and decodes the result into result, which
+// may be nil
+func (p *Plugin) Call(ctx context.Context, method string, params, result any) error {
+	input, err := json.Marshal(request{Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("plugin %s: encode %s request: %w", p.Name, method, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("plugin %s: %s: %w: %s", p.Name, method, err, msg)
+		}
+		return fmt.Errorf("plugin %s: %s: %w", p.Name, method, err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return fmt.Errorf("plugin %s: %s: invalid response: %w", p.Name, method, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("plugin %s: %s", p.Name, resp.Error)
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("plugin %s: %s: invalid result: %w", p.Name, method, err)
+	}
+	return nil
+}
+
+// Discover asks every executable in dir to describe itself and returns the
+// plugins that answered, sorted by file name. Executables that fail to
+// describe themselves are logged and skipped.
+func Discover(ctx context.Context, dir string, timeout time.Duration, logger *slog.Logger) ([]*Plugin, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read plugins directory: %w", err)
+	}
+
+	var plugins []*Plugin
+	names := make(map[string]string)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode()&0o111 == 0 {
+			continue
+		}
+
+		p := &Plugin{Name: entry.Name(), Path: filepath.Join(dir, entry.Name()), timeout: timeout}
+		var desc description
+		if err := p.Call(ctx, "describe", nil, &desc); err != nil {
+			logger.WarnContext(ctx, "skipping plugin", "path", p.Path, "error", err)
+			continue
+		}
+		if desc.Name != "" {
+			p.Name = desc.Name
+		}
+		if other, ok := names[p.Name]; ok {
+			logger.WarnContext(ctx, "skipping plugin with duplicate name",
+				"plugin", p.Name, "path", p.Path, "other", other)
+			continue
+		}
+		names[p.Name] = p.Path
+		p.Kinds = desc.Kinds
+		p.Events = desc.Events
+		plugins = append(plugins, p)
+		logger.InfoContext(ctx, "plugin loaded", "plugin", p.Name, "path", p.Path, "kinds", p.Kinds)
+	}
+	return plugins, nil
+}
+
+// Find returns the plugin named name
+func Find(plugins []*Plugin, name string) (*Plugin, bool) {
+	for _, p := range plugins {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,291 @@
+// internal/rules/rules.go
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/config"
+	"sysdwitch/internal/expr"
+	"sysdwitch/internal/service"
+)
+
+// Rule runs its actions when When holds, at most once per Cooldown
+type Rule struct {
+	Name     string
+	When     *expr.Program
+	Actions  []Action
+	Notify   bool
+	Cooldown time.Duration
+}
+
+// Action starts, stops or restarts a service
+type Action struct {
+	Action  string
+	Service string
+}
+
+// String describes the action, e.g. "restart jellyfin.service"
+func (a Action) String() string {
+	return a.Action + " " + a.Service
+}
+
+// Engine evaluates rules on every audit event and on a fixed interval
+type Engine struct {
+	rules     []Rule
+	services  *service.ServiceManager
+	audit     *audit.Recorder
+	logger    *slog.Logger
+	events    chan audit.Event
+	lastFired map[string]time.Time
+	now       func() time.Time
+}
+
+// NewEngine creates an engine for rules, checking that their expressions
+// use only the variables and functions the engine provides
+func NewEngine(rules []Rule, services *service.ServiceManager, recorder *audit.Recorder, logger *slog.Logger) (*Engine, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	e := &Engine{
+		rules:     rules,
+		services:  services,
+		audit:     recorder,
+		logger:    logger,
+		events:    make(chan audit.Event, 64),
+		lastFired: make(map[string]time.Time),
+		now:       time.Now,
+	}
+
+	env := e.env(context.Background(), audit.Event{})
+	for _, rule := range rules {
+		if err := rule.When.Check(env); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+	}
+	return e, nil
+}
+
+// Enqueue hands an audit event to the engine without blocking; it is meant
+// to be subscribed to the audit recorder. Events of fired rules are
+// ignored so rules cannot trigger each other.
+func (e *Engine) Enqueue(event audit.Event) {
+	if event.Type == audit.EventRuleFired {
+		return
+	}
+	select {
+	case e.events <- event:
+	default:
+		e.logger.Warn("rule engine queue full, dropping event", "event", event.Type)
+	}
+}
+
+// Run evaluates the rules on each queued event and every interval until
+// ctx is cancelled
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-e.events:
+			e.evaluate(ctx, event)
+		case <-ticker.C:
+			e.evaluate(ctx, audit.Event{Type: "tick", Time: e.now()})
+		}
+	}
+}
+
+// evaluate checks every rule against event and fires those that hold
+func (e *Engine) evaluate(ctx context.Context, event audit.Event) {
+	env := e.env(ctx, event)
+	now := e.now()
+	for _, rule := range e.rules {
+		if last, ok := e.lastFired[rule.Name]; ok && now.Sub(last) < rule.Cooldown {
+			continue
+		}
+		holds, err := rule.When.Eval(env)
+		if err != nil {
+			e.logger.WarnContext(ctx, "failed to evaluate rule", "rule", rule.Name, "event", event.Type, "error", err)
+			continue
+		}
+		if holds {
+			e.lastFired[rule.Name] = now
+			e.fire(ctx, rule, event)
+		}
+	}
+}
+
+// fire runs a rule's actions and records a rule.fired event
+func (e *Engine) fire(ctx context.Context, rule Rule, trigger audit.Event) {
+	e.logger.InfoContext(ctx, "rule fired", "rule", rule.Name, "event", trigger.Type)
+
+	actions := make([]string, 0, len(rule.Actions))
+	var failed []string
+	for _, action := range rule.Actions {
+		actions = append(actions, action.String())
+		if err := e.run(ctx, action); err != nil {
+			e.logger.ErrorContext(ctx, "rule action failed", "rule", rule.Name, "action", action.String(), "error", err)
+			failed = append(failed, action.String())
+		}
+	}
+
+	message := fmt.Sprintf("rule %s fired", rule.Name)
+	if len(actions) > 0 {
+		message += ": " + strings.Join(actions, ", ")
+	}
+	fields := map[string]any{"rule": rule.Name, "trigger": trigger.Type, "actions": actions}
+	if len(failed) > 0 {
+		fields["failed"] = failed
+	}
+	event := audit.Event{
+		Type:       audit.EventRuleFired,
+		User:       "rule:" + rule.Name,
+		Message:    message,
+		Fields:     fields,
+		Suppressed: !rule.Notify,
+	}
+	if len(rule.Actions) > 0 {
+		event.Service = rule.Actions[0].Service
+	} else {
+		event.Service = trigger.Service
+	}
+	e.audit.Record(event)
+}
+
+// run performs one action
+func (e *Engine) run(ctx context.Context, action Action) error {
+	act := map[string]func(context.Context, string) (service.ServiceStatus, error){
+		"start":   e.services.StartService,
+		"stop":    e.services.StopService,
+		"restart": e.services.RestartService,
+	}[action.Action]
+	status, err := act(ctx, action.Service)
+	if status.Refused != "" {
+		return errors.New(status.Refused)
+	}
+	if status.Failure != nil {
+		return fmt.Errorf("%s %s: %s", action.Action, action.Service, status.Failure.Message)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", action.Action, action.Service, err)
+	}
+	return nil
+}
+
+// env returns the variables and functions available to rule expressions
+func (e *Engine) env(ctx context.Context, event audit.Event) expr.Env {
+	now := e.now()
+	return expr.Env{
+		Vars: map[string]any{
+			"event.type":    event.Type,
+			"event.service": strings.TrimSuffix(event.Service, ".service"),
+			"event.user":    event.User,
+			"hour":          now.Hour(),
+			"minute":        now.Minute(),
+			"weekday":       strings.ToLower(now.Weekday().String()[:3]),
+		},
+		Funcs: map[string]expr.Func{
+			"state": e.serviceFunc(ctx, func(status service.ServiceStatus) any {
+				return string(status.Status)
+			}),
+			"state_for": e.serviceFunc(ctx, func(status service.ServiceStatus) any {
+				return e.stateFor(status)
+			}),
+			"flapping": func(args []any) (any, error) {
+				name, err := serviceArg(args)
+				if err != nil {
+					return nil, err
+				}
+				return e.services.IsFlapping(name), nil
+			},
+			"in_maintenance": func(args []any) (any, error) {
+				name, err := serviceArg(args)
+				if err != nil {
+					return nil, err
+				}
+				_, open := e.services.InMaintenance(name, now)
+				return open, nil
+			},
+			"between": func(args []any) (any, error) {
+				return between(args, now)
+			},
+		},
+	}
+}
+
+// serviceFunc builds an expression function taking a service name and
+// returning a value derived from its current status
+func (e *Engine) serviceFunc(ctx context.Context, value func(service.ServiceStatus) any) expr.Func {
+	return func(args []any) (any, error) {
+		name, err := serviceArg(args)
+		if err != nil {
+			return nil, err
+		}
+		status, err := e.services.GetServiceStatus(ctx, name)
+		if errors.Is(err, service.ErrNotAllowed) {
+			return nil, fmt.Errorf("service %s is not allowed", name)
+		}
+		return value(status), nil
+	}
+}
+
+// stateFor returns how long a unit has been in its current state, or zero
+// when unknown
+func (e *Engine) stateFor(status service.ServiceStatus) time.Duration {
+	if status.Since != nil {
+		return e.now().Sub(*status.Since)
+	}
+	if history := e.services.History(status.Name); len(history) > 0 {
+		if last := history[len(history)-1]; last.Status == status.Status {
+			return e.now().Sub(last.At)
+		}
+	}
+	return 0
+}
+
+// serviceArg reads the single service name argument of a function
+func serviceArg(args []any) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return "", errors.New("expected a service name")
+	}
+	return config.NormalizeServiceName(name), nil
+}
+
+// between reports whether now's local time of day lies in [from, to),
+// given as "HH:MM"; ranges wrap past midnight when to is before from
+func between(args []any, now time.Time) (bool, error) {
+	if len(args) != 2 {
+		return false, fmt.Errorf("expected 2 arguments, got %d", len(args))
+	}
+	var minutes [2]int
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return false, errors.New("expected times like \"02:00\"")
+		}
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			return false, fmt.Errorf("invalid time %q: expected HH:MM", s)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+
+	current := now.Hour()*60 + now.Minute()
+	from, to := minutes[0], minutes[1]
+	if from <= to {
+		return current >= from && current < to, nil
+	}
+	return current >= from || current < to, nil
+}
@@ -0,0 +1,193 @@
+// internal/schedule/schedule.go
+package schedule
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression:
+// minute hour day-of-month month day-of-week
+type Schedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// aliases maps the @-shorthands to their cron expressions
+var aliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// names maps month and weekday names to their numbers
+var names = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// Parse parses a cron expression such as "30 3 * * sat,sun" or an alias
+// like "@daily". Fields accept *, lists, ranges, steps and month or
+// weekday names; 7 is also Sunday.
+func Parse(expr string) (Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if alias, ok := aliases[strings.ToLower(spec)]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: expected 5 fields", expr)
+	}
+
+	s := Schedule{expr: expr}
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: minute: %w", expr, err)
+	}
+	if s.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: hour: %w", expr, err)
+	}
+	if s.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: day of month: %w", expr, err)
+	}
+	if s.month, err = parseField(fields[3], 1, 12); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: month: %w", expr, err)
+	}
+	if s.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: day of week: %w", expr, err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+	return s, nil
+}
+
+// parseField parses one cron field into a bit set of allowed values
+func parseField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		start, end := lo, hi
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseValue(from, lo, hi); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = parseValue(to, lo, hi); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = hi
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a number or a month/weekday name within [lo, hi]
+func parseValue(value string, lo, hi int) (int, error) {
+	n, ok := names[strings.ToLower(value)]
+	if !ok {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil {
+			return 0, fmt.Errorf("invalid value %q", value)
+		}
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, lo, hi)
+	}
+	return n, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s Schedule) String() string {
+	return s.expr
+}
+
+// Matches reports whether the schedule fires in the minute containing t.
+// As in cron, a restricted day of month and day of week match either.
+func (s Schedule) Matches(t time.Time) bool {
+	return s.minute&(1<<t.Minute()) != 0 && s.hour&(1<<t.Hour()) != 0 &&
+		s.month&(1<<int(t.Month())) != 0 && s.dayMatches(t)
+}
+
+// dayMatches reports whether the schedule fires on t's day
+func (s Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<t.Day()) != 0
+	dowMatch := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Prev returns the latest minute at or before t in which the schedule
+// fires, if it is not before since. Months, days and hours that do not
+// match are skipped whole, so the search stays short however far back
+// since is.
+func (s Schedule) Prev(t, since time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for !t.Before(since) {
+		year, month, day := t.Date()
+		loc := t.Location()
+		// Stepping back within the hour, not through time.Date, keeps
+		// the hour that repeats when clocks fall back apart
+		hour := t.Add(-time.Duration(t.Minute()) * time.Minute)
+		switch {
+		case s.month&(1<<int(month)) == 0:
+			// The last minute of the previous month
+			t = time.Date(year, month, 1, 0, 0, 0, 0, loc).Add(-time.Minute)
+		case !s.dayMatches(t):
+			t = time.Date(year, month, day, 0, 0, 0, 0, loc).Add(-time.Minute)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = hour.Add(-time.Minute)
+		default:
+			// The latest matching minute of this hour up to t's
+			earlier := s.minute & (1<<(t.Minute()+1) - 1)
+			if earlier == 0 {
+				t = hour.Add(-time.Minute)
+				continue
+			}
+			start := hour.Add(time.Duration(bits.Len64(earlier)-1) * time.Minute)
+			return start, !start.Before(since)
+		}
+	}
+	return time.Time{}, false
+}
+
+// ActiveAt reports whether t falls within duration of a time the schedule
+// fired, i.e. inside a window opened by the schedule
+func (s Schedule) ActiveAt(t time.Time, duration time.Duration) bool {
+	start, ok := s.Prev(t, t.Add(-duration))
+	return ok && t.Sub(start) < duration
+}
@@ -0,0 +1,69 @@
+// internal/schedule/schedule_test.go
+package schedule
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// activeByMinute is ActiveAt checking every minute of the window
+func activeByMinute(s Schedule, t time.Time, duration time.Duration) bool {
+	for start := t.Truncate(time.Minute); t.Sub(start) < duration; start = start.Add(-time.Minute) {
+		if s.Matches(start) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestActiveAtMatchesEveryMinute(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	exprs := []string{
+		"30 3 * * sat,sun", "@daily", "@hourly", "*/15 * * * *", "0 22 * * 1-5",
+		"0 0 1 * *", "0 12 15 * fri", "45 2 * 3 sun", "0 0 29 2 *", "5,50 */3 * jan,jul *",
+	}
+	durations := []time.Duration{0, 30 * time.Second, time.Minute, 20 * time.Minute, 2 * time.Hour, 36 * time.Hour, 168 * time.Hour}
+	// Around the spring and autumn clock changes and elsewhere in the year
+	starts := []time.Time{
+		time.Date(2026, 3, 29, 1, 30, 0, 0, berlin),
+		time.Date(2026, 10, 25, 1, 30, 0, 0, berlin),
+		time.Date(2028, 2, 28, 23, 0, 0, 0, time.UTC),
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, expr := range exprs {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, start := range starts {
+			for range 200 {
+				at := start.Add(time.Duration(rng.Int64N(int64(96 * time.Hour))))
+				for _, d := range durations {
+					if got, want := s.ActiveAt(at, d), activeByMinute(s, at, d); got != want {
+						t.Errorf("%q at %v for %v: ActiveAt %v, want %v", expr, at, d, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPrev(t *testing.T) {
+	s, err := Parse("30 3 * * sat,sun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wednesday; the last firing was Sunday
+	at := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 10, 11, 3, 30, 0, 0, time.UTC)
+	if got, ok := s.Prev(at, at.Add(-168*time.Hour)); !ok || !got.Equal(want) {
+		t.Errorf("Prev = %v, %v, want %v", got, ok, want)
+	}
+	if _, ok := s.Prev(at, at.Add(-24*time.Hour)); ok {
+		t.Error("Prev found a firing within the last day")
+	}
+}
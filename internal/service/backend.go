@@ -0,0 +1,118 @@
+// internal/service/backend.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Backend drives an init system. ServiceManager layers the allowlist,
+// per-unit locking, dry-run, history and events on top, so a backend only
+// has to run commands and report state.
+type Backend interface {
+	// Name identifies the backend in logs and errors
+	Name() string
+	// Status reports the current state of a unit
+	Status(ctx context.Context, unit string) (UnitState, error)
+	// Start, Stop and Restart act on a unit and return once the init
+	// system accepted the request or timeout passed
+	Start(ctx context.Context, unit string, timeout time.Duration) error
+	Stop(ctx context.Context, unit string, timeout time.Duration) error
+	Restart(ctx context.Context, unit string, timeout time.Duration) error
+	// List reports every unit the init system knows about
+	List(ctx context.Context) ([]UnitState, error)
+	// Logs returns up to lines recent log lines of a unit, oldest first
+	Logs(ctx context.Context, unit string, lines int) ([]string, error)
+}
+
+// UnitState is a unit's state as reported by a backend
+type UnitState struct {
+	Name  string
+	State State
+	// Raw is the state in the backend's own vocabulary, if it has one
+	Raw string
+	// Restarts counts automatic restarts by the init system, if known
+	Restarts int
+	// Since is when the unit entered State, if known
+	Since *time.Time
+}
+
+// Commander is implemented by backends that can show the command an action
+// would run, which dry-run reports
+type Commander interface {
+	Command(action, unit string) string
+}
+
+// Pinger is implemented by backends with a cheap reachability check;
+// others are probed with List
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// UsageReporter is implemented by backends that report resource usage
+type UsageReporter interface {
+	// Usage returns cumulative CPU time in nanoseconds (cpuValid is false
+	// when unavailable) and current memory use in bytes
+	Usage(ctx context.Context, unit string) (cpuNsec uint64, cpuValid bool, memory uint64, err error)
+}
+
+// unsupported reports a systemd-only feature used with another backend
+func unsupported(backend Backend, feature string) error {
+	return fmt.Errorf("%w: %s need the systemd backend, not %s", errors.ErrUnsupported, feature, backend.Name())
+}
+
+// SetBackend replaces the init-system backend; the default is systemd
+func (sm *ServiceManager) SetBackend(backend Backend) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.backend = backend
+}
+
+// Backend returns the init-system backend
+func (sm *ServiceManager) Backend() Backend {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.backend
+}
+
+// route returns the backend responsible for a unit and the unit's name
+// within it
+func (sm *ServiceManager) route(unit string) (Backend, string) {
+	backend := sm.Backend()
+	if multi, ok := backend.(*MultiBackend); ok {
+		return multi.route(unit)
+	}
+	return backend, unit
+}
+
+// backendOf returns the backend responsible for a unit
+func (sm *ServiceManager) backendOf(unit string) Backend {
+	backend, _ := sm.route(unit)
+	return backend
+}
+
+// systemd returns the systemd backend when it is responsible for unit, for
+// features only systemd provides. Namespaced units never qualify.
+func (sm *ServiceManager) systemd(unit string) (*SystemdBackend, bool) {
+	backend, local := sm.route(unit)
+	sd, ok := backend.(*SystemdBackend)
+	return sd, ok && local == unit
+}
+
+// backendLabel names the backend of a unit when several are configured
+func (sm *ServiceManager) backendLabel(unit string) string {
+	if multi, ok := sm.Backend().(*MultiBackend); ok {
+		return multi.Label(unit)
+	}
+	return ""
+}
+
+// command describes an action for dry-run output
+func (sm *ServiceManager) command(action, unit string) string {
+	if c, ok := sm.Backend().(Commander); ok {
+		return c.Command(action, unit)
+	}
+	return action + " " + unit
+}
@@ -0,0 +1,26 @@
+// internal/service/command.go
+package service
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// commandWaitDelay bounds how long a killed command may keep its output
+// pipes open, e.g. through a child it left behind
+const commandWaitDelay = 2 * time.Second
+
+// newCommand is exec.CommandContext for init-system tools. The command runs
+// in its own process group, and cancelling ctx kills the whole group, so
+// helpers such as the compose plugin do not outlive an abandoned request.
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
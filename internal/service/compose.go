@@ -0,0 +1,220 @@
+// internal/service/compose.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ComposeBackend treats Compose projects as services: each subdirectory
+// of a stacks directory holding a compose file is one stack, started with
+// "compose up -d" and stopped with "compose down"
+type ComposeBackend struct {
+	command   []string
+	stacksDir string
+	logger    *slog.Logger
+}
+
+// NewComposeBackend creates a backend for the stacks under stacksDir,
+// run with command such as "docker compose" or "podman-compose"
+func NewComposeBackend(command, stacksDir string, logger *slog.Logger) *ComposeBackend {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		fields = []string{"docker", "compose"}
+	}
+	return &ComposeBackend{command: fields, stacksDir: stacksDir, logger: logger}
+}
+
+// composeFiles are the file names compose looks for in a project directory
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// composeContainer is the part of "compose ps" output the backend reads
+type composeContainer struct {
+	Service  string `json:"Service"`
+	State    string `json:"State"`
+	ExitCode int    `json:"ExitCode"`
+}
+
+// Name implements Backend
+func (cb *ComposeBackend) Name() string {
+	return "compose"
+}
+
+// Status implements Backend by aggregating the states of the stack's
+// containers
+func (cb *ComposeBackend) Status(ctx context.Context, unit string) (UnitState, error) {
+	dir, err := cb.dir(unit)
+	if err != nil {
+		return UnitState{}, err
+	}
+	output, err := cb.compose(ctx, queryTimeout, dir, "ps", "--all", "--format", "json")
+	if err != nil {
+		return UnitState{}, err
+	}
+	containers, err := parseComposePs(output)
+	if err != nil {
+		return UnitState{}, err
+	}
+	return UnitState{Name: unit, State: composeState(containers)}, nil
+}
+
+// Start implements Backend
+func (cb *ComposeBackend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	return cb.control(ctx, unit, timeout, "up", "--detach")
+}
+
+// Stop implements Backend
+func (cb *ComposeBackend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	return cb.control(ctx, unit, timeout, "down")
+}
+
+// Restart implements Backend
+func (cb *ComposeBackend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	return cb.control(ctx, unit, timeout, "restart")
+}
+
+// control runs a compose command in the stack's directory
+func (cb *ComposeBackend) control(ctx context.Context, unit string, timeout time.Duration, args ...string) error {
+	dir, err := cb.dir(unit)
+	if err != nil {
+		return err
+	}
+	_, err = cb.compose(ctx, timeout, dir, args...)
+	return err
+}
+
+// List implements Backend with every stack in the stacks directory
+func (cb *ComposeBackend) List(ctx context.Context) ([]UnitState, error) {
+	entries, err := os.ReadDir(cb.stacksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []UnitState
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		state, err := cb.Status(ctx, entry.Name()+".service")
+		if err != nil {
+			continue
+		}
+		units = append(units, state)
+	}
+	return units, nil
+}
+
+// Logs implements Backend with the recent logs of all containers
+func (cb *ComposeBackend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	dir, err := cb.dir(unit)
+	if err != nil {
+		return nil, err
+	}
+	output, err := cb.compose(ctx, queryTimeout, dir, "logs", "--no-color", "--timestamps", "--tail", fmt.Sprint(lines))
+	if err != nil || output == "" {
+		return nil, err
+	}
+	logs := strings.Split(output, "\n")
+	// Lines of several containers are not interleaved by time
+	if len(logs) > lines {
+		logs = logs[len(logs)-lines:]
+	}
+	return logs, nil
+}
+
+// Command implements Commander
+func (cb *ComposeBackend) Command(action, unit string) string {
+	args := map[string]string{"start": "up --detach", "stop": "down"}
+	if mapped, ok := args[action]; ok {
+		action = mapped
+	}
+	return "cd " + filepath.Join(cb.stacksDir, supervisedName(unit)) + " && " + strings.Join(cb.command, " ") + " " + action
+}
+
+// dir returns the project directory of a stack, checking it holds a
+// compose file
+func (cb *ComposeBackend) dir(unit string) (string, error) {
+	dir := filepath.Join(cb.stacksDir, supervisedName(unit))
+	for _, name := range composeFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no compose file in %s", ErrUnitNotFound, dir)
+}
+
+// compose runs the compose command in dir
+func (cb *ComposeBackend) compose(ctx context.Context, timeout time.Duration, dir string, args ...string) (string, error) {
+	args = append(append([]string(nil), cb.command[1:]...), args...)
+	return runToolIn(ctx, cb.logger, timeout, dir, cb.command[0], args...)
+}
+
+// parseComposePs parses "compose ps --format json", which is a JSON array
+// in older releases and one object per line in newer ones
+func parseComposePs(output string) ([]composeContainer, error) {
+	var containers []composeContainer
+	if output == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(output, "[") {
+		if err := json.Unmarshal([]byte(output), &containers); err != nil {
+			return nil, fmt.Errorf("parse compose ps output: %w", err)
+		}
+		return containers, nil
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var c composeContainer
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return nil, fmt.Errorf("parse compose ps output: %w", err)
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
+// composeState aggregates container states: a stack without running
+// containers is stopped, or failed if one crashed or keeps restarting. One
+// with running containers is running, degraded when others crashed, or
+// starting while others are still being created.
+func composeState(containers []composeContainer) State {
+	running, pending, crashed := 0, 0, 0
+	for _, c := range containers {
+		switch c.State {
+		case "running":
+			running++
+		case "restarting", "dead":
+			crashed++
+		case "exited":
+			// One-shot containers exit cleanly
+			if c.ExitCode != 0 {
+				crashed++
+			}
+		case "created", "starting":
+			pending++
+		}
+	}
+	switch {
+	case running > 0 && crashed > 0:
+		return StateDegraded
+	case crashed > 0:
+		return StateFailed
+	case running > 0 && pending > 0:
+		return StateStarting
+	case running > 0:
+		return StateRunning
+	default:
+		return StateStopped
+	}
+}
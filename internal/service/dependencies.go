@@ -0,0 +1,65 @@
+// internal/service/dependencies.go
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// Dependency is a node in a unit's dependency tree
+type Dependency struct {
+	Unit     string       `json:"unit"`
+	Children []Dependency `json:"children,omitempty"`
+}
+
+// Dependencies returns the dependency tree of an allowed service as shown
+// by systemctl list-dependencies. With reverse set it lists the units that
+// depend on the service instead, which are the ones a stop drags down.
+func (sm *ServiceManager) Dependencies(ctx context.Context, serviceName string, reverse bool) (Dependency, error) {
+	if !sm.validateService(serviceName) {
+		return Dependency{}, ErrNotAllowed
+	}
+	sd, ok := sm.systemd(serviceName)
+	if !ok {
+		return Dependency{}, unsupported(sm.backendOf(serviceName), "dependency trees")
+	}
+
+	args := []string{"--user", "list-dependencies", "--plain", "--no-pager"}
+	if reverse {
+		args = append(args, "--reverse")
+	}
+	output, err := sd.Output(ctx, "systemctl", append(args, serviceName)...)
+	if err != nil && output == "" {
+		return Dependency{}, err
+	}
+	return parseDependencyTree(output, serviceName), nil
+}
+
+// parseDependencyTree builds a tree from list-dependencies --plain output,
+// where each level is indented by two more spaces than its parent
+func parseDependencyTree(output, root string) Dependency {
+	tree := Dependency{Unit: root}
+	// stack[i] is the most recent node at depth i
+	stack := []*Dependency{&tree}
+
+	lines := strings.Split(output, "\n")
+	for _, line := range lines[1:] {
+		unit := strings.TrimLeftFunc(line, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '●' || r == '○'
+		})
+		if unit == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		depth := max(indent/2, 1)
+		if depth > len(stack) {
+			depth = len(stack)
+		}
+
+		parent := stack[depth-1]
+		parent.Children = append(parent.Children, Dependency{Unit: strings.TrimSpace(unit)})
+		stack = append(stack[:depth], &parent.Children[len(parent.Children)-1])
+	}
+	return tree
+}
@@ -0,0 +1,80 @@
+// internal/service/details.go
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// maxHistory caps the number of state transitions kept per unit
+const maxHistory = 200
+
+// Transition is an observed change of a unit's state
+type Transition struct {
+	At     time.Time `json:"at"`
+	Status State     `json:"status"`
+}
+
+// Details is the extended information shown on a service's detail page
+type Details struct {
+	ServiceStatus
+	// StatusText is the output of systemctl status
+	StatusText string
+	// Journal holds the most recent journal lines, oldest first
+	Journal []string
+	// UnitFile is the unit file including drop-ins, as shown by systemctl cat
+	UnitFile string
+	// Dependencies is the tree of units this unit depends on
+	Dependencies Dependency
+	// RequiredBy is the tree of units depending on this unit
+	RequiredBy Dependency
+	// History lists observed state transitions, oldest first
+	History []Transition
+}
+
+// GetServiceDetails collects status output, recent journal lines, the unit
+// file and dependencies of an allowed service. Sections that fail to load
+// are left empty.
+func (sm *ServiceManager) GetServiceDetails(ctx context.Context, serviceName string, journalLines int) (Details, error) {
+	if !sm.validateService(serviceName) {
+		sm.logger.WarnContext(ctx, "attempted to view details of non-allowed service",
+			"service", serviceName)
+		return Details{}, ErrNotAllowed
+	}
+
+	var details Details
+	details.ServiceStatus, _ = sm.GetServiceStatus(ctx, serviceName)
+
+	details.Journal, _ = sm.Backend().Logs(ctx, serviceName, journalLines)
+
+	// Status text, unit file and dependencies are systemd-specific
+	if sd, ok := sm.systemd(serviceName); ok {
+		// systemctl status exits non-zero for inactive units but still prints
+		details.StatusText, _ = sd.Output(ctx, "systemctl", "--user", "status", "--no-pager", "--lines=0", serviceName)
+		details.UnitFile, _ = sd.Output(ctx, "systemctl", "--user", "cat", "--no-pager", serviceName)
+		details.Dependencies, _ = sm.Dependencies(ctx, serviceName, false)
+		details.RequiredBy, _ = sm.Dependencies(ctx, serviceName, true)
+	}
+
+	details.History = sm.History(serviceName)
+	return details, nil
+}
+
+// History returns the observed state transitions of a unit, oldest first
+func (sm *ServiceManager) History(serviceName string) []Transition {
+	sm.observedMu.Lock()
+	defer sm.observedMu.Unlock()
+	history := make([]Transition, len(sm.history[serviceName]))
+	copy(history, sm.history[serviceName])
+	return history
+}
+
+// recordTransition appends a state transition to a unit's history; the
+// caller must hold observedMu
+func (sm *ServiceManager) recordTransition(serviceName string, status State) {
+	history := append(sm.history[serviceName], Transition{At: time.Now(), Status: status})
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	sm.history[serviceName] = history
+}
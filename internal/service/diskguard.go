@@ -0,0 +1,60 @@
+// internal/service/diskguard.go
+package service
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// FreeSpace requires Min bytes free on the filesystem holding Path before
+// a service may start
+type FreeSpace struct {
+	Path string
+	Min  uint64
+}
+
+// InsufficientSpaceError refuses a start because a filesystem is too full
+type InsufficientSpaceError struct {
+	Service string
+	Path    string
+	Free    uint64
+	Min     uint64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("not starting %s: %s has %s free, needs at least %s",
+		e.Service, e.Path, formatSize(e.Free), formatSize(e.Min))
+}
+
+// checkFreeSpace verifies the free space preconditions of serviceName
+func (sm *ServiceManager) checkFreeSpace(serviceName string) error {
+	sm.mu.RLock()
+	checks := sm.metadata[serviceName].MinFree
+	sm.mu.RUnlock()
+
+	for _, check := range checks {
+		var fs syscall.Statfs_t
+		if err := syscall.Statfs(check.Path, &fs); err != nil {
+			return fmt.Errorf("not starting %s: checking free space on %s: %w", serviceName, check.Path, err)
+		}
+		free := fs.Bavail * uint64(fs.Bsize)
+		if free < check.Min {
+			return &InsufficientSpaceError{Service: serviceName, Path: check.Path, Free: free, Min: check.Min}
+		}
+	}
+	return nil
+}
+
+// formatSize renders a byte count in decimal units like "9.8 GB"
+func formatSize(n uint64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
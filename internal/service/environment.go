@@ -0,0 +1,133 @@
+// internal/service/environment.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// envDropIn is the runtime drop-in holding a unit's environment overrides
+const envDropIn = "50-sysdwitch-env.conf"
+
+// freeEnvValue restricts override values when a key has no fixed choices
+var freeEnvValue = regexp.MustCompile(`^[A-Za-z0-9._:/@+-]*$`)
+
+// environmentKey carries environment overrides for a start action
+type environmentKey struct{}
+
+// WithEnvironment returns a context under which StartService starts the
+// unit with the given environment overrides
+func WithEnvironment(ctx context.Context, env map[string]string) context.Context {
+	return context.WithValue(ctx, environmentKey{}, env)
+}
+
+// EnvironmentFrom returns the overrides set by WithEnvironment
+func EnvironmentFrom(ctx context.Context) map[string]string {
+	env, _ := ctx.Value(environmentKey{}).(map[string]string)
+	return env
+}
+
+// ValidateEnvironment checks overrides against the service's whitelist
+func (sm *ServiceManager) ValidateEnvironment(serviceName string, env map[string]string) error {
+	sm.mu.RLock()
+	options := sm.metadata[serviceName].EnvOptions
+	sm.mu.RUnlock()
+
+	for key, value := range env {
+		allowed, ok := options[key]
+		if !ok {
+			return fmt.Errorf("environment override %s is not allowed for %s", key, serviceName)
+		}
+		if len(allowed) > 0 && !slices.Contains(allowed, value) {
+			return fmt.Errorf("value %q is not allowed for %s", value, key)
+		}
+		if len(allowed) == 0 && !freeEnvValue.MatchString(value) {
+			return fmt.Errorf("value for %s contains unsupported characters", key)
+		}
+	}
+	return nil
+}
+
+// envDropInPath returns where the environment drop-in of a unit lives.
+// Runtime drop-ins are cleared on reboot.
+func envDropInPath(serviceName string) (string, error) {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set")
+	}
+	return filepath.Join(runtimeDir, "systemd", "user", serviceName+".d", envDropIn), nil
+}
+
+// renderEnvDropIn renders overrides as a [Service] section, sorted by key
+func renderEnvDropIn(env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# Written by sysdwitch; replaced on every start\n[Service]\n")
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(env[key])
+		fmt.Fprintf(&b, "Environment=\"%s=%s\"\n", key, value)
+	}
+	return b.String()
+}
+
+// applyEnvironment writes (or, without overrides, removes) the unit's
+// environment drop-in and reloads systemd when it changed. Services
+// without whitelisted overrides are left alone.
+func (sm *ServiceManager) applyEnvironment(ctx context.Context, serviceName string, env map[string]string) error {
+	sm.mu.RLock()
+	configured := len(sm.metadata[serviceName].EnvOptions) > 0
+	sm.mu.RUnlock()
+	if !configured {
+		return nil
+	}
+	sd, ok := sm.systemd(serviceName)
+	if !ok {
+		return unsupported(sm.backendOf(serviceName), "environment overrides")
+	}
+
+	path, err := envDropInPath(serviceName)
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(env) == 0 {
+		if current == nil {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	} else {
+		content := renderEnvDropIn(env)
+		if string(current) == content {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+
+	sm.logger.InfoContext(ctx, "updated environment overrides",
+		"service", serviceName,
+		"keys", len(env))
+	return sd.DaemonReload(ctx)
+}
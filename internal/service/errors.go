@@ -0,0 +1,74 @@
+// internal/service/errors.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned alongside a ServiceStatus; match them with errors.Is
+var (
+	// ErrNotAllowed is returned for services outside the allowlist
+	ErrNotAllowed = errors.New("service not allowed")
+	// ErrUnitNotFound is returned when the init system has no such unit
+	ErrUnitNotFound = errors.New("unit not found")
+	// ErrTimeout is returned when the init system did not answer in time
+	ErrTimeout = errors.New("timed out")
+	// ErrBackend is returned for other failures of the init system
+	ErrBackend = errors.New("backend error")
+	// ErrCanceled is returned when an action was canceled, by its caller
+	// going away or through CancelOperation
+	ErrCanceled = errors.New("canceled")
+)
+
+// notFoundMessages are stderr fragments of init-system tools for units
+// that do not exist
+var notFoundMessages = []string{
+	"not found",      // systemctl: Unit x.service not found.
+	"not loaded",     // systemctl: Unit x.service not loaded.
+	"does not exist", // rc-service: service `x' does not exist; sv: file does not exist
+	"no such file",   // s6-svstat: unable to read status for x: No such file or directory
+}
+
+// backendError classifies an error of a backend as ErrUnitNotFound,
+// ErrTimeout or ErrBackend, keeping the original in the chain
+func backendError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrUnitNotFound), errors.Is(err, ErrTimeout), errors.Is(err, ErrBackend), errors.Is(err, ErrCanceled):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		if cmdErr.TimedOut {
+			return fmt.Errorf("%w: %w", ErrTimeout, err)
+		}
+		if cmdErr.Canceled != nil {
+			return fmt.Errorf("%w: %w", ErrCanceled, err)
+		}
+		stderr := strings.ToLower(cmdErr.Stderr)
+		for _, msg := range notFoundMessages {
+			if strings.Contains(stderr, msg) {
+				return fmt.Errorf("%w: %w", ErrUnitNotFound, err)
+			}
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrBackend, err)
+}
+
+// contextError classifies the error of a done ctx as ErrTimeout or
+// ErrCanceled with its cause, such as a cancellation by an administrator
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, context.Cause(ctx))
+	}
+	return fmt.Errorf("%w: %w", ErrCanceled, context.Cause(ctx))
+}
@@ -0,0 +1,59 @@
+// internal/service/exclusive.go
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// exclusiveGroup returns the exclusive group of serviceName, if any
+func (sm *ServiceManager) exclusiveGroup(serviceName string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.metadata[serviceName].Exclusive
+}
+
+// runningConflicts returns the other members of serviceName's exclusive
+// group that are not stopped
+func (sm *ServiceManager) runningConflicts(ctx context.Context, serviceName string) []string {
+	sm.mu.RLock()
+	group := sm.metadata[serviceName].Exclusive
+	var members []string
+	if group != "" {
+		for _, name := range sm.order {
+			if name != serviceName && sm.metadata[name].Exclusive == group {
+				members = append(members, name)
+			}
+		}
+	}
+	sm.mu.RUnlock()
+
+	var running []string
+	for _, name := range members {
+		status, _ := sm.RefreshStatus(ctx, name)
+		switch status.Status {
+		case StateStopped, StateFailed, StateNotInstalled:
+		default:
+			running = append(running, name)
+		}
+	}
+	return running
+}
+
+// stopConflicts stops services sharing an exclusive group with
+// serviceName before it starts
+func (sm *ServiceManager) stopConflicts(ctx context.Context, serviceName string, conflicts []string) error {
+	for _, name := range conflicts {
+		sm.logger.InfoContext(ctx, "stopping service in exclusive group",
+			"service", name,
+			"starting", serviceName,
+			"group", sm.exclusiveGroup(name))
+		if _, err := sm.StopService(ctx, name); err != nil {
+			sm.logger.ErrorContext(ctx, "not starting service: conflicting service did not stop",
+				"service", serviceName,
+				"conflict", name)
+			return fmt.Errorf("stop %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// internal/service/failed.go
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// FailedUnit describes a failed user unit for triage
+type FailedUnit struct {
+	Unit        string `json:"unit"`
+	Description string `json:"description,omitempty"`
+	// Allowed is set for units in the allowlist, which can be controlled
+	Allowed bool `json:"allowed"`
+	// Result is systemd's reason for the failure, e.g. exit-code, signal,
+	// timeout or core-dump
+	Result string `json:"result,omitempty"`
+	// ExitCode is the main process's exit status, or the signal number
+	// when it was killed
+	ExitCode int `json:"exit_code"`
+	// ExitReason is how the main process ended: exited, killed or dumped
+	ExitReason string     `json:"exit_reason,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+	// Journal holds the most recent journal lines, oldest first
+	Journal []string `json:"journal,omitempty"`
+}
+
+// exitReasons maps ExecMainCode (a CLD_* code) to a readable reason
+var exitReasons = map[string]string{"1": "exited", "2": "killed", "3": "dumped"}
+
+// FailedUnits lists every failed user unit, including units outside the
+// allowlist, with the reason it failed and its recent journal lines. It
+// only reads state and never acts on the units.
+func (sm *ServiceManager) FailedUnits(ctx context.Context, journalLines int) ([]FailedUnit, error) {
+	all, err := sm.Backend().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	units := []FailedUnit{}
+	for _, unit := range all {
+		if unit.State != StateFailed {
+			continue
+		}
+		units = append(units, sm.failedUnit(ctx, unit, journalLines))
+	}
+	return units, nil
+}
+
+// failedUnit collects the triage details of a single failed unit
+func (sm *ServiceManager) failedUnit(ctx context.Context, unit UnitState, journalLines int) FailedUnit {
+	failed := FailedUnit{Unit: unit.Name, Allowed: sm.validateService(unit.Name), Since: unit.Since}
+
+	// Only systemd explains why a unit failed
+	if sd, ok := sm.systemd(unit.Name); ok {
+		if props, err := sd.Show(ctx, unit.Name, "Description", "Result", "ExecMainCode", "ExecMainStatus", "StateChangeTimestamp"); err == nil {
+			failed.Description = props["Description"]
+			failed.Result = props["Result"]
+			failed.ExitCode, _ = strconv.Atoi(props["ExecMainStatus"])
+			failed.ExitReason = exitReasons[props["ExecMainCode"]]
+			failed.Since = parseTimestamp(props["StateChangeTimestamp"])
+		}
+	}
+
+	failed.Journal, _ = sm.Backend().Logs(ctx, unit.Name, journalLines)
+	return failed
+}
@@ -0,0 +1,146 @@
+// internal/service/failure.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxFailureStderr caps the stderr kept in a Failure
+const maxFailureStderr = 4 << 10
+
+// statusExcerptLines is how many log lines the systemctl status excerpt
+// of a failed action shows
+const statusExcerptLines = 5
+
+// CommandError is returned when an init-system tool exits non-zero
+type CommandError struct {
+	Command string
+	// ExitCode is the tool's exit status, or -1 when it did not exit
+	// normally, e.g. when it was killed on timeout
+	ExitCode int
+	Stderr   string
+	// TimedOut is set when the tool was killed for running too long
+	TimedOut bool
+	// Canceled is the cause when the tool was killed because its action
+	// was canceled
+	Canceled error
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	if e.Stderr == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Stderr
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandError wraps the error of a command that ran, or failed to, under
+// ctx
+func commandError(ctx context.Context, name string, args []string, err error, stderr string) error {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	cmdErr := &CommandError{
+		Command:  strings.Join(append([]string{name}, args...), " "),
+		ExitCode: exitCode,
+		Stderr:   strings.TrimSpace(stderr),
+		TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+		Err:      err,
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		cmdErr.Canceled = context.Cause(ctx)
+	}
+	return cmdErr
+}
+
+// Failure explains why an action failed
+type Failure struct {
+	Action string `json:"action"`
+	// Message is a one-line summary, such as the first line of stderr
+	Message string `json:"message"`
+	// Command, ExitCode and Stderr describe the failed tool, if one ran
+	Command  string `json:"command,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	// Status is an excerpt of systemctl status with the last log lines
+	Status string `json:"status,omitempty"`
+	// Logs holds the last log lines on backends other than systemd
+	Logs []string `json:"logs,omitempty"`
+}
+
+// equal reports whether two failures are the same
+func (f Failure) equal(o Failure) bool {
+	return f.Action == o.Action && f.Message == o.Message && f.Command == o.Command &&
+		(f.ExitCode == nil) == (o.ExitCode == nil) && (f.ExitCode == nil || *f.ExitCode == *o.ExitCode) &&
+		f.Stderr == o.Stderr && f.Status == o.Status && slices.Equal(f.Logs, o.Logs)
+}
+
+// actionFailed returns the unit's status after a failed action with err,
+// explaining the failure with the tool's output and the unit's recent logs
+func (sm *ServiceManager) actionFailed(ctx context.Context, serviceName, action string, err error) (ServiceStatus, error) {
+	failure := &Failure{Action: action, Message: err.Error()}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		failure.Message = cmdErr.Err.Error()
+		failure.Command = cmdErr.Command
+		failure.ExitCode = &cmdErr.ExitCode
+		failure.Stderr = cmdErr.Stderr
+		if len(failure.Stderr) > maxFailureStderr {
+			failure.Stderr = failure.Stderr[:maxFailureStderr]
+		}
+		if first, _, _ := strings.Cut(cmdErr.Stderr, "\n"); first != "" {
+			failure.Message = first
+		}
+		if cmdErr.TimedOut {
+			failure.Message = cmdErr.Command + " did not finish in time"
+		}
+		if cmdErr.Canceled != nil {
+			failure.Message = cmdErr.Command + " was canceled"
+			if !errors.Is(cmdErr.Canceled, context.Canceled) {
+				failure.Message = cmdErr.Command + " was " + cmdErr.Canceled.Error()
+			}
+		}
+	}
+
+	// The action's context may be the reason it failed
+	ctx = context.WithoutCancel(ctx)
+
+	// The unit may never have been reached, e.g. for a bad environment
+	if cmdErr != nil {
+		if sd, ok := sm.systemd(serviceName); ok {
+			// systemctl status exits non-zero for failed units but still prints
+			failure.Status, _ = sd.Output(ctx, "systemctl", "--user", "status", "--no-pager",
+				"--lines="+strconv.Itoa(statusExcerptLines), serviceName)
+		} else {
+			failure.Logs, _ = sm.Backend().Logs(ctx, serviceName, statusExcerptLines)
+		}
+	}
+
+	status := sm.withMetadata(ServiceStatus{Name: serviceName, Status: StateUnknown, Active: false})
+	if !errors.Is(err, ErrTimeout) {
+		// Report the state the failure left the unit in
+		status, _ = sm.readStatus(ctx, serviceName)
+	}
+	status.Failure = failure
+	if cmdErr != nil {
+		// Keep the error's kind but say what the tool said
+		for _, kind := range []error{ErrUnitNotFound, ErrTimeout, ErrCanceled, ErrBackend} {
+			if errors.Is(err, kind) {
+				return status, fmt.Errorf("%w: %s", kind, failure.Message)
+			}
+		}
+	}
+	return status, err
+}
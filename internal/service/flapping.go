@@ -0,0 +1,59 @@
+// internal/service/flapping.go
+package service
+
+import (
+	"time"
+)
+
+// flapDetector counts automatic restarts per unit over a sliding window.
+// It is guarded by ServiceManager.observedMu.
+type flapDetector struct {
+	threshold int
+	window    time.Duration
+	restarts  map[string][]time.Time
+	flapping  map[string]bool
+}
+
+// SetFlapDetection marks a unit as flapping once systemd restarts it more
+// than threshold times within window; zero threshold disables detection
+func (sm *ServiceManager) SetFlapDetection(threshold int, window time.Duration) {
+	sm.observedMu.Lock()
+	defer sm.observedMu.Unlock()
+	sm.flaps = flapDetector{
+		threshold: threshold,
+		window:    window,
+		restarts:  make(map[string][]time.Time),
+		flapping:  make(map[string]bool),
+	}
+}
+
+// track records n new restarts of a unit at now and reports whether the
+// unit is flapping and whether that changed; the caller must hold
+// observedMu
+func (fd *flapDetector) track(serviceName string, n int, now time.Time) (flapping, changed bool) {
+	if fd.threshold <= 0 {
+		return false, false
+	}
+
+	times := fd.restarts[serviceName]
+	for range n {
+		times = append(times, now)
+	}
+	cutoff := now.Add(-fd.window)
+	for len(times) > 0 && times[0].Before(cutoff) {
+		times = times[1:]
+	}
+	fd.restarts[serviceName] = times
+
+	flapping = len(times) > fd.threshold
+	changed = flapping != fd.flapping[serviceName]
+	fd.flapping[serviceName] = flapping
+	return flapping, changed
+}
+
+// IsFlapping reports whether a unit is currently crash-looping
+func (sm *ServiceManager) IsFlapping(serviceName string) bool {
+	sm.observedMu.Lock()
+	defer sm.observedMu.Unlock()
+	return sm.flaps.flapping[serviceName]
+}
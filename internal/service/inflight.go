@@ -0,0 +1,104 @@
+// internal/service/inflight.go
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/requestid"
+)
+
+// errCanceledByAdmin is the cancellation cause of CancelOperation
+var errCanceledByAdmin = errors.New("canceled by an administrator")
+
+// Operation is a control action in flight, from waiting for the unit's
+// lock until the init system answered
+type Operation struct {
+	ID      string    `json:"id"`
+	Service string    `json:"service"`
+	Action  string    `json:"action"`
+	Started time.Time `json:"started"`
+	// RequestID ties the operation to the request, and audit event, that
+	// started it
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// operation is an Operation with the means to cancel it
+type operation struct {
+	Operation
+	cancel context.CancelCauseFunc
+}
+
+// operations tracks the operations in flight
+type operations struct {
+	mu   sync.Mutex
+	ops  map[string]*operation
+	next uint64
+}
+
+// track registers an action on serviceName. The returned context is
+// canceled with ctx or by CancelOperation; call done once the action
+// finished.
+func (sm *ServiceManager) track(ctx context.Context, serviceName, action string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	sm.inflight.mu.Lock()
+	defer sm.inflight.mu.Unlock()
+	if sm.inflight.ops == nil {
+		sm.inflight.ops = make(map[string]*operation)
+	}
+	sm.inflight.next++
+	op := &operation{
+		Operation: Operation{
+			ID:        strconv.FormatUint(sm.inflight.next, 10),
+			Service:   serviceName,
+			Action:    action,
+			Started:   time.Now(),
+			RequestID: requestid.FromContext(ctx),
+		},
+		cancel: cancel,
+	}
+	sm.inflight.ops[op.ID] = op
+
+	return ctx, func() {
+		sm.inflight.mu.Lock()
+		delete(sm.inflight.ops, op.ID)
+		sm.inflight.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// Operations lists the operations in flight, oldest first
+func (sm *ServiceManager) Operations() []Operation {
+	sm.inflight.mu.Lock()
+	defer sm.inflight.mu.Unlock()
+	ops := make([]Operation, 0, len(sm.inflight.ops))
+	for _, op := range sm.inflight.ops {
+		ops = append(ops, op.Operation)
+	}
+	slices.SortFunc(ops, func(a, b Operation) int { return a.Started.Compare(b.Started) })
+	return ops
+}
+
+// CancelOperation cancels an operation in flight, killing its command, and
+// returns it; ok is false when no operation has that ID. The action then
+// fails with ErrCanceled.
+func (sm *ServiceManager) CancelOperation(id string) (op Operation, ok bool) {
+	sm.inflight.mu.Lock()
+	running, ok := sm.inflight.ops[id]
+	sm.inflight.mu.Unlock()
+	if !ok {
+		return Operation{}, false
+	}
+	sm.logger.Warn("canceling operation",
+		"operation", running.ID,
+		"service", running.Service,
+		"action", running.Action,
+		"request_id", running.RequestID)
+	running.cancel(errCanceledByAdmin)
+	return running.Operation, true
+}
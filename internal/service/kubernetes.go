@@ -0,0 +1,256 @@
+// internal/service/kubernetes.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replicasAnnotation remembers a workload's replica count while it is
+// scaled to zero, so start restores it
+const replicasAnnotation = "sysdwitch/replicas"
+
+// KubernetesBackend treats Deployments and StatefulSets as services:
+// start scales a workload up, stop scales it to zero. It drives kubectl,
+// which also takes care of kubeconfig and authentication.
+type KubernetesBackend struct {
+	namespace string
+	context   string
+	replicas  int
+	logger    *slog.Logger
+}
+
+// NewKubernetesBackend creates a backend for the workloads in namespace
+// (kubectl's default when empty), restoring replicas when a workload has
+// no remembered count
+func NewKubernetesBackend(namespace string, replicas int, logger *slog.Logger) *KubernetesBackend {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if replicas < 1 {
+		replicas = 1
+	}
+	return &KubernetesBackend{namespace: namespace, replicas: replicas, logger: logger}
+}
+
+// SetContext selects a kubeconfig context other than the current one
+func (kb *KubernetesBackend) SetContext(name string) {
+	kb.context = name
+}
+
+// workload is the part of a Deployment or StatefulSet the backend reads
+type workload struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas *int `json:"replicas"`
+	} `json:"spec"`
+	Status struct {
+		Replicas      int `json:"replicas"`
+		ReadyReplicas int `json:"readyReplicas"`
+		Conditions    []struct {
+			Type               string    `json:"type"`
+			Status             string    `json:"status"`
+			Reason             string    `json:"reason"`
+			LastTransitionTime time.Time `json:"lastTransitionTime"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// ref returns the kubectl reference to the workload, e.g.
+// deployment/jellyfin
+func (w workload) ref() string {
+	return strings.ToLower(w.Kind) + "/" + w.Metadata.Name
+}
+
+// state maps replica counts and conditions onto a UnitState
+func (w workload) state() UnitState {
+	want := 1
+	if w.Spec.Replicas != nil {
+		want = *w.Spec.Replicas
+	}
+
+	state := UnitState{Name: w.Metadata.Name + ".service"}
+	switch {
+	case want == 0 && w.Status.Replicas == 0:
+		state.State = StateStopped
+	case want == 0:
+		state.State = StateStopping
+	case w.Status.ReadyReplicas >= want:
+		state.State = StateRunning
+	case w.Status.ReadyReplicas > 0:
+		state.State = StateDegraded
+	default:
+		state.State = StateStarting
+	}
+
+	for _, c := range w.Status.Conditions {
+		// A rollout that exceeded its progress deadline will not recover
+		// on its own
+		if c.Type == "Progressing" && c.Reason == "ProgressDeadlineExceeded" && want > 0 {
+			state.State = StateFailed
+		}
+		if state.Since == nil || c.LastTransitionTime.After(*state.Since) {
+			since := c.LastTransitionTime
+			state.Since = &since
+		}
+	}
+	return state
+}
+
+// Name implements Backend
+func (kb *KubernetesBackend) Name() string {
+	return "kubernetes"
+}
+
+// Status implements Backend
+func (kb *KubernetesBackend) Status(ctx context.Context, unit string) (UnitState, error) {
+	w, err := kb.get(ctx, unit)
+	if err != nil {
+		return UnitState{}, err
+	}
+	return w.state(), nil
+}
+
+// Start implements Backend by scaling the workload back to its remembered
+// replica count
+func (kb *KubernetesBackend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	w, err := kb.get(ctx, unit)
+	if err != nil {
+		return err
+	}
+	if w.Spec.Replicas != nil && *w.Spec.Replicas > 0 {
+		return nil
+	}
+
+	replicas := kb.replicas
+	if n, err := strconv.Atoi(w.Metadata.Annotations[replicasAnnotation]); err == nil && n > 0 {
+		replicas = n
+	}
+	_, err = kb.kubectl(ctx, timeout, "scale", w.ref(), "--replicas="+strconv.Itoa(replicas))
+	return err
+}
+
+// Stop implements Backend by remembering the replica count and scaling the
+// workload to zero
+func (kb *KubernetesBackend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	w, err := kb.get(ctx, unit)
+	if err != nil {
+		return err
+	}
+	if w.Spec.Replicas != nil && *w.Spec.Replicas == 0 {
+		return nil
+	}
+
+	if w.Spec.Replicas != nil {
+		annotation := replicasAnnotation + "=" + strconv.Itoa(*w.Spec.Replicas)
+		if _, err := kb.kubectl(ctx, timeout, "annotate", "--overwrite", w.ref(), annotation); err != nil {
+			return err
+		}
+	}
+	_, err = kb.kubectl(ctx, timeout, "scale", w.ref(), "--replicas=0")
+	return err
+}
+
+// Restart implements Backend with a rolling restart
+func (kb *KubernetesBackend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	w, err := kb.get(ctx, unit)
+	if err != nil {
+		return err
+	}
+	_, err = kb.kubectl(ctx, timeout, "rollout", "restart", w.ref())
+	return err
+}
+
+// List implements Backend
+func (kb *KubernetesBackend) List(ctx context.Context) ([]UnitState, error) {
+	workloads, err := kb.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]UnitState, len(workloads))
+	for i, w := range workloads {
+		units[i] = w.state()
+	}
+	return units, nil
+}
+
+// Logs implements Backend with the recent logs of all of the workload's
+// containers
+func (kb *KubernetesBackend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	w, err := kb.get(ctx, unit)
+	if err != nil {
+		return nil, err
+	}
+	if w.Status.Replicas == 0 {
+		return nil, nil
+	}
+	output, err := kb.kubectl(ctx, queryTimeout, "logs", w.ref(),
+		"--all-containers", "--prefix", "--timestamps", "--tail="+strconv.Itoa(lines))
+	if err != nil || output == "" {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// Command implements Commander
+func (kb *KubernetesBackend) Command(action, unit string) string {
+	name := supervisedName(unit)
+	switch action {
+	case "start":
+		return "kubectl scale " + name + " --replicas=<remembered or " + strconv.Itoa(kb.replicas) + ">"
+	case "stop":
+		return "kubectl scale " + name + " --replicas=0"
+	case "restart":
+		return "kubectl rollout restart " + name
+	}
+	return "kubectl " + action + " " + name
+}
+
+// get finds the Deployment or StatefulSet named after a unit
+func (kb *KubernetesBackend) get(ctx context.Context, unit string) (workload, error) {
+	name := supervisedName(unit)
+	workloads, err := kb.list(ctx, "--field-selector=metadata.name="+name)
+	if err != nil {
+		return workload{}, err
+	}
+	if len(workloads) == 0 {
+		return workload{}, fmt.Errorf("no deployment or statefulset named %s", name)
+	}
+	return workloads[0], nil
+}
+
+// list returns the Deployments and StatefulSets matching args
+func (kb *KubernetesBackend) list(ctx context.Context, args ...string) ([]workload, error) {
+	output, err := kb.kubectl(ctx, queryTimeout, append([]string{"get", "deployments,statefulsets", "--output=json"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []workload `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return nil, fmt.Errorf("parse kubectl output: %w", err)
+	}
+	return list.Items, nil
+}
+
+// kubectl runs kubectl against the configured context and namespace
+func (kb *KubernetesBackend) kubectl(ctx context.Context, timeout time.Duration, args ...string) (string, error) {
+	var global []string
+	if kb.context != "" {
+		global = append(global, "--context="+kb.context)
+	}
+	if kb.namespace != "" {
+		global = append(global, "--namespace="+kb.namespace)
+	}
+	return runTool(ctx, kb.logger, timeout, "kubectl", append(global, args...)...)
+}
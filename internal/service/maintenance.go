@@ -0,0 +1,44 @@
+// internal/service/maintenance.go
+package service
+
+import (
+	"slices"
+	"time"
+
+	"sysdwitch/internal/schedule"
+)
+
+// MaintenanceWindow silences alerts for services while it is open
+type MaintenanceWindow struct {
+	Name     string
+	Schedule schedule.Schedule
+	Duration time.Duration
+	// Unit names of the services covered by the window; empty covers all
+	// services
+	Services []string
+}
+
+// SetMaintenanceWindows replaces the configured maintenance windows
+func (sm *ServiceManager) SetMaintenanceWindows(windows []MaintenanceWindow) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.maintenance = windows
+}
+
+// InMaintenance returns the name of an open maintenance window covering
+// serviceName at t, if any
+func (sm *ServiceManager) InMaintenance(serviceName string, t time.Time) (string, bool) {
+	sm.mu.RLock()
+	windows := sm.maintenance
+	sm.mu.RUnlock()
+
+	for _, w := range windows {
+		if len(w.Services) > 0 && !slices.Contains(w.Services, serviceName) {
+			continue
+		}
+		if w.Schedule.ActiveAt(t, w.Duration) {
+			return w.Name, true
+		}
+	}
+	return "", false
+}
@@ -2,47 +2,373 @@
 package service
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
-	"os/exec"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"sysdwitch/internal/audit"
 )
 
-// ServiceStatus represents the status of a systemd service
+// ServiceStatus represents the status of a systemd service. Fields added
+// here must be compared in Equal, which TestStatusEqualCoversEveryField
+// enforces.
 type ServiceStatus struct {
 	Name   string `json:"name"`
-	Status string `json:"status"`
+	Status State  `json:"status"`
 	Active bool   `json:"active"`
+	// BackendState is the state in the backend's own vocabulary, such as
+	// systemd's "activating"
+	BackendState string `json:"backend_state,omitempty"`
+	// Since is when the unit entered its current state, if known
+	Since *time.Time `json:"since,omitempty"`
+	// Backend labels the unit's backend when several are configured
+	Backend string `json:"backend,omitempty"`
+	// Presentation metadata from configuration
+	Group       string `json:"group,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	URL         string `json:"url,omitempty"`
+	// Protected services ask for confirmation before destructive actions
+	Protected bool `json:"protected,omitempty"`
+	// Flapping is set while systemd keeps restarting the unit
+	Flapping bool `json:"flapping,omitempty"`
+	// Maintenance names the open maintenance window covering the unit
+	Maintenance string `json:"maintenance,omitempty"`
+	// Exclusive is the group of services this one may not run alongside
+	Exclusive string `json:"exclusive,omitempty"`
+	// EnvOptions lists environment overrides accepted on start
+	EnvOptions map[string][]string `json:"env_options,omitempty"`
+
+	// DryRun is set when an action was validated but not executed;
+	// Command then holds what would have been run
+	DryRun  bool   `json:"dry_run,omitempty"`
+	Command string `json:"command,omitempty"`
+	// Refused explains why an action was not run, such as a failed
+	// free space precondition
+	Refused string `json:"refused,omitempty"`
+	// Failure explains why an action failed
+	Failure *Failure `json:"failure,omitempty"`
 }
 
-// ServiceManager handles systemd service operations
+// Equal reports whether two statuses are the same in every field
+func (s ServiceStatus) Equal(o ServiceStatus) bool {
+	return s.Name == o.Name && s.Status == o.Status && s.Active == o.Active &&
+		s.BackendState == o.BackendState &&
+		(s.Since == nil) == (o.Since == nil) && (s.Since == nil || s.Since.Equal(*o.Since)) &&
+		s.Backend == o.Backend && s.Group == o.Group && s.DisplayName == o.DisplayName &&
+		s.Description == o.Description && s.Icon == o.Icon && s.URL == o.URL &&
+		s.Protected == o.Protected && s.Flapping == o.Flapping &&
+		s.Maintenance == o.Maintenance && s.Exclusive == o.Exclusive &&
+		maps.EqualFunc(s.EnvOptions, o.EnvOptions, slices.Equal) &&
+		s.DryRun == o.DryRun && s.Command == o.Command && s.Refused == o.Refused &&
+		(s.Failure == nil) == (o.Failure == nil) && (s.Failure == nil || s.Failure.equal(*o.Failure))
+}
+
+// ServiceManager controls the allowed services through a Backend
 type ServiceManager struct {
 	allowedServices map[string]bool
+	order           []string
+	metadata        map[string]Metadata
+	dryRun          bool
+	actionTimeout   time.Duration
+	timeouts        map[string]time.Duration
+	maintenance     []MaintenanceWindow
+	backend         Backend
+	audit           *audit.Recorder
 	logger          *slog.Logger
 	mu              sync.RWMutex
+
+	// observed tracks the last seen state of each unit to detect changes
+	observed   map[string]unitState
+	history    map[string][]Transition
+	flaps      flapDetector
+	observedMu sync.Mutex
+
+	metrics metricsStore
+
+	// unitLocks serializes actions per unit
+	unitLocks sync.Map
+	inflight  operations
+
+	// states holds the unit states read by RunRefresher
+	states statusTable
+}
+
+// Metadata is per-service presentation information carried through to
+// ServiceStatus
+type Metadata struct {
+	Group       string
+	DisplayName string
+	Description string
+	// Icon is an emoji or an image URL
+	Icon string
+	// URL links to the service's own web UI
+	URL string
+	// Protected asks for confirmation before stop/restart in the UI
+	Protected bool
+	// Exclusive names the group in which only one service may run
+	Exclusive string
+	// EnvOptions whitelists environment overrides on start
+	EnvOptions map[string][]string
+	// MinFree lists free space required before the service may start
+	MinFree []FreeSpace
+}
+
+// unitState is the last observed state of a unit
+type unitState struct {
+	status   State
+	restarts int
+}
+
+// defaultActionTimeout bounds start/stop when no timeout is configured
+const defaultActionTimeout = 30 * time.Second
+
+// queryTimeout bounds read-only systemctl queries
+const queryTimeout = 30 * time.Second
+
+// dryRunKey marks a request context as dry-run
+type dryRunKey struct{}
+
+// WithDryRun returns a context under which control actions are validated
+// and reported but not executed
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx was marked by WithDryRun
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
 }
 
 // NewServiceManager creates a new service manager with allowed services
 func NewServiceManager(allowedServices []string, logger *slog.Logger) *ServiceManager {
+	allowed, order := allowList(allowedServices)
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &ServiceManager{
+		allowedServices: allowed,
+		order:           order,
+		metadata:        make(map[string]Metadata),
+		actionTimeout:   defaultActionTimeout,
+		timeouts:        make(map[string]time.Duration),
+		logger:          logger,
+		observed:        make(map[string]unitState),
+		history:         make(map[string][]Transition),
+		metrics:         metricsStore{rings: make(map[string]*sampleRing)},
+		backend:         NewSystemdBackend(logger),
+	}
+}
+
+// allowList normalizes service names into a set and their first-seen order
+func allowList(services []string) (map[string]bool, []string) {
 	allowed := make(map[string]bool)
-	for _, service := range allowedServices {
+	order := make([]string, 0, len(services))
+	for _, service := range services {
 		if !strings.HasSuffix(service, ".service") {
 			service += ".service"
 		}
+		if !allowed[service] {
+			order = append(order, service)
+		}
 		allowed[service] = true
 	}
+	return allowed, order
+}
 
-	if logger == nil {
-		logger = slog.Default()
+// SetServices replaces the allowed services at runtime. Metadata and
+// per-service action timeouts are cleared for the caller to set again.
+func (sm *ServiceManager) SetServices(services []string) {
+	allowed, order := allowList(services)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.allowedServices = allowed
+	sm.order = order
+	sm.metadata = make(map[string]Metadata)
+	sm.timeouts = make(map[string]time.Duration)
+}
+
+// SetMetadata attaches presentation metadata to an allowed service
+func (sm *ServiceManager) SetMetadata(serviceName string, meta Metadata) {
+	if !strings.HasSuffix(serviceName, ".service") {
+		serviceName += ".service"
 	}
 
-	return &ServiceManager{
-		allowedServices: allowed,
-		logger:          logger,
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.metadata[serviceName] = meta
+}
+
+// withMetadata fills the metadata fields of status
+func (sm *ServiceManager) withMetadata(status ServiceStatus) ServiceStatus {
+	sm.mu.RLock()
+	meta := sm.metadata[status.Name]
+	sm.mu.RUnlock()
+
+	status.Group = meta.Group
+	status.DisplayName = meta.DisplayName
+	status.Description = meta.Description
+	status.Icon = meta.Icon
+	status.URL = meta.URL
+	status.Protected = meta.Protected
+	status.Exclusive = meta.Exclusive
+	status.EnvOptions = meta.EnvOptions
+	status.Backend = sm.backendLabel(status.Name)
+	return status
+}
+
+// SetActionTimeout sets how long start/stop may take for serviceName; an
+// empty serviceName sets the default for all services
+func (sm *ServiceManager) SetActionTimeout(serviceName string, timeout time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if serviceName == "" {
+		sm.actionTimeout = timeout
+		return
+	}
+	if !strings.HasSuffix(serviceName, ".service") {
+		serviceName += ".service"
+	}
+	sm.timeouts[serviceName] = timeout
+}
+
+// timeoutFor returns the action timeout of a service
+func (sm *ServiceManager) timeoutFor(serviceName string) time.Duration {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if timeout, ok := sm.timeouts[serviceName]; ok && timeout > 0 {
+		return timeout
+	}
+	return sm.actionTimeout
+}
+
+// SetRecorder sets the audit recorder receiving unit state-change events
+func (sm *ServiceManager) SetRecorder(recorder *audit.Recorder) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.audit = recorder
+}
+
+// observe records the latest state of a unit and emits events for state
+// changes, failures and automatic restarts by systemd
+func (sm *ServiceManager) observe(serviceName string, status State, restarts int) {
+	sm.observedMu.Lock()
+	prev, seen := sm.observed[serviceName]
+	sm.observed[serviceName] = unitState{status: status, restarts: restarts}
+	if !seen || status != prev.status {
+		sm.recordTransition(serviceName, status)
+	}
+	newRestarts := 0
+	if seen && restarts > prev.restarts {
+		newRestarts = restarts - prev.restarts
+	}
+	now := time.Now()
+	flapping, flapChanged := sm.flaps.track(serviceName, newRestarts, now)
+	sm.observedMu.Unlock()
+
+	if !seen {
+		return
+	}
+	_, inMaintenance := sm.InMaintenance(serviceName, now)
+	suppressed := flapping || inMaintenance
+
+	sm.mu.RLock()
+	recorder := sm.audit
+	sm.mu.RUnlock()
+
+	if flapChanged && flapping {
+		recorder.Record(audit.Event{
+			Type:       audit.EventServiceFlapping,
+			Service:    serviceName,
+			Message:    fmt.Sprintf("%s is flapping; further alerts are suppressed until it settles", serviceName),
+			Fields:     map[string]any{"restarts": restarts},
+			Suppressed: inMaintenance,
+		})
+	} else if flapChanged {
+		recorder.Record(audit.Event{
+			Type:       audit.EventServiceStable,
+			Service:    serviceName,
+			Message:    fmt.Sprintf("%s has stopped flapping", serviceName),
+			Fields:     map[string]any{"status": string(status)},
+			Suppressed: inMaintenance,
+		})
+	}
+
+	// While a unit is flapping or in maintenance its events are logged but
+	// not notified
+	if newRestarts > 0 {
+		recorder.Record(audit.Event{
+			Type:       audit.EventServiceRestarted,
+			Service:    serviceName,
+			Message:    fmt.Sprintf("%s was restarted automatically by systemd", serviceName),
+			Fields:     map[string]any{"restarts": restarts},
+			Suppressed: suppressed,
+		})
+	}
+
+	if status == prev.status {
+		return
+	}
+
+	recorder.Record(audit.Event{
+		Type:       audit.EventServiceStateChanged,
+		Service:    serviceName,
+		Message:    fmt.Sprintf("%s changed from %s to %s", serviceName, prev.status, status),
+		Fields:     map[string]any{"from": string(prev.status), "to": string(status)},
+		Suppressed: suppressed,
+	})
+	if status == StateFailed {
+		recorder.Record(audit.Event{
+			Type:       audit.EventServiceFailed,
+			Service:    serviceName,
+			Message:    fmt.Sprintf("%s has failed", serviceName),
+			Fields:     map[string]any{"from": string(prev.status)},
+			Suppressed: suppressed,
+		})
+	}
+}
+
+// SetDryRun enables or disables server-wide dry-run mode
+func (sm *ServiceManager) SetDryRun(enabled bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.dryRun = enabled
+}
+
+// isDryRun reports whether actions under ctx must not be executed
+func (sm *ServiceManager) isDryRun(ctx context.Context) bool {
+	if IsDryRun(ctx) {
+		return true
 	}
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.dryRun
+}
+
+// dryRunStatus reports the current status of a service together with the
+// command an action would have run
+func (sm *ServiceManager) dryRunStatus(ctx context.Context, serviceName, action string) (ServiceStatus, error) {
+	command := sm.command(action, serviceName)
+	sm.logger.InfoContext(ctx, "dry run: skipping action",
+		"service", serviceName,
+		"command", command)
+
+	status, err := sm.GetServiceStatus(ctx, serviceName)
+	status.DryRun = true
+	status.Command = command
+	return status, err
 }
 
 // validateService checks if a service is in the allowed list
@@ -52,102 +378,284 @@ func (sm *ServiceManager) validateService(serviceName string) bool {
 	return sm.allowedServices[serviceName]
 }
 
-// runSystemctl executes systemctl commands with timeout and context
-func (sm *ServiceManager) runSystemctl(ctx context.Context, args ...string) (string, error) {
-	// Create context with timeout for systemctl operations
-	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+// IsAllowed reports whether a unit is in the allowlist
+func (sm *ServiceManager) IsAllowed(serviceName string) bool {
+	return sm.validateService(serviceName)
+}
 
-	cmd := exec.CommandContext(timeoutCtx, "systemctl", append([]string{"--user"}, args...)...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// AllowedServices returns the allowed units in allowlist order
+func (sm *ServiceManager) AllowedServices() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return append([]string(nil), sm.order...)
+}
 
-	err := cmd.Run()
-	if err != nil {
-		sm.logger.Error("systemctl command failed",
-			"args", args,
-			"error", err,
-			"stderr", stderr.String())
-		return "", err
+// Ping checks that the init system is reachable
+func (sm *ServiceManager) Ping(ctx context.Context) error {
+	backend := sm.Backend()
+	if pinger, ok := backend.(Pinger); ok {
+		return pinger.Ping(ctx)
 	}
+	_, err := backend.List(ctx)
+	return err
+}
 
-	return strings.TrimSpace(stdout.String()), nil
+// GetServiceStatus gets the status of a systemd user service. It fails
+// with ErrNotAllowed outside the allowlist; when the backend fails, the
+// status is unknown, or not-installed for ErrUnitNotFound, and the error
+// is ErrUnitNotFound, ErrTimeout or ErrBackend. While RunRefresher runs,
+// the status is its last read of the unit.
+func (sm *ServiceManager) GetServiceStatus(ctx context.Context, serviceName string) (ServiceStatus, error) {
+	if !sm.validateService(serviceName) {
+		sm.logger.WarnContext(ctx, "attempted to check status of non-allowed service",
+			"service", serviceName)
+		return ServiceStatus{Name: serviceName}, ErrNotAllowed
+	}
+	if entry, ok := sm.states.get(serviceName); ok {
+		return sm.decorate(entry.status, entry.err), entry.err
+	}
+	return sm.readStatus(ctx, serviceName)
 }
 
-// GetServiceStatus gets the status of a systemd user service
-func (sm *ServiceManager) GetServiceStatus(ctx context.Context, serviceName string) ServiceStatus {
+// RefreshStatus is GetServiceStatus reading the unit's state from the
+// backend now, for callers that must not see a state older than their
+// request
+func (sm *ServiceManager) RefreshStatus(ctx context.Context, serviceName string) (ServiceStatus, error) {
 	if !sm.validateService(serviceName) {
-		sm.logger.Warn("attempted to check status of non-allowed service",
+		sm.logger.WarnContext(ctx, "attempted to check status of non-allowed service",
 			"service", serviceName)
-		return ServiceStatus{Name: serviceName, Status: "not_allowed", Active: false}
+		return ServiceStatus{Name: serviceName}, ErrNotAllowed
 	}
+	return sm.readStatus(ctx, serviceName)
+}
 
-	status, err := sm.runSystemctl(ctx, "is-active", serviceName)
-	if err != nil {
-		sm.logger.Error("failed to get status for service",
+// readStatus reads an allowed unit's state from the backend and records it
+// in the status table, unless the read was canceled
+func (sm *ServiceManager) readStatus(ctx context.Context, serviceName string) (ServiceStatus, error) {
+	started := time.Now()
+	state, err := sm.Backend().Status(ctx, serviceName)
+	var status ServiceStatus
+	if err = backendError(err); errors.Is(err, ErrUnitNotFound) {
+		// Usually a typo in the allowlist; logged once at startup
+		sm.logger.DebugContext(ctx, "service is not installed", "service", serviceName, "error", err)
+		status = ServiceStatus{Name: serviceName, Status: StateNotInstalled, Active: false}
+	} else if err != nil {
+		sm.logger.ErrorContext(ctx, "failed to get status for service",
 			"service", serviceName,
 			"error", err)
-		return ServiceStatus{Name: serviceName, Status: "error", Active: false}
+		status = ServiceStatus{Name: serviceName, Status: StateUnknown, Active: false}
+	} else {
+		sm.observe(serviceName, state.State, state.Restarts)
+		status = ServiceStatus{
+			Name:         serviceName,
+			Status:       state.State,
+			Active:       state.State.Active(),
+			BackendState: state.Raw,
+			Since:        state.Since,
+		}
+	}
+	// A read its caller gave up on says nothing about the unit, and must not
+	// replace what the refresher read for everyone else
+	if ctx.Err() == nil && !errors.Is(err, ErrCanceled) {
+		sm.states.put(serviceName, status, err, started)
+	}
+	return sm.decorate(status, err), err
+}
+
+// decorate adds what is not read from the backend to a unit's status:
+// metadata and, for units whose state was read, flapping and maintenance
+func (sm *ServiceManager) decorate(status ServiceStatus, err error) ServiceStatus {
+	if err == nil {
+		status.Flapping = sm.IsFlapping(status.Name)
+		status.Maintenance, _ = sm.InMaintenance(status.Name, time.Now())
+	}
+	return sm.withMetadata(status)
+}
+
+// Label returns the display name, falling back to the unit name without
+// its .service suffix and backend namespace
+func (s ServiceStatus) Label() string {
+	if s.DisplayName != "" {
+		return s.DisplayName
+	}
+	name := strings.TrimSuffix(s.Name, ".service")
+	if _, local, ok := strings.Cut(name, ":"); ok {
+		return local
+	}
+	return name
+}
+
+// parseProperties parses "Key=Value" lines from systemctl show
+func parseProperties(output string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// parseTimestamp parses a systemctl show timestamp, either the default
+// "Mon 2006-01-02 15:04:05 MST" form or "@<unix seconds>"
+func parseTimestamp(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "n/a" {
+		return nil
+	}
+
+	if secs, ok := strings.CutPrefix(value, "@"); ok {
+		n, err := strconv.ParseInt(secs, 10, 64)
+		if err != nil {
+			return nil
+		}
+		t := time.Unix(n, 0)
+		return &t
+	}
+
+	t, err := time.Parse("Mon 2006-01-02 15:04:05 MST", value)
+	if err != nil {
+		return nil
 	}
+	return &t
+}
 
-	return ServiceStatus{
-		Name:   serviceName,
-		Status: status,
-		Active: status == "active",
+// lockUnit blocks until no other action runs on serviceName and returns
+// the function releasing the lock, or an error when ctx is done first
+func (sm *ServiceManager) lockUnit(ctx context.Context, serviceName string) (func(), error) {
+	lock, _ := sm.unitLocks.LoadOrStore(serviceName, make(chan struct{}, 1))
+	select {
+	case lock.(chan struct{}) <- struct{}{}:
+		return func() { <-lock.(chan struct{}) }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for another action: %w", contextError(ctx))
 	}
 }
 
-// StartService starts a systemd user service
-func (sm *ServiceManager) StartService(ctx context.Context, serviceName string) ServiceStatus {
+// StartService starts a systemd user service. Besides ErrNotAllowed, it
+// fails with the error of the backend (see GetServiceStatus) and a status
+// whose Failure explains it.
+func (sm *ServiceManager) StartService(ctx context.Context, serviceName string) (ServiceStatus, error) {
+	return sm.start(ctx, serviceName, "start")
+}
+
+// RestartService restarts a service, or starts it when stopped, in one
+// call of the backend. It checks and fails like StartService.
+func (sm *ServiceManager) RestartService(ctx context.Context, serviceName string) (ServiceStatus, error) {
+	return sm.start(ctx, serviceName, "restart")
+}
+
+// start runs action, start or restart, on serviceName, after the checks
+// both share: environment, free space and exclusive groups
+func (sm *ServiceManager) start(ctx context.Context, serviceName, action string) (ServiceStatus, error) {
 	if !sm.validateService(serviceName) {
-		sm.logger.Warn("attempted to start non-allowed service",
+		sm.logger.WarnContext(ctx, "attempted to "+action+" non-allowed service",
 			"service", serviceName)
-		return ServiceStatus{Name: serviceName, Status: "not_allowed", Active: false}
+		return ServiceStatus{Name: serviceName}, ErrNotAllowed
+	}
+
+	env := EnvironmentFrom(ctx)
+	if err := sm.ValidateEnvironment(serviceName, env); err != nil {
+		sm.logger.WarnContext(ctx, "rejected environment overrides",
+			"service", serviceName,
+			"error", err)
+		return sm.actionFailed(ctx, serviceName, action, err)
+	}
+
+	if err := sm.checkFreeSpace(serviceName); err != nil {
+		sm.logger.WarnContext(ctx, "refused to "+action+" service",
+			"service", serviceName,
+			"error", err)
+		status, statusErr := sm.GetServiceStatus(ctx, serviceName)
+		status.Refused = err.Error()
+		return status, statusErr
+	}
+
+	conflicts := sm.runningConflicts(ctx, serviceName)
+	if sm.isDryRun(ctx) {
+		status, err := sm.dryRunStatus(ctx, serviceName, action)
+		if len(conflicts) > 0 {
+			status.Command = sm.command("stop", strings.Join(conflicts, " ")) + " && " + status.Command
+		}
+		if len(env) > 0 {
+			status.Command = "write " + envDropIn + " && systemctl --user daemon-reload && " + status.Command
+		}
+		return status, err
 	}
 
-	_, err := sm.runSystemctl(ctx, "start", serviceName)
+	ctx, done := sm.track(ctx, serviceName, action)
+	defer done()
+
+	if err := sm.stopConflicts(ctx, serviceName, conflicts); err != nil {
+		return sm.actionFailed(ctx, serviceName, action, err)
+	}
+
+	unlock, err := sm.lockUnit(ctx, serviceName)
 	if err != nil {
-		sm.logger.Error("failed to start service",
+		return sm.actionFailed(ctx, serviceName, action, err)
+	}
+	defer unlock()
+
+	if err := sm.applyEnvironment(ctx, serviceName, env); err != nil {
+		sm.logger.ErrorContext(ctx, "failed to apply environment overrides",
+			"service", serviceName,
+			"error", err)
+		return sm.actionFailed(ctx, serviceName, action, backendError(err))
+	}
+
+	run := sm.Backend().Start
+	if action == "restart" {
+		run = sm.Backend().Restart
+	}
+	if err := run(ctx, serviceName, sm.timeoutFor(serviceName)); err != nil {
+		sm.logger.ErrorContext(ctx, "failed to "+action+" service",
 			"service", serviceName,
 			"error", err)
-		return ServiceStatus{Name: serviceName, Status: "error", Active: false}
+		return sm.actionFailed(ctx, serviceName, action, backendError(err))
 	}
 
-	return sm.GetServiceStatus(ctx, serviceName)
+	return sm.readStatus(ctx, serviceName)
 }
 
-// StopService stops a systemd user service
-func (sm *ServiceManager) StopService(ctx context.Context, serviceName string) ServiceStatus {
+// StopService stops a systemd user service, failing like StartService
+func (sm *ServiceManager) StopService(ctx context.Context, serviceName string) (ServiceStatus, error) {
 	if !sm.validateService(serviceName) {
-		sm.logger.Warn("attempted to stop non-allowed service",
+		sm.logger.WarnContext(ctx, "attempted to stop non-allowed service",
 			"service", serviceName)
-		return ServiceStatus{Name: serviceName, Status: "not_allowed", Active: false}
+		return ServiceStatus{Name: serviceName}, ErrNotAllowed
 	}
 
-	_, err := sm.runSystemctl(ctx, "stop", serviceName)
+	if sm.isDryRun(ctx) {
+		return sm.dryRunStatus(ctx, serviceName, "stop")
+	}
+
+	ctx, done := sm.track(ctx, serviceName, "stop")
+	defer done()
+
+	unlock, err := sm.lockUnit(ctx, serviceName)
 	if err != nil {
-		sm.logger.Error("failed to stop service",
+		return sm.actionFailed(ctx, serviceName, "stop", err)
+	}
+	defer unlock()
+
+	if err := sm.Backend().Stop(ctx, serviceName, sm.timeoutFor(serviceName)); err != nil {
+		sm.logger.ErrorContext(ctx, "failed to stop service",
 			"service", serviceName,
 			"error", err)
-		return ServiceStatus{Name: serviceName, Status: "error", Active: false}
+		return sm.actionFailed(ctx, serviceName, "stop", backendError(err))
 	}
 
-	return sm.GetServiceStatus(ctx, serviceName)
+	return sm.readStatus(ctx, serviceName)
 }
 
-// GetAllServicesStatus gets status of all configured services
+// GetAllServicesStatus gets status of all configured services in
+// configuration order
 func (sm *ServiceManager) GetAllServicesStatus(ctx context.Context) []ServiceStatus {
-	sm.mu.RLock()
-	services := make([]string, 0, len(sm.allowedServices))
-	for service := range sm.allowedServices {
-		services = append(services, service)
-	}
-	sm.mu.RUnlock()
-
+	services := sm.AllowedServices()
 	results := make([]ServiceStatus, len(services))
 	for i, service := range services {
-		results[i] = sm.GetServiceStatus(ctx, service)
+		// Statuses of units that cannot be read show as unknown
+		results[i], _ = sm.GetServiceStatus(ctx, service)
 	}
 
 	return results
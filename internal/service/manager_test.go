@@ -0,0 +1,86 @@
+// internal/service/manager_test.go
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestStatusEqualCoversEveryField guards Equal against fields added to
+// ServiceStatus, or to the structs it points to, without comparing them
+func TestStatusEqualCoversEveryField(t *testing.T) {
+	typ := reflect.TypeFor[ServiceStatus]()
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		for _, values := range unequalValues(t, field.Type) {
+			a, b := reflect.New(typ).Elem(), reflect.New(typ).Elem()
+			a.Field(i).Set(values[0])
+			b.Field(i).Set(values[1])
+			sa, sb := a.Interface().(ServiceStatus), b.Interface().(ServiceStatus)
+			if sa.Equal(sb) || sb.Equal(sa) {
+				t.Errorf("Equal ignores %s: %v and %v", field.Name, values[0], values[1])
+			}
+		}
+	}
+}
+
+// unequalValues returns pairs of values of typ that differ: the zero value
+// and another, and, inside pointers, slices, maps and structs, values that
+// differ in what they hold
+func unequalValues(t *testing.T, typ reflect.Type) [][2]reflect.Value {
+	t.Helper()
+	zero := reflect.Zero(typ)
+	switch typ.Kind() {
+	case reflect.String:
+		return [][2]reflect.Value{{zero, reflect.ValueOf("x").Convert(typ)}}
+	case reflect.Bool:
+		return [][2]reflect.Value{{zero, reflect.ValueOf(true).Convert(typ)}}
+	case reflect.Int, reflect.Int64:
+		return [][2]reflect.Value{{zero, reflect.ValueOf(1).Convert(typ)}}
+	case reflect.Pointer:
+		pairs := [][2]reflect.Value{{zero, reflect.New(typ.Elem())}}
+		for _, inner := range unequalValues(t, typ.Elem()) {
+			a, b := reflect.New(typ.Elem()), reflect.New(typ.Elem())
+			a.Elem().Set(inner[0])
+			b.Elem().Set(inner[1])
+			pairs = append(pairs, [2]reflect.Value{a, b})
+		}
+		return pairs
+	case reflect.Slice:
+		var pairs [][2]reflect.Value
+		for _, inner := range unequalValues(t, typ.Elem()) {
+			pairs = append(pairs, [2]reflect.Value{
+				reflect.Append(reflect.MakeSlice(typ, 0, 1), inner[0]),
+				reflect.Append(reflect.MakeSlice(typ, 0, 1), inner[1]),
+			})
+		}
+		return pairs
+	case reflect.Map:
+		key := unequalValues(t, typ.Key())[0][1]
+		var pairs [][2]reflect.Value
+		for _, inner := range unequalValues(t, typ.Elem()) {
+			a, b := reflect.MakeMap(typ), reflect.MakeMap(typ)
+			a.SetMapIndex(key, inner[0])
+			b.SetMapIndex(key, inner[1])
+			pairs = append(pairs, [2]reflect.Value{a, b})
+		}
+		return pairs
+	case reflect.Struct:
+		if typ == reflect.TypeFor[time.Time]() {
+			return [][2]reflect.Value{{zero, reflect.ValueOf(time.Unix(1, 0))}}
+		}
+		var pairs [][2]reflect.Value
+		for i := range typ.NumField() {
+			for _, inner := range unequalValues(t, typ.Field(i).Type) {
+				a, b := reflect.New(typ).Elem(), reflect.New(typ).Elem()
+				a.Field(i).Set(inner[0])
+				b.Field(i).Set(inner[1])
+				pairs = append(pairs, [2]reflect.Value{a, b})
+			}
+		}
+		return pairs
+	}
+	t.Fatalf("no differing values for %s; extend unequalValues", typ)
+	return nil
+}
@@ -0,0 +1,132 @@
+// internal/service/metrics.go
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Sample is one resource usage measurement of a unit's cgroup
+type Sample struct {
+	At time.Time `json:"at"`
+	// CPUPercent is the CPU usage since the previous sample, where 100
+	// means one full core
+	CPUPercent float64 `json:"cpu_percent"`
+	// MemoryBytes is the unit's current memory usage
+	MemoryBytes uint64 `json:"memory_bytes"`
+}
+
+// sampleRing is a fixed-size ring buffer of samples
+type sampleRing struct {
+	samples []Sample
+	next    int
+	full    bool
+
+	// lastCPU and lastAt are the previous cumulative CPU reading
+	lastCPU uint64
+	lastAt  time.Time
+}
+
+// add appends a sample, overwriting the oldest once full
+func (r *sampleRing) add(s Sample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the samples oldest first
+func (r *sampleRing) list() []Sample {
+	if !r.full {
+		return append([]Sample(nil), r.samples[:r.next]...)
+	}
+	return append(append([]Sample(nil), r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// metricsStore holds the sample rings of all units
+type metricsStore struct {
+	mu    sync.Mutex
+	size  int
+	rings map[string]*sampleRing
+}
+
+// Samples returns the recent resource usage samples of a unit, oldest first
+func (sm *ServiceManager) Samples(serviceName string) []Sample {
+	sm.metrics.mu.Lock()
+	defer sm.metrics.mu.Unlock()
+	ring := sm.metrics.rings[serviceName]
+	if ring == nil {
+		return nil
+	}
+	return ring.list()
+}
+
+// RunSampler samples CPU and memory usage of every allowed unit each
+// interval, keeping the last size samples per unit, until ctx is done
+func (sm *ServiceManager) RunSampler(ctx context.Context, interval time.Duration, size int) {
+	sm.metrics.mu.Lock()
+	sm.metrics.size = size
+	sm.metrics.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sm.sampleAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sampleAll takes one sample of every allowed unit
+func (sm *ServiceManager) sampleAll(ctx context.Context) {
+	usage, ok := sm.Backend().(UsageReporter)
+	if !ok {
+		return
+	}
+
+	sm.mu.RLock()
+	services := make([]string, len(sm.order))
+	copy(services, sm.order)
+	sm.mu.RUnlock()
+
+	for _, serviceName := range services {
+		cpu, cpuValid, memory, err := usage.Usage(ctx, serviceName)
+		if err != nil {
+			continue
+		}
+		sm.addSample(serviceName, time.Now(), cpu, cpuValid, memory)
+	}
+}
+
+// addSample converts a cumulative CPU reading into a usage percentage and
+// stores the sample
+func (sm *ServiceManager) addSample(serviceName string, at time.Time, cpuNsec uint64, cpuValid bool, memory uint64) {
+	sm.metrics.mu.Lock()
+	defer sm.metrics.mu.Unlock()
+
+	ring := sm.metrics.rings[serviceName]
+	if ring == nil {
+		ring = &sampleRing{samples: make([]Sample, sm.metrics.size)}
+		sm.metrics.rings[serviceName] = ring
+	}
+
+	sample := Sample{At: at, MemoryBytes: memory}
+	if cpuValid && !ring.lastAt.IsZero() && cpuNsec >= ring.lastCPU {
+		elapsed := at.Sub(ring.lastAt)
+		if elapsed > 0 {
+			sample.CPUPercent = float64(cpuNsec-ring.lastCPU) / float64(elapsed) * 100
+		}
+	}
+	if cpuValid {
+		ring.lastCPU, ring.lastAt = cpuNsec, at
+	} else {
+		ring.lastCPU, ring.lastAt = 0, time.Time{}
+	}
+	ring.add(sample)
+}
@@ -0,0 +1,137 @@
+// internal/service/multi.go
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// MultiBackend routes units to one of several backends by namespace:
+// "nas:backup.service" goes to the backend added as "nas", while names
+// without a known namespace go to the primary backend
+type MultiBackend struct {
+	primary Backend
+	named   map[string]Backend
+	order   []string
+}
+
+// NewMultiBackend creates a router with primary as the default backend
+func NewMultiBackend(primary Backend) *MultiBackend {
+	return &MultiBackend{primary: primary, named: make(map[string]Backend)}
+}
+
+// Add registers a backend under a namespace
+func (mb *MultiBackend) Add(namespace string, backend Backend) {
+	if _, ok := mb.named[namespace]; !ok {
+		mb.order = append(mb.order, namespace)
+	}
+	mb.named[namespace] = backend
+}
+
+// route returns the backend of a unit and the unit's name within it
+func (mb *MultiBackend) route(unit string) (Backend, string) {
+	if namespace, local, ok := strings.Cut(unit, ":"); ok {
+		if backend, ok := mb.named[namespace]; ok {
+			return backend, local
+		}
+	}
+	return mb.primary, unit
+}
+
+// Label returns the namespace of a unit, or the primary backend's name
+func (mb *MultiBackend) Label(unit string) string {
+	if namespace, _, ok := strings.Cut(unit, ":"); ok {
+		if _, ok := mb.named[namespace]; ok {
+			return namespace
+		}
+	}
+	return mb.primary.Name()
+}
+
+// Name implements Backend
+func (mb *MultiBackend) Name() string {
+	return "multi"
+}
+
+// Status implements Backend
+func (mb *MultiBackend) Status(ctx context.Context, unit string) (UnitState, error) {
+	backend, local := mb.route(unit)
+	state, err := backend.Status(ctx, local)
+	state.Name = unit
+	return state, err
+}
+
+// Start implements Backend
+func (mb *MultiBackend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	backend, local := mb.route(unit)
+	return backend.Start(ctx, local, timeout)
+}
+
+// Stop implements Backend
+func (mb *MultiBackend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	backend, local := mb.route(unit)
+	return backend.Stop(ctx, local, timeout)
+}
+
+// Restart implements Backend
+func (mb *MultiBackend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	backend, local := mb.route(unit)
+	return backend.Restart(ctx, local, timeout)
+}
+
+// List implements Backend with the units of all backends, namespaced.
+// Only a failure of the primary backend is an error; unreachable
+// namespaced backends are left out.
+func (mb *MultiBackend) List(ctx context.Context) ([]UnitState, error) {
+	units, err := mb.primary.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, namespace := range mb.order {
+		named, err := mb.named[namespace].List(ctx)
+		if err != nil {
+			continue
+		}
+		for _, unit := range named {
+			unit.Name = namespace + ":" + unit.Name
+			units = append(units, unit)
+		}
+	}
+	return units, nil
+}
+
+// Logs implements Backend
+func (mb *MultiBackend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	backend, local := mb.route(unit)
+	return backend.Logs(ctx, local, lines)
+}
+
+// Command implements Commander
+func (mb *MultiBackend) Command(action, unit string) string {
+	backend, local := mb.route(unit)
+	if c, ok := backend.(Commander); ok {
+		return c.Command(action, local)
+	}
+	return action + " " + unit
+}
+
+// Ping implements Pinger by checking the primary backend
+func (mb *MultiBackend) Ping(ctx context.Context) error {
+	if pinger, ok := mb.primary.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	_, err := mb.primary.List(ctx)
+	return err
+}
+
+// Usage implements UsageReporter for units whose backend reports usage
+func (mb *MultiBackend) Usage(ctx context.Context, unit string) (uint64, bool, uint64, error) {
+	backend, local := mb.route(unit)
+	usage, ok := backend.(UsageReporter)
+	if !ok {
+		return 0, false, 0, errors.ErrUnsupported
+	}
+	return usage.Usage(ctx, local)
+}
@@ -0,0 +1,195 @@
+// internal/service/openrc.go
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openrcStates maps OpenRC service states onto canonical states
+var openrcStates = map[string]State{
+	"started":   StateRunning,
+	"stopped":   StateStopped,
+	"inactive":  StateStopped,
+	"scheduled": StateStopped,
+	"starting":  StateStarting,
+	"stopping":  StateStopping,
+	"crashed":   StateFailed,
+	"failed":    StateFailed,
+}
+
+// openrcStatus matches the state in rc-service status output, e.g.
+// " * status: started"
+var openrcStatus = regexp.MustCompile(`status:\s*(\S+)`)
+
+// openrcListEntry matches a line of rc-status --servicelist, e.g.
+// " sshd   [  started 01:02:03 (2) ]" where (2) counts respawns by
+// supervise-daemon
+var openrcListEntry = regexp.MustCompile(`^\s*(\S+)\s+\[\s*(\S+)[^\]]*?(?:\((\d+)\))?\s*\]`)
+
+// OpenRCBackend drives OpenRC through rc-service and rc-status, for hosts
+// such as Alpine or Gentoo that do not run systemd
+type OpenRCBackend struct {
+	logDir string
+	user   bool
+	logger *slog.Logger
+}
+
+// NewOpenRCBackend creates an OpenRC backend reading service logs from
+// logDir/<service>.log
+func NewOpenRCBackend(logDir string, logger *slog.Logger) *OpenRCBackend {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if logDir == "" {
+		logDir = "/var/log"
+	}
+	return &OpenRCBackend{logDir: logDir, logger: logger}
+}
+
+// SetUser controls user services (rc-service --user) instead of system
+// services
+func (rc *OpenRCBackend) SetUser(user bool) {
+	rc.user = user
+}
+
+// Name implements Backend
+func (rc *OpenRCBackend) Name() string {
+	return "openrc"
+}
+
+// Status implements Backend
+func (rc *OpenRCBackend) Status(ctx context.Context, unit string) (UnitState, error) {
+	// rc-service status exits non-zero for stopped and crashed services
+	// but still prints the state
+	output, err := rc.run(ctx, queryTimeout, "rc-service", openrcName(unit), "status")
+	match := openrcStatus.FindStringSubmatch(output)
+	if match == nil {
+		if err == nil {
+			err = errors.New("unexpected rc-service output: " + output)
+		}
+		return UnitState{}, err
+	}
+	return UnitState{Name: unit, State: mapState(openrcStates, match[1]), Raw: match[1]}, nil
+}
+
+// Start implements Backend
+func (rc *OpenRCBackend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	_, err := rc.run(ctx, timeout, "rc-service", openrcName(unit), "start")
+	return err
+}
+
+// Stop implements Backend
+func (rc *OpenRCBackend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	_, err := rc.run(ctx, timeout, "rc-service", openrcName(unit), "stop")
+	return err
+}
+
+// Restart implements Backend
+func (rc *OpenRCBackend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	_, err := rc.run(ctx, timeout, "rc-service", openrcName(unit), "restart")
+	return err
+}
+
+// List implements Backend. Units are reported with the .service suffix
+// the rest of the application uses.
+func (rc *OpenRCBackend) List(ctx context.Context) ([]UnitState, error) {
+	output, err := rc.run(ctx, queryTimeout, "rc-status", "--servicelist")
+	if err != nil {
+		return nil, err
+	}
+
+	var units []UnitState
+	for _, line := range strings.Split(output, "\n") {
+		match := openrcListEntry.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		restarts, _ := strconv.Atoi(match[3])
+		units = append(units, UnitState{
+			Name:     match[1] + ".service",
+			State:    mapState(openrcStates, match[2]),
+			Raw:      match[2],
+			Restarts: restarts,
+		})
+	}
+	return units, nil
+}
+
+// Logs implements Backend by tailing logDir/<service>.log, as OpenRC has
+// no journal. A missing log file yields no lines.
+func (rc *OpenRCBackend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	f, err := os.Open(filepath.Join(rc.logDir, openrcName(unit)+".log"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return tailLines(f, lines)
+}
+
+// Command implements Commander
+func (rc *OpenRCBackend) Command(action, unit string) string {
+	if rc.user {
+		return "rc-service --user " + openrcName(unit) + " " + action
+	}
+	return "rc-service " + openrcName(unit) + " " + action
+}
+
+// run runs an OpenRC tool, passing --user for user services
+func (rc *OpenRCBackend) run(ctx context.Context, timeout time.Duration, name string, args ...string) (string, error) {
+	if rc.user {
+		args = append([]string{"--user"}, args...)
+	}
+	return runTool(ctx, rc.logger, timeout, name, args...)
+}
+
+// openrcName strips the .service suffix the allowlist adds to every name
+func openrcName(unit string) string {
+	return strings.TrimSuffix(unit, ".service")
+}
+
+// tailBytesPerLine estimates line length when seeking back from the end of
+// a log file
+const tailBytesPerLine = 512
+
+// tailLines returns the last n lines of f, oldest first
+func tailLines(f *os.File, n int) ([]string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(info.Size()-int64(n)*tailBytesPerLine, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimRight(string(data), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	lines := strings.Split(text, "\n")
+	// The first line is likely cut off when reading from the middle
+	if offset > 0 && len(lines) > 1 {
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
@@ -0,0 +1,100 @@
+// internal/service/plugin.go
+package service
+
+import (
+	"context"
+	"time"
+
+	"sysdwitch/internal/plugin"
+)
+
+// PluginBackend forwards backend calls to an external plugin executable
+type PluginBackend struct {
+	plugin *plugin.Plugin
+}
+
+// NewPluginBackend creates a backend served by p
+func NewPluginBackend(p *plugin.Plugin) *PluginBackend {
+	return &PluginBackend{plugin: p}
+}
+
+// pluginUnit is a unit state on the wire
+type pluginUnit struct {
+	Name     string     `json:"name"`
+	State    string     `json:"state"`
+	Restarts int        `json:"restarts,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// unitState maps a plugin's state, canonical or in systemd's vocabulary,
+// onto a UnitState named unit
+func (u pluginUnit) unitState(unit string) UnitState {
+	state, ok := ParseState(u.State)
+	if !ok {
+		state = StateUnknown
+	}
+	return UnitState{Name: unit, State: state, Raw: u.State, Restarts: u.Restarts, Since: u.Since}
+}
+
+// pluginParams are the parameters of backend calls
+type pluginParams struct {
+	Unit    string `json:"unit,omitempty"`
+	Timeout int    `json:"timeout_seconds,omitempty"`
+	Lines   int    `json:"lines,omitempty"`
+}
+
+// Name implements Backend
+func (pb *PluginBackend) Name() string {
+	return "plugin " + pb.plugin.Name
+}
+
+// Status implements Backend
+func (pb *PluginBackend) Status(ctx context.Context, unit string) (UnitState, error) {
+	var state pluginUnit
+	if err := pb.plugin.Call(ctx, "status", pluginParams{Unit: unit}, &state); err != nil {
+		return UnitState{}, err
+	}
+	return state.unitState(unit), nil
+}
+
+// Start implements Backend
+func (pb *PluginBackend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	return pb.control(ctx, "start", unit, timeout)
+}
+
+// Stop implements Backend
+func (pb *PluginBackend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	return pb.control(ctx, "stop", unit, timeout)
+}
+
+// Restart implements Backend
+func (pb *PluginBackend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	return pb.control(ctx, "restart", unit, timeout)
+}
+
+// control runs a control action, giving the plugin until timeout
+func (pb *PluginBackend) control(ctx context.Context, action, unit string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return pb.plugin.Call(ctx, action, pluginParams{Unit: unit, Timeout: int(timeout.Seconds())}, nil)
+}
+
+// List implements Backend
+func (pb *PluginBackend) List(ctx context.Context) ([]UnitState, error) {
+	var units []pluginUnit
+	if err := pb.plugin.Call(ctx, "list", nil, &units); err != nil {
+		return nil, err
+	}
+	states := make([]UnitState, len(units))
+	for i, u := range units {
+		states[i] = u.unitState(u.Name)
+	}
+	return states, nil
+}
+
+// Logs implements Backend
+func (pb *PluginBackend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	var logs []string
+	err := pb.plugin.Call(ctx, "logs", pluginParams{Unit: unit, Lines: lines}, &logs)
+	return logs, err
+}
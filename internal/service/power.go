@@ -0,0 +1,42 @@
+// internal/service/power.go
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// errInvalidPowerAction is returned for actions outside PowerActions
+var errInvalidPowerAction = errors.New("invalid power action")
+
+// PowerActions are the host power actions systemctl asks logind for
+var PowerActions = []string{"suspend", "hibernate", "reboot", "poweroff"}
+
+// HostPower suspends, hibernates, reboots or powers off the host through
+// logind and returns the command it ran. The user running sysdwitch must
+// be allowed to by polkit, which active local sessions usually are.
+func (sm *ServiceManager) HostPower(ctx context.Context, action string) (string, error) {
+	if !slices.Contains(PowerActions, action) {
+		return "", errInvalidPowerAction
+	}
+
+	command := "systemctl " + action
+	if sm.isDryRun(ctx) {
+		sm.logger.InfoContext(ctx, "dry run: skipping host power action",
+			"command", command)
+		return command, nil
+	}
+
+	sm.logger.WarnContext(ctx, "running host power action",
+		"action", action)
+	_, err := runTool(ctx, sm.logger, queryTimeout, "systemctl", action)
+	return command, err
+}
+
+// AllServices returns the allowed services in configuration order
+func (sm *ServiceManager) AllServices() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return slices.Clone(sm.order)
+}
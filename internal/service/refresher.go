@@ -0,0 +1,118 @@
+// internal/service/refresher.go
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// refreshWorkers bounds the units read in parallel by a refresh pass, so a
+// slow init system is not asked for every unit at once
+const refreshWorkers = 8
+
+// statusTable holds the last state read of each unit while the refresher
+// runs; otherwise it stays empty and every status is read on demand
+type statusTable struct {
+	running atomic.Bool
+	mu      sync.RWMutex
+	entries map[string]tableEntry
+}
+
+// tableEntry is a unit's status as last read, without metadata
+type tableEntry struct {
+	status ServiceStatus
+	err    error
+	// read is when the read began; an older read never replaces a newer
+	// one, such as the read after an action
+	read time.Time
+}
+
+// get returns a unit's last read status while the refresher runs
+func (t *statusTable) get(serviceName string) (tableEntry, bool) {
+	if !t.running.Load() {
+		return tableEntry{}, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entry, ok := t.entries[serviceName]
+	return entry, ok
+}
+
+// put records a read begun at read while the refresher runs
+func (t *statusTable) put(serviceName string, status ServiceStatus, err error, read time.Time) {
+	if !t.running.Load() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, ok := t.entries[serviceName]; ok && prev.read.After(read) {
+		return
+	}
+	t.entries[serviceName] = tableEntry{status: status, err: err, read: read}
+}
+
+// retain drops the entries of units no longer allowed
+func (t *statusTable) retain(services []string) {
+	keep := make(map[string]bool, len(services))
+	for _, name := range services {
+		keep[name] = true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name := range t.entries {
+		if !keep[name] {
+			delete(t.entries, name)
+		}
+	}
+}
+
+// RunRefresher reads the state of every allowed unit each interval until
+// ctx is done. Meanwhile GetServiceStatus answers from these reads, so
+// status requests cost the same however many units there are and however
+// slow the init system is; state changes are detected, and their events
+// recorded, here rather than when someone looks.
+func (sm *ServiceManager) RunRefresher(ctx context.Context, interval time.Duration) {
+	sm.states.mu.Lock()
+	sm.states.entries = make(map[string]tableEntry)
+	sm.states.mu.Unlock()
+
+	// Units missing from the table are read on demand, so requests still
+	// get a status while the first pass runs
+	sm.states.running.Store(true)
+	defer sm.states.running.Store(false)
+	sm.refreshAll(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sm.refreshAll(ctx)
+		}
+	}
+}
+
+// refreshAll reads the state of every allowed unit
+func (sm *ServiceManager) refreshAll(ctx context.Context) {
+	started := time.Now()
+	services := sm.AllowedServices()
+	slots := make(chan struct{}, refreshWorkers)
+	var wg sync.WaitGroup
+	for _, name := range services {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			sm.readStatus(ctx, name)
+		})
+	}
+	wg.Wait()
+	sm.states.retain(services)
+
+	sm.logger.DebugContext(ctx, "refreshed unit states",
+		"services", len(services),
+		"duration", time.Since(started))
+}
@@ -0,0 +1,107 @@
+// internal/service/sequence.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// statePollInterval is how often WaitForState re-checks a unit
+const statePollInterval = 500 * time.Millisecond
+
+// WaitForState polls a unit until it reports want (or failed) or timeout
+// passes, returning the last status seen
+func (sm *ServiceManager) WaitForState(ctx context.Context, serviceName string, want State, timeout time.Duration) (ServiceStatus, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(statePollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := sm.RefreshStatus(ctx, serviceName)
+		if errors.Is(err, ErrNotAllowed) {
+			return status, err
+		}
+		switch status.Status {
+		case want:
+			return status, nil
+		case StateFailed:
+			return status, fmt.Errorf("%s is %s", serviceName, status.Status)
+		}
+
+		select {
+		case <-deadline.C:
+			return status, fmt.Errorf("%s did not become %s within %s (now %s)", serviceName, want, timeout, status.Status)
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForChange polls a unit until its status differs from from or timeout
+// passes, returning the last status seen and whether it changed. Failed
+// queries do not count as a change.
+func (sm *ServiceManager) WaitForChange(ctx context.Context, serviceName string, from State, timeout time.Duration) (ServiceStatus, bool) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(statePollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := sm.RefreshStatus(ctx, serviceName)
+		if err == nil && status.Status != from {
+			return status, true
+		}
+
+		select {
+		case <-deadline.C:
+			return status, false
+		case <-ctx.Done():
+			return status, false
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunSequence starts or restarts services in order, or stops them in
+// reverse order, waiting up to wait for each unit to be running (or stopped) before
+// acting on the next. It stops at the first unit that fails and returns
+// the statuses of the units acted on so far.
+func (sm *ServiceManager) RunSequence(ctx context.Context, services []string, action string, wait time.Duration) ([]ServiceStatus, error) {
+	var act func(context.Context, string) (ServiceStatus, error)
+	var want State
+	switch action {
+	case "start":
+		act, want = sm.StartService, StateRunning
+	case "restart":
+		act, want = sm.RestartService, StateRunning
+	case "stop":
+		act, want = sm.StopService, StateStopped
+		services = slices.Clone(services)
+		slices.Reverse(services)
+	default:
+		return nil, fmt.Errorf("unsupported sequence action %q", action)
+	}
+
+	statuses := make([]ServiceStatus, 0, len(services))
+	for _, name := range services {
+		status, err := act(ctx, name)
+		if err != nil {
+			return append(statuses, status), fmt.Errorf("%s %s: %w", action, name, err)
+		}
+		if status.Refused != "" {
+			return append(statuses, status), errors.New(status.Refused)
+		}
+		if !status.DryRun && status.Status != want {
+			if status, err = sm.WaitForState(ctx, name, want, wait); err != nil {
+				return append(statuses, status), err
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,207 @@
+// internal/service/servicetest/backend.go
+package servicetest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// Backend is an in-memory init system for tests. Units start and stop
+// instantly unless told to fail or slowed down, and every action is
+// recorded, so the panel can be exercised without a systemd user session.
+type Backend struct {
+	mu       sync.Mutex
+	units    map[string]*unit
+	failures map[string]error
+	delay    time.Duration
+	calls    []Call
+}
+
+// Call is an action the backend was asked to run
+type Call struct {
+	Action string
+	Unit   string
+}
+
+// unit is the state of one fake unit
+type unit struct {
+	state service.State
+	since time.Time
+	logs  []string
+}
+
+var _ service.Backend = (*Backend)(nil)
+
+// NewBackend returns a backend with the given units, all stopped
+func NewBackend(units ...string) *Backend {
+	b := &Backend{
+		units:    make(map[string]*unit),
+		failures: make(map[string]error),
+	}
+	for _, name := range units {
+		b.SetState(name, service.StateStopped)
+	}
+	return b
+}
+
+// SetState puts a unit, added when missing, into state
+func (b *Backend) SetState(name string, state service.State) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.set(name, state)
+}
+
+// set changes a unit's state; mu must be held
+func (b *Backend) set(name string, state service.State) {
+	u, ok := b.units[name]
+	if !ok {
+		u = &unit{}
+		b.units[name] = u
+	}
+	if u.state != state {
+		u.state, u.since = state, time.Now()
+	}
+}
+
+// Remove deletes a unit, which then reports service.ErrUnitNotFound
+func (b *Backend) Remove(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.units, name)
+}
+
+// Fail makes actions on a unit return err until cleared with a nil err
+func (b *Backend) Fail(name string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.failures, name)
+		return
+	}
+	b.failures[name] = err
+}
+
+// SetDelay makes every action take d, as on a slow init system
+func (b *Backend) SetDelay(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.delay = d
+}
+
+// AddLogs appends log lines to a unit
+func (b *Backend) AddLogs(name string, lines ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if u, ok := b.units[name]; ok {
+		u.logs = append(u.logs, lines...)
+	}
+}
+
+// Calls returns the actions run so far, oldest first
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.calls)
+}
+
+// Name implements service.Backend
+func (b *Backend) Name() string {
+	return "fake"
+}
+
+// Status implements service.Backend
+func (b *Backend) Status(ctx context.Context, name string) (service.UnitState, error) {
+	if err := ctx.Err(); err != nil {
+		return service.UnitState{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	u, ok := b.units[name]
+	if !ok {
+		return service.UnitState{}, fmt.Errorf("%w: %s", service.ErrUnitNotFound, name)
+	}
+	return u.unitState(name), nil
+}
+
+// Start implements service.Backend
+func (b *Backend) Start(ctx context.Context, name string, timeout time.Duration) error {
+	return b.act(ctx, "start", name, service.StateRunning)
+}
+
+// Stop implements service.Backend
+func (b *Backend) Stop(ctx context.Context, name string, timeout time.Duration) error {
+	return b.act(ctx, "stop", name, service.StateStopped)
+}
+
+// Restart implements service.Backend
+func (b *Backend) Restart(ctx context.Context, name string, timeout time.Duration) error {
+	return b.act(ctx, "restart", name, service.StateRunning)
+}
+
+// act records an action and moves the unit to state unless it fails
+func (b *Backend) act(ctx context.Context, action, name string, state service.State) error {
+	b.mu.Lock()
+	delay := b.delay
+	b.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, Call{Action: action, Unit: name})
+	if _, ok := b.units[name]; !ok {
+		return fmt.Errorf("%w: %s", service.ErrUnitNotFound, name)
+	}
+	if err, ok := b.failures[name]; ok {
+		b.set(name, service.StateFailed)
+		return err
+	}
+	b.set(name, state)
+	return nil
+}
+
+// List implements service.Backend
+func (b *Backend) List(ctx context.Context) ([]service.UnitState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	states := make([]service.UnitState, 0, len(b.units))
+	for name, u := range b.units {
+		states = append(states, u.unitState(name))
+	}
+	slices.SortFunc(states, func(x, y service.UnitState) int { return strings.Compare(x.Name, y.Name) })
+	return states, nil
+}
+
+// Logs implements service.Backend
+func (b *Backend) Logs(ctx context.Context, name string, lines int) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	u, ok := b.units[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", service.ErrUnitNotFound, name)
+	}
+	logs := u.logs
+	if len(logs) > lines {
+		logs = logs[len(logs)-lines:]
+	}
+	return slices.Clone(logs), nil
+}
+
+// unitState reports the unit as a backend would
+func (u *unit) unitState(name string) service.UnitState {
+	since := u.since
+	return service.UnitState{Name: name, State: u.state, Raw: string(u.state), Since: &since}
+}
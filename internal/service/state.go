@@ -0,0 +1,68 @@
+// internal/service/state.go
+package service
+
+// State is the canonical state of a unit, the same for every backend.
+// Backends map their own vocabulary onto it; the original word is kept in
+// ServiceStatus.BackendState.
+type State string
+
+// Canonical states
+const (
+	StateRunning  State = "running"
+	StateStopped  State = "stopped"
+	StateStarting State = "starting"
+	StateStopping State = "stopping"
+	StateFailed   State = "failed"
+	// StateDegraded is a unit only partly running, such as a compose stack
+	// with a crashed container or a workload short of ready replicas
+	StateDegraded State = "degraded"
+	// StateUnknown is reported when the state could not be read
+	StateUnknown State = "unknown"
+	// StateNotInstalled is reported for allowed services the init system
+	// has no unit for
+	StateNotInstalled State = "not-installed"
+)
+
+// Active reports whether the unit runs, even if only partly
+func (s State) Active() bool {
+	return s == StateRunning || s == StateDegraded
+}
+
+// Settled reports whether the unit is not in the middle of a transition
+func (s State) Settled() bool {
+	return s != StateStarting && s != StateStopping
+}
+
+// systemdStates maps systemd's ActiveState onto canonical states
+var systemdStates = map[string]State{
+	"active":       StateRunning,
+	"reloading":    StateRunning,
+	"refreshing":   StateRunning,
+	"inactive":     StateStopped,
+	"activating":   StateStarting,
+	"deactivating": StateStopping,
+	"maintenance":  StateStopping,
+	"failed":       StateFailed,
+}
+
+// mapState looks a backend's state up in its table, falling back to
+// StateUnknown
+func mapState(table map[string]State, raw string) State {
+	if state, ok := table[raw]; ok {
+		return state
+	}
+	return StateUnknown
+}
+
+// ParseState reads a canonical state, or a state in systemd's vocabulary
+// as used before states were normalized, such as "active". ok is false
+// for anything else.
+func ParseState(s string) (state State, ok bool) {
+	switch state := State(s); state {
+	case StateRunning, StateStopped, StateStarting, StateStopping, StateFailed,
+		StateDegraded, StateUnknown, StateNotInstalled:
+		return state, true
+	}
+	state, ok = systemdStates[s]
+	return state, ok
+}
@@ -0,0 +1,359 @@
+// internal/service/supervise.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// supervisedDir holds what the runit and s6 backends share: a directory
+// of service directories and svlogd/s6-log output in logDir/<name>/current
+type supervisedDir struct {
+	serviceDir string
+	logDir     string
+	logger     *slog.Logger
+}
+
+// path returns the service directory of a unit
+func (d supervisedDir) path(unit string) string {
+	return filepath.Join(d.serviceDir, supervisedName(unit))
+}
+
+// names lists the services in the service directory
+func (d supervisedDir) names() ([]string, error) {
+	entries, err := os.ReadDir(d.serviceDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		// Service directories are often symlinks; skip dot entries such
+		// as s6-svscan's .s6-svscan
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// logs tails logDir/<name>/current; a missing log yields no lines
+func (d supervisedDir) logs(unit string, lines int) ([]string, error) {
+	f, err := os.Open(filepath.Join(d.logDir, supervisedName(unit), "current"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return tailLines(f, lines)
+}
+
+// supervisedName strips the .service suffix the allowlist adds to every
+// name
+func supervisedName(unit string) string {
+	return strings.TrimSuffix(unit, ".service")
+}
+
+// runTool runs an init-system tool with a timeout and returns its trimmed
+// output, which is kept even when the tool exits non-zero with a
+// *CommandError
+func runTool(ctx context.Context, logger *slog.Logger, timeout time.Duration, name string, args ...string) (string, error) {
+	return runToolIn(ctx, logger, timeout, "", name, args...)
+}
+
+// runToolIn is runTool with dir as the working directory
+func runToolIn(ctx context.Context, logger *slog.Logger, timeout time.Duration, dir, name string, args ...string) (string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := newCommand(timeoutCtx, name, args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		logger.DebugContext(ctx, "command exited with error",
+			"command", name,
+			"args", args,
+			"error", err,
+			"stderr", stderr.String())
+		err = commandError(timeoutCtx, name, args, err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), err
+}
+
+// sinceSeconds converts an "N seconds in this state" reading to a time
+func sinceSeconds(value string) *time.Time {
+	secs, err := strconv.Atoi(value)
+	if err != nil {
+		return nil
+	}
+	t := time.Now().Add(-time.Duration(secs) * time.Second).Truncate(time.Second)
+	return &t
+}
+
+// svStatus matches a line of sv status output, e.g.
+// "run: /var/service/sshd: (pid 123) 45s; run: log: (pid 120) 45s" or
+// "down: /var/service/cron: 12s, normally up, want up"
+var svStatus = regexp.MustCompile(`^(run|down|finish): ([^:]+): (?:\(pid \d+\) )?(\d+)s([^;]*)`)
+
+// RunitBackend drives runit service directories with sv, as on Void Linux
+type RunitBackend struct {
+	supervisedDir
+}
+
+// NewRunitBackend creates a runit backend for the services linked into
+// serviceDir (usually /var/service), reading svlogd logs from
+// logDir/<name>/current
+func NewRunitBackend(serviceDir, logDir string, logger *slog.Logger) *RunitBackend {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if serviceDir == "" {
+		serviceDir = "/var/service"
+	}
+	if logDir == "" {
+		logDir = "/var/log"
+	}
+	return &RunitBackend{supervisedDir{serviceDir: serviceDir, logDir: logDir, logger: logger}}
+}
+
+// Name implements Backend
+func (rb *RunitBackend) Name() string {
+	return "runit"
+}
+
+// Status implements Backend
+func (rb *RunitBackend) Status(ctx context.Context, unit string) (UnitState, error) {
+	output, err := runTool(ctx, rb.logger, queryTimeout, "sv", "status", rb.path(unit))
+	state, ok := parseSvStatus(unit, output)
+	if !ok {
+		if err == nil {
+			err = errors.New("unexpected sv output: " + output)
+		}
+		return UnitState{}, err
+	}
+	return state, nil
+}
+
+// Start implements Backend; sv waits for the service to come up
+func (rb *RunitBackend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	return rb.control(ctx, "start", unit, timeout)
+}
+
+// Stop implements Backend
+func (rb *RunitBackend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	return rb.control(ctx, "stop", unit, timeout)
+}
+
+// Restart implements Backend
+func (rb *RunitBackend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	return rb.control(ctx, "restart", unit, timeout)
+}
+
+// control runs an sv command that waits up to timeout for the result
+func (rb *RunitBackend) control(ctx context.Context, command, unit string, timeout time.Duration) error {
+	wait := strconv.Itoa(max(int(timeout.Seconds()), 1))
+	_, err := runTool(ctx, rb.logger, timeout+5*time.Second, "sv", "-w", wait, command, rb.path(unit))
+	return err
+}
+
+// List implements Backend
+func (rb *RunitBackend) List(ctx context.Context) ([]UnitState, error) {
+	names, err := rb.names()
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	args := []string{"status"}
+	for _, name := range names {
+		args = append(args, rb.path(name))
+	}
+	// sv exits non-zero when any service could not be queried
+	output, _ := runTool(ctx, rb.logger, queryTimeout, "sv", args...)
+
+	var units []UnitState
+	for _, line := range strings.Split(output, "\n") {
+		match := svStatus.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		state, _ := parseSvStatus(filepath.Base(match[2])+".service", line)
+		units = append(units, state)
+	}
+	return units, nil
+}
+
+// Logs implements Backend
+func (rb *RunitBackend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	return rb.logs(unit, lines)
+}
+
+// Command implements Commander
+func (rb *RunitBackend) Command(action, unit string) string {
+	return "sv " + action + " " + rb.path(unit)
+}
+
+// parseSvStatus maps a line of sv status output onto a UnitState
+func parseSvStatus(unit, line string) (UnitState, bool) {
+	match := svStatus.FindStringSubmatch(line)
+	if match == nil {
+		return UnitState{}, false
+	}
+
+	state := UnitState{Name: unit, Raw: match[1], Since: sinceSeconds(match[3])}
+	flags := match[4]
+	switch {
+	case match[1] == "run" && strings.Contains(flags, "want down"):
+		state.State = StateStopping
+	case match[1] == "run":
+		state.State = StateRunning
+	case match[1] == "finish":
+		state.State = StateStopping
+	case strings.Contains(flags, "want up"):
+		state.State = StateStarting
+	default:
+		state.State = StateStopped
+	}
+	return state, true
+}
+
+// s6Status matches s6-svstat output, e.g. "up (pid 123) 45 seconds" or
+// "down (exitcode 1) 3 seconds, normally up, want up"
+var s6Status = regexp.MustCompile(`^(up|down) (?:\((pid \d+|exitcode (\d+)|signal (\w+))\) )?(\d+) seconds(.*)`)
+
+// S6Backend drives s6 service directories with s6-svc and s6-svstat
+type S6Backend struct {
+	supervisedDir
+}
+
+// NewS6Backend creates an s6 backend for the services in the scan
+// directory serviceDir (usually /run/service), reading s6-log logs from
+// logDir/<name>/current
+func NewS6Backend(serviceDir, logDir string, logger *slog.Logger) *S6Backend {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if serviceDir == "" {
+		serviceDir = "/run/service"
+	}
+	if logDir == "" {
+		logDir = "/var/log"
+	}
+	return &S6Backend{supervisedDir{serviceDir: serviceDir, logDir: logDir, logger: logger}}
+}
+
+// Name implements Backend
+func (sb *S6Backend) Name() string {
+	return "s6"
+}
+
+// Status implements Backend
+func (sb *S6Backend) Status(ctx context.Context, unit string) (UnitState, error) {
+	output, err := runTool(ctx, sb.logger, queryTimeout, "s6-svstat", sb.path(unit))
+	if err != nil {
+		return UnitState{}, err
+	}
+	state, ok := parseS6Status(unit, output)
+	if !ok {
+		return UnitState{}, errors.New("unexpected s6-svstat output: " + output)
+	}
+	return state, nil
+}
+
+// Start implements Backend; s6-svc waits for the service to come up
+func (sb *S6Backend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	return sb.control(ctx, "-u", "-wu", unit, timeout)
+}
+
+// Stop implements Backend
+func (sb *S6Backend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	return sb.control(ctx, "-d", "-wD", unit, timeout)
+}
+
+// Restart implements Backend
+func (sb *S6Backend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	return sb.control(ctx, "-r", "-wr", unit, timeout)
+}
+
+// control sends a command to a service and waits up to timeout for it to
+// take effect
+func (sb *S6Backend) control(ctx context.Context, command, wait, unit string, timeout time.Duration) error {
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
+	_, err := runTool(ctx, sb.logger, timeout+5*time.Second, "s6-svc", wait, "-T", ms, command, sb.path(unit))
+	return err
+}
+
+// List implements Backend
+func (sb *S6Backend) List(ctx context.Context) ([]UnitState, error) {
+	names, err := sb.names()
+	if err != nil {
+		return nil, err
+	}
+
+	var units []UnitState
+	for _, name := range names {
+		state, err := sb.Status(ctx, name)
+		if err != nil {
+			// Not a supervised service directory
+			continue
+		}
+		state.Name = name + ".service"
+		units = append(units, state)
+	}
+	return units, nil
+}
+
+// Logs implements Backend
+func (sb *S6Backend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	return sb.logs(unit, lines)
+}
+
+// Command implements Commander
+func (sb *S6Backend) Command(action, unit string) string {
+	flags := map[string]string{"start": "-u", "stop": "-d", "restart": "-r"}
+	if flag, ok := flags[action]; ok {
+		return "s6-svc " + flag + " " + sb.path(unit)
+	}
+	return "s6-svc " + action + " " + sb.path(unit)
+}
+
+// parseS6Status maps s6-svstat output onto a UnitState. A service that is
+// down after exiting non-zero or being killed by a signal it did not ask
+// for counts as failed.
+func parseS6Status(unit, output string) (UnitState, bool) {
+	match := s6Status.FindStringSubmatch(output)
+	if match == nil {
+		return UnitState{}, false
+	}
+
+	state := UnitState{Name: unit, Raw: match[1], Since: sinceSeconds(match[5])}
+	flags := match[6]
+	crashed := (match[3] != "" && match[3] != "0") || (match[4] != "" && match[4] != "SIGTERM")
+	switch {
+	case match[1] == "up" && strings.Contains(flags, "want down"):
+		state.State = StateStopping
+	case match[1] == "up":
+		state.State = StateRunning
+	case strings.Contains(flags, "want up"):
+		state.State = StateStarting
+	case crashed:
+		state.State = StateFailed
+	default:
+		state.State = StateStopped
+	}
+	return state, true
+}
@@ -0,0 +1,215 @@
+// internal/service/systemd.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds read-only helper commands such as journalctl
+const commandTimeout = 10 * time.Second
+
+// SystemdBackend drives the systemd user manager through systemctl and
+// journalctl
+type SystemdBackend struct {
+	logger *slog.Logger
+}
+
+// NewSystemdBackend creates the systemd --user backend
+func NewSystemdBackend(logger *slog.Logger) *SystemdBackend {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SystemdBackend{logger: logger}
+}
+
+// Name implements Backend
+func (sd *SystemdBackend) Name() string {
+	return "systemd"
+}
+
+// Status implements Backend
+func (sd *SystemdBackend) Status(ctx context.Context, unit string) (UnitState, error) {
+	// Unlike is-active, show exits zero for inactive units and also reports
+	// how often systemd restarted the unit on its own. It also exits zero
+	// for units that do not exist, which only LoadState tells apart.
+	props, err := sd.Show(ctx, unit, "LoadState", "ActiveState", "NRestarts", "StateChangeTimestamp")
+	if err != nil {
+		return UnitState{}, err
+	}
+	if props["LoadState"] == "not-found" {
+		return UnitState{}, fmt.Errorf("%w: %s", ErrUnitNotFound, unit)
+	}
+	restarts, _ := strconv.Atoi(props["NRestarts"])
+	return UnitState{
+		Name:     unit,
+		State:    mapState(systemdStates, props["ActiveState"]),
+		Raw:      props["ActiveState"],
+		Restarts: restarts,
+		Since:    parseTimestamp(props["StateChangeTimestamp"]),
+	}, nil
+}
+
+// Start implements Backend
+func (sd *SystemdBackend) Start(ctx context.Context, unit string, timeout time.Duration) error {
+	return sd.control(ctx, timeout, "start", unit)
+}
+
+// Stop implements Backend
+func (sd *SystemdBackend) Stop(ctx context.Context, unit string, timeout time.Duration) error {
+	return sd.control(ctx, timeout, "stop", unit)
+}
+
+// Restart implements Backend
+func (sd *SystemdBackend) Restart(ctx context.Context, unit string, timeout time.Duration) error {
+	return sd.control(ctx, timeout, "restart", unit)
+}
+
+// control runs a systemctl verb on unit. When ctx is canceled, the job
+// systemctl queued outlives it, so the unit's jobs are canceled as well.
+func (sd *SystemdBackend) control(ctx context.Context, timeout time.Duration, verb, unit string) error {
+	_, err := sd.systemctl(ctx, timeout, verb, unit)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		sd.cancelJobs(context.WithoutCancel(ctx), unit)
+	}
+	return err
+}
+
+// cancelJobs cancels the queued and running jobs of unit
+func (sd *SystemdBackend) cancelJobs(ctx context.Context, unit string) {
+	output, err := sd.systemctl(ctx, queryTimeout, "list-jobs", "--plain", "--no-legend", "--no-pager")
+	if err != nil {
+		return
+	}
+	var ids []string
+	for _, line := range strings.Split(output, "\n") {
+		// id unit type state
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[1] == unit {
+			ids = append(ids, fields[0])
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if _, err := sd.systemctl(ctx, queryTimeout, append([]string{"cancel"}, ids...)...); err == nil {
+		sd.logger.InfoContext(ctx, "canceled systemd jobs", "unit", unit, "jobs", ids)
+	}
+}
+
+// List implements Backend
+func (sd *SystemdBackend) List(ctx context.Context) ([]UnitState, error) {
+	output, err := sd.systemctl(ctx, queryTimeout, "list-units", "--all", "--plain", "--no-legend", "--no-pager")
+	if err != nil {
+		return nil, err
+	}
+
+	var units []UnitState
+	for _, line := range strings.Split(output, "\n") {
+		// unit load active sub description
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		units = append(units, UnitState{Name: fields[0], State: mapState(systemdStates, fields[2]), Raw: fields[2]})
+	}
+	return units, nil
+}
+
+// Logs implements Backend
+func (sd *SystemdBackend) Logs(ctx context.Context, unit string, lines int) ([]string, error) {
+	journal, err := sd.Output(ctx, "journalctl", "--user", "--unit", unit,
+		"--lines", strconv.Itoa(lines), "--no-pager", "--output", "short-iso")
+	if err != nil || journal == "" || journal == "-- No entries --" {
+		return nil, err
+	}
+	return strings.Split(journal, "\n"), nil
+}
+
+// Command implements Commander
+func (sd *SystemdBackend) Command(action, unit string) string {
+	return "systemctl --user " + action + " " + unit
+}
+
+// Ping implements Pinger by checking the user manager answers
+func (sd *SystemdBackend) Ping(ctx context.Context) error {
+	_, err := sd.systemctl(ctx, queryTimeout, "show", "--property=Version")
+	return err
+}
+
+// Usage implements UsageReporter from systemd's cgroup accounting
+func (sd *SystemdBackend) Usage(ctx context.Context, unit string) (uint64, bool, uint64, error) {
+	props, err := sd.Show(ctx, unit, "CPUUsageNSec", "MemoryCurrent")
+	if err != nil {
+		return 0, false, 0, err
+	}
+	// Stopped units and units without accounting report "[not set]"
+	cpu, cpuErr := strconv.ParseUint(props["CPUUsageNSec"], 10, 64)
+	memory, _ := strconv.ParseUint(props["MemoryCurrent"], 10, 64)
+	return cpu, cpuErr == nil, memory, nil
+}
+
+// Show returns the given properties of a unit
+func (sd *SystemdBackend) Show(ctx context.Context, unit string, properties ...string) (map[string]string, error) {
+	output, err := sd.systemctl(ctx, queryTimeout, "show", "--property="+strings.Join(properties, ","), unit)
+	if err != nil {
+		return nil, err
+	}
+	return parseProperties(output), nil
+}
+
+// DaemonReload makes systemd re-read unit files and drop-ins
+func (sd *SystemdBackend) DaemonReload(ctx context.Context) error {
+	_, err := sd.systemctl(ctx, queryTimeout, "daemon-reload")
+	return err
+}
+
+// systemctl runs systemctl --user with a timeout and returns its trimmed
+// output, or a *CommandError when it exits non-zero
+func (sd *SystemdBackend) systemctl(ctx context.Context, timeout time.Duration, args ...string) (string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := newCommand(timeoutCtx, "systemctl", append([]string{"--user"}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		sd.logger.ErrorContext(ctx, "systemctl command failed",
+			"args", args,
+			"error", err,
+			"stderr", stderr.String())
+		return "", commandError(timeoutCtx, "systemctl", append([]string{"--user"}, args...), err, stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Output runs a read-only command and returns its output even when it
+// exits non-zero, as systemctl status does for inactive units
+func (sd *SystemdBackend) Output(ctx context.Context, name string, args ...string) (string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	cmd := newCommand(timeoutCtx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		sd.logger.DebugContext(ctx, "command exited with error",
+			"command", name,
+			"args", args,
+			"error", err,
+			"stderr", stderr.String())
+	}
+	return strings.TrimRight(stdout.String(), "\n"), err
+}
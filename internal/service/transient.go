@@ -0,0 +1,92 @@
+// internal/service/transient.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transientPrefix names the transient units started by RunTransient
+const transientPrefix = "sysdwitch-task-"
+
+// TransientUnit returns the unit name a task runs as. The name is fixed
+// per task so its journal collects every run and systemd refuses to run
+// the same task twice at once.
+func TransientUnit(task string) string {
+	return transientPrefix + task + ".service"
+}
+
+// RunTransient runs command as a transient user unit with systemd-run and
+// waits for it to exit, killing it after timeout. Output goes to the
+// journal of the unit. The returned status is stopped after a clean
+// exit and failed otherwise.
+func (sm *ServiceManager) RunTransient(ctx context.Context, task string, command []string, timeout time.Duration) (ServiceStatus, error) {
+	unit := TransientUnit(task)
+	if _, ok := sm.systemd(unit); !ok {
+		return ServiceStatus{}, unsupported(sm.backendOf(unit), "tasks")
+	}
+
+	args := []string{
+		"--user",
+		"--unit=" + unit,
+		"--collect",
+		"--wait",
+		"--quiet",
+		"--property=RuntimeMaxSec=" + strconv.Itoa(int(timeout.Seconds())),
+		"--",
+	}
+	args = append(args, command...)
+
+	if sm.isDryRun(ctx) {
+		commandLine := "systemd-run " + strings.Join(args, " ")
+		sm.logger.InfoContext(ctx, "dry run: skipping systemd-run",
+			"task", task,
+			"command", commandLine)
+		return ServiceStatus{Name: unit, Status: StateStopped, DryRun: true, Command: commandLine}, nil
+	}
+
+	ctx, done := sm.track(ctx, unit, "run")
+	defer done()
+
+	// Leave systemd room to enforce RuntimeMaxSec itself
+	runCtx, cancel := context.WithTimeout(ctx, timeout+30*time.Second)
+	defer cancel()
+
+	cmd := newCommand(runCtx, "systemd-run", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	sm.logger.InfoContext(ctx, "running task",
+		"task", task,
+		"unit", unit)
+	if err := cmd.Run(); err != nil {
+		sm.logger.ErrorContext(ctx, "task failed",
+			"task", task,
+			"unit", unit,
+			"error", err,
+			"stderr", stderr.String())
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			// Killing systemd-run leaves the unit running
+			if sd, ok := sm.systemd(unit); ok {
+				sd.systemctl(context.WithoutCancel(ctx), queryTimeout, "stop", unit)
+			}
+			err = contextError(ctx)
+		}
+		return ServiceStatus{Name: unit, Status: StateFailed}, err
+	}
+	return ServiceStatus{Name: unit, Status: StateStopped}, nil
+}
+
+// TransientJournal returns the most recent journal lines of a task's
+// transient unit, oldest first
+func (sm *ServiceManager) TransientJournal(ctx context.Context, task string, lines int) ([]string, error) {
+	return sm.Backend().Logs(ctx, TransientUnit(task), lines)
+}
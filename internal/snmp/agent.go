@@ -0,0 +1,400 @@
+// internal/snmp/agent.go
+package snmp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"sysdwitch/internal/service"
+)
+
+// DefaultOID roots the service table in net-snmp's experimental subtree
+// until the project has an enterprise number of its own
+const DefaultOID = "1.3.6.1.4.1.8072.9999.9999"
+
+// PDU types
+const (
+	pduGet      = 0xa0
+	pduGetNext  = 0xa1
+	pduResponse = 0xa2
+	pduSet      = 0xa3
+	pduGetBulk  = 0xa5
+)
+
+// Protocol versions as carried in messages
+const (
+	versionV1  = 0
+	versionV2c = 1
+)
+
+// Error statuses of responses
+const (
+	errNoSuchName = 2
+	errNoAccess   = 6
+)
+
+// Columns of the service table
+const (
+	columnIndex  = 1
+	columnName   = 2
+	columnStatus = 3
+	columnUp     = 4
+)
+
+// maxBindings bounds the variables in one GetBulk response
+const maxBindings = 64
+
+// cacheFor is how long service states are reused across requests, so a
+// walk of the table costs one status query rather than one per variable
+const cacheFor = 5 * time.Second
+
+// system is the MIB-2 system group
+var system = OID{1, 3, 6, 1, 2, 1, 1}
+
+// errWrongCommunity drops requests with another community string
+var errWrongCommunity = errors.New("wrong community")
+
+// Agent answers SNMPv1 and v2c Get, GetNext and GetBulk requests for the
+// states of services, like a switch answers for its ports. It is
+// read-only: Set requests are refused.
+type Agent struct {
+	community string
+	base      OID
+	descr     string
+	sysName   string
+	services  func(context.Context) []service.ServiceStatus
+	logger    *slog.Logger
+	started   time.Time
+
+	mu       sync.Mutex
+	statuses []service.ServiceStatus
+	cachedAt time.Time
+}
+
+// binding is a variable binding: a name and its value
+type binding struct {
+	oid   OID
+	value value
+}
+
+// pdu is a request or response
+type pdu struct {
+	kind      byte
+	requestID int64
+	// errStatus and errIndex are non-repeaters and max-repetitions in a
+	// GetBulk request
+	errStatus int64
+	errIndex  int64
+	bindings  []binding
+}
+
+// message is an SNMPv1 or v2c message
+type message struct {
+	version   int64
+	community string
+	pdu       pdu
+}
+
+// NewAgent creates an agent answering requests that carry community. The
+// service table is rooted at base; descr is reported as sysDescr.
+func NewAgent(community string, base OID, descr string, services func(context.Context) []service.ServiceStatus, logger *slog.Logger) *Agent {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	name, _ := os.Hostname()
+	return &Agent{
+		community: community,
+		base:      base,
+		descr:     descr,
+		sysName:   name,
+		services:  services,
+		logger:    logger,
+		started:   time.Now(),
+	}
+}
+
+// Serve answers requests on the UDP address until ctx is cancelled
+func (a *Agent) Serve(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return a.ServeConn(ctx, conn)
+}
+
+// ServeConn answers requests on conn until ctx is cancelled, then closes it
+func (a *Agent) ServeConn(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	a.logger.Info("SNMP agent listening", "address", conn.LocalAddr().String())
+
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		response, err := a.Handle(ctx, buf[:n])
+		if err != nil {
+			a.logger.Debug("SNMP request dropped", "error", err, "remote_addr", from.String())
+			continue
+		}
+		if _, err := conn.WriteTo(response, from); err != nil {
+			a.logger.Debug("failed to send SNMP response", "error", err, "remote_addr", from.String())
+		}
+	}
+}
+
+// Handle answers one request packet. Packets that cannot be answered,
+// such as those with a wrong community, return an error and get no
+// response, as SNMP agents silently drop them.
+func (a *Agent) Handle(ctx context.Context, packet []byte) ([]byte, error) {
+	msg, err := parseMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	if msg.version != versionV1 && msg.version != versionV2c {
+		return nil, fmt.Errorf("unsupported SNMP version %d", msg.version)
+	}
+	if subtle.ConstantTimeCompare([]byte(msg.community), []byte(a.community)) != 1 {
+		return nil, errWrongCommunity
+	}
+
+	req := msg.pdu
+	resp := pdu{kind: pduResponse, requestID: req.requestID}
+	v1 := msg.version == versionV1
+	switch req.kind {
+	case pduGet, pduGetNext:
+		vars := a.variables(ctx)
+		for i, b := range req.bindings {
+			var found binding
+			var ok bool
+			if req.kind == pduGet {
+				found, ok = lookup(vars, b.oid)
+			} else {
+				found, ok = next(vars, b.oid)
+			}
+			switch {
+			case ok:
+				resp.bindings = append(resp.bindings, found)
+			case v1:
+				// SNMPv1 fails the whole request on the first miss
+				return encodeMessage(message{msg.version, msg.community, pdu{
+					kind: pduResponse, requestID: req.requestID,
+					errStatus: errNoSuchName, errIndex: int64(i + 1), bindings: req.bindings,
+				}}), nil
+			case req.kind == pduGet:
+				resp.bindings = append(resp.bindings, binding{b.oid, value{tag: tagNoSuchObject}})
+			default:
+				resp.bindings = append(resp.bindings, binding{b.oid, value{tag: tagEndOfMibView}})
+			}
+		}
+	case pduGetBulk:
+		if v1 {
+			return nil, errors.New("GetBulk in an SNMPv1 message")
+		}
+		resp.bindings = bulk(a.variables(ctx), req)
+	case pduSet:
+		resp.errStatus, resp.errIndex, resp.bindings = errNoAccess, 1, req.bindings
+		if v1 {
+			resp.errStatus = errNoSuchName
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PDU type %#x", req.kind)
+	}
+	return encodeMessage(message{msg.version, msg.community, resp}), nil
+}
+
+// bulk answers a GetBulk request: GetNext for the first non-repeaters
+// bindings, then repeatedly for the others
+func bulk(vars []binding, req pdu) []binding {
+	nonRepeaters := int(min(max(req.errStatus, 0), int64(len(req.bindings))))
+	repetitions := int(min(max(req.errIndex, 0), maxBindings))
+
+	var result []binding
+	for _, b := range req.bindings[:nonRepeaters] {
+		result = append(result, nextOrEnd(vars, b.oid))
+	}
+	cursors := make([]OID, 0, len(req.bindings)-nonRepeaters)
+	for _, b := range req.bindings[nonRepeaters:] {
+		cursors = append(cursors, b.oid)
+	}
+	for range repetitions {
+		if len(cursors) == 0 || len(result)+len(cursors) > maxBindings {
+			break
+		}
+		ended := true
+		for i, cursor := range cursors {
+			found := nextOrEnd(vars, cursor)
+			result = append(result, found)
+			cursors[i] = found.oid
+			ended = ended && found.value.tag == tagEndOfMibView
+		}
+		if ended {
+			break
+		}
+	}
+	return result
+}
+
+// lookup finds the variable named oid
+func lookup(vars []binding, oid OID) (binding, bool) {
+	i, ok := slices.BinarySearchFunc(vars, oid, func(b binding, oid OID) int { return slices.Compare(b.oid, oid) })
+	if !ok {
+		return binding{}, false
+	}
+	return vars[i], true
+}
+
+// next finds the first variable after oid in lexicographic order
+func next(vars []binding, oid OID) (binding, bool) {
+	i, found := slices.BinarySearchFunc(vars, oid, func(b binding, oid OID) int { return slices.Compare(b.oid, oid) })
+	if found {
+		i++
+	}
+	if i >= len(vars) {
+		return binding{}, false
+	}
+	return vars[i], true
+}
+
+func nextOrEnd(vars []binding, oid OID) binding {
+	if found, ok := next(vars, oid); ok {
+		return found
+	}
+	return binding{oid, value{tag: tagEndOfMibView}}
+}
+
+// variables lists the agent's MIB in order: the system group, the number
+// of services at base.1.0 and the service table at base.2, whose rows
+// are numbered from 1 in allowlist order
+func (a *Agent) variables(ctx context.Context) []binding {
+	statuses := a.cachedStatuses(ctx)
+	uptime := uint32(time.Since(a.started) / (10 * time.Millisecond))
+	vars := []binding{
+		{system.Append(1, 0), octetString(a.descr)},
+		{system.Append(2, 0), objectID(a.base)},
+		{system.Append(3, 0), timeTicks(uptime)},
+		{system.Append(5, 0), octetString(a.sysName)},
+		{a.base.Append(1, 0), integer(int64(len(statuses)))},
+	}
+	entry := a.base.Append(2, 1)
+	for i, s := range statuses {
+		row := uint32(i + 1)
+		up := int64(2)
+		if s.Active {
+			up = 1
+		}
+		vars = append(vars,
+			binding{entry.Append(columnIndex, row), integer(int64(row))},
+			binding{entry.Append(columnName, row), octetString(s.Name)},
+			binding{entry.Append(columnStatus, row), octetString(string(s.Status))},
+			binding{entry.Append(columnUp, row), integer(up)},
+		)
+	}
+	slices.SortFunc(vars, func(x, y binding) int { return slices.Compare(x.oid, y.oid) })
+	return vars
+}
+
+// cachedStatuses returns service states no older than cacheFor
+func (a *Agent) cachedStatuses(ctx context.Context) []service.ServiceStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.statuses == nil || time.Since(a.cachedAt) > cacheFor {
+		a.statuses = a.services(ctx)
+		a.cachedAt = time.Now()
+	}
+	return a.statuses
+}
+
+// parseMessage decodes a message and its PDU
+func parseMessage(packet []byte) (message, error) {
+	var msg message
+	body, _, err := readExpected(packet, tagSequence)
+	if err != nil {
+		return msg, err
+	}
+	content, body, err := readExpected(body, tagInteger)
+	if err != nil {
+		return msg, err
+	}
+	if msg.version, err = decodeInt(content); err != nil {
+		return msg, err
+	}
+	content, body, err = readExpected(body, tagOctetString)
+	if err != nil {
+		return msg, err
+	}
+	msg.community = string(content)
+
+	kind, body, _, err := readTLV(body)
+	if err != nil {
+		return msg, err
+	}
+	msg.pdu.kind = kind
+	for _, field := range []*int64{&msg.pdu.requestID, &msg.pdu.errStatus, &msg.pdu.errIndex} {
+		if content, body, err = readExpected(body, tagInteger); err != nil {
+			return msg, err
+		}
+		if *field, err = decodeInt(content); err != nil {
+			return msg, err
+		}
+	}
+
+	list, _, err := readExpected(body, tagSequence)
+	if err != nil {
+		return msg, err
+	}
+	for len(list) > 0 {
+		var vb []byte
+		if vb, list, err = readExpected(list, tagSequence); err != nil {
+			return msg, err
+		}
+		content, vb, err := readExpected(vb, tagOID)
+		if err != nil {
+			return msg, err
+		}
+		oid, err := decodeOID(content)
+		if err != nil {
+			return msg, err
+		}
+		tag, content, _, err := readTLV(vb)
+		if err != nil {
+			return msg, err
+		}
+		msg.pdu.bindings = append(msg.pdu.bindings, binding{oid, value{tag, content}})
+	}
+	return msg, nil
+}
+
+// encodeMessage encodes a response message
+func encodeMessage(msg message) []byte {
+	var list []byte
+	for _, b := range msg.pdu.bindings {
+		vb := appendTLV(nil, tagOID, encodeOID(b.oid))
+		vb = appendTLV(vb, b.value.tag, b.value.content)
+		list = appendTLV(list, tagSequence, vb)
+	}
+	p := appendTLV(nil, tagInteger, encodeInt(msg.pdu.requestID))
+	p = appendTLV(p, tagInteger, encodeInt(msg.pdu.errStatus))
+	p = appendTLV(p, tagInteger, encodeInt(msg.pdu.errIndex))
+	p = appendTLV(p, tagSequence, list)
+
+	body := appendTLV(nil, tagInteger, encodeInt(msg.version))
+	body = appendTLV(body, tagOctetString, []byte(msg.community))
+	body = appendTLV(body, msg.pdu.kind, p)
+	return appendTLV(nil, tagSequence, body)
+}
@@ -0,0 +1,199 @@
+// internal/snmp/ber.go
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BER tags of the types SNMPv1 and v2c use
+const (
+	tagInteger      = 0x02
+	tagOctetString  = 0x04
+	tagOID          = 0x06
+	tagSequence     = 0x30
+	tagTimeTicks    = 0x43
+	tagNoSuchObject = 0x80
+	tagEndOfMibView = 0x82
+)
+
+// errMalformed rejects packets that are not valid BER
+var errMalformed = errors.New("malformed packet")
+
+// OID is an object identifier such as 1.3.6.1.2.1.1.3.0
+type OID []uint32
+
+// ParseOID reads a dotted object identifier; a leading dot is allowed
+func ParseOID(s string) (OID, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q: expected at least two numbers", s)
+	}
+	oid := make(OID, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid[i] = uint32(n)
+	}
+	if oid[0] > 2 || oid[0] < 2 && oid[1] >= 40 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return oid, nil
+}
+
+func (o OID) String() string {
+	parts := make([]string, len(o))
+	for i, n := range o {
+		parts[i] = strconv.FormatUint(uint64(n), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// Append returns a new OID with arcs added to o
+func (o OID) Append(arcs ...uint32) OID {
+	return append(slices.Clip(o), arcs...)
+}
+
+// value is a typed variable value: its tag and BER content
+type value struct {
+	tag     byte
+	content []byte
+}
+
+func integer(n int64) value {
+	return value{tagInteger, encodeInt(n)}
+}
+
+func octetString(s string) value {
+	return value{tagOctetString, []byte(s)}
+}
+
+func timeTicks(n uint32) value {
+	return value{tagTimeTicks, encodeInt(int64(n))}
+}
+
+func objectID(o OID) value {
+	return value{tagOID, encodeOID(o)}
+}
+
+// appendTLV appends a tag, its length and content
+func appendTLV(b []byte, tag byte, content []byte) []byte {
+	b = append(b, tag)
+	switch n := len(content); {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n <= 0xff:
+		b = append(b, 0x81, byte(n))
+	default:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	}
+	return append(b, content...)
+}
+
+// encodeInt encodes n in the fewest two's complement bytes
+func encodeInt(n int64) []byte {
+	b := []byte{byte(n)}
+	for n >>= 8; ; n >>= 8 {
+		// Stop once the remaining bytes only repeat the sign bit
+		if n == 0 && b[0]&0x80 == 0 || n == -1 && b[0]&0x80 != 0 {
+			return b
+		}
+		b = append([]byte{byte(n)}, b...)
+	}
+}
+
+// encodeOID encodes o in base 128, the first two arcs sharing a byte
+func encodeOID(o OID) []byte {
+	b := appendBase128(nil, o[0]*40+o[1])
+	for _, arc := range o[2:] {
+		b = appendBase128(b, arc)
+	}
+	return b
+}
+
+func appendBase128(b []byte, n uint32) []byte {
+	var digits []byte
+	for {
+		digits = append(digits, byte(n&0x7f))
+		if n >>= 7; n == 0 {
+			break
+		}
+	}
+	for i := len(digits) - 1; i > 0; i-- {
+		b = append(b, digits[i]|0x80)
+	}
+	return append(b, digits[0])
+}
+
+// readTLV splits the first tag-length-value off b
+func readTLV(b []byte) (tag byte, content, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errMalformed
+	}
+	tag, n, b := b[0], int(b[1]), b[2:]
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 2 || len(b) < size {
+			return 0, nil, nil, errMalformed
+		}
+		n = 0
+		for _, c := range b[:size] {
+			n = n<<8 | int(c)
+		}
+		b = b[size:]
+	}
+	if len(b) < n {
+		return 0, nil, nil, errMalformed
+	}
+	return tag, b[:n], b[n:], nil
+}
+
+// readExpected reads a TLV that must carry tag
+func readExpected(b []byte, tag byte) (content, rest []byte, err error) {
+	got, content, rest, err := readTLV(b)
+	if err == nil && got != tag {
+		err = fmt.Errorf("%w: expected tag %#x, got %#x", errMalformed, tag, got)
+	}
+	return content, rest, err
+}
+
+func decodeInt(content []byte) (int64, error) {
+	if len(content) == 0 || len(content) > 8 {
+		return 0, errMalformed
+	}
+	n := int64(int8(content[0]))
+	for _, c := range content[1:] {
+		n = n<<8 | int64(c)
+	}
+	return n, nil
+}
+
+func decodeOID(content []byte) (OID, error) {
+	var arcs []uint32
+	var n uint64
+	for i, c := range content {
+		n = n<<7 | uint64(c&0x7f)
+		if n > 0xffffffff {
+			return nil, errMalformed
+		}
+		if c&0x80 != 0 {
+			if i == len(content)-1 {
+				return nil, errMalformed
+			}
+			continue
+		}
+		arcs = append(arcs, uint32(n))
+		n = 0
+	}
+	if len(arcs) == 0 {
+		return nil, errMalformed
+	}
+	first := arcs[0]
+	oid := OID{min(first/40, 2), first - min(first/40, 2)*40}
+	return append(oid, arcs[1:]...), nil
+}
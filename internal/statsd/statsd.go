@@ -0,0 +1,164 @@
+// internal/statsd/statsd.go
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"sysdwitch/internal/audit"
+	"sysdwitch/internal/service"
+)
+
+// maxPacket keeps datagrams within a typical MTU; lines are batched up to it
+const maxPacket = 1432
+
+// Emitter sends counters for actions and failures as they happen and
+// gauges of service states every interval over StatsD UDP
+type Emitter struct {
+	conn      net.Conn
+	prefix    string
+	tags      []string
+	dogstatsd bool
+	services  *service.ServiceManager
+	logger    *slog.Logger
+}
+
+// Dial creates an emitter sending to the UDP address addr. Metric names
+// start with prefix. With dogstatsd, tags are appended in the DogStatsD
+// "|#key:value" form; otherwise the service and action of a metric are
+// folded into its name and tags are not sent.
+func Dial(addr, prefix string, tags []string, dogstatsd bool, services *service.ServiceManager, logger *slog.Logger) (*Emitter, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Emitter{
+		conn:      conn,
+		prefix:    prefix,
+		tags:      tags,
+		dogstatsd: dogstatsd,
+		services:  services,
+		logger:    logger.With("component", "statsd"),
+	}, nil
+}
+
+// Close closes the UDP socket
+func (e *Emitter) Close() error {
+	return e.conn.Close()
+}
+
+// Record counts service actions and failures; subscribe it to the audit
+// recorder
+func (e *Emitter) Record(event audit.Event) {
+	var line string
+	switch event.Type {
+	case audit.EventServiceAction:
+		action, _ := event.Fields["action"].(string)
+		line = e.line("actions", "1|c", "service", event.Service, "action", action)
+	case audit.EventServiceFailed:
+		line = e.line("failures", "1|c", "service", event.Service)
+	default:
+		return
+	}
+	e.send([]byte(line))
+}
+
+// Run sends gauges every interval until ctx is done
+func (e *Emitter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.gauges(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// gauges sends the number of allowed and active services and whether
+// each one is active, batching lines into as few datagrams as fit
+func (e *Emitter) gauges(ctx context.Context) {
+	statuses := e.services.GetAllServicesStatus(ctx)
+	active := 0
+	lines := make([]string, 0, len(statuses)+2)
+	for _, s := range statuses {
+		value := "0|g"
+		if s.Active {
+			active++
+			value = "1|g"
+		}
+		lines = append(lines, e.line("service.active", value, "service", s.Name))
+	}
+	lines = append(lines,
+		e.line("services.active", fmt.Sprintf("%d|g", active)),
+		e.line("services.total", fmt.Sprintf("%d|g", len(statuses))))
+
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacket {
+			e.send(packet.Bytes())
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		e.send(packet.Bytes())
+	}
+}
+
+// line formats a metric such as "sysdwitch.actions:1|c|#service:x" from
+// its name, value and type, and key/value pairs of dimensions
+func (e *Emitter) line(name, value string, dims ...string) string {
+	var tags []string
+	if e.dogstatsd {
+		tags = append(tags, e.tags...)
+	}
+	for i := 0; i+1 < len(dims); i += 2 {
+		if dims[i+1] == "" {
+			continue
+		}
+		if e.dogstatsd {
+			tags = append(tags, dims[i]+":"+sanitize(dims[i+1], ",|"))
+		} else {
+			name += "." + sanitize(dims[i+1], ".:|@")
+		}
+	}
+
+	line := e.prefix + name + ":" + value
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// sanitize replaces characters StatsD reserves, and those in reserved,
+// with underscores
+func sanitize(s, reserved string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == ' ' || strings.ContainsRune(reserved, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// send writes one datagram. StatsD is fire-and-forget, so errors, such
+// as nothing listening, are only logged at debug level.
+func (e *Emitter) send(packet []byte) {
+	if _, err := e.conn.Write(packet); err != nil {
+		e.logger.Debug("failed to send statsd packet", "error", err)
+	}
+}
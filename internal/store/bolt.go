@@ -0,0 +1,192 @@
+// internal/store/bolt.go
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltLockTimeout bounds how long a call waits for another process, such
+// as an admin command, to release the database
+const boltLockTimeout = 5 * time.Second
+
+// Buckets of a BoltStore, holding JSON records keyed by user name and
+// token ID
+var (
+	boltUsers  = []byte("users")
+	boltTokens = []byte("tokens")
+)
+
+// BoltStore is a Store in a single bbolt database file. The file is only
+// open, and locked, for the duration of a call, so the admin commands can
+// change it while the panel runs.
+type BoltStore struct {
+	path string
+}
+
+// OpenBolt opens the bbolt database at path, creating it and its buckets
+// when missing
+func OpenBolt(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	bs := &BoltStore{path: path}
+	err := bs.update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltUsers, boltTokens} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// view runs fn in a read-only transaction, sharing the file with other
+// readers
+func (bs *BoltStore) view(fn func(*bolt.Tx) error) error {
+	db, err := bolt.Open(bs.path, 0o600, &bolt.Options{Timeout: boltLockTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction, holding the file exclusively
+func (bs *BoltStore) update(fn func(*bolt.Tx) error) error {
+	db, err := bolt.Open(bs.path, 0o600, &bolt.Options{Timeout: boltLockTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+// boltGet decodes the record under key in bucket into v
+func boltGet(tx *bolt.Tx, bucket []byte, key string, v any) error {
+	raw := tx.Bucket(bucket).Get([]byte(key))
+	if raw == nil {
+		return ErrNotFound
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to parse %s record %q: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// boltPut stores v as the record under key in bucket
+func boltPut(tx *bolt.Tx, bucket []byte, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put([]byte(key), raw)
+}
+
+// boltDelete removes the record under key in bucket
+func boltDelete(tx *bolt.Tx, bucket []byte, key string) error {
+	b := tx.Bucket(bucket)
+	if b.Get([]byte(key)) == nil {
+		return ErrNotFound
+	}
+	return b.Delete([]byte(key))
+}
+
+// GetUser returns the user with the given name
+func (bs *BoltStore) GetUser(ctx context.Context, name string) (User, error) {
+	var user User
+	err := bs.view(func(tx *bolt.Tx) error {
+		return boltGet(tx, boltUsers, name, &user)
+	})
+	return user, err
+}
+
+// PutUser creates or replaces a user
+func (bs *BoltStore) PutUser(ctx context.Context, user User) error {
+	return bs.update(func(tx *bolt.Tx) error {
+		return boltPut(tx, boltUsers, user.Name, user)
+	})
+}
+
+// DeleteUser removes a user
+func (bs *BoltStore) DeleteUser(ctx context.Context, name string) error {
+	return bs.update(func(tx *bolt.Tx) error {
+		return boltDelete(tx, boltUsers, name)
+	})
+}
+
+// ListUsers returns all users sorted by name
+func (bs *BoltStore) ListUsers(ctx context.Context) ([]User, error) {
+	users := []User{}
+	err := bs.view(func(tx *bolt.Tx) error {
+		// Keys are kept in byte order, which is name order
+		return tx.Bucket(boltUsers).ForEach(func(key, raw []byte) error {
+			var user User
+			if err := json.Unmarshal(raw, &user); err != nil {
+				return fmt.Errorf("failed to parse user %q: %w", key, err)
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	return users, err
+}
+
+// GetToken returns the token with the given ID
+func (bs *BoltStore) GetToken(ctx context.Context, id string) (Token, error) {
+	var token Token
+	err := bs.view(func(tx *bolt.Tx) error {
+		return boltGet(tx, boltTokens, id, &token)
+	})
+	return token, err
+}
+
+// PutToken creates or replaces a token
+func (bs *BoltStore) PutToken(ctx context.Context, token Token) error {
+	return bs.update(func(tx *bolt.Tx) error {
+		return boltPut(tx, boltTokens, token.ID, token)
+	})
+}
+
+// DeleteToken removes a token
+func (bs *BoltStore) DeleteToken(ctx context.Context, id string) error {
+	return bs.update(func(tx *bolt.Tx) error {
+		return boltDelete(tx, boltTokens, id)
+	})
+}
+
+// ListTokens returns all tokens, oldest first
+func (bs *BoltStore) ListTokens(ctx context.Context) ([]Token, error) {
+	tokens := []Token{}
+	err := bs.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTokens).ForEach(func(key, raw []byte) error {
+			var token Token
+			if err := json.Unmarshal(raw, &token); err != nil {
+				return fmt.Errorf("failed to parse token %q: %w", key, err)
+			}
+			tokens = append(tokens, token)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// Close implements Store; the database is only open during calls
+func (bs *BoltStore) Close() error {
+	return nil
+}
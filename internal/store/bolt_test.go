@@ -0,0 +1,22 @@
+// internal/store/bolt_test.go
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sysdwitch.db")
+	bs, err := OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bs.Close()
+
+	other, err := OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, bs, other)
+}
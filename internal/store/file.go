@@ -0,0 +1,233 @@
+// internal/store/file.go
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileData is the on-disk layout of a FileStore
+type fileData struct {
+	Users  map[string]User  `json:"users"`
+	Tokens map[string]Token `json:"tokens,omitempty"`
+}
+
+// FileStore is a Store backed by a single JSON file, rewritten atomically
+// on every change. Changes made by another process, such as the admin
+// commands, are picked up on the next call.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+	data fileData
+
+	// modTime and size identify the file contents last read or written
+	modTime time.Time
+	size    int64
+}
+
+// OpenFile opens the JSON store at path, creating it on first write
+func OpenFile(path string) (*FileStore, error) {
+	fs := &FileStore{path: path}
+	if err := fs.reload(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// reload reads the file again when it changed since it was last seen.
+// Callers must hold fs.mu.
+func (fs *FileStore) reload() error {
+	info, err := os.Stat(fs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		if fs.data.Users == nil {
+			fs.data = fileData{Users: make(map[string]User), Tokens: make(map[string]Token)}
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read store: %w", err)
+	}
+	if fs.data.Users != nil && info.ModTime().Equal(fs.modTime) && info.Size() == fs.size {
+		return nil
+	}
+
+	raw, err := os.ReadFile(fs.path)
+	if err != nil {
+		return fmt.Errorf("failed to read store: %w", err)
+	}
+	var data fileData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("failed to parse store %s: %w", fs.path, err)
+	}
+	if data.Users == nil {
+		data.Users = make(map[string]User)
+	}
+	if data.Tokens == nil {
+		data.Tokens = make(map[string]Token)
+	}
+	fs.data, fs.modTime, fs.size = data, info.ModTime(), info.Size()
+	return nil
+}
+
+// GetUser returns the user with the given name
+func (fs *FileStore) GetUser(ctx context.Context, name string) (User, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return User{}, err
+	}
+
+	user, ok := fs.data.Users[name]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// PutUser creates or replaces a user
+func (fs *FileStore) PutUser(ctx context.Context, user User) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return err
+	}
+
+	fs.data.Users[user.Name] = user
+	return fs.flush()
+}
+
+// DeleteUser removes a user
+func (fs *FileStore) DeleteUser(ctx context.Context, name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return err
+	}
+
+	if _, ok := fs.data.Users[name]; !ok {
+		return ErrNotFound
+	}
+	delete(fs.data.Users, name)
+	return fs.flush()
+}
+
+// ListUsers returns all users sorted by name
+func (fs *FileStore) ListUsers(ctx context.Context) ([]User, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(fs.data.Users))
+	for _, user := range fs.data.Users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// GetToken returns the token with the given ID
+func (fs *FileStore) GetToken(ctx context.Context, id string) (Token, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return Token{}, err
+	}
+
+	token, ok := fs.data.Tokens[id]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	return token, nil
+}
+
+// PutToken creates or replaces a token
+func (fs *FileStore) PutToken(ctx context.Context, token Token) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return err
+	}
+
+	fs.data.Tokens[token.ID] = token
+	return fs.flush()
+}
+
+// DeleteToken removes a token
+func (fs *FileStore) DeleteToken(ctx context.Context, id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return err
+	}
+
+	if _, ok := fs.data.Tokens[id]; !ok {
+		return ErrNotFound
+	}
+	delete(fs.data.Tokens, id)
+	return fs.flush()
+}
+
+// ListTokens returns all tokens, oldest first
+func (fs *FileStore) ListTokens(ctx context.Context) ([]Token, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.reload(); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]Token, 0, len(fs.data.Tokens))
+	for _, token := range fs.data.Tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// Close implements Store; the file store holds no open handles
+func (fs *FileStore) Close() error {
+	return nil
+}
+
+// flush writes the store to disk via a temp file and rename.
+// Callers must hold fs.mu.
+func (fs *FileStore) flush() error {
+	raw, err := json.MarshalIndent(fs.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fs.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fs.path), ".store-*")
+	if err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), fs.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(fs.path); err == nil {
+		fs.modTime, fs.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// internal/store/postgres.go
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresMaxConns bounds the connections a PostgresStore opens, plenty
+// for a handful of users
+const postgresMaxConns = 4
+
+// PostgresStore is a Store in a PostgreSQL database, for sharing one
+// database server with other applications. Users and API tokens live in
+// the sysdwitch_users and sysdwitch_tokens tables, created on open.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// OpenPostgres connects to the database at dsn, a postgres:// URL with
+// the parameters libpq accepts
+func OpenPostgres(ctx context.Context, dsn string) (*PostgresStore, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+	cfg.MaxConns = postgresMaxConns
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+
+	ps := &PostgresStore{pool: pool}
+	for _, statement := range []string{
+		`CREATE TABLE IF NOT EXISTS sysdwitch_users (
+			name text PRIMARY KEY,
+			password_hash text NOT NULL,
+			created_at timestamptz NOT NULL
+		)`,
+		// Added after the table; older databases lack it
+		`ALTER TABLE sysdwitch_users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT ''`,
+		`CREATE TABLE IF NOT EXISTS sysdwitch_tokens (
+			id text PRIMARY KEY,
+			name text NOT NULL,
+			username text NOT NULL,
+			secret_hash text NOT NULL,
+			created_at timestamptz NOT NULL,
+			expires_at timestamptz
+		)`,
+	} {
+		if _, err := pool.Exec(ctx, statement); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("postgres: %w", err)
+		}
+	}
+	return ps, nil
+}
+
+// GetUser returns the user with the given name
+func (ps *PostgresStore) GetUser(ctx context.Context, name string) (User, error) {
+	rows, _ := ps.pool.Query(ctx, `SELECT name, password_hash, created_at, role FROM sysdwitch_users WHERE name = $1`, name)
+	user, err := pgx.CollectExactlyOneRow(rows, scanUser)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// PutUser creates or replaces a user
+func (ps *PostgresStore) PutUser(ctx context.Context, user User) error {
+	_, err := ps.pool.Exec(ctx, `INSERT INTO sysdwitch_users (name, password_hash, created_at, role) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET password_hash = EXCLUDED.password_hash, created_at = EXCLUDED.created_at,
+			role = EXCLUDED.role`,
+		user.Name, user.PasswordHash, user.CreatedAt, user.Role)
+	return err
+}
+
+// DeleteUser removes a user
+func (ps *PostgresStore) DeleteUser(ctx context.Context, name string) error {
+	return ps.delete(ctx, `DELETE FROM sysdwitch_users WHERE name = $1`, name)
+}
+
+// ListUsers returns all users sorted by name
+func (ps *PostgresStore) ListUsers(ctx context.Context) ([]User, error) {
+	rows, _ := ps.pool.Query(ctx, `SELECT name, password_hash, created_at, role FROM sysdwitch_users ORDER BY name`)
+	users, err := pgx.CollectRows(rows, scanUser)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetToken returns the token with the given ID
+func (ps *PostgresStore) GetToken(ctx context.Context, id string) (Token, error) {
+	rows, _ := ps.pool.Query(ctx, `SELECT id, name, username, secret_hash, created_at, expires_at FROM sysdwitch_tokens WHERE id = $1`, id)
+	token, err := pgx.CollectExactlyOneRow(rows, scanToken)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Token{}, ErrNotFound
+	}
+	return token, err
+}
+
+// PutToken creates or replaces a token
+func (ps *PostgresStore) PutToken(ctx context.Context, token Token) error {
+	_, err := ps.pool.Exec(ctx, `INSERT INTO sysdwitch_tokens (id, name, username, secret_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, username = EXCLUDED.username,
+			secret_hash = EXCLUDED.secret_hash, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at`,
+		token.ID, token.Name, token.User, token.SecretHash, token.CreatedAt, token.ExpiresAt)
+	return err
+}
+
+// DeleteToken removes a token
+func (ps *PostgresStore) DeleteToken(ctx context.Context, id string) error {
+	return ps.delete(ctx, `DELETE FROM sysdwitch_tokens WHERE id = $1`, id)
+}
+
+// ListTokens returns all tokens, oldest first
+func (ps *PostgresStore) ListTokens(ctx context.Context) ([]Token, error) {
+	rows, _ := ps.pool.Query(ctx, `SELECT id, name, username, secret_hash, created_at, expires_at FROM sysdwitch_tokens ORDER BY created_at`)
+	tokens, err := pgx.CollectRows(rows, scanToken)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Close closes the database connections
+func (ps *PostgresStore) Close() error {
+	ps.pool.Close()
+	return nil
+}
+
+// delete runs a DELETE statement, failing with ErrNotFound when it removed
+// nothing
+func (ps *PostgresStore) delete(ctx context.Context, query string, key string) error {
+	tag, err := ps.pool.Exec(ctx, query, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanUser decodes a name, password_hash, created_at, role row
+func scanUser(row pgx.CollectableRow) (User, error) {
+	var user User
+	err := row.Scan(&user.Name, &user.PasswordHash, &user.CreatedAt, &user.Role)
+	return user, err
+}
+
+// scanToken decodes an id, name, username, secret_hash, created_at,
+// expires_at row
+func scanToken(row pgx.CollectableRow) (Token, error) {
+	var token Token
+	err := row.Scan(&token.ID, &token.Name, &token.User, &token.SecretHash, &token.CreatedAt, &token.ExpiresAt)
+	return token, err
+}
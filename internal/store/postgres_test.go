@@ -0,0 +1,33 @@
+// internal/store/postgres_test.go
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestPostgresStore needs a throwaway database, named by a URL in
+// SYSDWITCH_TEST_POSTGRES; its sysdwitch tables are emptied first
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("SYSDWITCH_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("SYSDWITCH_TEST_POSTGRES is not set")
+	}
+	ctx := context.Background()
+	ps, err := OpenPostgres(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+	if _, err := ps.pool.Exec(ctx, `TRUNCATE sysdwitch_users, sysdwitch_tokens`); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := OpenPostgres(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	testStore(t, ps, other)
+}
@@ -0,0 +1,75 @@
+// internal/store/store.go
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// RoleAdmin is the role of accounts allowed to use the admin endpoints,
+// such as host power actions and API tokens. Accounts without a role may
+// view and control the allowed services.
+const RoleAdmin = "admin"
+
+// User is a panel account with a hashed password
+type User struct {
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Token is an API token acting as User. Only a hash of its secret is
+// kept; the secret itself is shown once when the token is created.
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	User       string     `json:"user"`
+	SecretHash string     `json:"secret_hash"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the token is past its expiry at t
+func (t Token) Expired(at time.Time) bool {
+	return t.ExpiresAt != nil && !at.Before(*t.ExpiresAt)
+}
+
+// Store persists panel state that outlives a single process
+type Store interface {
+	GetUser(ctx context.Context, name string) (User, error)
+	PutUser(ctx context.Context, user User) error
+	DeleteUser(ctx context.Context, name string) error
+	ListUsers(ctx context.Context) ([]User, error)
+	GetToken(ctx context.Context, id string) (Token, error)
+	PutToken(ctx context.Context, token Token) error
+	DeleteToken(ctx context.Context, id string) error
+	ListTokens(ctx context.Context) ([]Token, error)
+	Close() error
+}
+
+// Open opens a store by driver: location is a JSON file path for "file"
+// (the default), a database file path for "bolt" and a postgres:// URL for
+// "postgres"
+func Open(ctx context.Context, driver, location string) (Store, error) {
+	switch driver {
+	case "", "file":
+		return OpenFile(location)
+	case "bolt":
+		return OpenBolt(location)
+	case "postgres":
+		return OpenPostgres(ctx, location)
+	default:
+		return nil, fmt.Errorf("unknown store driver %q", driver)
+	}
+}
@@ -0,0 +1,68 @@
+// internal/store/store_test.go
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testStore runs a store through its operations. other is a second handle
+// on the same data, as an admin command would open while the panel runs;
+// both start out empty.
+func testStore(t *testing.T, bs, other Store) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := bs.GetUser(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing user: got %v, want ErrNotFound", err)
+	}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, user := range []User{
+		{Name: "bob", PasswordHash: "hash-b", CreatedAt: created},
+		{Name: "alice", PasswordHash: "hash-a", Role: RoleAdmin, CreatedAt: created},
+	} {
+		if err := bs.PutUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expires := created.Add(time.Hour)
+	for _, token := range []Token{
+		{ID: "2", Name: "ci", User: "bob", SecretHash: "s2", CreatedAt: created.Add(time.Minute), ExpiresAt: &expires},
+		{ID: "1", Name: "backup", User: "alice", SecretHash: "s1", CreatedAt: created},
+	} {
+		if err := bs.PutToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The other handle sees the changes
+	user, err := other.GetUser(ctx, "alice")
+	if err != nil || !user.IsAdmin() || !user.CreatedAt.Equal(created) {
+		t.Fatalf("stored user: got %+v, %v", user, err)
+	}
+	users, err := other.ListUsers(ctx)
+	if err != nil || len(users) != 2 || users[0].Name != "alice" || users[1].Name != "bob" {
+		t.Fatalf("users: got %+v, %v, want alice then bob", users, err)
+	}
+	tokens, err := other.ListTokens(ctx)
+	if err != nil || len(tokens) != 2 || tokens[0].ID != "1" || tokens[1].ExpiresAt == nil || !tokens[1].ExpiresAt.Equal(expires) {
+		t.Fatalf("tokens: got %+v, %v, want oldest first", tokens, err)
+	}
+
+	if err := bs.DeleteToken(ctx, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteToken(ctx, "2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting a missing token: got %v, want ErrNotFound", err)
+	}
+	if _, err := other.GetToken(ctx, "2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted token: got %v, want ErrNotFound", err)
+	}
+	if err := other.DeleteUser(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if users, _ := bs.ListUsers(ctx); len(users) != 1 {
+		t.Fatalf("users after delete: %+v", users)
+	}
+}
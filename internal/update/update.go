@@ -0,0 +1,163 @@
+// internal/update/update.go
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReleasesURL is the GitHub API endpoint for the latest release
+const DefaultReleasesURL = "https://api.github.com/repos/jollySleeper/SysDwitch/releases/latest"
+
+// Release describes the latest published release
+type Release struct {
+	Version   string    `json:"version"`
+	URL       string    `json:"url"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Available is set when Version is newer than the running version
+	Available bool `json:"available"`
+}
+
+// Checker periodically looks up the latest release
+type Checker struct {
+	current string
+	url     string
+	client  *http.Client
+	logger  *slog.Logger
+
+	mu     sync.RWMutex
+	latest *Release
+}
+
+// NewChecker creates a checker for the running version against the
+// releases endpoint at url
+func NewChecker(current, url string, logger *slog.Logger) *Checker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Checker{
+		current: current,
+		url:     url,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		logger:  logger,
+	}
+}
+
+// Latest returns the result of the last successful check, or nil
+func (c *Checker) Latest() *Release {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.latest
+}
+
+// Run checks immediately and then every interval until ctx is done
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.check(ctx); err != nil {
+			c.logger.Warn("update check failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check fetches the latest release and records it
+func (c *Checker) check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "sysdwitch/"+c.current)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("releases endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return fmt.Errorf("decode release: %w", err)
+	}
+
+	release := &Release{
+		Version:   body.TagName,
+		URL:       body.HTMLURL,
+		CheckedAt: time.Now(),
+		Available: Newer(body.TagName, c.current),
+	}
+	if release.Available {
+		c.logger.Info("update available", "current", c.current, "latest", release.Version)
+	}
+
+	c.mu.Lock()
+	c.latest = release
+	c.mu.Unlock()
+	return nil
+}
+
+// Newer reports whether version a is newer than b. Both are compared as
+// dotted numeric versions with an optional "v" prefix and pre-release
+// suffix; b values that are not versions (such as "dev") never compare older.
+func Newer(a, b string) bool {
+	av, ok := parseVersion(a)
+	if !ok {
+		return false
+	}
+	bv, ok := parseVersion(b)
+	if !ok {
+		return false
+	}
+	for i := range av {
+		if av[i] != bv[i] {
+			return av[i] > bv[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses "v1.2.3" (or "1.2", "1.2.3-rc1") into its numbers
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -0,0 +1,55 @@
+// internal/wol/wol.go
+package wol
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// DefaultBroadcast is where magic packets go when a host sets no address
+const DefaultBroadcast = "255.255.255.255:9"
+
+// MagicPacket builds the Wake-on-LAN payload for mac: six 0xFF bytes
+// followed by the MAC address repeated sixteen times
+func MagicPacket(mac string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %q: expected 6 bytes", mac)
+	}
+	packet := bytes.Repeat([]byte{0xFF}, 6)
+	for range 16 {
+		packet = append(packet, hw...)
+	}
+	return packet, nil
+}
+
+// BroadcastAddr returns addr with the default port 9 added when it has
+// none, or DefaultBroadcast when it is empty
+func BroadcastAddr(addr string) string {
+	if addr == "" {
+		return DefaultBroadcast
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(addr, "9")
+	}
+	return addr
+}
+
+// Send broadcasts a magic packet for mac to the UDP address broadcast
+func Send(mac, broadcast string) error {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("udp", BroadcastAddr(broadcast))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(packet)
+	return err
+}
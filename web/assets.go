@@ -0,0 +1,94 @@
+// web/assets.go
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Assets serves static files under content-hashed names such as
+// css/style.3f2a9c1e.css. A hashed name changes whenever the file does, so
+// it can be cached forever; the plain names keep working but must be
+// revalidated, which keeps bookmarks and hand-written links fresh.
+type Assets struct {
+	fsys   fs.FS
+	prefix string
+	hashed map[string]string // plain name -> hashed name
+	plain  map[string]string // hashed name -> plain name
+	etags  map[string]string // plain name -> ETag
+	dev    bool
+}
+
+// NewAssets hashes every file of fsys, which is served at the URL prefix
+// such as /static/
+func NewAssets(fsys fs.FS, prefix string) (*Assets, error) {
+	a := &Assets{
+		fsys:   fsys,
+		prefix: prefix,
+		hashed: make(map[string]string),
+		plain:  make(map[string]string),
+		etags:  make(map[string]string),
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		digest := hex.EncodeToString(sum[:])
+		ext := path.Ext(name)
+		hashedName := strings.TrimSuffix(name, ext) + "." + digest[:8] + ext
+		a.hashed[name] = hashedName
+		a.plain[hashedName] = name
+		a.etags[name] = `"` + digest[:16] + `"`
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// NewDevAssets serves the files of fsys as they are on disk, under their
+// plain names and never cached, so edits show up on reload
+func NewDevAssets(fsys fs.FS, prefix string) *Assets {
+	return &Assets{fsys: fsys, prefix: prefix, dev: true}
+}
+
+// Path returns the URL of an asset by its hashed name, or by its plain
+// name when there is no such file
+func (a *Assets) Path(name string) string {
+	if hashedName, ok := a.hashed[name]; ok {
+		return a.prefix + hashedName
+	}
+	return a.prefix + name
+}
+
+// ServeHTTP serves the asset named by the request path, which must be
+// stripped of the prefix
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if a.dev {
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeFileFS(w, r, a.fsys, name)
+		return
+	}
+	if plainName, ok := a.plain[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		name = plainName
+	} else if _, ok := a.hashed[name]; ok {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", a.etags[name])
+	http.ServeFileFS(w, r, a.fsys, name)
+}
@@ -1,37 +1,146 @@
 // Service Control Panel JavaScript
 // Dynamic service management functionality
 
+// URL prefix the panel is served under (BASE_PATH), "" at the root
+const basePath = document.body.dataset.basePath || '';
+
+// Auto-refresh state: interval in seconds (0 = off) and pause toggle,
+// persisted per browser
+const refreshState = {
+    interval: 30,
+    paused: false,
+    timer: null,
+};
+
 // Refresh services from API (for updates after actions)
 async function refreshServices() {
     try {
-        const response = await fetch('/api/services/status');
+        const response = await fetch(basePath + '/api/services/status');
         const data = await response.json();
         if (data.services) {
             updateServiceCards(data.services);
         }
+        await refreshHostStats();
+        const lastRefresh = document.getElementById('last-refresh');
+        if (lastRefresh) {
+            lastRefresh.textContent = 'Updated ' + new Date().toLocaleTimeString();
+        }
     } catch (error) {
         console.error('Failed to refresh services:', error);
     }
 }
 
+// Re-render the host resource widget in the header
+async function refreshHostStats() {
+    const widget = document.getElementById('host-stats');
+    if (!widget) {
+        return;
+    }
+    const response = await fetch(basePath + '/ui/host/stats');
+    if (response.ok) {
+        widget.innerHTML = await response.text();
+    }
+}
+
+// (Re)start the auto-refresh timer from the current state
+function scheduleRefresh() {
+    if (refreshState.timer) {
+        clearInterval(refreshState.timer);
+        refreshState.timer = null;
+    }
+    if (refreshState.interval > 0 && !refreshState.paused) {
+        refreshState.timer = setInterval(() => {
+            // Skip polling while the tab is hidden
+            if (!document.hidden) {
+                refreshServices();
+            }
+        }, refreshState.interval * 1000);
+    }
+
+    const pauseBtn = document.getElementById('refresh-pause');
+    if (pauseBtn) {
+        pauseBtn.textContent = refreshState.paused ? 'Resume' : 'Pause';
+        pauseBtn.disabled = refreshState.interval === 0;
+    }
+}
+
+// Wire up the interval selector and pause toggle
+function initRefreshControls() {
+    const serverDefault = parseInt(document.body.dataset.refreshInterval, 10);
+    const saved = localStorage.getItem('refreshInterval');
+    refreshState.interval = saved !== null ? parseInt(saved, 10) : (isNaN(serverDefault) ? 30 : serverDefault);
+    refreshState.paused = localStorage.getItem('refreshPaused') === 'true';
+
+    const select = document.getElementById('refresh-interval');
+    if (select) {
+        if (!select.querySelector(`option[value="${refreshState.interval}"]`)) {
+            const option = document.createElement('option');
+            option.value = refreshState.interval;
+            option.textContent = refreshState.interval + 's';
+            select.appendChild(option);
+        }
+        select.value = String(refreshState.interval);
+        select.addEventListener('change', () => {
+            refreshState.interval = parseInt(select.value, 10);
+            localStorage.setItem('refreshInterval', select.value);
+            scheduleRefresh();
+        });
+    }
+
+    const pauseBtn = document.getElementById('refresh-pause');
+    if (pauseBtn) {
+        pauseBtn.addEventListener('click', () => {
+            refreshState.paused = !refreshState.paused;
+            localStorage.setItem('refreshPaused', String(refreshState.paused));
+            scheduleRefresh();
+        });
+    }
+
+    const refreshNow = document.getElementById('refresh-now');
+    if (refreshNow) {
+        refreshNow.addEventListener('click', refreshServices);
+    }
+
+    // Catch up immediately when the tab becomes visible again
+    document.addEventListener('visibilitychange', () => {
+        if (!document.hidden && refreshState.interval > 0 && !refreshState.paused) {
+            refreshServices();
+        }
+    });
+
+    scheduleRefresh();
+}
+
 // Update service card states after actions
 function updateServiceCards(services) {
     services.forEach(service => {
         const serviceName = service.name.replace('.service', '');
         const card = document.querySelector(`[data-service="${serviceName}"]`);
         if (card) {
+            card.dataset.status = service.status;
+            const missing = service.status === 'not-installed';
+
             // Update status badge
             const statusBadge = card.querySelector('.status-badge');
-            statusBadge.textContent = service.status;
+            statusBadge.textContent = missing ? 'not installed' : service.status;
             statusBadge.className = `px-2 py-1 rounded-full text-sm status-badge ${
-                service.active ? 'bg-green-100 text-green-800' : 'bg-red-100 text-red-800'
+                service.status === 'degraded' ? 'bg-yellow-100 text-yellow-800'
+                    : service.active ? 'bg-green-100 text-green-800'
+                    : missing ? 'bg-gray-100 text-gray-600' : 'bg-red-100 text-red-800'
             }`;
 
             // Update buttons
             const startBtn = card.querySelector('.start-btn');
             const stopBtn = card.querySelector('.stop-btn');
 
-            if (service.active) {
+            if (missing || document.getElementById('read-only-banner')) {
+                // Control actions are disabled server-side in read-only mode
+                // and there is nothing to control without a unit
+                [startBtn, stopBtn].forEach(btn => {
+                    btn.disabled = true;
+                    btn.classList.add('opacity-50', 'cursor-not-allowed');
+                });
+            } else if (service.active) {
                 startBtn.disabled = true;
                 startBtn.classList.add('opacity-50', 'cursor-not-allowed');
                 stopBtn.disabled = false;
@@ -44,23 +153,629 @@ function updateServiceCards(services) {
             }
         }
     });
+    applyFilters();
+}
+
+// Current dashboard filter: free-text query and state quick filter
+const filterState = {
+    query: '',
+    state: 'all',
+};
+
+// matchesState reports whether a unit status belongs to a quick filter
+function matchesState(status, state) {
+    switch (state) {
+        case 'running':
+            return status === 'running';
+        case 'failed':
+            return ['failed', 'degraded', 'unknown', 'not-installed'].includes(status);
+        case 'stopped':
+            return status === 'stopped' || status === 'stopping';
+        default:
+            return true;
+    }
+}
+
+// Recount the sticky status summary from the rendered cards
+function updateSummary() {
+    const summary = document.getElementById('status-summary');
+    if (!summary) {
+        return;
+    }
+    const cards = [...document.querySelectorAll('.service-card')];
+    ['running', 'stopped', 'failed'].forEach(state => {
+        summary.querySelector(`[data-count="${state}"]`).textContent =
+            cards.filter(card => matchesState(card.dataset.status, state)).length;
+    });
+
+    // Flag failures in the tab icon so a pinned tab shows them at a glance
+    const favicon = document.getElementById('favicon');
+    if (favicon) {
+        const failed = cards.some(card => matchesState(card.dataset.status, 'failed'));
+        favicon.href = failed ? favicon.dataset.alert : favicon.dataset.ok;
+    }
+}
+
+// Show only cards matching the search text and state filter, hiding
+// groups left without visible cards
+function applyFilters() {
+    const query = filterState.query.trim().toLowerCase();
+    let visible = 0;
+
+    document.querySelectorAll('.service-card').forEach(card => {
+        const text = (card.dataset.service + ' ' + card.textContent).toLowerCase();
+        const show = (!query || text.includes(query)) && matchesState(card.dataset.status, filterState.state);
+        card.classList.toggle('hidden', !show);
+        if (show) {
+            visible++;
+        }
+    });
+
+    document.querySelectorAll('.service-group').forEach(group => {
+        group.classList.toggle('hidden', !group.querySelector('.service-card:not(.hidden)'));
+    });
+
+    const empty = document.getElementById('filter-empty');
+    if (empty) {
+        empty.classList.toggle('hidden', visible > 0 || document.querySelectorAll('.service-card').length === 0);
+    }
+
+    updateSummary();
+}
+
+// Wire up the search box and quick filter buttons
+function initFilters() {
+    const search = document.getElementById('service-search');
+    if (search) {
+        search.addEventListener('input', () => {
+            filterState.query = search.value;
+            applyFilters();
+        });
+    }
+
+    document.querySelectorAll('.state-filter').forEach(btn => {
+        btn.addEventListener('click', () => {
+            filterState.state = btn.dataset.filter;
+            document.querySelectorAll('.state-filter').forEach(other => {
+                const active = other === btn;
+                other.setAttribute('aria-pressed', String(active));
+                other.style.backgroundColor = active ? 'var(--accent)' : '';
+                other.style.color = active ? '#fff' : '';
+            });
+            applyFilters();
+        });
+    });
+}
+
+// Cycle the theme (system -> light -> dark) and persist it in a cookie so
+// the server renders the right theme on the next load
+function initThemeToggle() {
+    const toggle = document.getElementById('theme-toggle');
+    if (!toggle) {
+        return;
+    }
+    const modes = ['system', 'light', 'dark'];
+    toggle.addEventListener('click', () => {
+        const current = document.documentElement.dataset.theme || 'system';
+        const next = modes[(modes.indexOf(current) + 1) % modes.length];
+        document.documentElement.dataset.theme = next;
+        document.cookie = `theme=${next}; path=${basePath || '/'}; max-age=31536000; SameSite=Lax`;
+        const name = document.getElementById('theme-name');
+        if (name) {
+            name.textContent = next;
+        }
+    });
+}
+
+// Command palette: jump to a service or run an action by keyboard
+const palette = {
+    commands: [],
+    matches: [],
+    selected: 0,
+};
+
+// Build the command list from the cards currently on the page
+function paletteCommands() {
+    const commands = [{ label: 'Refresh now', run: refreshServices }];
+    document.querySelectorAll('.service-card').forEach(card => {
+        const name = card.dataset.service;
+        const label = card.querySelector('h3').textContent.trim();
+        commands.push({ label: `Go to ${label}`, run: () => { window.location.href = basePath + `/services/${encodeURIComponent(name)}`; } });
+        card.querySelectorAll('button[data-action]').forEach(btn => {
+            if (!btn.disabled) {
+                const action = btn.dataset.action;
+                commands.push({ label: `${btn.textContent.trim()} ${label}`, run: () => requestAction(name, action) });
+            }
+        });
+    });
+    return commands;
+}
+
+// Render commands matching the palette input
+function renderPalette() {
+    const query = document.getElementById('palette-input').value.trim().toLowerCase();
+    const words = query.split(/\s+/).filter(Boolean);
+    palette.matches = palette.commands.filter(cmd => words.every(w => cmd.label.toLowerCase().includes(w)));
+    palette.selected = Math.min(palette.selected, Math.max(palette.matches.length - 1, 0));
+
+    const list = document.getElementById('palette-results');
+    list.replaceChildren(...palette.matches.map((cmd, i) => {
+        const item = document.createElement('li');
+        item.setAttribute('role', 'option');
+        item.setAttribute('aria-selected', String(i === palette.selected));
+        item.textContent = cmd.label;
+        item.addEventListener('click', () => runPaletteCommand(i));
+        return item;
+    }));
+    const current = list.children[palette.selected];
+    if (current) {
+        current.scrollIntoView({ block: 'nearest' });
+    }
+}
+
+function openPalette() {
+    const dialog = document.getElementById('command-palette');
+    if (!dialog || dialog.open) {
+        return;
+    }
+    palette.commands = paletteCommands();
+    palette.selected = 0;
+    const input = document.getElementById('palette-input');
+    input.value = '';
+    renderPalette();
+    dialog.showModal();
+    input.focus();
+}
+
+function runPaletteCommand(index) {
+    const cmd = palette.matches[index];
+    document.getElementById('command-palette').close();
+    if (cmd) {
+        cmd.run();
+    }
+}
+
+// Wire up the palette and global keyboard shortcuts:
+// Ctrl/Cmd+K palette, "/" search, "r" refresh
+function initKeyboard() {
+    const dialog = document.getElementById('command-palette');
+    if (dialog) {
+        const input = document.getElementById('palette-input');
+        input.addEventListener('input', () => {
+            palette.selected = 0;
+            renderPalette();
+        });
+        input.addEventListener('keydown', event => {
+            if (event.key === 'ArrowDown' || event.key === 'ArrowUp') {
+                event.preventDefault();
+                const step = event.key === 'ArrowDown' ? 1 : -1;
+                const count = palette.matches.length;
+                palette.selected = count ? (palette.selected + step + count) % count : 0;
+                renderPalette();
+            } else if (event.key === 'Enter') {
+                event.preventDefault();
+                runPaletteCommand(palette.selected);
+            }
+        });
+        // Close when clicking the backdrop
+        dialog.addEventListener('click', event => {
+            if (event.target === dialog) {
+                dialog.close();
+            }
+        });
+        const openBtn = document.getElementById('palette-open');
+        if (openBtn) {
+            openBtn.addEventListener('click', openPalette);
+        }
+    }
+
+    document.addEventListener('keydown', event => {
+        if ((event.ctrlKey || event.metaKey) && event.key.toLowerCase() === 'k') {
+            event.preventDefault();
+            openPalette();
+            return;
+        }
+
+        // Single-key shortcuts only apply outside text fields and dialogs
+        const target = event.target;
+        if (event.ctrlKey || event.metaKey || event.altKey || document.querySelector('dialog[open]') ||
+            target.isContentEditable || ['INPUT', 'SELECT', 'TEXTAREA'].includes(target.tagName)) {
+            return;
+        }
+        if (event.key === '/') {
+            const search = document.getElementById('service-search');
+            if (search) {
+                event.preventDefault();
+                search.focus();
+            }
+        } else if (event.key === 'r') {
+            event.preventDefault();
+            refreshServices();
+        }
+    });
+}
+
+// Actions that ask for confirmation on protected services
+const destructiveActions = ['stop', 'restart', 'mask'];
+
+// How long the undo toast stays visible after stopping a service
+const undoTimeoutMs = 8000;
+
+// Ask the user to confirm an action; resolves to true when confirmed
+function confirmAction(message, confirmLabel) {
+    const dialog = document.getElementById('confirm-dialog');
+    if (!dialog || typeof dialog.showModal !== 'function') {
+        return Promise.resolve(window.confirm(message));
+    }
+    document.getElementById('confirm-message').textContent = message;
+    document.getElementById('confirm-ok').textContent = confirmLabel;
+    return new Promise(resolve => {
+        dialog.addEventListener('close', () => resolve(dialog.returnValue === 'confirm'), { once: true });
+        dialog.showModal();
+    });
+}
+
+// Show a short-lived toast offering to undo a stop
+let toastTimer = null;
+function showUndoToast(serviceName, label) {
+    const toast = document.getElementById('toast');
+    if (!toast) {
+        return;
+    }
+    document.getElementById('toast-message').textContent = `Stopped ${label}`;
+    const undo = document.getElementById('toast-undo');
+    undo.onclick = () => {
+        hideToast();
+        controlService(serviceName, 'start');
+    };
+    toast.classList.remove('hidden');
+    clearTimeout(toastTimer);
+    toastTimer = setTimeout(hideToast, undoTimeoutMs);
+}
+
+function hideToast() {
+    const toast = document.getElementById('toast');
+    if (toast) {
+        toast.classList.add('hidden');
+    }
+    clearTimeout(toastTimer);
+}
+
+// Confirm destructive actions on protected services, then run the action
+async function requestAction(serviceName, action) {
+    const card = document.querySelector(`.service-card[data-service="${serviceName}"]`);
+    const label = card ? card.querySelector('h3').textContent.trim() : serviceName;
+
+    if (card && card.dataset.protected === 'true' && destructiveActions.includes(action)) {
+        const verb = action.charAt(0).toUpperCase() + action.slice(1);
+        if (!await confirmAction(`${verb} ${label}? This service is marked as protected.`, verb)) {
+            return;
+        }
+    }
+
+    // Starting a member of an exclusive group stops the others
+    const group = card && card.dataset.exclusive;
+    const conflicts = group && action === 'start'
+        ? [...document.querySelectorAll('.service-card[data-exclusive]')]
+            .filter(c => c !== card && c.dataset.exclusive === group && !['stopped', 'failed', 'not-installed'].includes(c.dataset.status))
+        : [];
+    if (conflicts.length > 0) {
+        const names = conflicts.map(c => c.querySelector('h3').textContent.trim()).join(', ');
+        if (!await confirmAction(`Starting ${label} will stop ${names}. Continue?`, 'Start')) {
+            return;
+        }
+    }
+
+    const ok = await controlService(serviceName, action);
+    if (ok && action === 'stop') {
+        showUndoToast(serviceName, label);
+    }
+    if (conflicts.length > 0) {
+        refreshServices();
+    }
 }
 
-// Control service (start/stop)
+// Control service (start/stop): the server runs the action and returns the
+// re-rendered card, which replaces the old one in place
 async function controlService(serviceName, action) {
-    try {
-        const response = await fetch(`/api/services/${serviceName}/${action}`, {
-            method: 'POST'
+    const card = document.querySelector(`.service-card[data-service="${serviceName}"]`);
+    if (card) {
+        card.querySelectorAll('button[data-action]').forEach(btn => { btn.disabled = true; });
+        card.setAttribute('aria-busy', 'true');
+    }
+
+    // Environment overrides chosen on the card apply to start only
+    const env = {};
+    if (card && action === 'start') {
+        card.querySelectorAll('[data-env]').forEach(input => {
+            if (input.value) {
+                env[input.dataset.env] = input.value;
+            }
         });
-        const result = await response.json();
-        if (result.success) {
-            refreshServices(); // Refresh the display after action
-        } else {
-            alert('Operation failed: ' + (result.error || 'Unknown error'));
+    }
+    const options = { method: 'POST' };
+    if (Object.keys(env).length > 0) {
+        options.headers = { 'Content-Type': 'application/json' };
+        options.body = JSON.stringify({ env });
+    }
+
+    try {
+        const response = await fetch(basePath + `/ui/services/${encodeURIComponent(serviceName)}/${action}`, options);
+        if (!response.ok) {
+            throw new Error(await response.text());
         }
+        const html = await response.text();
+        if (card) {
+            card.outerHTML = html;
+        }
+        applyFilters();
+
+        const error = response.headers.get('X-Error');
+        if (error) {
+            alert(error);
+        }
+
+        // A job still in flight (ours, or the one that made the unit busy)
+        const jobId = response.headers.get('X-Job-ID');
+        if (jobId) {
+            const ok = await waitForJob(serviceName, jobId);
+            return ok && !error;
+        }
+        return !error;
     } catch (error) {
         console.error('Control service error:', error);
-        alert('Operation failed');
+        alert('Operation failed' + (error.message ? ': ' + error.message : ''));
+        refreshServices();
+        return false;
+    }
+}
+
+// How often a running job is polled
+const jobPollMs = 1000;
+
+// Poll a job until it finishes, then swap in the service's fresh card.
+// Resolves to true when the job succeeded.
+async function waitForJob(serviceName, jobId) {
+    let job;
+    for (;;) {
+        await new Promise(resolve => setTimeout(resolve, jobPollMs));
+        const response = await fetch(basePath + `/api/jobs/${encodeURIComponent(jobId)}`);
+        const data = await response.json();
+        job = data.job;
+        if (!job || (job.status !== 'queued' && job.status !== 'running')) {
+            break;
+        }
+        // Reflect queued/running in the busy card's badge
+        const badge = document.querySelector(`.service-card[data-service="${serviceName}"] .status-badge`);
+        if (badge) {
+            badge.innerHTML = '<span class="spinner" aria-hidden="true"></span> ';
+            badge.append(`${job.status}…`);
+        }
+    }
+
+    await refreshCard(serviceName);
+
+    if (!job || job.status === 'failed') {
+        alert((job && job.error) || 'Operation failed');
+        return false;
+    }
+    return true;
+}
+
+// Swap in a service's freshly rendered card
+async function refreshCard(serviceName) {
+    const response = await fetch(basePath + `/ui/services/${encodeURIComponent(serviceName)}/card`);
+    const card = document.querySelector(`.service-card[data-service="${serviceName}"]`);
+    if (card && response.ok) {
+        card.outerHTML = await response.text();
+        applyFilters();
+    }
+}
+
+// Events listed in the recent-activity feed, as kept by the server
+const activityTypes = ['service.state_changed', 'service.failed', 'service.watchdog_restart', 'service.action', 'profile.action'];
+const activityShown = 10;
+
+// Prepend an event to the recent-activity feed, rendered like the server
+// renders the feed's first entries
+function addActivity(event) {
+    const feed = document.getElementById('activity-feed');
+    if (!feed || !activityTypes.includes(event.type)) {
+        return;
+    }
+    feed.querySelectorAll('.activity-empty').forEach(item => item.remove());
+
+    const item = document.createElement('li');
+    item.className = 'px-4 py-2';
+    const time = document.createElement('time');
+    time.className = 'text-gray-500';
+    time.dateTime = event.time;
+    time.textContent = new Date(event.time).toLocaleTimeString([], { hour12: false });
+    item.append(time, ' ' + event.message);
+    if (event.user) {
+        const user = document.createElement('span');
+        user.className = 'text-gray-500';
+        user.textContent = 'by ' + event.user;
+        item.append(' ', user);
+    }
+    feed.prepend(item);
+    while (feed.children.length > activityShown) {
+        feed.lastElementChild.remove();
+    }
+}
+
+// Reload the recent-activity feed, after events may have been missed
+async function reloadActivity() {
+    const feed = document.getElementById('activity-feed');
+    if (!feed) {
+        return;
+    }
+    const response = await fetch(basePath + `/api/events/recent?limit=${activityShown}`);
+    if (!response.ok) {
+        return;
+    }
+    const data = await response.json();
+    feed.replaceChildren();
+    data.events.reverse().forEach(addActivity);
+}
+
+// Follow the server's event stream and update a service's card as soon as
+// something happens to it. The browser reconnects on its own; events missed
+// meanwhile are caught up with a full refresh. Polling stays as a fallback.
+function initEventStream() {
+    if (!window.EventSource) {
+        return;
+    }
+    let dropped = false;
+    const stream = new EventSource(basePath + '/api/events');
+    stream.addEventListener('open', () => {
+        if (dropped) {
+            dropped = false;
+            refreshServices();
+            reloadActivity();
+        }
+    });
+    stream.addEventListener('error', () => {
+        dropped = true;
+    });
+    stream.addEventListener('message', event => {
+        const data = JSON.parse(event.data);
+        addActivity(data);
+        if (!data.service || !data.type.startsWith('service.')) {
+            return;
+        }
+        // Cards with an action in flight are updated when it finishes
+        const name = data.service.replace(/\.service$/, '');
+        const card = document.querySelector(`.service-card[data-service="${name}"]`);
+        if (card && card.getAttribute('aria-busy') !== 'true') {
+            refreshCard(name);
+        }
+    });
+}
+
+// Run a profile: start its units in order or stop them in reverse. The
+// server answers 202 with a job when the sequence outlasts the request.
+async function controlProfile(name, action) {
+    const card = document.querySelector(`.profile-card[data-profile="${name}"]`);
+    const label = card ? card.querySelector('h3').textContent.trim() : name;
+    if (action === 'stop' && !await confirmAction(`Stop every service in ${label}?`, 'Stop all')) {
+        return;
+    }
+
+    const buttons = card ? card.querySelectorAll('button[data-profile-action]') : [];
+    buttons.forEach(btn => { btn.disabled = true; });
+    if (card) {
+        card.setAttribute('aria-busy', 'true');
+        const badge = card.querySelector('.status-badge');
+        badge.innerHTML = '<span class="spinner" aria-hidden="true"></span> ';
+        badge.append(`${action === 'start' ? 'starting' : 'stopping'}…`);
+    }
+
+    try {
+        const response = await fetch(basePath + `/api/profiles/${encodeURIComponent(name)}/${action}`, { method: 'POST' });
+        const data = await response.json();
+        let error = data.success ? '' : (data.error || 'Operation failed');
+        if (response.status === 202 && data.job) {
+            let job = data.job;
+            while (job && (job.status === 'queued' || job.status === 'running')) {
+                await new Promise(resolve => setTimeout(resolve, jobPollMs));
+                job = (await (await fetch(basePath + `/api/jobs/${encodeURIComponent(job.id)}`)).json()).job;
+            }
+            if (!job || job.status === 'failed') {
+                error = (job && job.error) || 'Operation failed';
+            }
+        }
+        if (error) {
+            alert(error);
+        }
+    } catch (error) {
+        console.error('Control profile error:', error);
+        alert('Operation failed' + (error.message ? ': ' + error.message : ''));
+    }
+
+    // Cards and profile counts both change, so reload the page state
+    window.location.reload();
+}
+
+// Load the journal of a task's transient unit into its card
+async function loadTaskLog(card) {
+    const response = await fetch(basePath + `/api/admin/tasks/${encodeURIComponent(card.dataset.task)}/logs`);
+    const data = await response.json();
+    card.querySelector('.task-log pre').textContent = (data.lines || []).join('\n') || 'No output yet';
+}
+
+// Run a predefined task as a transient unit and show its output
+async function runTask(card) {
+    const label = card.querySelector('h3').textContent.trim();
+    if (!await confirmAction(`Run ${label} now?`, 'Run')) {
+        return;
+    }
+
+    const button = card.querySelector('button[data-task-action]');
+    const badge = card.querySelector('.status-badge');
+    button.disabled = true;
+    badge.innerHTML = '<span class="spinner" aria-hidden="true"></span> running…';
+
+    let error = '';
+    try {
+        const response = await fetch(basePath + `/api/admin/tasks/${encodeURIComponent(card.dataset.task)}/run`, { method: 'POST' });
+        const data = await response.json();
+        error = data.success ? '' : (data.error || 'Task failed');
+        let job = response.status === 202 ? data.job : null;
+        while (job && (job.status === 'queued' || job.status === 'running')) {
+            await new Promise(resolve => setTimeout(resolve, jobPollMs));
+            job = (await (await fetch(basePath + `/api/jobs/${encodeURIComponent(job.id)}`)).json()).job;
+            if (job && job.status === 'failed') {
+                error = job.error || 'Task failed';
+            }
+        }
+    } catch (err) {
+        error = err.message || 'Task failed';
+    }
+
+    badge.textContent = error ? 'failed' : 'done';
+    badge.className = `px-2 py-1 rounded-full text-sm status-badge ${error ? 'bg-red-100 text-red-800' : 'bg-green-100 text-green-800'}`;
+    button.disabled = false;
+    card.querySelector('.task-log').open = true;
+    await loadTaskLog(card);
+}
+
+// Suspend, reboot or power off the host, optionally stopping every
+// service first. The server runs the action in the background.
+async function powerHost(action) {
+    const stop = document.getElementById('power-stop-services').checked;
+    const question = stop ? `Stop all services and ${action} the host?` : `${action[0].toUpperCase() + action.slice(1)} the host?`;
+    if (!await confirmAction(question, action[0].toUpperCase() + action.slice(1))) {
+        return;
+    }
+
+    const status = document.getElementById('power-status');
+    try {
+        const response = await fetch(basePath + `/api/admin/host/${encodeURIComponent(action)}?stop_services=${stop}`, { method: 'POST' });
+        const data = await response.json();
+        if (!data.success) {
+            throw new Error(data.error || 'Operation failed');
+        }
+        status.textContent = stop ? `Stopping services, then ${action}…` : `${action} requested…`;
+    } catch (error) {
+        console.error('Power action error:', error);
+        alert('Operation failed' + (error.message ? ': ' + error.message : ''));
+    }
+}
+
+// Send a Wake-on-LAN packet to a remote host
+async function wakeHost(card) {
+    const badge = card.querySelector('.status-badge');
+    try {
+        const response = await fetch(basePath + `/api/hosts/${encodeURIComponent(card.dataset.host)}/wake`, { method: 'POST' });
+        const data = await response.json();
+        if (!data.success) {
+            throw new Error(data.error || 'Operation failed');
+        }
+        badge.textContent = 'packet sent';
+    } catch (error) {
+        console.error('Wake host error:', error);
+        alert('Operation failed' + (error.message ? ': ' + error.message : ''));
     }
 }
 
@@ -68,25 +783,85 @@ async function controlService(serviceName, action) {
 document.addEventListener('DOMContentLoaded', function() {
     console.log('Service Control Panel loaded');
 
-    // Add data-service attributes to cards for easier targeting
-    document.querySelectorAll('.service-card').forEach((card, index) => {
-        const serviceName = card.querySelector('h3').textContent;
-        card.setAttribute('data-service', serviceName);
+    // Card markup (data-service, button and badge classes) is rendered
+    // server-side by the service-card template
 
-        // Add classes to buttons for easier targeting
-        const buttons = card.querySelectorAll('button');
-        if (buttons.length >= 2) {
-            buttons[0].classList.add('start-btn');
-            buttons[1].classList.add('stop-btn');
+    // Remember which groups were collapsed
+    const collapsed = JSON.parse(localStorage.getItem('collapsedGroups') || 'null');
+    document.querySelectorAll('.service-group[data-group]').forEach(group => {
+        const name = group.dataset.group;
+        if (!name) {
+            return;
+        }
+        if (collapsed !== null) {
+            group.open = !collapsed.includes(name);
         }
+        group.addEventListener('toggle', () => {
+            const names = [...document.querySelectorAll('.service-group[data-group]')]
+                .filter(g => g.dataset.group && !g.open)
+                .map(g => g.dataset.group);
+            localStorage.setItem('collapsedGroups', JSON.stringify(names));
+        });
+    });
+
+    initFilters();
+    initThemeToggle();
+    initKeyboard();
 
-        // Add class to status badge
-        const statusBadge = card.querySelector('span');
-        if (statusBadge) {
-            statusBadge.classList.add('status-badge');
+    // Action buttons are wired here rather than inline so the CSP can
+    // forbid inline scripts
+    document.getElementById('services-grid').addEventListener('click', event => {
+        const btn = event.target.closest('button[data-action]');
+        const card = btn && btn.closest('.service-card');
+        if (card && !btn.disabled) {
+            requestAction(card.dataset.service, btn.dataset.action);
         }
     });
 
-    // Refresh every 30 seconds to show status changes from external sources
-    setInterval(refreshServices, 30000);
+    const profiles = document.getElementById('profiles');
+    if (profiles) {
+        profiles.addEventListener('click', event => {
+            const btn = event.target.closest('button[data-profile-action]');
+            const card = btn && btn.closest('.profile-card');
+            if (card && !btn.disabled) {
+                controlProfile(card.dataset.profile, btn.dataset.profileAction);
+            }
+        });
+    }
+
+    const tasks = document.getElementById('tasks');
+    if (tasks) {
+        tasks.addEventListener('click', event => {
+            const btn = event.target.closest('button[data-task-action]');
+            const card = btn && btn.closest('.task-card');
+            if (card && !btn.disabled) {
+                runTask(card);
+            }
+        });
+        tasks.querySelectorAll('.task-log').forEach(log => {
+            log.addEventListener('toggle', () => {
+                if (log.open) {
+                    loadTaskLog(log.closest('.task-card'));
+                }
+            });
+        });
+    }
+
+    const host = document.getElementById('host');
+    if (host) {
+        host.addEventListener('click', event => {
+            const btn = event.target.closest('button[data-power-action]');
+            if (btn && !btn.disabled) {
+                powerHost(btn.dataset.powerAction);
+            }
+            const wake = event.target.closest('button[data-wake]');
+            if (wake && !wake.disabled) {
+                wakeHost(wake.closest('.wake-card'));
+            }
+        });
+    }
+
+    // Periodically refresh to show status changes from external sources
+    initRefreshControls();
+    initEventStream();
 });
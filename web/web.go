@@ -1,11 +1,21 @@
+// Package web embeds the dashboard templates and static assets.
+//
+// Assets are plain files, served under content-hashed names (see Assets):
+// edit them under web/static or web/templates and rebuild the binary, or
+// run with --dev to serve them from disk as they are edited.
+// There is no bundler or npm step.
 package web
 
 import (
 	"embed"
 )
 
+// TemplatesFS holds the HTML templates rendered by the handlers
+//
 //go:embed templates
 var TemplatesFS embed.FS
 
+// StaticFS holds the CSS, JS and icons served under /static/
+//
 //go:embed static
 var StaticFS embed.FS